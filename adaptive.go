@@ -0,0 +1,170 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"container/heap"
+	"strings"
+	"sync"
+)
+
+// CandidateScheduler orders the brute-force candidates remaining by the hit rates observed for
+// their patterns, so the names likely to exist are resolved first during time-boxed scans. The
+// candidates sharing a pattern are returned in the order added, and patterns without observations
+// are returned in the order first seen.
+type CandidateScheduler struct {
+	sync.Mutex
+	patterns map[string]*candidateBucket
+	queue    bucketQueue
+	count    int
+	next     int
+}
+
+type candidateBucket struct {
+	pattern string
+	names   []string
+	tries   int
+	hits    int
+	order   int
+	index   int
+}
+
+// Returns the hit rate of the pattern, using the rule of succession so unobserved
+// patterns rank above those with misses and below those with hits.
+func (b *candidateBucket) score() float64 {
+	return float64(b.hits+1) / float64(b.tries+2)
+}
+
+// NewCandidateScheduler returns a CandidateScheduler containing the provided names.
+func NewCandidateScheduler(names ...string) *CandidateScheduler {
+	s := &CandidateScheduler{patterns: make(map[string]*candidateBucket)}
+
+	s.Add(names...)
+	return s
+}
+
+// CandidatePattern returns the pattern of the candidate name used to share hit rates, which is
+// the leading token of the first label. For example, "dev.example.com", "dev01.example.com"
+// and "dev-api.example.com" share the "dev" pattern.
+func CandidatePattern(name string) string {
+	label, _, _ := strings.Cut(strings.ToLower(RemoveLastDot(name)), ".")
+
+	end := strings.IndexFunc(label, func(c rune) bool {
+		return c == '-' || c == '_' || (c >= '0' && c <= '9')
+	})
+	if end == 0 {
+		// Labels starting with a digit or separator are grouped by the first character
+		end = 1
+	}
+	if end < 0 {
+		return label
+	}
+	return label[:end]
+}
+
+// Add places the candidate names on the scheduler.
+func (s *CandidateScheduler) Add(names ...string) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, name := range names {
+		b := s.bucket(CandidatePattern(name))
+
+		b.names = append(b.names, name)
+		if b.index < 0 {
+			heap.Push(&s.queue, b)
+		}
+		s.count++
+	}
+}
+
+// Next returns the candidate with the pattern showing the best hit rate, and false
+// once no candidates remain.
+func (s *CandidateScheduler) Next() (string, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.queue.Len() == 0 {
+		return "", false
+	}
+
+	b := s.queue[0]
+	name := b.names[0]
+	b.names = b.names[1:]
+	if len(b.names) == 0 {
+		heap.Pop(&s.queue)
+	}
+	s.count--
+	return name, true
+}
+
+// Observe records whether the name, which does not need to be a candidate of the scheduler,
+// was found to exist. The remaining candidates sharing the pattern are moved accordingly.
+func (s *CandidateScheduler) Observe(name string, hit bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	b := s.bucket(CandidatePattern(name))
+	b.tries++
+	if hit {
+		b.hits++
+	}
+	if b.index >= 0 {
+		heap.Fix(&s.queue, b.index)
+	}
+}
+
+// Len returns the number of candidates remaining on the scheduler.
+func (s *CandidateScheduler) Len() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.count
+}
+
+// Returns the bucket for the pattern, creating it when necessary.
+func (s *CandidateScheduler) bucket(pattern string) *candidateBucket {
+	b, found := s.patterns[pattern]
+	if !found {
+		b = &candidateBucket{pattern: pattern, order: s.next, index: -1}
+		s.patterns[pattern] = b
+		s.next++
+	}
+	return b
+}
+
+// bucketQueue implements heap.Interface for the buckets holding candidates.
+type bucketQueue []*candidateBucket
+
+func (q bucketQueue) Len() int { return len(q) }
+
+func (q bucketQueue) Less(i, j int) bool {
+	if si, sj := q[i].score(), q[j].score(); si != sj {
+		return si > sj
+	}
+	return q[i].order < q[j].order
+}
+
+func (q bucketQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *bucketQueue) Push(x interface{}) {
+	b := x.(*candidateBucket)
+	b.index = len(*q)
+	*q = append(*q, b)
+}
+
+func (q *bucketQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	b := old[n-1]
+	old[n-1] = nil
+	b.index = -1
+	*q = old[:n-1]
+	return b
+}
@@ -0,0 +1,69 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCandidatePattern(t *testing.T) {
+	for name, expected := range map[string]string{
+		"www.owasp.org":      "www",
+		"DEV01.owasp.org.":   "dev",
+		"dev-api.owasp.org":  "dev",
+		"api_v2.owasp.org":   "api",
+		"01.owasp.org":       "0",
+		"-x.owasp.org":       "-",
+		"owasp":              "owasp",
+		"mail2.caffix.net":   "mail",
+		"mail.caffix.net":    "mail",
+		"staging.caffix.net": "staging",
+	} {
+		if got := CandidatePattern(name); got != expected {
+			t.Errorf("CandidatePattern(%s) returned %s; expected %s", name, got, expected)
+		}
+	}
+}
+
+func TestCandidateScheduler(t *testing.T) {
+	s := NewCandidateScheduler(
+		"www.owasp.org", "dev1.owasp.org", "ftp.owasp.org",
+		"dev2.owasp.org", "dev-api.owasp.org", "vpn.owasp.org",
+	)
+	if s.Len() != 6 {
+		t.Fatalf("Got: %d candidates; Expected: 6", s.Len())
+	}
+
+	var got []string
+	name, _ := s.Next()
+	got = append(got, name)
+	s.Observe(name, false)
+	// The hit for the first dev name moves the remaining dev names to the front
+	name, _ = s.Next()
+	got = append(got, name)
+	s.Observe(name, true)
+	// Names outside of the scheduler contribute to the hit rates
+	s.Observe("mail.caffix.net", true)
+	s.Add("mail.owasp.org")
+
+	for name, ok := s.Next(); ok; name, ok = s.Next() {
+		got = append(got, name)
+	}
+
+	expected := []string{
+		"www.owasp.org", "dev1.owasp.org", "dev2.owasp.org", "dev-api.owasp.org",
+		"mail.owasp.org", "ftp.owasp.org", "vpn.owasp.org",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Got: %v; Expected: %v", got, expected)
+	}
+	if s.Len() != 0 {
+		t.Errorf("%d candidates remain on the scheduler", s.Len())
+	}
+	if _, ok := s.Next(); ok {
+		t.Errorf("a candidate was returned from the empty scheduler")
+	}
+}
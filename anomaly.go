@@ -0,0 +1,89 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// The reasons provided with an AnswerAnomaly.
+const (
+	AnomalyShortTTL        = "short TTL"
+	AnomalyChangingAnswers = "changing answers"
+	AnomalyPrivateAddress  = "private address"
+)
+
+// AnswerAnomaly describes answers that indicate a possible DNS rebinding setup or fast-flux infrastructure.
+type AnswerAnomaly struct {
+	Name   string
+	Qtype  uint16
+	Reason string
+	TTL    uint32
+	First  []string
+	Second []string
+}
+
+// CheckAnswerAnomalies flags the answers in the provided response that have a TTL below minTTL,
+// that resolve to private, loopback or link-local addresses, or that change when the query is
+// immediately repeated through the pool.
+func (r *Resolvers) CheckAnswerAnomalies(ctx context.Context, resp *dns.Msg, minTTL uint32) []*AnswerAnomaly {
+	if ValidateMsg(resp) != nil || resp.Rcode != dns.RcodeSuccess {
+		return nil
+	}
+
+	q := resp.Question[0]
+	first := answerData(resp, q.Qtype)
+	if len(first) == 0 {
+		return nil
+	}
+
+	name := strings.ToLower(RemoveLastDot(q.Name))
+	anomaly := func(reason string) *AnswerAnomaly {
+		return &AnswerAnomaly{Name: name, Qtype: q.Qtype, Reason: reason, First: first}
+	}
+
+	var anomalies []*AnswerAnomaly
+	if ttl, ok := MinimumTTL(resp); ok && ttl < minTTL {
+		a := anomaly(AnomalyShortTTL)
+		a.TTL = ttl
+		anomalies = append(anomalies, a)
+	}
+	for _, ip := range IPs(resp) {
+		if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+			anomalies = append(anomalies, anomaly(AnomalyPrivateAddress))
+			break
+		}
+	}
+
+	// The cache would repeat the first answers
+	if again, err := r.QueryBlocking(withoutCache(ctx), QueryMsg(name, q.Qtype)); err == nil && again.Rcode == dns.RcodeSuccess {
+		if second := answerData(again, q.Qtype); len(second) > 0 && !sameAddrs(first, second) {
+			a := anomaly(AnomalyChangingAnswers)
+			a.Second = second
+			anomalies = append(anomalies, a)
+		}
+	}
+	return anomalies
+}
+
+// Returns the sorted data of the answers with the provided type.
+func answerData(msg *dns.Msg, qtype uint16) []string {
+	var data []string
+
+	for _, a := range AnswersByType(ExtractAnswers(msg), qtype) {
+		if ip := net.ParseIP(a.Data); ip != nil {
+			data = append(data, ip.String())
+			continue
+		}
+		data = append(data, a.Data)
+	}
+	sort.Strings(data)
+	return data
+}
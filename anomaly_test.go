@@ -0,0 +1,78 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestCheckAnswerAnomalies(t *testing.T) {
+	var flux int32
+	mux := dns.NewServeMux()
+	mux.HandleFunc("anomaly.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		name := req.Question[0].Name
+		rr := testARecord(name, "93.184.216.34")
+		rr.Hdr.Ttl = 3600
+		switch name {
+		case "short.anomaly.net.":
+			rr.Hdr.Ttl = 5
+		case "rebind.anomaly.net.":
+			rr = testARecord(name, "192.168.1.1")
+			rr.Hdr.Ttl = 3600
+		case "flux.anomaly.net.":
+			rr = testARecord(name, fmt.Sprintf("93.184.216.%d", atomic.AddInt32(&flux, 1)))
+			rr.Hdr.Ttl = 3600
+		}
+		m.Answer = append(m.Answer, rr)
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	cases := []struct {
+		name   string
+		reason string
+	}{
+		{name: "www.anomaly.net"},
+		{name: "short.anomaly.net", reason: AnomalyShortTTL},
+		{name: "rebind.anomaly.net", reason: AnomalyPrivateAddress},
+		{name: "flux.anomaly.net", reason: AnomalyChangingAnswers},
+	}
+
+	for _, c := range cases {
+		resp, err := r.QueryBlocking(context.Background(), QueryMsg(c.name, dns.TypeA))
+		if err != nil {
+			t.Errorf("the query for %s failed: %v", c.name, err)
+			continue
+		}
+
+		anomalies := r.CheckAnswerAnomalies(context.Background(), resp, 60)
+		if c.reason == "" {
+			if len(anomalies) > 0 {
+				t.Errorf("%s was flagged with %s", c.name, anomalies[0].Reason)
+			}
+			continue
+		}
+		if len(anomalies) != 1 || anomalies[0].Reason != c.reason || anomalies[0].Name != c.name {
+			t.Errorf("%s was not flagged with %s: %v", c.name, c.reason, anomalies)
+		}
+	}
+}
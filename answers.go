@@ -0,0 +1,85 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Records returns the resource records of type T found in the provided slice.
+func Records[T dns.RR](rrs []dns.RR) []T {
+	var records []T
+
+	for _, rr := range rrs {
+		if t, ok := rr.(T); ok {
+			records = append(records, t)
+		}
+	}
+	return records
+}
+
+// Answers returns the resource records of type T found in the answer section of the provided message.
+func Answers[T dns.RR](msg *dns.Msg) []T {
+	if msg == nil {
+		return nil
+	}
+	return Records[T](msg.Answer)
+}
+
+// IPs returns the IPv4 and IPv6 addresses found in the answer section of the provided message.
+func IPs(msg *dns.Msg) []net.IP {
+	var ips []net.IP
+
+	for _, a := range Answers[*dns.A](msg) {
+		ips = append(ips, a.A)
+	}
+	for _, aaaa := range Answers[*dns.AAAA](msg) {
+		ips = append(ips, aaaa.AAAA)
+	}
+	return ips
+}
+
+// CNAMETargets returns the targets of the CNAME records found in the answer section of the provided message.
+func CNAMETargets(msg *dns.Msg) []string {
+	var targets []string
+
+	for _, cname := range Answers[*dns.CNAME](msg) {
+		targets = append(targets, strings.ToLower(RemoveLastDot(cname.Target)))
+	}
+	return targets
+}
+
+// MergeAnswers returns the union of the answer sections found in the provided messages. Records
+// that are identical apart from the TTL, such as a CNAME returned for multiple query types, are
+// only included once.
+func MergeAnswers(msgs ...*dns.Msg) []dns.RR {
+	var merged []dns.RR
+
+	for _, msg := range msgs {
+		if msg == nil {
+			continue
+		}
+
+		for _, rr := range msg.Answer {
+			if !ContainsRR(merged, rr) {
+				merged = append(merged, rr)
+			}
+		}
+	}
+	return merged
+}
+
+// ContainsRR returns true when the slice has a record identical to rr apart from the TTL.
+func ContainsRR(rrs []dns.RR, rr dns.RR) bool {
+	for _, r := range rrs {
+		if dns.IsDuplicate(r, rr) {
+			return true
+		}
+	}
+	return false
+}
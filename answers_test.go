@@ -0,0 +1,83 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestTypedAnswers(t *testing.T) {
+	msg := new(dns.Msg)
+	msg.SetQuestion("www.caffix.net.", dns.TypeA)
+	msg.Answer = []dns.RR{
+		&dns.CNAME{
+			Hdr:    dns.RR_Header{Name: "www.caffix.net.", Rrtype: dns.TypeCNAME, Class: dns.ClassINET},
+			Target: "Web.Caffix.net.",
+		},
+		testARecord("web.caffix.net.", "192.168.1.1"),
+		&dns.AAAA{
+			Hdr:  dns.RR_Header{Name: "web.caffix.net.", Rrtype: dns.TypeAAAA, Class: dns.ClassINET},
+			AAAA: net.ParseIP("2001:db8::1"),
+		},
+		&dns.TXT{
+			Hdr: dns.RR_Header{Name: "web.caffix.net.", Rrtype: dns.TypeTXT, Class: dns.ClassINET},
+			Txt: []string{"v=spf1", "-all"},
+		},
+	}
+
+	if a := Answers[*dns.A](msg); len(a) != 1 || a[0].A.String() != "192.168.1.1" {
+		t.Errorf("Answers failed to return the A record: %v", a)
+	}
+	if ns := Answers[*dns.NS](msg); len(ns) != 0 {
+		t.Errorf("Answers returned records of the wrong type: %v", ns)
+	}
+	if ips := IPs(msg); len(ips) != 2 || ips[0].String() != "192.168.1.1" || ips[1].String() != "2001:db8::1" {
+		t.Errorf("IPs returned %v", ips)
+	}
+	if targets := CNAMETargets(msg); len(targets) != 1 || targets[0] != "web.caffix.net" {
+		t.Errorf("CNAMETargets returned %v", targets)
+	}
+	if a := Answers[*dns.A](nil); a != nil {
+		t.Errorf("Answers returned records for a nil message")
+	}
+}
+
+func TestMergeAnswers(t *testing.T) {
+	cname := &dns.CNAME{
+		Hdr:    dns.RR_Header{Name: "www.caffix.net.", Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 300},
+		Target: "web.caffix.net.",
+	}
+
+	a := new(dns.Msg)
+	a.SetQuestion("www.caffix.net.", dns.TypeA)
+	a.Answer = []dns.RR{cname, testARecord("web.caffix.net.", "192.168.1.1")}
+
+	c := new(dns.Msg)
+	c.SetQuestion("www.caffix.net.", dns.TypeCNAME)
+	dup := dns.Copy(cname).(*dns.CNAME)
+	dup.Hdr.Ttl = 60
+	c.Answer = []dns.RR{dup}
+
+	merged := MergeAnswers(a, nil, c)
+	if len(merged) != 2 {
+		t.Fatalf("expected 2 merged records, got %d: %v", len(merged), merged)
+	}
+	if merged[0] != cname || len(Records[*dns.A](merged)) != 1 {
+		t.Errorf("unexpected merged records: %v", merged)
+	}
+
+	if !ContainsRR(merged, dup) {
+		t.Errorf("the record with a different TTL was not found")
+	}
+	if ContainsRR(merged, testARecord("web.caffix.net.", "192.168.1.2")) {
+		t.Errorf("a different record was reported as contained")
+	}
+	if len(MergeAnswers()) != 0 {
+		t.Errorf("records were returned without any messages")
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/publicsuffix"
+)
+
+// authServers manages the resolvers created for the authoritative nameservers of DNS zones.
+type authServers struct {
+	sync.Mutex
+	enabled   bool
+	requireAA bool
+	qps       int
+	port      string
+	zones     map[string][]*resolver
+	addrs     map[string]*resolver
+}
+
+func newAuthServers() *authServers {
+	return &authServers{
+		port:  "53",
+		zones: make(map[string][]*resolver),
+		addrs: make(map[string]*resolver),
+	}
+}
+
+// SetAuthoritativeDetection causes DNS wildcard detection to send the probes directly to the
+// authoritative nameservers of the zone being tested, instead of a recursive resolver.
+func (r *Resolvers) SetAuthoritativeDetection(qps int) {
+	r.auth.Lock()
+	defer r.auth.Unlock()
+
+	if qps <= 0 {
+		qps = startQPSPerNameserver
+	}
+
+	r.auth.enabled = true
+	r.auth.qps = qps
+}
+
+func (r *Resolvers) authDetection() bool {
+	r.auth.Lock()
+	defer r.auth.Unlock()
+
+	return r.auth.enabled
+}
+
+// SetAuthoritativeAnswers causes responses from the authoritative nameservers that do not have
+// the AA bit set to be rejected, and the query to be sent to another nameserver for the zone.
+// This guards against stale parent-side data being treated as the final answer.
+func (r *Resolvers) SetAuthoritativeAnswers(require bool) {
+	r.auth.Lock()
+	defer r.auth.Unlock()
+
+	r.auth.requireAA = require
+}
+
+// Returns true when the response from the authoritative nameserver must be rejected.
+func (r *Resolvers) nonAuthoritative(res *resolver, resp *dns.Msg) bool {
+	r.auth.Lock()
+	require := r.auth.requireAA
+	r.auth.Unlock()
+
+	if !require || resp.Rcode == RcodeNoResponse || resp.Authoritative {
+		return false
+	}
+	return r.lookupAuthResolver(res.address.String()) == res
+}
+
+// Returns another authoritative nameserver for the zone containing the name, or nil when the
+// nameservers not yet tried have been exhausted.
+func (r *Resolvers) alternateAuthResolver(ctx context.Context, name string, tried map[*resolver]struct{}) *resolver {
+	var remaining []*resolver
+	for _, res := range r.authoritativeResolvers(ctx, name) {
+		if _, found := tried[res]; !found {
+			remaining = append(remaining, res)
+		}
+	}
+
+	if len(remaining) == 0 {
+		return nil
+	}
+	return remaining[prng.Intn(len(remaining))]
+}
+
+// AuthoritativeServers returns the zone containing the provided name and the IP addresses
+// of the authoritative nameservers for the zone, as obtained through the resolver pool.
+func (r *Resolvers) AuthoritativeServers(ctx context.Context, name string) (string, []string) {
+	name = strings.ToLower(RemoveLastDot(name))
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(name)
+	if err != nil {
+		return "", nil
+	}
+
+	var zone string
+	var servers []string
+	FQDNToRegistered(name, domain, func(sub string) bool {
+		var found bool
+
+		servers, found = r.zoneServers(ctx, sub)
+		if found {
+			zone = sub
+		}
+		return found
+	})
+	return zone, servers
+}
+
+// Returns the IP addresses of the nameservers for the provided zone, and true when NS records
+// were found at the apex of the zone.
+func (r *Resolvers) zoneServers(ctx context.Context, zone string) ([]string, bool) {
+	resp, err := r.QueryBlocking(ctx, QueryMsg(zone, dns.TypeNS))
+	if err != nil || resp.Rcode != dns.RcodeSuccess {
+		return nil, false
+	}
+
+	var found bool
+	var servers []string
+	for _, ns := range AnswersByType(ExtractAnswers(resp), dns.TypeNS) {
+		if ns.Name != zone {
+			continue
+		}
+
+		found = true
+		servers = append(servers, r.nameserverAddrs(ctx, ns.Data)...)
+	}
+	return servers, found
+}
+
+func (r *Resolvers) nameserverAddrs(ctx context.Context, host string) []string {
+	var addrs []string
+
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		resp, err := r.QueryBlocking(ctx, QueryMsg(host, qtype))
+		if err != nil || resp.Rcode != dns.RcodeSuccess {
+			continue
+		}
+
+		for _, a := range AnswersByType(ExtractAnswers(resp), qtype) {
+			addrs = append(addrs, a.Data)
+		}
+	}
+	return addrs
+}
+
+// Returns resolvers for the authoritative nameservers of the zone containing the provided name.
+func (r *Resolvers) authoritativeResolvers(ctx context.Context, name string) []*resolver {
+	zone, servers := r.AuthoritativeServers(ctx, name)
+	if zone == "" || len(servers) == 0 {
+		return nil
+	}
+
+	r.auth.Lock()
+	list, found := r.auth.zones[zone]
+	r.auth.Unlock()
+
+	if found {
+		return list
+	}
+
+	for _, ip := range servers {
+		if res := r.authResolver(ip); res != nil {
+			list = append(list, res)
+		}
+	}
+
+	r.auth.Lock()
+	r.auth.zones[zone] = list
+	r.auth.Unlock()
+	return list
+}
+
+// Returns the resolver for the authoritative nameserver at the provided IP address.
+func (r *Resolvers) authResolver(ip string) *resolver {
+	r.auth.Lock()
+	defer r.auth.Unlock()
+
+	addr := net.JoinHostPort(ip, r.auth.port)
+	if res, found := r.auth.addrs[addr]; found {
+		return res
+	}
+
+	qps := r.auth.qps
+	if qps <= 0 {
+		qps = startQPSPerNameserver
+	}
+
+	res := r.initializeResolver(qps, addr)
+	if res != nil {
+		r.auth.addrs[addr] = res
+	}
+	return res
+}
+
+// Returns a query message that does not request recursion, for use with authoritative nameservers.
+func authMsg(name string, qtype uint16) *dns.Msg {
+	msg := QueryMsg(name, qtype)
+	msg.RecursionDesired = false
+	return msg
+}
+
+// Sends the provided message directly to the nameserver and returns the first response received.
+func (r *Resolvers) authExchange(ctx context.Context, res *resolver, msg *dns.Msg) (*dns.Msg, error) {
+	ch := make(chan *dns.Msg, 1)
+
+	var resp *dns.Msg
+	retryAttempts(ctx, func() bool {
+		res.writeReq(&request{
+			Res:    res,
+			Msg:    msg.Copy(),
+			Result: ch,
+			ctx:    ctx,
+		})
+
+		select {
+		case <-ctx.Done():
+			return true
+		case m := <-ch:
+			if m.Rcode != RcodeNoResponse {
+				resp = m
+			}
+		}
+		return resp != nil
+	})
+	if resp != nil {
+		return resp, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("authExchange: no response from %s", res.address)
+}
+
+func (r *Resolvers) lookupAuthResolver(addr string) *resolver {
+	r.auth.Lock()
+	defer r.auth.Unlock()
+
+	return r.auth.addrs[addr]
+}
+
+func (r *Resolvers) allAuthResolvers() []*resolver {
+	r.auth.Lock()
+	defer r.auth.Unlock()
+
+	var all []*resolver
+	for _, res := range r.auth.addrs {
+		all = append(all, res)
+	}
+	return all
+}
@@ -0,0 +1,194 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestAuthoritativeDetection(t *testing.T) {
+	// The authoritative nameserver only has a record for www.auth.net
+	var probes int32
+	authMux := dns.NewServeMux()
+	authMux.HandleFunc("auth.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		if !req.RecursionDesired {
+			atomic.AddInt32(&probes, 1)
+		}
+
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Authoritative = true
+
+		if req.Question[0].Name == "www.auth.net." && req.Question[0].Qtype == dns.TypeA {
+			m.Answer = append(m.Answer, testARecord("www.auth.net.", "192.168.1.1"))
+		} else if req.Question[0].Name != "www.auth.net." {
+			m.Rcode = dns.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	as, authaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = authMux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = as.Shutdown() }()
+	_, authport, _ := net.SplitHostPort(authaddr)
+
+	// The recursive resolver intercepts names that do not exist
+	recMux := dns.NewServeMux()
+	recMux.HandleFunc("auth.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		switch name := req.Question[0].Name; {
+		case name == "auth.net." && req.Question[0].Qtype == dns.TypeNS:
+			m.Answer = append(m.Answer, &dns.NS{
+				Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeNS, Class: dns.ClassINET},
+				Ns:  "ns1.auth.net.",
+			})
+		case name == "ns1.auth.net." && req.Question[0].Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, testARecord(name, "127.0.0.1"))
+		case req.Question[0].Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, testARecord(name, "10.0.0.1"))
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	rs, recaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = recMux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = rs.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, recaddr)
+	defer r.Stop()
+	r.SetAuthoritativeDetection(100)
+	r.auth.port = authport
+
+	zone, servers := r.AuthoritativeServers(context.Background(), "foo.bar.auth.net")
+	if zone != "auth.net" || len(servers) != 1 || servers[0] != "127.0.0.1" {
+		t.Fatalf("failed to obtain the authoritative servers: %s %v", zone, servers)
+	}
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("foo.auth.net", dns.TypeA))
+	if err != nil || len(resp.Answer) == 0 {
+		t.Fatalf("the query through the recursive resolver failed: %v", err)
+	}
+	if r.WildcardDetected(context.Background(), resp, "auth.net") {
+		t.Errorf("the interception by the recursive resolver was reported as a wildcard")
+	}
+	if len(r.allAuthResolvers()) != 1 || atomic.LoadInt32(&probes) == 0 {
+		t.Errorf("the wildcard probes were not sent to the authoritative nameserver")
+	}
+}
+
+func TestAuthoritativeAnswers(t *testing.T) {
+	// The first nameserver returns stale data for every name without the AA bit
+	staleMux := dns.NewServeMux()
+	staleMux.HandleFunc("stale.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		if req.Question[0].Qtype == dns.TypeA {
+			m.Answer = append(m.Answer, testARecord(req.Question[0].Name, "10.0.0.1"))
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	ss, staleaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = staleMux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = ss.Shutdown() }()
+	_, authport, _ := net.SplitHostPort(staleaddr)
+
+	// The second nameserver is authoritative and only has a record for www.stale.net
+	var probes int32
+	authMux := dns.NewServeMux()
+	authMux.HandleFunc("stale.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		atomic.AddInt32(&probes, 1)
+
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Authoritative = true
+
+		if req.Question[0].Name != "www.stale.net." {
+			m.Rcode = dns.RcodeNameError
+		} else if req.Question[0].Qtype == dns.TypeA {
+			m.Answer = append(m.Answer, testARecord("www.stale.net.", "192.168.1.1"))
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	as, _, _, err := RunLocalUDPServer(net.JoinHostPort("127.0.0.2", authport), func(s *dns.Server) { s.Handler = authMux })
+	if err != nil {
+		t.Skipf("unable to run test server on a second loopback address: %v", err)
+	}
+	defer func() { _ = as.Shutdown() }()
+
+	recMux := dns.NewServeMux()
+	recMux.HandleFunc("stale.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		switch name := req.Question[0].Name; {
+		case name == "stale.net." && req.Question[0].Qtype == dns.TypeNS:
+			for _, ns := range []string{"ns1.stale.net.", "ns2.stale.net."} {
+				m.Answer = append(m.Answer, &dns.NS{
+					Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeNS, Class: dns.ClassINET},
+					Ns:  ns,
+				})
+			}
+		case name == "ns1.stale.net." && req.Question[0].Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, testARecord(name, "127.0.0.1"))
+		case name == "ns2.stale.net." && req.Question[0].Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, testARecord(name, "127.0.0.2"))
+		case req.Question[0].Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, testARecord(name, "10.0.0.1"))
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	rs, recaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = recMux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = rs.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, recaddr)
+	defer r.Stop()
+	r.SetAuthoritativeDetection(100)
+	r.SetAuthoritativeAnswers(true)
+	r.auth.port = authport
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("foo.stale.net", dns.TypeA))
+	if err != nil || len(resp.Answer) == 0 {
+		t.Fatalf("the query through the recursive resolver failed: %v", err)
+	}
+	if r.WildcardDetected(context.Background(), resp, "stale.net") {
+		t.Errorf("the stale data without the AA bit was reported as a wildcard")
+	}
+	if atomic.LoadInt32(&probes) == 0 {
+		t.Errorf("the wildcard probes were not sent to the nameserver with authoritative answers")
+	}
+}
+
+func testARecord(name, addr string) *dns.A {
+	return &dns.A{
+		Hdr: dns.RR_Header{
+			Name:   name,
+			Rrtype: dns.TypeA,
+			Class:  dns.ClassINET,
+		},
+		A: net.ParseIP(addr),
+	}
+}
@@ -5,26 +5,114 @@
 package resolve
 
 import (
+	"context"
 	"math"
-	"math/rand"
 	"time"
 )
 
-const numOfUnits int = 100
+const (
+	numOfUnits int = 100
+	maxEvents  int = 32
+)
+
+// JitterStrategy determines how random jitter is applied to the delays computed by a Backoff.
+type JitterStrategy int
+
+// The jitter strategies supported by the Backoff type.
+const (
+	// NoJitter uses the exponential delay without modification.
+	NoJitter JitterStrategy = iota
+	// AdditiveJitter adds a random duration in the range [0,base) to the exponential delay.
+	AdditiveJitter
+	// FullJitter selects a random duration in the range [0,delay).
+	FullJitter
+	// EqualJitter keeps half the exponential delay and randomizes the other half.
+	EqualJitter
+)
+
+// Backoff is a policy object that computes exponentially increasing delays between attempts.
+type Backoff struct {
+	Base   time.Duration
+	Max    time.Duration
+	Jitter JitterStrategy
+}
+
+// Backoff policies used by the package when retrying queries and replacing connections.
+var (
+	retryBackoff = NewBackoff(50*time.Millisecond, time.Second, EqualJitter)
+	connBackoff  = NewBackoff(10*time.Millisecond, 250*time.Millisecond, FullJitter)
+)
+
+// Performs the attempt up to maxQueryAttempts times, waiting longer between each one using the
+// retryBackoff, until the attempt reports being done or the context expires.
+func retryAttempts(ctx context.Context, attempt func() bool) {
+	for i := 0; i < maxQueryAttempts; i++ {
+		if i > 0 && retryBackoff.Sleep(ctx, i-1) != nil {
+			return
+		}
+		if attempt() {
+			return
+		}
+	}
+}
+
+// NewBackoff returns a Backoff using the provided base delay, maximum delay and jitter strategy.
+// A maximum of zero indicates that the delay should not be truncated.
+func NewBackoff(base, max time.Duration, jitter JitterStrategy) *Backoff {
+	return &Backoff{
+		Base:   base,
+		Max:    max,
+		Jitter: jitter,
+	}
+}
+
+// NextDelay returns the Duration that should be waited after the provided number of events.
+func (b *Backoff) NextDelay(events int) time.Duration {
+	if events < 0 {
+		events = 0
+	} else if events > maxEvents {
+		events = maxEvents
+	}
+
+	delay := time.Duration(math.Pow(2, float64(events))) * b.Base
+	switch b.Jitter {
+	case AdditiveJitter:
+		delay += BackoffJitter(0, b.Base)
+	case FullJitter:
+		delay = BackoffJitter(0, delay)
+	case EqualJitter:
+		delay = (delay / 2) + BackoffJitter(0, delay/2)
+	}
+
+	if b.Max > 0 && delay > b.Max {
+		return b.Max
+	}
+	return delay
+}
+
+// Sleep blocks for the Duration returned by NextDelay, or until the context expires.
+func (b *Backoff) Sleep(ctx context.Context, events int) error {
+	t := time.NewTimer(b.NextDelay(events))
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+	}
+	return nil
+}
 
 // ExponentialBackoff returns a Duration equal to 2^events multiplied by the provided delay
 // and jitter added equal to [0,delay).
 func ExponentialBackoff(events int, delay time.Duration) time.Duration {
-	return (time.Duration(math.Pow(2, float64(events))) * delay) + BackoffJitter(0, delay)
+	return NewBackoff(delay, 0, AdditiveJitter).NextDelay(events)
 }
 
 // TruncatedExponentialBackoff returns a Duration equal to ExponentialBackoff with a provided
 // maximum Duration used to truncate the result.
 func TruncatedExponentialBackoff(events int, delay, max time.Duration) time.Duration {
-	if backoff := ExponentialBackoff(events, delay); backoff < max {
-		return backoff
-	}
-	return max
+	return NewBackoff(delay, max, AdditiveJitter).NextDelay(events)
 }
 
 // BackoffJitter returns a random Duration between the provided min and max parameters.
@@ -33,7 +121,7 @@ func BackoffJitter(min, max time.Duration) time.Duration {
 		return time.Duration(0)
 	}
 	if period := max - min; period > time.Duration(numOfUnits) {
-		return min + (time.Duration(rand.Intn(numOfUnits)) * (period / time.Duration(numOfUnits)))
+		return min + (time.Duration(prng.Intn(numOfUnits)) * (period / time.Duration(numOfUnits)))
 	}
 	return min
 }
@@ -5,6 +5,7 @@
 package resolve
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -110,3 +111,68 @@ func TestBackoffJitter(t *testing.T) {
 		})
 	}
 }
+
+func TestBackoffNextDelay(t *testing.T) {
+	base := 100 * time.Millisecond
+	tests := []struct {
+		name   string
+		jitter JitterStrategy
+		events int
+		min    time.Duration
+		max    time.Duration
+	}{
+		{
+			name:   "No jitter",
+			jitter: NoJitter,
+			events: 3,
+			min:    800 * time.Millisecond,
+			max:    800 * time.Millisecond,
+		},
+		{
+			name:   "Full jitter",
+			jitter: FullJitter,
+			events: 3,
+			min:    0,
+			max:    800 * time.Millisecond,
+		},
+		{
+			name:   "Equal jitter",
+			jitter: EqualJitter,
+			events: 3,
+			min:    400 * time.Millisecond,
+			max:    800 * time.Millisecond,
+		},
+		{
+			name:   "Truncated to the maximum",
+			jitter: AdditiveJitter,
+			events: 100,
+			min:    2 * time.Second,
+			max:    2 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBackoff(base, 2*time.Second, tt.jitter)
+
+			if d := b.NextDelay(tt.events); d < tt.min || d > tt.max {
+				t.Errorf("Unexpected Result from min: %v, max: %v, got %v", tt.min, tt.max, d)
+			}
+		})
+	}
+}
+
+func TestBackoffSleep(t *testing.T) {
+	b := NewBackoff(time.Minute, 0, NoJitter)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.Sleep(ctx, 1); err == nil {
+		t.Errorf("Sleep failed to return an error when the context expired")
+	}
+
+	b = NewBackoff(time.Millisecond, 0, NoJitter)
+	if err := b.Sleep(context.Background(), 1); err != nil {
+		t.Errorf("Sleep returned an error: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// The duration waited for the hostname of a resolver to be bootstrapped.
+const bootstrapTimeout = 5 * time.Second
+
+// SetBootstrapServer sets the DNS server used to obtain the IP addresses of resolvers specified
+// by hostname. The system resolver is used when a bootstrap server has not been provided.
+func (r *Resolvers) SetBootstrapServer(addr string) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "53")
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.bootstrap = addr
+}
+
+func (r *Resolvers) bootstrapServer() string {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.bootstrap
+}
+
+// Returns the resolver addresses with each hostname replaced by an IP:port entry for each
+// of the IP addresses bootstrapped for the hostname.
+func (r *Resolvers) expandHostnames(addrs []string) []string {
+	var expanded []string
+
+	for _, addr := range addrs {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			host, port = addr, "53"
+		}
+		if net.ParseIP(host) != nil {
+			expanded = append(expanded, addr)
+			continue
+		}
+
+		for _, ip := range r.bootstrapHost(host) {
+			expanded = append(expanded, net.JoinHostPort(ip, port))
+		}
+	}
+	return expanded
+}
+
+// Returns the IP addresses for the hostname, as obtained from the bootstrap server or system resolver.
+func (r *Resolvers) bootstrapHost(host string) []string {
+	var ips []string
+
+	server := r.bootstrapServer()
+	if server == "" {
+		ctx, cancel := context.WithTimeout(context.Background(), bootstrapTimeout)
+		defer cancel()
+
+		if addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host); err == nil {
+			for _, addr := range addrs {
+				ips = append(ips, addr.IP.String())
+			}
+		}
+		return ips
+	}
+
+	client := dns.Client{Timeout: bootstrapTimeout}
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		if m, _, err := client.Exchange(QueryMsg(host, qtype), server); err == nil {
+			for _, ip := range IPs(m) {
+				ips = append(ips, ip.String())
+			}
+		}
+	}
+	return ips
+}
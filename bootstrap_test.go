@@ -0,0 +1,64 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestBootstrapHostnames(t *testing.T) {
+	mux := dns.NewServeMux()
+	mux.HandleFunc("resolvers.test.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		if req.Question[0].Name == "dns.resolvers.test." && req.Question[0].Qtype == dns.TypeA {
+			m.Answer = append(m.Answer, testARecord("dns.resolvers.test.", "127.0.0.1"))
+			m.Answer = append(m.Answer, testARecord("dns.resolvers.test.", "127.0.0.2"))
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	defer r.Stop()
+	r.SetBootstrapServer(addrstr)
+
+	expanded := r.expandHostnames([]string{"dns.resolvers.test:5353", "192.168.1.1", "2001:db8::1"})
+	expected := []string{"127.0.0.1:5353", "127.0.0.2:5353", "192.168.1.1", "2001:db8::1"}
+	if len(expanded) != len(expected) {
+		t.Fatalf("Got: %v; Expected: %v", expanded, expected)
+	}
+	for i := range expected {
+		if expanded[i] != expected[i] {
+			t.Errorf("Got: %v; Expected: %v", expanded, expected)
+			break
+		}
+	}
+
+	if err := r.AddResolvers(10, "dns.resolvers.test"); err != nil || r.Len() != 2 {
+		t.Errorf("failed to add a resolver for each bootstrapped address")
+	}
+	if res := r.pool.LookupResolver(net.JoinHostPort("127.0.0.2", "53")); res == nil {
+		t.Errorf("the bootstrapped resolver was not added with the default port")
+	}
+}
+
+func TestSystemBootstrap(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	if ips := r.bootstrapHost("localhost"); len(ips) == 0 {
+		t.Errorf("the system resolver failed to bootstrap localhost")
+	}
+}
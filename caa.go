@@ -0,0 +1,113 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// CAAPolicy is the relevant CAA record set for a name (RFC 8659). Name is the domain name
+// where the record set was found, and is empty when no CAA records exist up to the TLD. The
+// issuer domain names of the "issue" and "issuewild" properties are normalized to lowercase
+// without parameters, and properties that forbid issuance do not contribute an issuer.
+type CAAPolicy struct {
+	Name            string
+	Records         []*dns.CAA
+	Issuers         []string
+	WildcardIssuers []string
+	IODEF           []string
+}
+
+// CAAPolicy walks the CAA lookup algorithm for the name through the resolver pool, removing
+// one label at a time until a non-empty CAA record set is found.
+func (r *Resolvers) CAAPolicy(ctx context.Context, name string) (*CAAPolicy, error) {
+	name = strings.ToLower(RemoveLastDot(name))
+	labels := strings.Split(name, ".")
+
+	var err error
+	policy := new(CAAPolicy)
+	FQDNToRegistered(name, labels[len(labels)-1], func(domain string) bool {
+		var records []*dns.CAA
+
+		records, err = r.caaLookup(ctx, domain)
+		if err == nil && len(records) > 0 {
+			policy.Name = domain
+			policy.Records = records
+		}
+		return err != nil || len(records) > 0
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, caa := range policy.Records {
+		switch strings.ToLower(caa.Tag) {
+		case "issue":
+			policy.Issuers = appendIssuer(policy.Issuers, caa.Value)
+		case "issuewild":
+			policy.WildcardIssuers = appendIssuer(policy.WildcardIssuers, caa.Value)
+		case "iodef":
+			policy.IODEF = append(policy.IODEF, caa.Value)
+		}
+	}
+	return policy, nil
+}
+
+// Permits returns true when the policy authorizes the issuer to issue certificates for the
+// name, or for wildcard names when requested. The "issuewild" properties take precedence
+// for wildcard names when present.
+func (p *CAAPolicy) Permits(issuer string, wildcard bool) bool {
+	issuer = normalizeIssuer(issuer)
+
+	var issue, issuewild bool
+	for _, caa := range p.Records {
+		switch strings.ToLower(caa.Tag) {
+		case "issue":
+			issue = true
+		case "issuewild":
+			issuewild = true
+		}
+	}
+
+	if wildcard && issuewild {
+		return slices.Contains(p.WildcardIssuers, issuer)
+	}
+	if issue {
+		return slices.Contains(p.Issuers, issuer)
+	}
+	return true
+}
+
+// Returns the CAA records found for the name, or an error when the lookup failed.
+func (r *Resolvers) caaLookup(ctx context.Context, name string) ([]*dns.CAA, error) {
+	if resp, err := r.QueryWithRetries(ctx, QueryMsg(name, dns.TypeCAA)); err == nil {
+		switch resp.Rcode {
+		case dns.RcodeNameError:
+			return nil, nil
+		case dns.RcodeSuccess:
+			return Answers[*dns.CAA](resp), nil
+		}
+	}
+	return nil, fmt.Errorf("CAAPolicy: the %s CAA lookup failed", name)
+}
+
+// Adds the normalized issuer domain name of the property value when it is not already present.
+func appendIssuer(issuers []string, value string) []string {
+	domain, _, _ := strings.Cut(value, ";")
+
+	if issuer := normalizeIssuer(domain); issuer != "" && !slices.Contains(issuers, issuer) {
+		issuers = append(issuers, issuer)
+	}
+	return issuers
+}
+
+func normalizeIssuer(issuer string) string {
+	return strings.ToLower(RemoveLastDot(strings.TrimSpace(issuer)))
+}
@@ -0,0 +1,93 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestCAAPolicy(t *testing.T) {
+	records := map[string][]string{
+		"caa.net.": {
+			`caa.net. 300 IN CAA 0 issue "LetsEncrypt.org; accounturi=https://acme-v02.api.letsencrypt.org/acme/acct/1"`,
+			`caa.net. 300 IN CAA 0 issue "pki.goog."`,
+			`caa.net. 300 IN CAA 0 issue "letsencrypt.org"`,
+			`caa.net. 300 IN CAA 0 issuewild ";"`,
+			`caa.net. 300 IN CAA 0 iodef "mailto:security@caa.net"`,
+		},
+		"shop.caa.net.": {`shop.caa.net. 300 IN CAA 0 issue "digicert.com"`},
+	}
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc("caa.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		switch name := req.Question[0].Name; name {
+		case "fail.caa.net.":
+			m.Rcode = dns.RcodeServerFailure
+		case "missing.caa.net.":
+			m.Rcode = dns.RcodeNameError
+		default:
+			for _, s := range records[name] {
+				if rr, err := dns.NewRR(s); err == nil {
+					m.Answer = append(m.Answer, rr)
+				}
+			}
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	mux.HandleFunc("org.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	policy, err := r.CAAPolicy(context.Background(), "www.dev.caa.net")
+	if err != nil {
+		t.Fatalf("the CAA lookup failed: %v", err)
+	}
+	if policy.Name != "caa.net" || len(policy.Records) != 5 {
+		t.Errorf("the relevant record set was not found: %+v", policy)
+	}
+	if !reflect.DeepEqual(policy.Issuers, []string{"letsencrypt.org", "pki.goog"}) {
+		t.Errorf("unexpected issuers: %v", policy.Issuers)
+	}
+	if len(policy.WildcardIssuers) != 0 || len(policy.IODEF) != 1 {
+		t.Errorf("unexpected properties: %+v", policy)
+	}
+	if !policy.Permits("LetsEncrypt.org.", false) || policy.Permits("letsencrypt.org", true) || policy.Permits("digicert.com", false) {
+		t.Errorf("the issuance was not evaluated correctly")
+	}
+
+	if policy, err := r.CAAPolicy(context.Background(), "shop.caa.net"); err != nil || policy.Name != "shop.caa.net" ||
+		!policy.Permits("digicert.com", true) || policy.Permits("letsencrypt.org", false) {
+		t.Errorf("the record set at the name was not used: %+v %v", policy, err)
+	}
+	if policy, err := r.CAAPolicy(context.Background(), "missing.caa.net"); err != nil || policy.Name != "caa.net" {
+		t.Errorf("the lookup did not climb past the nonexistent name: %+v %v", policy, err)
+	}
+	if _, err := r.CAAPolicy(context.Background(), "www.fail.caa.net"); err == nil {
+		t.Errorf("the failed lookup was not reported")
+	}
+	if policy, err := r.CAAPolicy(context.Background(), "www.other.org"); err != nil || policy.Name != "" || !policy.Permits("letsencrypt.org", true) {
+		t.Errorf("unexpected policy for a name without CAA records: %+v %v", policy, err)
+	}
+}
@@ -0,0 +1,420 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultCacheLoadTTL is how long the entries loaded from the output of a previous run are used.
+const DefaultCacheLoadTTL = 24 * time.Hour
+
+// TransportCache is reported by the ResponseSource of the responses answered from the cache.
+const TransportCache = "cache"
+
+// Cache holds the positive and negative responses obtained by the pool, so repeated queries
+// are answered without being sent. Answers expire by their TTLs, while NXDOMAIN and NODATA
+// responses expire by the SOA record in the authority section, and are not cached without one.
+// The whole responses are kept, including the DNSSEC records of the authority section, and the
+// responses to queries with the DO or CD bits set are kept apart from the others. The TTLs of
+// the responses can be clamped by SetTTLLimits. The queries sent to observe changing answers,
+// such as by QueryMerged, WatchSOA and the RequeryScheduler, are never answered from the cache.
+type Cache struct {
+	sync.Mutex
+	entries map[cacheKey]*cacheEntry
+	loadTTL time.Duration
+	minTTL  time.Duration
+	maxTTL  time.Duration
+}
+
+// The context key marking the queries that must not be answered from the cache.
+type bypassCacheKey struct{}
+
+// The NXDOMAIN responses are cached for the name using the TypeNone query type.
+type cacheKey struct {
+	name  string
+	qtype uint16
+	do    bool
+	cd    bool
+}
+
+type cacheEntry struct {
+	msg     *dns.Msg
+	expires time.Time
+}
+
+// Returns the key for the name of the message and the query type, with the DO and CD bits
+// of the message.
+func cacheKeyFor(msg *dns.Msg, qtype uint16) cacheKey {
+	key := cacheKey{
+		name:  strings.ToLower(msg.Question[0].Name),
+		qtype: qtype,
+		cd:    msg.CheckingDisabled,
+	}
+	if opt := msg.IsEdns0(); opt != nil {
+		key.do = opt.Do()
+	}
+	return key
+}
+
+// NewCache returns an empty response cache.
+func NewCache() *Cache {
+	return &Cache{
+		entries: make(map[cacheKey]*cacheEntry),
+		loadTTL: DefaultCacheLoadTTL,
+	}
+}
+
+// SetCache assigns the cache used to answer queries and store the responses obtained by the pool.
+func (r *Resolvers) SetCache(c *Cache) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.cache = c
+}
+
+func (r *Resolvers) getCache() *Cache {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.cache
+}
+
+// Returns a context causing the queries sent using it to bypass the cache, for the features
+// observing how the answers change. The responses obtained are still added to the cache.
+func withoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+// Returns the response from the cache for the query, or nil when the context requires the query
+// to be sent.
+func (r *Resolvers) cachedResponse(ctx context.Context, msg *dns.Msg) *dns.Msg {
+	if ctx.Value(bypassCacheKey{}) != nil {
+		return nil
+	}
+	return r.getCache().Lookup(msg)
+}
+
+// SetLoadTTL sets how long the entries added by Load are used before they expire.
+func (c *Cache) SetLoadTTL(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.loadTTL = d
+}
+
+// SetTTLLimits sets the minimum and maximum time the responses added to the cache are kept,
+// like the cache-min-ttl and cache-max-ttl options of unbound. Responses with a shorter TTL
+// are kept for the minimum, including those with a TTL of zero, and responses with a longer
+// TTL expire after the maximum. The TTLs of the answers returned by Lookup are raised or
+// lowered to match. A zero value leaves the TTLs unchanged at that end of the range.
+func (c *Cache) SetTTLLimits(min, max time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.minTTL = min
+	c.maxTTL = max
+}
+
+// Returns the TTL clamped to the limits set for the cache.
+func (c *Cache) clamp(ttl time.Duration) time.Duration {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.minTTL > 0 && ttl < c.minTTL {
+		ttl = c.minTTL
+	}
+	if c.maxTTL > 0 && ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+	return ttl
+}
+
+// Len returns the number of entries in the cache, including those that have expired.
+func (c *Cache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return len(c.entries)
+}
+
+// Add stores the NOERROR or NXDOMAIN response in the cache. The DO and CD bits of the response,
+// which are copied from the query, select the entry.
+func (c *Cache) Add(msg *dns.Msg) {
+	if c == nil || ValidateMsg(msg) != nil {
+		return
+	}
+
+	q := msg.Question[0]
+	switch msg.Rcode {
+	case dns.RcodeNameError:
+		if ttl, ok := negativeTTL(msg); ok {
+			c.put(cacheKeyFor(msg, dns.TypeNone), c.stored(msg), c.clamp(ttl))
+		}
+	case dns.RcodeSuccess:
+		if ttl, ok := MinimumTTL(msg); ok {
+			c.put(cacheKeyFor(msg, q.Qtype), c.stored(msg), c.clamp(time.Duration(ttl)*time.Second))
+		} else if ttl, ok := negativeTTL(msg); ok {
+			c.put(cacheKeyFor(msg, q.Qtype), c.stored(msg), c.clamp(ttl))
+		}
+	}
+}
+
+// Returns a copy of the response with the TTLs of the records clamped to the limits of the cache.
+func (c *Cache) stored(msg *dns.Msg) *dns.Msg {
+	cp := msg.Copy()
+
+	for _, section := range [][]dns.RR{cp.Answer, cp.Ns, cp.Extra} {
+		for _, rr := range section {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				continue
+			}
+			t := c.clamp(time.Duration(rr.Header().Ttl) * time.Second)
+			rr.Header().Ttl = uint32(t / time.Second)
+		}
+	}
+	return cp
+}
+
+func (c *Cache) put(key cacheKey, msg *dns.Msg, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.entries[key] = &cacheEntry{
+		msg:     msg,
+		expires: time.Now().Add(ttl),
+	}
+}
+
+// Lookup returns the response from the cache for the query, or nil when no entry is current.
+// The TTLs of the records are reduced to the time remaining before the entry expires.
+func (c *Cache) Lookup(msg *dns.Msg) *dns.Msg {
+	if c == nil || ValidateMsg(msg) != nil {
+		return nil
+	}
+
+	now := time.Now()
+	c.Lock()
+	defer c.Unlock()
+
+	e := c.current(cacheKeyFor(msg, dns.TypeNone), now)
+	if e == nil {
+		if e = c.current(cacheKeyFor(msg, msg.Question[0].Qtype), now); e == nil {
+			return nil
+		}
+	}
+
+	resp := e.msg.Copy()
+	resp.Id = msg.Id
+	resp.Question = []dns.Question{msg.Question[0]}
+	ttl := uint32(e.expires.Sub(now) / time.Second)
+	for _, section := range [][]dns.RR{resp.Answer, resp.Ns, resp.Extra} {
+		for _, rr := range section {
+			// The answers loaded from a previous run do not carry a TTL
+			if h := rr.Header(); h.Rrtype != dns.TypeOPT && (h.Ttl == 0 || h.Ttl > ttl) {
+				h.Ttl = ttl
+			}
+		}
+	}
+	return resp
+}
+
+// RemoveZone removes the entries for the zone and the names within it, so the queries are sent
+// again once the zone has changed. The number of entries removed is returned.
+func (c *Cache) RemoveZone(zone string) int {
+	if c == nil {
+		return 0
+	}
+	zone = strings.ToLower(dns.Fqdn(zone))
+
+	c.Lock()
+	defer c.Unlock()
+
+	var removed int
+	for key := range c.entries {
+		if dns.IsSubDomain(zone, key.name) {
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Returns the entry for the key when it has not expired. The caller must hold the lock.
+func (c *Cache) current(key cacheKey, now time.Time) *cacheEntry {
+	e, found := c.entries[key]
+	if !found {
+		return nil
+	}
+	if now.After(e.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+	return e
+}
+
+// cachedRecord contains the fields read from the JSON output written by the resolve command.
+type cachedRecord struct {
+	Name     string              `json:"name"`
+	Records  map[string][]string `json:"records"`
+	Chain    []string            `json:"chain"`
+	NXDomain bool                `json:"nxdomain"`
+	NoData   []string            `json:"nodata"`
+}
+
+// Load adds the entries found in the JSON output of a previous run, with a record for each line,
+// and returns the number of entries added. Answers are restored for the A, AAAA, CNAME, PTR,
+// NS and TXT types, since the other types are not written with all their fields. The entries
+// are used for the duration set by SetLoadTTL.
+func (c *Cache) Load(r io.Reader) (int, error) {
+	c.Lock()
+	ttl := c.loadTTL
+	c.Unlock()
+
+	var added int
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+
+		var rec cachedRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil || rec.Name == "" {
+			continue
+		}
+
+		for key, answer := range rec.entries() {
+			c.put(key, loadedMsg(key, answer), ttl)
+			added++
+		}
+	}
+	return added, scanner.Err()
+}
+
+// Returns the response stored for the entry loaded from the output of a previous run.
+func loadedMsg(key cacheKey, answer []dns.RR) *dns.Msg {
+	msg := new(dns.Msg)
+	if key.qtype == dns.TypeNone {
+		msg.SetQuestion(key.name, dns.TypeA)
+		msg.Rcode = dns.RcodeNameError
+	} else {
+		msg.SetQuestion(key.name, key.qtype)
+	}
+	msg.Response = true
+	msg.RecursionAvailable = true
+	msg.Answer = answer
+	return msg
+}
+
+// Returns the cache entries represented by the record.
+func (rec *cachedRecord) entries() map[cacheKey][]dns.RR {
+	name := strings.ToLower(dns.Fqdn(rec.Name))
+	entries := make(map[cacheKey][]dns.RR)
+
+	if rec.NXDomain {
+		entries[cacheKey{name: name, qtype: dns.TypeNone}] = nil
+		return entries
+	}
+	for _, t := range rec.NoData {
+		if qtype, found := dns.StringToType[t]; found {
+			entries[cacheKey{name: name, qtype: qtype}] = nil
+		}
+	}
+
+	// The alias chain identifies the owner of the records obtained through the CNAME records
+	owner := name
+	var aliases []dns.RR
+	for i := 0; i+1 < len(rec.Chain); i++ {
+		aliases = append(aliases, &dns.CNAME{
+			Hdr:    dns.RR_Header{Name: dns.Fqdn(rec.Chain[i]), Rrtype: dns.TypeCNAME, Class: dns.ClassINET},
+			Target: dns.Fqdn(rec.Chain[i+1]),
+		})
+		owner = dns.Fqdn(rec.Chain[i+1])
+	}
+	if _, found := rec.Records["CNAME"]; found && len(aliases) == 0 {
+		// Without the chain, the owners of the other records cannot be determined
+		for _, target := range rec.Records["CNAME"] {
+			aliases = append(aliases, &dns.CNAME{
+				Hdr:    dns.RR_Header{Name: name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET},
+				Target: dns.Fqdn(target),
+			})
+		}
+		entries[cacheKey{name: name, qtype: dns.TypeCNAME}] = aliases
+		return entries
+	}
+	if len(aliases) > 0 {
+		entries[cacheKey{name: name, qtype: dns.TypeCNAME}] = aliases[:1]
+	}
+
+	for t, values := range rec.Records {
+		qtype, found := dns.StringToType[t]
+		if !found || qtype == dns.TypeCNAME {
+			continue
+		}
+
+		var answer []dns.RR
+		for _, v := range values {
+			if rr := cachedRR(owner, qtype, v); rr != nil {
+				answer = append(answer, rr)
+			}
+		}
+		if len(answer) > 0 {
+			entries[cacheKey{name: name, qtype: qtype}] = append(append([]dns.RR(nil), aliases...), answer...)
+		}
+	}
+	return entries
+}
+
+// Returns the resource record restored from the data written for the type, or nil when not supported.
+func cachedRR(owner string, qtype uint16, data string) dns.RR {
+	hdr := dns.RR_Header{Name: owner, Rrtype: qtype, Class: dns.ClassINET}
+
+	switch qtype {
+	case dns.TypeA:
+		if ip := net.ParseIP(data).To4(); ip != nil {
+			return &dns.A{Hdr: hdr, A: ip}
+		}
+	case dns.TypeAAAA:
+		if ip := net.ParseIP(data); ip != nil && ip.To4() == nil {
+			return &dns.AAAA{Hdr: hdr, AAAA: ip}
+		}
+	case dns.TypePTR:
+		return &dns.PTR{Hdr: hdr, Ptr: dns.Fqdn(data)}
+	case dns.TypeNS:
+		return &dns.NS{Hdr: hdr, Ns: dns.Fqdn(data)}
+	case dns.TypeTXT:
+		return &dns.TXT{Hdr: hdr, Txt: []string{data}}
+	}
+	return nil
+}
+
+// Returns the negative caching TTL from the SOA record in the authority section (RFC 2308).
+func negativeTTL(msg *dns.Msg) (time.Duration, bool) {
+	soas := Records[*dns.SOA](msg.Ns)
+	if len(soas) == 0 {
+		return 0, false
+	}
+
+	ttl := soas[0].Hdr.Ttl
+	if soas[0].Minttl < ttl {
+		ttl = soas[0].Minttl
+	}
+	return time.Duration(ttl) * time.Second, true
+}
@@ -0,0 +1,260 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func testSOARecord(zone string, ttl, minttl uint32) *dns.SOA {
+	return &dns.SOA{
+		Hdr:    dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: ttl},
+		Ns:     "ns1." + zone,
+		Mbox:   "hostmaster." + zone,
+		Minttl: minttl,
+	}
+}
+
+func TestCacheAdd(t *testing.T) {
+	c := NewCache()
+
+	answer := new(dns.Msg).SetReply(QueryMsg("www.owasp.org", dns.TypeA))
+	rr := testARecord("www.owasp.org.", "192.168.1.1")
+	rr.Hdr.Ttl = 300
+	answer.Answer = append(answer.Answer, rr)
+	c.Add(answer)
+
+	nx := new(dns.Msg).SetRcode(QueryMsg("bad.owasp.org", dns.TypeA), dns.RcodeNameError)
+	nx.Ns = append(nx.Ns, testSOARecord("owasp.org.", 3600, 60))
+	c.Add(nx)
+
+	// Negative responses without the SOA record are not cached
+	c.Add(new(dns.Msg).SetRcode(QueryMsg("other.owasp.org", dns.TypeA), dns.RcodeNameError))
+	c.Add(new(dns.Msg).SetRcode(QueryMsg("fail.owasp.org", dns.TypeA), dns.RcodeServerFailure))
+	if n := c.Len(); n != 2 {
+		t.Errorf("Expected 2 entries in the cache, got %d", n)
+	}
+
+	resp := c.Lookup(QueryMsg("WWW.owasp.org", dns.TypeA))
+	if ans := ExtractAnswers(resp); len(ans) != 1 || ans[0].Data != "192.168.1.1" || ans[0].TTL > 300 {
+		t.Errorf("The cached answer was not returned as expected")
+	}
+	if resp := c.Lookup(QueryMsg("www.owasp.org", dns.TypeAAAA)); resp != nil {
+		t.Errorf("A response was returned for a query type that was not cached")
+	}
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeTXT} {
+		if resp := c.Lookup(QueryMsg("bad.owasp.org", qtype)); resp == nil || resp.Rcode != dns.RcodeNameError {
+			t.Errorf("The NXDOMAIN response was not returned for the %s query", dns.TypeToString[qtype])
+		}
+	}
+
+	c.Lock()
+	c.entries[cacheKey{name: "www.owasp.org.", qtype: dns.TypeA}].expires = time.Now().Add(-time.Second)
+	c.Unlock()
+	if resp := c.Lookup(QueryMsg("www.owasp.org", dns.TypeA)); resp != nil || c.Len() != 1 {
+		t.Errorf("The expired entry was returned or kept")
+	}
+}
+
+func TestCacheTTLLimits(t *testing.T) {
+	c := NewCache()
+	c.SetTTLLimits(time.Minute, time.Hour)
+
+	zero := new(dns.Msg).SetReply(QueryMsg("zero.owasp.org", dns.TypeA))
+	zero.Answer = append(zero.Answer, testARecord("zero.owasp.org.", "192.168.1.1"))
+	c.Add(zero)
+
+	long := new(dns.Msg).SetReply(QueryMsg("long.owasp.org", dns.TypeA))
+	rr := testARecord("long.owasp.org.", "192.168.1.2")
+	rr.Hdr.Ttl = 604800
+	long.Answer = append(long.Answer, rr)
+	c.Add(long)
+
+	nx := new(dns.Msg).SetRcode(QueryMsg("bad.owasp.org", dns.TypeA), dns.RcodeNameError)
+	nx.Ns = append(nx.Ns, testSOARecord("owasp.org.", 3600, 0))
+	c.Add(nx)
+
+	if n := c.Len(); n != 3 {
+		t.Fatalf("Expected 3 entries in the cache, got %d", n)
+	}
+	if ans := ExtractAnswers(c.Lookup(QueryMsg("zero.owasp.org", dns.TypeA))); len(ans) != 1 || ans[0].TTL < 59 || ans[0].TTL > 60 {
+		t.Errorf("The TTL of zero was not raised to the minimum")
+	}
+	if ans := ExtractAnswers(c.Lookup(QueryMsg("long.owasp.org", dns.TypeA))); len(ans) != 1 || ans[0].TTL > 3600 {
+		t.Errorf("The long TTL was not lowered to the maximum")
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	for key, e := range c.entries {
+		if remaining := time.Until(e.expires); remaining < 59*time.Second || remaining > time.Hour {
+			t.Errorf("The entry for %s expires in %v, outside of the limits", key.name, remaining)
+		}
+	}
+}
+
+func TestCacheLoad(t *testing.T) {
+	output := `{"name":"www.owasp.org","records":{"A":["192.168.1.1"],"CNAME":["owasp.org"]},"chain":["www.owasp.org","owasp.org"],"nodata":["AAAA"]}
+{"name":"bad.owasp.org","records":{},"nxdomain":true}
+;; not a JSON line
+{"name":"mail.owasp.org","records":{"MX":["mx.owasp.org"]}}
+{"name":"txt.owasp.org","records":{"TXT":["v=spf1 -all"]}}
+`
+	c := NewCache()
+	c.SetLoadTTL(time.Hour)
+
+	added, err := c.Load(strings.NewReader(output))
+	if err != nil {
+		t.Fatalf("Failed to load the output: %v", err)
+	}
+	// The A, CNAME and AAAA entries, the NXDOMAIN entry and the TXT entry
+	if added != 5 {
+		t.Errorf("Expected 5 entries loaded, got %d", added)
+	}
+
+	resp := c.Lookup(QueryMsg("www.owasp.org", dns.TypeA))
+	if resp == nil || len(resp.Answer) != 2 {
+		t.Fatalf("The loaded answer with the alias was not returned")
+	}
+	if cname, ok := resp.Answer[0].(*dns.CNAME); !ok || cname.Target != "owasp.org." {
+		t.Errorf("The alias was not restored as expected: %v", resp.Answer[0])
+	}
+	if a, ok := resp.Answer[1].(*dns.A); !ok || a.Hdr.Name != "owasp.org." || a.Hdr.Ttl == 0 {
+		t.Errorf("The address was not restored as expected: %v", resp.Answer[1])
+	}
+	if resp := c.Lookup(QueryMsg("www.owasp.org", dns.TypeAAAA)); resp == nil || resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+		t.Errorf("The NODATA response was not restored")
+	}
+	if resp := c.Lookup(QueryMsg("bad.owasp.org", dns.TypeA)); resp == nil || resp.Rcode != dns.RcodeNameError {
+		t.Errorf("The NXDOMAIN response was not restored")
+	}
+	if resp := c.Lookup(QueryMsg("mail.owasp.org", dns.TypeMX)); resp != nil {
+		t.Errorf("The incomplete MX record was restored")
+	}
+	if ans := ExtractAnswers(c.Lookup(QueryMsg("txt.owasp.org", dns.TypeTXT))); len(ans) != 1 || ans[0].Data != "v=spf1 -all" {
+		t.Errorf("The TXT record was not restored as expected")
+	}
+}
+
+func TestPoolCache(t *testing.T) {
+	dns.HandleFunc("cache.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg).SetReply(req)
+		rr := testARecord(req.Question[0].Name, "192.168.1.1")
+		rr.Hdr.Ttl = 300
+		m.Answer = append(m.Answer, rr)
+		_ = w.WriteMsg(m)
+	})
+	defer dns.HandleRemove("cache.net.")
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	c := NewCache()
+	r.SetCache(c)
+
+	first, err := r.QueryBlocking(context.Background(), QueryMsg("cache.net", dns.TypeA))
+	if err != nil || len(first.Answer) == 0 {
+		t.Fatalf("The query failed: %v", err)
+	}
+	if src := SourceOf(first); src == nil || src.Transport != TransportUDP {
+		t.Errorf("The first response was not obtained from the resolver")
+	}
+
+	second, err := r.QueryBlocking(context.Background(), QueryMsg("cache.net", dns.TypeA))
+	if err != nil {
+		t.Fatalf("The query failed: %v", err)
+	}
+	if src := SourceOf(second); src == nil || src.Transport != TransportCache {
+		t.Errorf("The second response was not answered from the cache")
+	}
+	if ans := ExtractAnswers(second); len(ans) != 1 || ans[0].Data != "192.168.1.1" {
+		t.Errorf("The cached response did not contain the answer")
+	}
+}
+
+func TestCacheDNSSEC(t *testing.T) {
+	c := NewCache()
+
+	query := QueryMsg("www.owasp.org", dns.TypeAAAA)
+	SetDNSSECOK(query)
+	nodata := new(dns.Msg).SetReply(query)
+	nodata.SetEdns0(dns.DefaultMsgSize, true)
+	nodata.Ns = append(nodata.Ns, testSOARecord("owasp.org.", 3600, 300), &dns.NSEC{
+		Hdr:        dns.RR_Header{Name: "www.owasp.org.", Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 300},
+		NextDomain: "zzz.owasp.org.",
+		TypeBitMap: []uint16{dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC},
+	})
+	c.Add(nodata)
+
+	// The responses to queries with the DO bit are not returned for the other queries
+	if resp := c.Lookup(QueryMsg("www.owasp.org", dns.TypeAAAA)); resp != nil {
+		t.Errorf("The response to the DNSSEC query was returned without the DO bit")
+	}
+	cd := QueryMsg("www.owasp.org", dns.TypeAAAA)
+	SetDNSSECOK(cd)
+	cd.CheckingDisabled = true
+	if resp := c.Lookup(cd); resp != nil {
+		t.Errorf("The response was returned for a query with the CD bit")
+	}
+
+	again := QueryMsg("WWW.owasp.org", dns.TypeAAAA)
+	SetDNSSECOK(again)
+	resp := c.Lookup(again)
+	if resp == nil || resp.Id != again.Id || resp.Question[0].Name != "WWW.owasp.org." {
+		t.Fatalf("The response was not returned for the matching query")
+	}
+	// The authority section is kept, so the proofs are available from the cache
+	if len(resp.Ns) != 2 || resp.Ns[1].Header().Rrtype != dns.TypeNSEC || resp.Ns[1].Header().Ttl > 300 {
+		t.Errorf("The authority section was not returned from the cache: %v", resp.Ns)
+	}
+}
+
+func TestCacheBypass(t *testing.T) {
+	var serial uint32
+	dns.HandleFunc("bypass.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg).SetReply(req)
+		soa := testSOARecord("bypass.net.", 300, 300)
+		serial++
+		soa.Serial = serial
+		m.Answer = append(m.Answer, soa)
+		_ = w.WriteMsg(m)
+	})
+	defer dns.HandleRemove("bypass.net.")
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	r.SetCache(NewCache())
+	defer r.Stop()
+
+	first, err := r.SOARecord(context.Background(), "bypass.net")
+	if err != nil {
+		t.Fatalf("Failed to obtain the SOA record: %v", err)
+	}
+	second, err := r.SOARecord(context.Background(), "bypass.net")
+	if err != nil || second.Serial == first.Serial {
+		t.Errorf("The SOA record was answered from the cache")
+	}
+	if r.getCache().Len() != 1 {
+		t.Errorf("The SOA response was not added to the cache")
+	}
+}
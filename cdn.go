@@ -0,0 +1,82 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// CDNSuffixes maps the domain name suffixes of well-known CDN services to a normalized provider
+// label. Entries can be added or replaced before the package functions are used.
+var CDNSuffixes = map[string]string{
+	"akamai.net":             "akamai",
+	"akamaiedge.net":         "akamai",
+	"akamaihd.net":           "akamai",
+	"akamaitechnologies.com": "akamai",
+	"edgekey.net":            "akamai",
+	"edgesuite.net":          "akamai",
+	"alikunlun.com":          "alibaba",
+	"cdngslb.com":            "alibaba",
+	"azureedge.net":          "azure",
+	"azurefd.net":            "azure",
+	"b-cdn.net":              "bunny",
+	"cachefly.net":           "cachefly",
+	"cdn77.org":              "cdn77",
+	"cdn.cloudflare.net":     "cloudflare",
+	"cloudfront.net":         "cloudfront",
+	"edgecastcdn.net":        "edgecast",
+	"fastly.net":             "fastly",
+	"fastlylb.net":           "fastly",
+	"gcdn.co":                "gcore",
+	"googlehosted.com":       "google",
+	"googleusercontent.com":  "google",
+	"hwcdn.net":              "highwinds",
+	"incapdns.net":           "imperva",
+	"kxcdn.com":              "keycdn",
+	"llnwd.net":              "limelight",
+	"netlify.app":            "netlify",
+	"stackpathdns.com":       "stackpath",
+	"vercel-dns.com":         "vercel",
+}
+
+// CDNProvider returns the provider label for the name when it is within one of the CDNSuffixes,
+// using the most specific suffix, or an empty string otherwise.
+func CDNProvider(name string) string {
+	name = strings.ToLower(RemoveLastDot(name))
+
+	for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+		if label, found := CDNSuffixes[name[off:]]; found {
+			return label
+		}
+	}
+	return ""
+}
+
+// CDNProviders returns the sorted provider labels for the owner names and CNAME targets in the
+// answer section of the message.
+func CDNProviders(msg *dns.Msg) []string {
+	set := make(map[string]struct{})
+
+	names := CNAMETargets(msg)
+	for _, rr := range msg.Answer {
+		names = append(names, rr.Header().Name)
+	}
+
+	for _, name := range names {
+		if label := CDNProvider(name); label != "" {
+			set[label] = struct{}{}
+		}
+	}
+
+	var labels []string
+	for label := range set {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
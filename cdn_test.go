@@ -0,0 +1,50 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestCDNProvider(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		expected string
+	}{
+		{"d1234abcd.cloudfront.net.", "cloudfront"},
+		{"E1234.A.AKAMAIEDGE.NET", "akamai"},
+		{"www.example.com.edgekey.net", "akamai"},
+		{"dualstack.fastly.net", "fastly"},
+		{"www.example.com.cdn.cloudflare.net.", "cloudflare"},
+		{"cloudflare.net", ""},
+		{"notcloudfront.net", ""},
+		{"www.caffix.net", ""},
+	} {
+		if got := CDNProvider(test.name); got != test.expected {
+			t.Errorf("CDNProvider(%s) returned %q; expected %q", test.name, got, test.expected)
+		}
+	}
+}
+
+func TestCDNProviders(t *testing.T) {
+	msg := new(dns.Msg)
+	msg.SetQuestion("www.caffix.net.", dns.TypeA)
+	msg.Answer = append(msg.Answer,
+		testCNAMERecord("www.caffix.net.", "www.caffix.net.edgekey.net."),
+		testCNAMERecord("www.caffix.net.edgekey.net.", "e1234.a.akamaiedge.net."),
+		testCNAMERecord("e1234.a.akamaiedge.net.", "d1234abcd.cloudfront.net."),
+		testARecord("d1234abcd.cloudfront.net.", "192.168.1.1"),
+	)
+
+	if got := CDNProviders(msg); !reflect.DeepEqual(got, []string{"akamai", "cloudfront"}) {
+		t.Errorf("CDNProviders returned %v; expected [akamai cloudfront]", got)
+	}
+	if got := CDNProviders(new(dns.Msg)); len(got) != 0 {
+		t.Errorf("CDNProviders returned %v for an empty message", got)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultMaxAliasDepth is the number of CNAME records followed for a name by default.
+const DefaultMaxAliasDepth int = 8
+
+// Errors returned when following alias chains that cannot be completed.
+var (
+	ErrAliasLoop  = errors.New("the alias chain contains a loop")
+	ErrAliasDepth = errors.New("the alias chain exceeds the maximum depth")
+)
+
+// SetMaxAliasDepth sets the number of CNAME records followed for a name before giving up.
+func (r *Resolvers) SetMaxAliasDepth(depth int) {
+	if depth <= 0 {
+		depth = DefaultMaxAliasDepth
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.maxDepth = depth
+}
+
+func (r *Resolvers) maxAliasDepth() int {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.maxDepth
+}
+
+// AliasChain returns the names in the CNAME chain found in the answer section of the response,
+// starting with the queried name and ending with the name owning the terminal records. The
+// names followed are returned along with an error when a loop is detected or the chain is
+// longer than the maximum depth.
+func (r *Resolvers) AliasChain(msg *dns.Msg) ([]string, error) {
+	if err := ValidateMsg(msg); err != nil {
+		return nil, err
+	}
+
+	start := strings.ToLower(RemoveLastDot(msg.Question[0].Name))
+	return appendAliases(msg, []string{start}, map[string]struct{}{start: {}}, r.maxAliasDepth())
+}
+
+// FollowAliases resolves the name for the provided type, sending additional queries for the
+// targets when the CNAME chain is not completed within a response. The full chain is returned
+// along with the response containing the terminal records.
+func (r *Resolvers) FollowAliases(ctx context.Context, name string, qtype uint16) ([]string, *dns.Msg, error) {
+	name = strings.ToLower(RemoveLastDot(name))
+	chain := []string{name}
+	seen := map[string]struct{}{name: {}}
+	depth := r.maxAliasDepth()
+
+	for {
+		resp, err := r.QueryBlocking(ctx, QueryMsg(chain[len(chain)-1], qtype))
+		if err != nil {
+			return chain, resp, err
+		}
+
+		last := len(chain)
+		if chain, err = appendAliases(resp, chain, seen, depth); err != nil {
+			return chain, resp, err
+		}
+		// Stop once the response does not add to the chain or contains the terminal records
+		if resp.Rcode != dns.RcodeSuccess || qtype == dns.TypeCNAME ||
+			len(chain) == last || hasRecordsFor(resp, chain[len(chain)-1], qtype) {
+			return chain, resp, nil
+		}
+	}
+}
+
+// Appends the CNAME targets found in the response, starting from the last name in the chain.
+func appendAliases(msg *dns.Msg, chain []string, seen map[string]struct{}, depth int) ([]string, error) {
+	for {
+		target := cnameTarget(msg, chain[len(chain)-1])
+		if target == "" {
+			return chain, nil
+		}
+		if _, found := seen[target]; found {
+			return chain, ErrAliasLoop
+		}
+		if len(chain) > depth {
+			return chain, ErrAliasDepth
+		}
+
+		seen[target] = struct{}{}
+		chain = append(chain, target)
+	}
+}
+
+// Returns true when the answer section contains records of the type owned by the name.
+func hasRecordsFor(msg *dns.Msg, name string, qtype uint16) bool {
+	for _, rr := range msg.Answer {
+		if rr.Header().Rrtype == qtype && strings.EqualFold(RemoveLastDot(rr.Header().Name), name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestAliasChain(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	msg := QueryMsg("www.caffix.net", dns.TypeA)
+	msg.Answer = append(msg.Answer, testCNAMERecord("www.caffix.net.", "cdn.caffix.net."),
+		testCNAMERecord("cdn.caffix.net.", "edge.cdn.net."), testARecord("edge.cdn.net.", "192.0.2.1"))
+
+	chain, err := r.AliasChain(msg)
+	if err != nil || strings.Join(chain, ",") != "www.caffix.net,cdn.caffix.net,edge.cdn.net" {
+		t.Errorf("unexpected chain: %v %v", chain, err)
+	}
+
+	r.SetMaxAliasDepth(1)
+	if chain, err := r.AliasChain(msg); !errors.Is(err, ErrAliasDepth) || len(chain) != 2 {
+		t.Errorf("the maximum depth was not enforced: %v %v", chain, err)
+	}
+	r.SetMaxAliasDepth(0)
+
+	loop := QueryMsg("www.caffix.net", dns.TypeA)
+	loop.Answer = append(loop.Answer, testCNAMERecord("www.caffix.net.", "cdn.caffix.net."),
+		testCNAMERecord("cdn.caffix.net.", "www.caffix.net."))
+	if chain, err := r.AliasChain(loop); !errors.Is(err, ErrAliasLoop) || len(chain) != 2 {
+		t.Errorf("the loop was not detected: %v %v", chain, err)
+	}
+
+	if chain, err := r.AliasChain(QueryMsg("www.caffix.net", dns.TypeA)); err != nil || len(chain) != 1 {
+		t.Errorf("unexpected chain for a response without aliases: %v %v", chain, err)
+	}
+	if _, err := r.AliasChain(nil); err == nil {
+		t.Errorf("AliasChain accepted a nil message")
+	}
+}
+
+func TestFollowAliases(t *testing.T) {
+	mux := dns.NewServeMux()
+	mux.HandleFunc("chain.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		// Each response only provides the next alias in the chain
+		switch name := req.Question[0].Name; {
+		case name == "loop1.chain.net.":
+			m.Answer = append(m.Answer, testCNAMERecord(name, "loop2.chain.net."))
+		case name == "loop2.chain.net.":
+			m.Answer = append(m.Answer, testCNAMERecord(name, "loop1.chain.net."))
+		case strings.HasPrefix(name, "a"):
+			var n int
+			_, _ = fmt.Sscanf(name, "a%d.", &n)
+			if n < 10 {
+				m.Answer = append(m.Answer, testCNAMERecord(name, fmt.Sprintf("a%d.chain.net.", n+1)))
+			} else {
+				m.Answer = append(m.Answer, testARecord(name, "192.0.2.1"))
+			}
+		default:
+			m.Rcode = dns.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	chain, resp, err := r.FollowAliases(context.Background(), "a7.chain.net", dns.TypeA)
+	if err != nil || strings.Join(chain, ",") != "a7.chain.net,a8.chain.net,a9.chain.net,a10.chain.net" {
+		t.Fatalf("unexpected chain: %v %v", chain, err)
+	}
+	if ips := IPs(resp); len(ips) != 1 || ips[0].String() != "192.0.2.1" {
+		t.Errorf("the terminal records were not returned: %v", resp)
+	}
+
+	if chain, _, err := r.FollowAliases(context.Background(), "a1.chain.net", dns.TypeA); !errors.Is(err, ErrAliasDepth) || len(chain) != DefaultMaxAliasDepth+1 {
+		t.Errorf("the maximum depth was not enforced: %v %v", chain, err)
+	}
+	if chain, _, err := r.FollowAliases(context.Background(), "loop1.chain.net", dns.TypeA); !errors.Is(err, ErrAliasLoop) || len(chain) != 2 {
+		t.Errorf("the loop was not detected: %v %v", chain, err)
+	}
+	if chain, resp, err := r.FollowAliases(context.Background(), "b.chain.net", dns.TypeA); err != nil || len(chain) != 1 || resp.Rcode != dns.RcodeNameError {
+		t.Errorf("unexpected result for a name without aliases: %v %v", chain, err)
+	}
+}
+
+func testCNAMERecord(name, target string) *dns.CNAME {
+	return &dns.CNAME{
+		Hdr: dns.RR_Header{
+			Name:   name,
+			Rrtype: dns.TypeCNAME,
+			Class:  dns.ClassINET,
+		},
+		Target: target,
+	}
+}
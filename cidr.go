@@ -0,0 +1,111 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"sort"
+)
+
+// CIDRSummary is a network block along with the number of unique addresses observed within it.
+type CIDRSummary struct {
+	Network *net.IPNet
+	Count   int
+}
+
+// SummarizeCIDRs groups the unique IP addresses into blocks with the provided IPv4 and IPv6
+// prefix lengths, and then merges adjacent blocks into the minimal set of CIDR blocks covering them.
+// The summaries are returned sorted by network address.
+func SummarizeCIDRs(ips []net.IP, v4bits, v6bits int) ([]*CIDRSummary, error) {
+	if v4bits < 0 || v4bits > 32 || v6bits < 0 || v6bits > 128 {
+		return nil, errors.New("SummarizeCIDRs: the prefix lengths are out of range")
+	}
+
+	seen := make(map[netip.Addr]struct{}, len(ips))
+	blocks := make(map[netip.Prefix]int)
+	for _, ip := range ips {
+		addr, ok := netip.AddrFromSlice(ip)
+		if !ok {
+			continue
+		}
+
+		addr = addr.Unmap()
+		if _, found := seen[addr]; found {
+			continue
+		}
+		seen[addr] = struct{}{}
+
+		bits := v6bits
+		if addr.Is4() {
+			bits = v4bits
+		}
+		if p, err := addr.Prefix(bits); err == nil {
+			blocks[p]++
+		}
+	}
+
+	// Merge the sibling blocks into their parent until no more can be combined
+	for merged := true; merged; {
+		merged = false
+
+		for p, count := range blocks {
+			if p.Bits() == 0 {
+				continue
+			}
+
+			sibling := siblingPrefix(p)
+			scount, found := blocks[sibling]
+			if !found {
+				continue
+			}
+
+			parent, _ := p.Addr().Prefix(p.Bits() - 1)
+			delete(blocks, p)
+			delete(blocks, sibling)
+			blocks[parent] = count + scount
+			merged = true
+		}
+	}
+
+	var summaries []*CIDRSummary
+	for p, count := range blocks {
+		summaries = append(summaries, &CIDRSummary{
+			Network: &net.IPNet{
+				IP:   net.IP(p.Addr().AsSlice()),
+				Mask: net.CIDRMask(p.Bits(), p.Addr().BitLen()),
+			},
+			Count: count,
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i].Network, summaries[j].Network
+		if len(a.IP) != len(b.IP) {
+			return len(a.IP) < len(b.IP)
+		}
+		return bytesLess(a.IP, b.IP)
+	})
+	return summaries, nil
+}
+
+// Returns the prefix with the same length that shares the parent block with the provided prefix.
+func siblingPrefix(p netip.Prefix) netip.Prefix {
+	b := p.Addr().AsSlice()
+	bit := p.Bits() - 1
+
+	b[bit/8] ^= 0x80 >> (bit % 8)
+	addr, _ := netip.AddrFromSlice(b)
+	return netip.PrefixFrom(addr, p.Bits())
+}
+
+func bytesLess(a, b []byte) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestSummarizeCIDRs(t *testing.T) {
+	var ips []net.IP
+	for _, addr := range []string{
+		"192.168.0.1", "192.168.0.2", "192.168.1.1", "192.168.1.1",
+		"192.168.3.7", "10.0.0.1", "2001:db8::1", "2001:db8:0:1::1", "::ffff:10.0.0.2",
+	} {
+		ips = append(ips, net.ParseIP(addr))
+	}
+
+	summaries, err := SummarizeCIDRs(ips, 24, 64)
+	if err != nil {
+		t.Fatalf("failed to summarize the addresses: %v", err)
+	}
+
+	var got []string
+	for _, s := range summaries {
+		got = append(got, fmt.Sprintf("%s %d", s.Network, s.Count))
+	}
+
+	expected := []string{"10.0.0.0/24 2", "192.168.0.0/23 3", "192.168.3.0/24 1", "2001:db8::/63 2"}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Got: %v; Expected: %v", got, expected)
+	}
+
+	if _, err := SummarizeCIDRs(ips, 33, 64); err == nil {
+		t.Errorf("an invalid IPv4 prefix length was accepted")
+	}
+	if summaries, err := SummarizeCIDRs(nil, 24, 64); err != nil || len(summaries) != 0 {
+		t.Errorf("summaries were returned without any addresses")
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitBreakerOptions determine when the pool stops sending queries because most of them
+// are timing out, such as during a network outage, and how the network is probed afterwards.
+type CircuitBreakerOptions struct {
+	// Threshold is the fraction of the requests in a window that timed out to open the circuit.
+	Threshold float64
+	// MinSamples is the number of requests that must complete in a window before the timeout
+	// rate is considered.
+	MinSamples int
+	// Window is the period after which the counts of completed requests are discarded.
+	Window time.Duration
+	// ProbeBase is the delay before the first probe, which doubles after each failed probe.
+	ProbeBase time.Duration
+	// ProbeMax is the longest delay between the probes.
+	ProbeMax time.Duration
+}
+
+// DefaultCircuitBreakerOptions opens the circuit when 90% of at least 100 requests completing
+// within a ten second window timed out, and probes the network after one second, backing off
+// up to a minute between the probes.
+var DefaultCircuitBreakerOptions = CircuitBreakerOptions{
+	Threshold:  0.9,
+	MinSamples: 100,
+	Window:     10 * time.Second,
+	ProbeBase:  time.Second,
+	ProbeMax:   time.Minute,
+}
+
+// circuitBreaker pauses the requests leaving the pool queue while the circuit is open, and
+// allows a single probe request through at exponentially increasing intervals. The circuit is
+// closed by the first response received.
+type circuitBreaker struct {
+	sync.Mutex
+	opts      *CircuitBreakerOptions
+	backoff   *Backoff
+	start     time.Time
+	timeouts  int
+	responses int
+	open      bool
+	probing   bool
+	probeSent time.Time
+	failed    int
+	next      time.Time
+	trips     uint64
+}
+
+func newCircuitBreaker() *circuitBreaker {
+	return new(circuitBreaker)
+}
+
+// SetCircuitBreaker enables the circuit breaker of the pool using the provided options, or
+// disables it when the options are nil. While the circuit is open, queued queries wait instead
+// of being sent and retried into a network that is not responding. The pools created by Clone
+// share the circuit breaker.
+func (r *Resolvers) SetCircuitBreaker(opts *CircuitBreakerOptions) {
+	b := r.breaker
+	b.Lock()
+	defer b.Unlock()
+
+	b.opts = nil
+	b.open = false
+	b.probing = false
+	if opts == nil || opts.Threshold <= 0 || opts.Window <= 0 {
+		return
+	}
+
+	o := *opts
+	if o.ProbeBase <= 0 {
+		o.ProbeBase = DefaultCircuitBreakerOptions.ProbeBase
+	}
+	if o.ProbeMax < o.ProbeBase {
+		o.ProbeMax = o.ProbeBase
+	}
+	b.opts = &o
+	b.backoff = NewBackoff(o.ProbeBase, o.ProbeMax, NoJitter)
+	b.reset(time.Now())
+}
+
+// CircuitOpen returns true while the circuit breaker is holding the queries of the pool.
+func (r *Resolvers) CircuitOpen() bool {
+	r.breaker.Lock()
+	defer r.breaker.Unlock()
+
+	return r.breaker.open
+}
+
+// CircuitTrips returns the number of times the circuit breaker has opened.
+func (r *Resolvers) CircuitTrips() uint64 {
+	r.breaker.Lock()
+	defer r.breaker.Unlock()
+
+	return r.breaker.trips
+}
+
+// wait blocks while the circuit is open, until the request can be sent as the probe, and
+// returns false when the pool is stopped or the context of the request expires first.
+func (b *circuitBreaker) wait(req *request, done chan struct{}) bool {
+	for {
+		d, ok := b.admit(req, time.Now())
+		if ok {
+			return true
+		}
+
+		t := time.NewTimer(d)
+		select {
+		case <-done:
+			t.Stop()
+			return false
+		case <-req.context().Done():
+			t.Stop()
+			return false
+		case <-t.C:
+		}
+	}
+}
+
+// admit returns true when the request can be sent, or the time to wait before checking again.
+func (b *circuitBreaker) admit(req *request, now time.Time) (time.Duration, bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.opts == nil || !b.open {
+		return 0, true
+	}
+	// A probe that was dropped before completing is considered failed
+	if b.probing && now.Sub(b.probeSent) > b.opts.ProbeMax {
+		b.probeFailed(now)
+	}
+	if b.probing {
+		return b.opts.ProbeBase, false
+	}
+	if now.Before(b.next) {
+		return b.next.Sub(now), false
+	}
+
+	b.probing = true
+	b.probeSent = now
+	req.probe = true
+	return 0, true
+}
+
+// success records a response received for a request, which closes an open circuit.
+func (b *circuitBreaker) success() {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.opts == nil {
+		return
+	}
+
+	now := time.Now()
+	if b.open {
+		b.open = false
+		b.probing = false
+		b.failed = 0
+		b.reset(now)
+		return
+	}
+	b.responses++
+	b.evaluate(now)
+}
+
+// failure records a request that expired without a response.
+func (b *circuitBreaker) failure(req *request) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.opts == nil {
+		return
+	}
+
+	now := time.Now()
+	if b.open {
+		if req.probe && b.probing {
+			b.probeFailed(now)
+		}
+		return
+	}
+	b.timeouts++
+	b.evaluate(now)
+}
+
+// Opens the circuit when enough requests completed in the window and the timeout rate
+// reached the threshold, and starts a new window once it has elapsed. The caller must
+// hold the lock.
+func (b *circuitBreaker) evaluate(now time.Time) {
+	total := b.timeouts + b.responses
+	if total > 0 && total >= b.opts.MinSamples && float64(b.timeouts)/float64(total) >= b.opts.Threshold {
+		b.open = true
+		b.trips++
+		b.failed = 0
+		b.next = now.Add(b.backoff.NextDelay(0))
+		b.reset(now)
+		return
+	}
+	if now.Sub(b.start) >= b.opts.Window {
+		b.reset(now)
+	}
+}
+
+// Schedules the next probe after a failed probe. The caller must hold the lock.
+func (b *circuitBreaker) probeFailed(now time.Time) {
+	b.probing = false
+	b.failed++
+	b.next = now.Add(b.backoff.NextDelay(b.failed))
+}
+
+// Starts a new window for computing the timeout rate. The caller must hold the lock.
+func (b *circuitBreaker) reset(now time.Time) {
+	b.start = now
+	b.timeouts = 0
+	b.responses = 0
+}
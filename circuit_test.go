@@ -0,0 +1,124 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestCircuitBreakerStates(t *testing.T) {
+	r := &Resolvers{breaker: newCircuitBreaker()}
+	r.SetCircuitBreaker(&CircuitBreakerOptions{
+		Threshold:  0.5,
+		MinSamples: 4,
+		Window:     time.Hour,
+		ProbeBase:  time.Second,
+		ProbeMax:   4 * time.Second,
+	})
+	b := r.breaker
+
+	b.success()
+	b.failure(new(request))
+	b.failure(new(request))
+	if r.CircuitOpen() {
+		t.Fatalf("the circuit opened before the minimum number of samples")
+	}
+	b.failure(new(request))
+	if !r.CircuitOpen() || r.CircuitTrips() != 1 {
+		t.Fatalf("the circuit did not open when the threshold was reached")
+	}
+
+	now := time.Now()
+	if d, ok := b.admit(new(request), now); ok || d <= 0 || d > time.Second {
+		t.Errorf("a request was admitted before the first probe: %v", d)
+	}
+
+	probe := new(request)
+	if _, ok := b.admit(probe, now.Add(time.Second)); !ok || !probe.probe {
+		t.Fatalf("the probe was not admitted")
+	}
+	if _, ok := b.admit(new(request), now.Add(time.Second)); ok {
+		t.Errorf("a second request was admitted while the probe was in flight")
+	}
+
+	// The delay doubles after the failed probe
+	b.failure(probe)
+	b.Lock()
+	delay := b.next.Sub(time.Now())
+	b.Unlock()
+	if delay <= time.Second || delay > 2*time.Second {
+		t.Errorf("the delay after the failed probe was %v", delay)
+	}
+
+	b.success()
+	if r.CircuitOpen() {
+		t.Errorf("the response did not close the circuit")
+	}
+	if _, ok := b.admit(new(request), time.Now()); !ok {
+		t.Errorf("a request was not admitted after the circuit closed")
+	}
+
+	r.SetCircuitBreaker(nil)
+	for i := 0; i < 10; i++ {
+		b.failure(new(request))
+	}
+	if r.CircuitOpen() {
+		t.Errorf("the disabled circuit breaker opened")
+	}
+}
+
+func TestPoolCircuitBreaker(t *testing.T) {
+	var answer atomic.Bool
+	mux := dns.NewServeMux()
+	mux.HandleFunc("circuit.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		if answer.Load() {
+			typeAHandler(w, req)
+		}
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	r.SetTimeout(100 * time.Millisecond)
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	r.SetCircuitBreaker(&CircuitBreakerOptions{
+		Threshold:  0.9,
+		MinSamples: 5,
+		Window:     time.Minute,
+		ProbeBase:  50 * time.Millisecond,
+		ProbeMax:   time.Second,
+	})
+
+	var chs []chan *dns.Msg
+	for i := 0; i < 5; i++ {
+		chs = append(chs, r.QueryChan(context.Background(), QueryMsg("circuit.net", dns.TypeA)))
+	}
+	for _, ch := range chs {
+		<-ch
+	}
+	if !r.CircuitOpen() {
+		t.Fatalf("the circuit did not open after the queries timed out")
+	}
+
+	answer.Store(true)
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("circuit.net", dns.TypeA))
+	if err != nil || len(IPs(resp)) != 1 {
+		t.Fatalf("the probe query was not answered: %v", err)
+	}
+	if r.CircuitOpen() || r.CircuitTrips() != 1 {
+		t.Errorf("the circuit was not closed by the response to the probe")
+	}
+}
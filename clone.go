@@ -0,0 +1,96 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"io"
+	"log"
+	"slices"
+
+	"github.com/caffix/queue"
+)
+
+// Clone returns a lightweight child pool that sends queries through the sockets, selector and
+// resolvers of the pool, while applying its own QPS limit, retry policy and the other settings
+// of a Resolvers, such as wildcard detection or the header flags. This allows bulk resolution
+// and wildcard detection to behave differently without duplicating the sockets. The timeouts
+// and per-resolver rate limits are shared with the pool, and stopping the pool also stops the child.
+func (r *Resolvers) Clone(qps, retries int) *Resolvers {
+	r.Lock()
+	defer r.Unlock()
+
+	c := &Resolvers{
+		done:      make(chan struct{}, 1),
+		log:       log.New(io.Discard, "", 0),
+		conns:     r.conns,
+		pool:      r.pool,
+		rmap:      make(map[string]struct{}),
+		wildcards: make(map[string]*wildcard),
+		auth:      newAuthServers(),
+		nx:        newNXPruner(),
+		parts:     newPartitions(),
+		scope:     new(queryScope),
+		verify:    new(nxVerifier),
+		hosts:     newHostsOverrides(),
+		stubs:     newStubZones(),
+		routes:    newRoutingRules(),
+		mem:       newMemoryWatchdog(),
+		jitter:    newZoneJitter(),
+		latency:   newLatencyTracer(),
+		breaker:   r.breaker,
+		retired:   new(retiredResolvers),
+		events:    newEventBus(),
+		queue:     queue.NewQueue(),
+		resps:     r.resps,
+		timeout:   r.timeout,
+		options:   new(ThresholdOptions),
+		maxDepth:  r.maxDepth,
+		bootstrap: r.bootstrap,
+		cloned:    true,
+		parent:    r,
+	}
+	c.SetMaxQPS(qps)
+	c.SetRetries(retries)
+	r.clones = append(r.clones, c)
+	return c
+}
+
+// SetRetries sets the number of times QueryBlocking sends the query again after receiving
+// no response, waiting longer between each attempt.
+func (r *Resolvers) SetRetries(retries int) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.retries = retries
+}
+
+func (r *Resolvers) getRetries() int {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.retries
+}
+
+// Stops the child pools created by Clone.
+func (r *Resolvers) stopClones() {
+	r.Lock()
+	clones := r.clones
+	r.clones = nil
+	r.Unlock()
+
+	for _, c := range clones {
+		c.Stop()
+	}
+}
+
+// Removes a child pool that has been stopped, so the pool no longer references it.
+func (r *Resolvers) removeClone(c *Resolvers) {
+	r.Lock()
+	defer r.Unlock()
+
+	if i := slices.Index(r.clones, c); i >= 0 {
+		r.clones = slices.Delete(r.clones, i, i+1)
+	}
+}
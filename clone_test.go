@@ -0,0 +1,82 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestClone(t *testing.T) {
+	var drops int32 = 2
+	mux := dns.NewServeMux()
+	mux.HandleFunc("clone.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		// The first queries for the name are dropped
+		if req.Question[0].Name == "retry.clone.net." && atomic.AddInt32(&drops, -1) >= 0 {
+			return
+		}
+
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Answer = append(m.Answer, testARecord(req.Question[0].Name, "192.0.2.1"))
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+	r.SetTimeout(250 * time.Millisecond)
+
+	c := r.Clone(50, 2)
+	if err := c.AddResolvers(10, "192.168.1.1"); err == nil {
+		t.Errorf("resolvers were added to the child pool")
+	}
+	if c.Len() != 1 || c.conns != r.conns {
+		t.Errorf("the child pool does not share the resolvers and sockets of the pool")
+	}
+
+	resp, err := c.QueryBlocking(context.Background(), QueryMsg("retry.clone.net", dns.TypeA))
+	if err != nil || resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+		t.Errorf("the query was not retried by the child pool: %v", resp)
+	}
+	if s := r.ResolverStats(); len(s) != 1 || s[0].Queries != 3 || s[0].Successes != 1 {
+		t.Errorf("the queries through the child pool were not sent by the shared resolver: %+v", s)
+	}
+
+	// The pool does not retry the queries
+	atomic.StoreInt32(&drops, 1)
+	if resp, err := r.QueryBlocking(context.Background(), QueryMsg("retry.clone.net", dns.TypeA)); err != nil || resp.Rcode != RcodeNoResponse {
+		t.Errorf("the query was retried by the pool: %v", resp)
+	}
+
+	c.Stop()
+	r.Lock()
+	remaining := len(r.clones)
+	r.Unlock()
+	if remaining != 0 {
+		t.Errorf("the stopped child pool was not removed from the pool")
+	}
+	if resp, err := r.QueryBlocking(context.Background(), QueryMsg("www.clone.net", dns.TypeA)); err != nil || resp.Rcode != dns.RcodeSuccess {
+		t.Errorf("stopping the child pool affected the pool: %v", err)
+	}
+
+	second := r.Clone(0, 0)
+	r.Stop()
+	select {
+	case <-second.done:
+	default:
+		t.Errorf("the child pool was not stopped with the pool")
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+)
+
+// FormatAnomaly returns the line written to the log for an answer anomaly.
+func FormatAnomaly(a *resolve.AnswerAnomaly) string {
+	line := fmt.Sprintf("Anomaly: %s %s %s", a.Name, dns.TypeToString[a.Qtype], a.Reason)
+
+	switch a.Reason {
+	case resolve.AnomalyShortTTL:
+		line += fmt.Sprintf(" (%d)", a.TTL)
+	case resolve.AnomalyChangingAnswers:
+		line += fmt.Sprintf(" (%s -> %s)", strings.Join(a.First, ","), strings.Join(a.Second, ","))
+	default:
+		line += fmt.Sprintf(" (%s)", strings.Join(a.First, ","))
+	}
+	return line
+}
@@ -0,0 +1,42 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+)
+
+func TestFormatAnomaly(t *testing.T) {
+	cases := []struct {
+		anomaly  *resolve.AnswerAnomaly
+		expected string
+	}{
+		{
+			anomaly:  &resolve.AnswerAnomaly{Name: "www.caffix.net", Qtype: dns.TypeA, Reason: resolve.AnomalyShortTTL, TTL: 5},
+			expected: "Anomaly: www.caffix.net A short TTL (5)",
+		}, {
+			anomaly: &resolve.AnswerAnomaly{
+				Name:   "www.caffix.net",
+				Qtype:  dns.TypeA,
+				Reason: resolve.AnomalyChangingAnswers,
+				First:  []string{"192.0.2.1", "192.0.2.2"},
+				Second: []string{"192.0.2.3"},
+			},
+			expected: "Anomaly: www.caffix.net A changing answers (192.0.2.1,192.0.2.2 -> 192.0.2.3)",
+		}, {
+			anomaly:  &resolve.AnswerAnomaly{Name: "www.caffix.net", Qtype: dns.TypeA, Reason: resolve.AnomalyPrivateAddress, First: []string{"10.0.0.1"}},
+			expected: "Anomaly: www.caffix.net A private address (10.0.0.1)",
+		},
+	}
+
+	for _, c := range cases {
+		if got := FormatAnomaly(c.anomaly); got != c.expected {
+			t.Errorf("Got: %q; Expected: %q", got, c.expected)
+		}
+	}
+}
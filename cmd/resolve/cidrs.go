@@ -0,0 +1,41 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/owasp-amass/resolve"
+)
+
+const (
+	summaryIPv4Bits int = 24
+	summaryIPv6Bits int = 64
+)
+
+// WriteCIDRs outputs each CIDR block and the number of addresses observed within it to the provided writer.
+func WriteCIDRs(w io.Writer, summaries []*resolve.CIDRSummary) {
+	for _, s := range summaries {
+		fmt.Fprintf(w, "%s %d\n", s.Network, s.Count)
+	}
+}
+
+// WriteCIDRSummary aggregates the resolved addresses into CIDR blocks and sends the summary
+// to the output file, or the log when running in quiet mode.
+func WriteCIDRSummary(p *params, ips []net.IP) {
+	w := p.Log.Writer()
+	if p.Output != nil {
+		w = p.Output
+	}
+
+	summaries, err := resolve.SummarizeCIDRs(ips, summaryIPv4Bits, summaryIPv6Bits)
+	if err != nil {
+		p.Log.Printf("%v", err)
+		return
+	}
+	WriteCIDRs(w, summaries)
+}
@@ -0,0 +1,30 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/owasp-amass/resolve"
+)
+
+func TestWriteCIDRs(t *testing.T) {
+	_, first, _ := net.ParseCIDR("192.168.0.0/23")
+	_, second, _ := net.ParseCIDR("2001:db8::/64")
+	summaries := []*resolve.CIDRSummary{
+		{Network: first, Count: 3},
+		{Network: second, Count: 1},
+	}
+
+	buf := new(bytes.Buffer)
+	WriteCIDRs(buf, summaries)
+
+	expected := "192.168.0.0/23 3\n2001:db8::/64 1\n"
+	if got := buf.String(); got != expected {
+		t.Errorf("Got: %q; Expected: %q", got, expected)
+	}
+}
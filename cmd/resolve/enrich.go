@@ -0,0 +1,113 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/owasp-amass/resolve"
+)
+
+// Pfx2AS is an enricher backed by a prefix to ASN mapping in the CAIDA pfx2as format.
+type Pfx2AS struct {
+	lengths  []int
+	prefixes map[int]map[netip.Prefix]int
+}
+
+// ReadPfx2AS parses the lines of a pfx2as file, each containing the network address,
+// the prefix length and the origin ASN separated by whitespace.
+func ReadPfx2AS(r io.Reader) (*Pfx2AS, error) {
+	p := &Pfx2AS{prefixes: make(map[int]map[netip.Prefix]int)}
+
+	err := ExtractLines(r, func(str string) error {
+		fields := strings.Fields(str)
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			return nil
+		}
+
+		addr, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return nil
+		}
+		bits, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil
+		}
+		prefix, err := addr.Unmap().Prefix(bits)
+		if err != nil {
+			return nil
+		}
+		// Multi-origin and AS set entries are represented by the first ASN
+		asn, err := strconv.Atoi(strings.FieldsFunc(fields[2], func(c rune) bool {
+			return c == '_' || c == ','
+		})[0])
+		if err != nil {
+			return nil
+		}
+
+		if _, found := p.prefixes[bits]; !found {
+			p.prefixes[bits] = make(map[netip.Prefix]int)
+			p.lengths = append(p.lengths, bits)
+		}
+		p.prefixes[bits][prefix] = asn
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(p.lengths) == 0 {
+		return nil, errors.New("no prefixes were found")
+	}
+
+	// The longest prefixes are checked first
+	sort.Sort(sort.Reverse(sort.IntSlice(p.lengths)))
+	return p, nil
+}
+
+// Enrich implements the resolve.Enricher interface using the longest matching prefix.
+func (p *Pfx2AS) Enrich(ctx context.Context, ip net.IP) (*resolve.Enrichment, error) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return nil, fmt.Errorf("invalid IP address: %v", ip)
+	}
+	addr = addr.Unmap()
+
+	for _, bits := range p.lengths {
+		if bits > addr.BitLen() {
+			continue
+		}
+
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if asn, found := p.prefixes[bits][prefix]; found {
+			return &resolve.Enrichment{ASN: asn, Prefix: prefix.String()}, nil
+		}
+	}
+	return nil, fmt.Errorf("no prefix contains %s", addr)
+}
+
+// FormatEnrichment returns the comment line written after a response for an enriched address.
+func FormatEnrichment(addr string, e *resolve.Enrichment) string {
+	line := ";; " + addr
+	if e.ASN != 0 {
+		line += fmt.Sprintf(" AS%d", e.ASN)
+	}
+	for _, field := range []string{e.Organization, e.Prefix, e.Country} {
+		if field != "" {
+			line += " " + field
+		}
+	}
+	return line
+}
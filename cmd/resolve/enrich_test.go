@@ -0,0 +1,68 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/owasp-amass/resolve"
+)
+
+func TestPfx2AS(t *testing.T) {
+	data := `# comment
+1.0.0.0	24	13335
+8.8.0.0	16	15169
+8.8.8.0	24	15169_36040
+9.9.9.0	24	19281,42
+invalid	24	1
+2001:db8::	32	64500
+`
+	pfx, err := ReadPfx2AS(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to read the pfx2as data: %v", err)
+	}
+
+	cases := []struct {
+		addr   string
+		asn    int
+		prefix string
+	}{
+		{addr: "1.0.0.1", asn: 13335, prefix: "1.0.0.0/24"},
+		{addr: "8.8.4.4", asn: 15169, prefix: "8.8.0.0/16"},
+		{addr: "8.8.8.8", asn: 15169, prefix: "8.8.8.0/24"},
+		{addr: "9.9.9.9", asn: 19281, prefix: "9.9.9.0/24"},
+		{addr: "2001:db8::1", asn: 64500, prefix: "2001:db8::/32"},
+	}
+	for _, c := range cases {
+		e, err := pfx.Enrich(context.Background(), net.ParseIP(c.addr))
+		if err != nil {
+			t.Errorf("Failed to enrich %s: %v", c.addr, err)
+			continue
+		}
+		if e.ASN != c.asn || e.Prefix != c.prefix {
+			t.Errorf("Got: AS%d %s; Expected: AS%d %s", e.ASN, e.Prefix, c.asn, c.prefix)
+		}
+	}
+
+	if _, err := pfx.Enrich(context.Background(), net.ParseIP("192.0.2.1")); err == nil {
+		t.Errorf("An address outside all prefixes was enriched")
+	}
+	if _, err := ReadPfx2AS(strings.NewReader("# empty\n")); err == nil {
+		t.Errorf("ReadPfx2AS accepted data without any prefixes")
+	}
+}
+
+func TestFormatEnrichment(t *testing.T) {
+	e := &resolve.Enrichment{ASN: 13335, Organization: "CLOUDFLARENET", Prefix: "1.1.1.0/24", Country: "US"}
+	if got := FormatEnrichment("1.1.1.1", e); got != ";; 1.1.1.1 AS13335 CLOUDFLARENET 1.1.1.0/24 US" {
+		t.Errorf("Unexpected line: %q", got)
+	}
+	if got := FormatEnrichment("1.1.1.1", &resolve.Enrichment{Country: "US"}); got != ";; 1.1.1.1 US" {
+		t.Errorf("Unexpected line: %q", got)
+	}
+}
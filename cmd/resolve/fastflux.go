@@ -0,0 +1,25 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/owasp-amass/resolve"
+)
+
+// The number of times a flagged name is resolved across the fast-flux window.
+const fluxSamples int = 5
+
+// FormatFastFlux returns the line written to the log for a fast-flux report.
+func FormatFastFlux(r *resolve.FastFluxReport) string {
+	label := "unlikely"
+	if r.Likely {
+		label = "likely"
+	}
+
+	return fmt.Sprintf("FastFlux: %s %s (samples: %d, addresses: %d, networks: %d, min TTL: %d, avg TTL: %d)",
+		r.Name, label, r.Samples, r.DistinctIPs, r.DistinctNetworks, r.MinTTL, r.AvgTTL)
+}
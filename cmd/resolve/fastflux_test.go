@@ -0,0 +1,34 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/owasp-amass/resolve"
+)
+
+func TestFormatFastFlux(t *testing.T) {
+	report := &resolve.FastFluxReport{
+		Name:             "flux.caffix.net",
+		Samples:          5,
+		DistinctIPs:      12,
+		DistinctNetworks: 9,
+		MinTTL:           30,
+		AvgTTL:           45,
+		Likely:           true,
+	}
+
+	expected := "FastFlux: flux.caffix.net likely (samples: 5, addresses: 12, networks: 9, min TTL: 30, avg TTL: 45)"
+	if got := FormatFastFlux(report); got != expected {
+		t.Errorf("Got: %q; Expected: %q", got, expected)
+	}
+
+	report.Likely = false
+	expected = "FastFlux: flux.caffix.net unlikely (samples: 5, addresses: 12, networks: 9, min TTL: 30, avg TTL: 45)"
+	if got := FormatFastFlux(report); got != expected {
+		t.Errorf("Got: %q; Expected: %q", got, expected)
+	}
+}
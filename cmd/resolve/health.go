@@ -0,0 +1,45 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/owasp-amass/resolve"
+)
+
+// WriteZoneHealth outputs the classification of each nameserver in the report to the provided writer.
+func WriteZoneHealth(w io.Writer, report *resolve.ZoneHealth) {
+	for _, s := range report.Servers {
+		fmt.Fprintf(w, "%s %s %s\n", report.Zone, s.Address, s.Status())
+	}
+}
+
+// WriteHealth checks the authoritative nameservers of the zones and sends the reports to the
+// output file, or the log when running in quiet mode.
+func WriteHealth(ctx context.Context, p *params, zones map[string]struct{}) {
+	w := p.Log.Writer()
+	if p.Output != nil {
+		w = p.Output
+	}
+
+	var sorted []string
+	for zone := range zones {
+		sorted = append(sorted, zone)
+	}
+	sort.Strings(sorted)
+
+	for _, zone := range sorted {
+		report, err := p.Pool.CheckZoneHealth(ctx, zone)
+		if err != nil {
+			p.Log.Printf("%v", err)
+			continue
+		}
+		WriteZoneHealth(w, report)
+	}
+}
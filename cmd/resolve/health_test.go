@@ -0,0 +1,31 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/owasp-amass/resolve"
+)
+
+func TestWriteZoneHealth(t *testing.T) {
+	report := &resolve.ZoneHealth{
+		Zone: "caffix.net",
+		Servers: []*resolve.ServerHealth{
+			{Address: "192.168.1.1", Reachable: true},
+			{Address: "192.168.1.2", Reachable: true, Lame: true},
+			{Address: "192.168.1.3"},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	WriteZoneHealth(buf, report)
+
+	expected := "caffix.net 192.168.1.1 healthy\ncaffix.net 192.168.1.2 lame\ncaffix.net 192.168.1.3 unreachable\n"
+	if got := buf.String(); got != expected {
+		t.Errorf("Got: %q; Expected: %q", got, expected)
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"os"
 	"strings"
 
-	"github.com/caffix/stringset"
 	"github.com/miekg/dns"
 	"github.com/owasp-amass/resolve"
 )
@@ -62,23 +61,61 @@ func (c *CommaSep) Set(s string) error {
 	return nil
 }
 
-func ResolverFileList(p string) []string {
-	set := stringset.New()
-	defer set.Close()
+// StubZones implements the flag.Value interface for zone=server,server values.
+type StubZones map[string][]string
+
+// String implements the fmt.Stringer interface.
+func (s StubZones) String() string {
+	var zones []string
+
+	for zone, servers := range s {
+		zones = append(zones, zone+"="+strings.Join(servers, ","))
+	}
+	return strings.Join(zones, " ")
+}
+
+// Set implements the flag.Value interface.
+func (s StubZones) Set(str string) error {
+	zone, list, found := strings.Cut(str, "=")
+	if !found || zone == "" || list == "" {
+		return fmt.Errorf("failed to parse the stub zone: %s", str)
+	}
 
+	var servers CommaSep
+	if err := servers.Set(list); err != nil {
+		return err
+	}
+	s[zone] = append(s[zone], servers...)
+	return nil
+}
+
+func ResolverFileList(p string) []string {
 	if input, err := os.Open(p); err == nil {
 		defer input.Close()
 
+		var list []string
 		if err := ExtractLines(input, func(str string) error {
-			set.Insert(str)
+			list = append(list, str)
 			return nil
 		}); err == nil {
-			return set.Slice()
+			return uniqueStrings(list)
 		}
 	}
+	return uniqueStrings(resolvers)
+}
 
-	set.InsertMany(resolvers...)
-	return set.Slice()
+// Returns the strings without the duplicates, in the order they first appear.
+func uniqueStrings(list []string) []string {
+	seen := make(map[string]struct{}, len(list))
+
+	var unique []string
+	for _, str := range list {
+		if _, found := seen[str]; !found {
+			seen[str] = struct{}{}
+			unique = append(unique, str)
+		}
+	}
+	return unique
 }
 
 func InputDomainNames(input io.Reader, requests chan string) {
@@ -92,6 +129,37 @@ func InputDomainNames(input io.Reader, requests chan string) {
 	})
 }
 
+// InputOrderedNames reads all the DNS names from the input before sending them in the order requested.
+func InputOrderedNames(input io.Reader, requests chan string, order resolve.NameOrder) {
+	for _, name := range resolve.OrderNames(readDomainNames(input), order) {
+		requests <- name
+	}
+}
+
+// InputScheduledNames reads all the DNS names from the input, placed in the order requested, onto
+// the scheduler, and sends the names as the scheduler returns them.
+func InputScheduledNames(input io.Reader, requests chan string, order resolve.NameOrder, sched *resolve.CandidateScheduler) {
+	sched.Add(resolve.OrderNames(readDomainNames(input), order)...)
+	for name, ok := sched.Next(); ok; name, ok = sched.Next() {
+		requests <- name
+	}
+}
+
+// Returns all the valid DNS names read from the input.
+func readDomainNames(input io.Reader) []string {
+	var names []string
+
+	_ = ExtractLines(input, func(str string) error {
+		name := resolve.RemoveLastDot(strings.ToLower(str))
+
+		if _, ok := dns.IsDomainName(name); ok {
+			names = append(names, name)
+		}
+		return nil
+	})
+	return names
+}
+
 func ExtractLines(reader io.Reader, cb func(str string) error) error {
 	scanner := bufio.NewScanner(reader)
 
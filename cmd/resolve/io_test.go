@@ -9,8 +9,8 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/caffix/stringset"
 	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
 )
 
 func TestCommaSep(t *testing.T) {
@@ -58,17 +58,34 @@ func TestCommaSep(t *testing.T) {
 	}
 }
 
-func TestResolverList(t *testing.T) {
-	set := stringset.New(resolvers...)
-	defer set.Close()
+func TestStubZones(t *testing.T) {
+	stubs := make(StubZones)
+
+	for _, input := range []string{"", "corp.local", "corp.local=", "=192.168.1.1"} {
+		if err := stubs.Set(input); err == nil {
+			t.Errorf("Set accepted the invalid stub zone %q", input)
+		}
+	}
+
+	if err := stubs.Set("corp.local=192.168.1.1, 192.168.1.2:5353"); err != nil {
+		t.Fatalf("Set failed to parse a valid stub zone: %v", err)
+	}
+	if got := stubs.String(); got != "corp.local=192.168.1.1,192.168.1.2:5353" {
+		t.Errorf("Got: %s; Expected: %s", got, "corp.local=192.168.1.1,192.168.1.2:5353")
+	}
+}
 
+func TestResolverList(t *testing.T) {
 	for _, p := range []string{"", "../../example/resolvers.txt"} {
-		list := stringset.New(ResolverFileList(p)...)
-		defer list.Close()
+		list := make(map[string]struct{})
+		for _, addr := range ResolverFileList(p) {
+			list[addr] = struct{}{}
+		}
 
-		list.Intersect(set)
-		if list.Len() != set.Len() {
-			t.Errorf("Failed to obtain all of the DNS resolvers from %s", p)
+		for _, addr := range resolvers {
+			if _, found := list[addr]; !found {
+				t.Errorf("Failed to obtain the DNS resolver %s from %s", addr, p)
+			}
 		}
 	}
 }
@@ -86,18 +103,52 @@ func TestInputDomainNames(t *testing.T) {
 	}
 }
 
+func TestInputOrderedNames(t *testing.T) {
+	results := make(chan string, 2)
+	reader := strings.NewReader("www.caffix.net\nWWW.owasp.org.\nmail.caffix.net\nftp.caffix.net")
+
+	go InputOrderedNames(reader, results, resolve.InterleaveOrder)
+	for _, name := range []string{"www.caffix.net", "www.owasp.org", "mail.caffix.net", "ftp.caffix.net"} {
+		if n := <-results; n != name {
+			t.Errorf("Got: %s; Expected: %q", n, name)
+		}
+	}
+}
+
+func TestInputScheduledNames(t *testing.T) {
+	results := make(chan string, 4)
+	sched := resolve.NewCandidateScheduler()
+	// Names with the dev prefix were found, so those candidates are sent first
+	sched.Observe("dev.caffix.net", true)
+	reader := strings.NewReader("www.caffix.net\nftp.caffix.net\ndev1.caffix.net\nDEV2.caffix.net.")
+
+	InputScheduledNames(reader, results, resolve.InputOrder, sched)
+	close(results)
+
+	var got []string
+	for name := range results {
+		got = append(got, name)
+	}
+	expected := []string{"dev1.caffix.net", "dev2.caffix.net", "www.caffix.net", "ftp.caffix.net"}
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Got: %v; Expected: %v", got, expected)
+	}
+}
+
 func TestExtractLines(t *testing.T) {
 	names := []string{"www.caffix.net", "mail.caffix.net", "ftp.caffix.net"}
 	reader := strings.NewReader(names[0] + "\n" + names[1] + "\n" + names[2])
-	set := stringset.New(names...)
-	defer set.Close()
+	set := make(map[string]struct{})
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
 
 	err := ExtractLines(reader, func(str string) error {
-		set.Remove(str)
+		delete(set, str)
 		return nil
 	})
-	if err != nil || set.Len() > 0 {
-		t.Errorf("Failed to extract all names from the reader: %s", set.String())
+	if err != nil || len(set) > 0 {
+		t.Errorf("Failed to extract all names from the reader: %v", set)
 	}
 
 	second := strings.NewReader("test")
@@ -0,0 +1,112 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// RunLock records the run writing to a file, so another invocation cannot write to the
+// same file at the same time. The lock is held by a file next to the locked file.
+type RunLock struct {
+	Path    string    `json:"-"`
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Started time.Time `json:"started"`
+	Args    []string  `json:"args"`
+}
+
+// LockPath returns the path of the lock file for the provided file.
+func LockPath(path string) string {
+	return path + ".lock"
+}
+
+// AcquireRunLock creates the lock file for the provided file with the metadata of this run.
+// A lock held by a process that is no longer running on this host is replaced, while a lock
+// held by another run is only replaced when force is true.
+func AcquireRunLock(path string, force bool) (*RunLock, error) {
+	host, _ := os.Hostname()
+	lock := &RunLock{
+		Path:    LockPath(path),
+		PID:     os.Getpid(),
+		Host:    host,
+		Started: time.Now().UTC(),
+		Args:    os.Args,
+	}
+
+	data, err := json.Marshal(lock)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(lock.Path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = f.Write(append(data, '\n'))
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				_ = os.Remove(lock.Path)
+				return nil, err
+			}
+			return lock, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		held, err := ReadRunLock(lock.Path)
+		if err == nil && !force && !held.stale(host) {
+			return nil, fmt.Errorf("%s is in use by process %d on %s since %s, use -force to override",
+				path, held.PID, held.Host, held.Started.Format(time.RFC3339))
+		}
+		if err != nil && !force {
+			return nil, fmt.Errorf("the lock file %s could not be read, use -force to override: %v", lock.Path, err)
+		}
+		if err := os.Remove(lock.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("failed to acquire the lock file %s", lock.Path)
+}
+
+// ReadRunLock returns the metadata of the run holding the lock file.
+func ReadRunLock(path string) (*RunLock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &RunLock{Path: path}
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// Release removes the lock file, unless it has been replaced by another run.
+func (l *RunLock) Release() error {
+	held, err := ReadRunLock(l.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if held.PID != l.PID || held.Host != l.Host || !held.Started.Equal(l.Started) {
+		return nil
+	}
+	return os.Remove(l.Path)
+}
+
+// Returns true when the lock was held by a process on this host that is no longer running.
+func (l *RunLock) stale(host string) bool {
+	return l.Host == host && !processAlive(l.PID)
+}
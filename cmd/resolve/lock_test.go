@@ -0,0 +1,82 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireRunLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.txt")
+
+	lock, err := AcquireRunLock(path, false)
+	if err != nil {
+		t.Fatalf("failed to acquire the run lock: %v", err)
+	}
+	if held, err := ReadRunLock(LockPath(path)); err != nil || held.PID != os.Getpid() {
+		t.Errorf("the lock file did not contain the metadata of the run: %v", err)
+	}
+
+	if _, err := AcquireRunLock(path, false); err == nil || !strings.Contains(err.Error(), "-force") {
+		t.Errorf("the lock held by a running process was acquired again: %v", err)
+	}
+
+	forced, err := AcquireRunLock(path, true)
+	if err != nil {
+		t.Fatalf("failed to acquire the lock using force: %v", err)
+	}
+	// The first run does not remove the lock acquired using force
+	if err := lock.Release(); err != nil {
+		t.Errorf("failed to release the replaced lock: %v", err)
+	}
+	if _, err := os.Stat(LockPath(path)); err != nil {
+		t.Errorf("the lock acquired using force was removed by the first run")
+	}
+
+	if err := forced.Release(); err != nil {
+		t.Errorf("failed to release the lock: %v", err)
+	}
+	if _, err := os.Stat(LockPath(path)); !os.IsNotExist(err) {
+		t.Errorf("the lock file was not removed after the release")
+	}
+}
+
+func TestAcquireRunLockStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.txt")
+
+	// Obtain the ID of a process that is no longer running
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("failed to run the process: %v", err)
+	}
+
+	host, _ := os.Hostname()
+	data, _ := json.Marshal(&RunLock{PID: cmd.Process.Pid, Host: host, Started: time.Now()})
+	if err := os.WriteFile(LockPath(path), data, 0644); err != nil {
+		t.Fatalf("failed to write the lock file: %v", err)
+	}
+
+	lock, err := AcquireRunLock(path, false)
+	if err != nil {
+		t.Fatalf("the stale lock was not replaced: %v", err)
+	}
+	defer func() { _ = lock.Release() }()
+
+	// A lock held on another host cannot be checked
+	other := filepath.Join(t.TempDir(), "other.txt")
+	data, _ = json.Marshal(&RunLock{PID: cmd.Process.Pid, Host: host + ".other", Started: time.Now()})
+	if err := os.WriteFile(LockPath(other), data, 0644); err != nil {
+		t.Fatalf("failed to write the lock file: %v", err)
+	}
+	if _, err := AcquireRunLock(other, false); err == nil {
+		t.Errorf("the lock held on another host was replaced")
+	}
+}
@@ -7,13 +7,14 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"net"
 	"os"
 	"path"
-	"strconv"
 	"strings"
 	"time"
 
@@ -43,10 +44,60 @@ type params struct {
 	QPS       int
 	Retries   int
 	Detection bool
+	Proofs    bool
+	JSON      bool
+	Stats     bool
+	Quorum    int
+	Prune     bool
+	RequireAA bool
+	Health    bool
+	Mine      bool
+	CIDRs     bool
+	Expired   bool
+	RStats    bool
+	Sources   bool
+	CDN       bool
+	VerifyNX  bool
+	Throttle  bool
+	DryRun    bool
+	Wildcards []string
+	Key       resolve.QueryKeyFunc
+	Order     resolve.NameOrder
+	Replay    []*ReplayQuery
+	ReplayQPS int
+	MinTTL    int
+	Flux      time.Duration
+	Bootstrap string
+	RFile     string
+	Routes    string
+	OFile     string
+	Force     bool
+	Denylist  string
+	Scheduler *resolve.CandidateScheduler
+	Profile   *resolve.NameProfile
+	Report    string
+	ReportOut string
+	TLS       []string
+	TCPOnly   bool
+	System    bool
 	Help      bool
 }
 
 func main() {
+	os.Exit(run())
+}
+
+// Performs the run and returns the exit code, so the deferred calls release the resources,
+// such as the lock on the output file, before the process exits.
+func run() int {
+	if len(os.Args) > 1 && os.Args[1] == "survey" {
+		if err := Survey(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
 	p, buf, err := ObtainParams(os.Args[1:])
 	if err != nil {
 		msg := err.Error()
@@ -54,24 +105,69 @@ func main() {
 			msg = buf.String()
 		}
 		fmt.Fprintln(os.Stderr, msg)
-		os.Exit(1)
+		return 1
 	}
 	if p.Help && buf != nil {
 		fmt.Fprintf(os.Stderr, "Usage: %s %s\n%s\n", path.Base(os.Args[0]), "[options]", buf.String())
-		return
+		return 0
 	}
 	defer p.Pool.Stop()
+	// Prevent another run from writing to the same output file
+	if p.OFile != "" && !p.DryRun {
+		lock, err := AcquireRunLock(p.OFile, p.Force)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer func() { _ = lock.Release() }()
+	}
+	if p.DryRun {
+		if err := DryRun(p); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	// Open the sockets before any names are read, so a failure ends the run
+	if err := p.Pool.Start(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if p.Replay != nil {
+		Replay(p, p.Replay, p.ReplayQPS)
+		return 0
+	}
+	// Allow the QPS to be raised with SIGUSR1 and lowered with SIGUSR2 during the scan
+	defer WatchQPSSignals(p)()
+	// Allow the resolvers and routing rules files to be reloaded with SIGHUP during the scan
+	if p.RFile != "" || p.Routes != "" {
+		defer WatchReloadSignal(p)()
+	}
 	// Begin reading DNS names from input
 	p.Requests = make(chan string, p.QPS)
-	go InputDomainNames(p.Input, p.Requests)
+	if p.Scheduler != nil {
+		go InputScheduledNames(p.Input, p.Requests, p.Order, p.Scheduler)
+	} else if p.Order != resolve.InputOrder {
+		go InputOrderedNames(p.Input, p.Requests, p.Order)
+	} else {
+		go InputDomainNames(p.Input, p.Requests)
+	}
 
 	EventLoop(p)
+	return 0
 }
 
 func ObtainParams(args []string) (*params, *bytes.Buffer, error) {
-	var timeout int
-	var queryTypes, rlist CommaSep
-	var rpath, ipath, lpath, opath, detector string
+	var timeout, sla, inflight, budget, depth, rcvbuf, rdeadline, bufsize int
+	var ndots int
+	var seed int64
+	var queryTypes, rlist, flist, tlist, search, hdrflags CommaSep
+	stubs := make(StubZones)
+	var rpath, ipath, lpath, opath, hpath, apath, cpath, wpath, filter, replay, detector string
+	var dedup, packets, routes, jitter, order, cachettl string
+	var hexdump, adaptive, screen, hijack, noedns, cookies bool
+	var maxlabel int
+	var maxentropy, latency, circuit float64
 
 	buf := new(bytes.Buffer)
 	flags := flag.NewFlagSet("resolve", flag.ContinueOnError)
@@ -80,14 +176,76 @@ func ObtainParams(args []string) (*params, *bytes.Buffer, error) {
 	p := new(params)
 	flags.BoolVar(&p.Quiet, "q", defaultQuiet, "Quiet mode")
 	flags.BoolVar(&p.Help, "h", defaultHelp, "Print usage information")
+	flags.BoolVar(&p.Proofs, "dnssec", false, "Request DNSSEC proofs of non-existence with the responses, written to the JSON output")
+	flags.BoolVar(&p.JSON, "json", false, "Write a JSON object per name with the records grouped by type")
+	flags.BoolVar(&p.RequireAA, "aa", false, "Reject responses without the AA bit from the authoritative servers (with -d auto)")
+	flags.BoolVar(&p.Health, "health", false, "Report the health of the authoritative servers for the zones resolved")
+	flags.BoolVar(&adaptive, "adaptive", false, "Resolve the names with prefixes showing the best hit rates first, reading all names before starting")
+	flags.BoolVar(&p.CDN, "cdn", false, "Label the names served through well-known CDN providers based on the CNAME records")
+	flags.BoolVar(&p.CIDRs, "cidrs", false, "Output a summary of the resolved addresses aggregated into CIDR blocks")
+	flags.BoolVar(&p.DryRun, "dryrun", false, "Report the queries that would be sent per zone, destination and server without sending them")
+	flags.BoolVar(&p.Expired, "expired", false, "Log each request that expired without a response")
+	flags.BoolVar(&p.Mine, "mine", false, "Resolve the hostnames found in the TXT (SPF), MX, SRV and CNAME answers")
+	flags.BoolVar(&p.VerifyNX, "verifynx", false, "Check NXDOMAIN responses against the authoritative servers of the zone before reporting them")
+	flags.BoolVar(&p.Throttle, "throttle", false, "Log the resolvers showing timeout bursts, REFUSED spikes or truncation storms")
+	flags.BoolVar(&hexdump, "hexdump", false, "Log the packets selected by -packets as hex dumps of the wire format")
+	flags.BoolVar(&hijack, "hijack", false, "Remove the resolvers answering random nonexistent names with the same addresses, such as captive portals")
+	flags.BoolVar(&screen, "screen", false, "Skip the names with labels that are not valid for hostnames")
+	flags.BoolVar(&p.Prune, "prune", false, "Skip names beneath a name that returned NXDOMAIN")
+	flags.BoolVar(&p.TCPOnly, "tcp", false, "Send all queries over persistent TCP connections instead of UDP")
+	flags.BoolVar(&p.System, "system", false, "Use the DNS resolvers configured on the host system")
+	flags.BoolVar(&p.RStats, "rstats", false, "Write a table of statistics for each resolver to the log at the end of the run")
+	flags.BoolVar(&p.Sources, "sources", false, "Include the server, transport and attempts of each response in the output")
+	flags.BoolVar(&p.Stats, "stats", false, "Only output statistics regarding the names that were resolved")
 	flags.IntVar(&p.QPS, "qps", defaultQPS, "Number of queries sent to each resolver per second")
 	flags.IntVar(&p.Retries, "c", defaultRetries, "Times each DNS name is attempted before giving up")
+	flags.IntVar(&depth, "maxdepth", resolve.DefaultMaxAliasDepth, "Maximum CNAME records followed for a name, reported as the chain in the JSON output")
+	flags.IntVar(&inflight, "inflight", 0, "Maximum requests outstanding for each resolver (default unlimited)")
+	flags.IntVar(&rcvbuf, "rcvbuf", 0, "Bytes requested for the kernel receive buffer of each UDP socket (default system)")
+	flags.Float64Var(&circuit, "circuit", 0, "Fraction of the queries timing out, such as 0.9, that pauses the run while the network is probed")
+	flags.IntVar(&bufsize, "bufsize", 0, "UDP payload size advertised in the EDNS0 OPT record of the queries, such as 1232")
+	flags.BoolVar(&noedns, "noedns", false, "Send the queries without the EDNS0 OPT record")
+	flags.BoolVar(&cookies, "cookies", false, "Send DNS Cookies to the resolvers, so repeated queries are recognized by the servers")
+	flags.IntVar(&rdeadline, "rdeadline", 0, "Milliseconds each socket read blocks, with sockets stuck beyond it replaced (default 5000)")
+	flags.IntVar(&budget, "mem", 0, "Megabytes of memory used before reading new names is paused (default unlimited)")
+	flags.IntVar(&maxlabel, "maxlabel", 0, "Skip the names with labels longer than the number of characters provided")
+	flags.Float64Var(&latency, "latency", 0, "Fraction of queries traced, such as 0.01, with the latency of each stage written to the log")
+	flags.Float64Var(&maxentropy, "maxentropy", 0, "Skip the names with labels exceeding the bits of entropy per character, such as 3.5")
+	flags.IntVar(&p.MinTTL, "minttl", 0, "Log answers with a TTL below the seconds provided, private addresses or changing answers")
+	flags.DurationVar(&p.Flux, "flux", 0, "Window over which names flagged by -minttl are resolved again to label likely fast-flux domains")
+	flags.IntVar(&p.Quorum, "quorum", 0, "Detection resolvers that must agree on a DNS wildcard (default majority)")
+	flags.IntVar(&ndots, "ndots", 0, "Names with fewer dots are tried with the search domains first (default 1)")
+	flags.Var(&search, "search", `Search domains comma-separated used to expand names, or "system"`)
+	flags.Var(&hdrflags, "flags", `Header bits comma-separated set on each query from "rd", "cd" and "ad" (default "rd")`)
+	flags.Int64Var(&seed, "seed", 0, "Pin all randomness to the seed and serialize response handling to reproduce a run")
 	flags.IntVar(&timeout, "timeout", defaultTimeout, "Milliseconds to wait before a request times out")
 	flags.Var(&queryTypes, "t", `DNS record types comma-separated (default "A")`)
-	flags.Var(&rlist, "r", "DNS resolver IP addresses comma-separated")
-	flags.StringVar(&rpath, "rf", "", "File containing a DNS resolver IP address on each line")
-	flags.StringVar(&detector, "d", "", "Set a resolver to perform DNS wildcard detection")
+	flags.Var(&rlist, "r", "DNS resolver IP addresses or hostnames, optionally with a port, comma-separated")
+	flags.StringVar(&rpath, "rf", "", "File containing a DNS resolver IP address or hostname, optionally with a port, on each line")
+	flags.Var(&tlist, "tls", "DNS-over-TLS resolver IP addresses or hostnames, optionally with a port (default 853), comma-separated")
+	flags.Var(&flist, "fallback", "Fallback DNS resolvers comma-separated that retry the requests exceeding the SLA")
+	flags.IntVar(&sla, "sla", defaultTimeout, "Milliseconds to wait for the resolvers before a request is sent to the fallback resolvers")
+	flags.StringVar(&p.Denylist, "denylist", "", "File recording the resolvers disqualified, which are skipped on future runs")
+	flags.StringVar(&p.Bootstrap, "bootstrap", "", "DNS server used to obtain the addresses of resolvers specified by hostname")
+	flags.StringVar(&detector, "d", "", "Resolvers comma-separated to perform DNS wildcard detection, or auto for the authoritative servers")
+	flags.StringVar(&routes, "routes", "", "File of rules sending the names matched by a pattern to other servers or skipping wildcard detection")
+	flags.Var(stubs, "stub", "Zone and servers queried without recursion, as zone=ip,ip (repeatable)")
+	flags.StringVar(&apath, "asn", "", "File in the pfx2as format used to add the ASN and prefix of each address to the output")
+	flags.StringVar(&filter, "filter", "", `Probe the resolvers with canary names and "exclude" or "annotate" those filtering answers`)
+	flags.StringVar(&dedup, "dedup", "fold", `Names considered the same while tracking queries, "fold" ignoring case or "exact"`)
+	flags.StringVar(&jitter, "jitter", "", `Random delay "min,max", such as "100ms,2s", between the queries for names in the same zone`)
+	flags.StringVar(&order, "order", "input", `Order the names are resolved: "input", "shuffle", "interleave" across zones, "depth" or "breadth" first`)
+	flags.StringVar(&packets, "packets", "", `Glob pattern of the names, such as "*.example.com", with the packets sent and received logged`)
+	flags.StringVar(&p.Report, "report", "", `Write a report for each registered domain at the end of the run, as "json" or "markdown"`)
+	flags.StringVar(&p.ReportOut, "reportfile", "", "Write the report selected by -report to the specified file (default the log)")
+	flags.StringVar(&replay, "replay", "", "Send the queries found in the output file of a previous run instead of reading names")
+	flags.IntVar(&p.ReplayQPS, "replayqps", 0, "Queries per second sent while replaying a previous run (default pool QPS)")
+	flags.StringVar(&wpath, "wildcards", "", "JSON zone reports of a previous run, with the queries beneath the wildcards found counted by -dryrun")
+	flags.StringVar(&cpath, "cache", "", "JSON output file of a previous run used to answer the repeated queries without sending them")
+	flags.StringVar(&cachettl, "cachettl", "", `TTL limits "min,max", such as "60s,24h", of the responses cached during the run`)
+	flags.StringVar(&hpath, "hosts", "", "Hosts-style file of pinned addresses answered without sending queries")
 	flags.StringVar(&ipath, "i", "", "Read DNS names from the specified input file (default stdin)")
+	flags.BoolVar(&p.Force, "force", false, "Write to the output file even when it is locked by another run")
 	flags.StringVar(&opath, "o", "", "Write DNS responses to the specified output file (default stdout)")
 	flags.StringVar(&lpath, "l", "", "Errors are written to the specified log file (default stderr)")
 	if err := flags.Parse(args); err != nil {
@@ -100,13 +258,155 @@ func ObtainParams(args []string) (*params, *bytes.Buffer, error) {
 	if err := p.SetupFiles(lpath, opath, ipath); err != nil {
 		return nil, nil, fmt.Errorf("failed to open files: %v", err)
 	}
+	if err := p.SetupQueryKey(dedup); err != nil {
+		return nil, nil, err
+	}
+	if err := p.SetupNameOrder(order); err != nil {
+		return nil, nil, err
+	}
+	if p.Report != "" && p.Report != "json" && p.Report != "markdown" {
+		return nil, nil, fmt.Errorf("the report format must be json or markdown: %s", p.Report)
+	}
+	if adaptive {
+		p.Scheduler = resolve.NewCandidateScheduler()
+	}
+	if screen || maxlabel > 0 || maxentropy > 0 {
+		p.Profile = &resolve.NameProfile{
+			MaxLabelLength:  maxlabel,
+			MaxEntropy:      maxentropy,
+			AllowUnderscore: true,
+		}
+	}
+	if seed != 0 {
+		resolve.SetDeterministic(seed)
+	}
+	if wpath != "" {
+		if err := p.SetupWildcards(wpath); err != nil {
+			return nil, nil, fmt.Errorf("failed to read the wildcards file: %v", err)
+		}
+	}
+	if replay != "" {
+		if err := p.SetupReplay(replay); err != nil {
+			return nil, nil, fmt.Errorf("failed to read the replay file: %v", err)
+		}
+	}
 	p.Qtypes = StringsToQtypes(queryTypes)
 	if len(p.Qtypes) == 0 {
 		p.Qtypes = []uint16{dns.TypeA}
 	}
+	p.TLS = tlist
+	if err := ValidateOptions(p, rlist, timeout, inflight, depth, detector); err != nil {
+		return nil, nil, err
+	}
 	if err := p.SetupResolverPool(rlist, rpath, timeout, detector); err != nil {
 		return nil, nil, fmt.Errorf("failed to setup the resolver pool: %v", err)
 	}
+	if len(flist) > 0 {
+		if err := p.Pool.AddFallbackResolvers(p.QPS, time.Duration(sla)*time.Millisecond, flist...); err != nil {
+			p.Pool.Stop()
+			return nil, nil, fmt.Errorf("failed to add the fallback resolvers: %v", err)
+		}
+	}
+	for zone, servers := range stubs {
+		if err := p.Pool.AddStubZone(p.QPS, zone, servers...); err != nil {
+			p.Pool.Stop()
+			return nil, nil, fmt.Errorf("failed to add the stub zone: %v", err)
+		}
+	}
+	if routes != "" {
+		if err := p.SetupRoutingRules(routes); err != nil {
+			p.Pool.Stop()
+			return nil, nil, fmt.Errorf("failed to load the routing rules: %v", err)
+		}
+	}
+	if hpath != "" {
+		if err := p.Pool.LoadHostsFile(hpath); err != nil {
+			p.Pool.Stop()
+			return nil, nil, fmt.Errorf("failed to load the hosts file: %v", err)
+		}
+	}
+	if cpath != "" || cachettl != "" {
+		if err := p.SetupCache(cpath, cachettl); err != nil {
+			p.Pool.Stop()
+			return nil, nil, fmt.Errorf("failed to setup the cache: %v", err)
+		}
+	}
+	if apath != "" {
+		if err := p.SetupEnricher(apath); err != nil {
+			p.Pool.Stop()
+			return nil, nil, fmt.Errorf("failed to load the pfx2as file: %v", err)
+		}
+	}
+	if err := p.SetupSearch(search, ndots); err != nil {
+		p.Pool.Stop()
+		return nil, nil, fmt.Errorf("failed to setup the search domains: %v", err)
+	}
+	if len(hdrflags) > 0 {
+		if err := p.SetupHeaderFlags(hdrflags); err != nil {
+			p.Pool.Stop()
+			return nil, nil, fmt.Errorf("failed to setup the header flags: %v", err)
+		}
+	}
+	if hijack {
+		if err := p.SetupHijacking(); err != nil {
+			p.Pool.Stop()
+			return nil, nil, fmt.Errorf("failed to detect the hijacking resolvers: %v", err)
+		}
+	}
+	if filter != "" {
+		if err := p.SetupFiltering(filter); err != nil {
+			p.Pool.Stop()
+			return nil, nil, fmt.Errorf("failed to detect the filtering resolvers: %v", err)
+		}
+	}
+	if packets != "" {
+		if err := p.SetupPacketLogging(packets, hexdump); err != nil {
+			p.Pool.Stop()
+			return nil, nil, fmt.Errorf("failed to setup the packet logging: %v", err)
+		}
+	}
+	if jitter != "" {
+		if err := p.SetupZoneJitter(jitter); err != nil {
+			p.Pool.Stop()
+			return nil, nil, fmt.Errorf("failed to setup the zone jitter: %v", err)
+		}
+	}
+	p.Pool.SetMaxInFlight(inflight)
+	p.Pool.SetMaxAliasDepth(depth)
+	if p.Expired {
+		p.Pool.SetExpiryHook(p.LogExpired)
+	}
+	if p.Throttle {
+		p.Pool.SetThrottleHook(p.LogThrottle)
+	}
+	if rcvbuf > 0 {
+		p.Pool.SetReceiveBuffer(rcvbuf)
+	}
+	if bufsize < 0 || bufsize > math.MaxUint16 {
+		p.Pool.Stop()
+		return nil, nil, fmt.Errorf("%d is not a valid UDP payload size", bufsize)
+	}
+	p.Pool.SetEDNSBufferSize(uint16(bufsize))
+	p.Pool.SetEDNSDisabled(noedns)
+	p.Pool.SetCookies(cookies)
+	if latency > 0 {
+		p.Pool.SetLatencyTracing(latency)
+	}
+	if rdeadline > 0 {
+		p.Pool.SetReadDeadline(time.Duration(rdeadline) * time.Millisecond)
+	}
+	if circuit > 0 {
+		if err := p.SetupCircuitBreaker(circuit); err != nil {
+			p.Pool.Stop()
+			return nil, nil, fmt.Errorf("failed to setup the circuit breaker: %v", err)
+		}
+	}
+	if budget > 0 {
+		p.Pool.SetMemoryBudget(uint64(budget) << 20)
+	}
+	p.Pool.SetDenialProofs(p.Proofs)
+	p.Pool.SetNXDomainPruning(p.Prune)
+	p.Pool.SetNXDomainVerification(p.VerifyNX)
 	return p, nil, nil
 }
 
@@ -125,6 +425,7 @@ func (p *params) SetupFiles(lpath, opath, ipath string) error {
 	if !p.Quiet {
 		p.Output = os.Stdout
 	}
+	p.OFile = opath
 	if opath != "" {
 		f, err := os.OpenFile(opath, os.O_RDWR|os.O_CREATE, 0666)
 		if err != nil {
@@ -144,87 +445,593 @@ func (p *params) SetupFiles(lpath, opath, ipath string) error {
 	return nil
 }
 
+// ValidateOptions checks the options used to create the resolver pool before it is started.
+func ValidateOptions(p *params, list []string, timeout, inflight, depth int, detector string) error {
+	cfg := &resolve.Config{
+		Resolvers:     list,
+		QPS:           p.QPS,
+		Timeout:       time.Duration(timeout) * time.Millisecond,
+		Retries:       p.Retries,
+		MaxInFlight:   inflight,
+		MaxAliasDepth: depth,
+	}
+	if detector != "" && detector != "auto" {
+		cfg.Detectors = strings.Split(detector, ",")
+		cfg.Quorum = p.Quorum
+	}
+	return resolve.Validate(cfg)
+}
+
 func (p *params) SetupResolverPool(list []string, rpath string, timeout int, detector string) error {
 	p.Pool = resolve.NewResolvers()
+	p.Pool.SetTCPOnly(p.TCPOnly)
+	if p.Bootstrap != "" {
+		p.Pool.SetBootstrapServer(p.Bootstrap)
+	}
+	if p.Denylist != "" {
+		deny, err := resolve.LoadDenylist(p.Denylist)
+		if err != nil {
+			p.Pool.Stop()
+			return fmt.Errorf("failed to load the denylist: %v", err)
+		}
+		p.Pool.SetDenylist(deny)
+	}
 
 	// Load DNS resolvers into the pool
-	if l := len(list); l == 0 || rpath != "" {
+	if p.System {
+		if _, err := p.Pool.AddSystemResolvers(p.QPS); err != nil {
+			p.Pool.Stop()
+			return fmt.Errorf("failed to add the system resolvers: %v", err)
+		}
+	} else if l := len(list); (l == 0 && len(p.TLS) == 0) || rpath != "" {
 		list = append(list, ResolverFileList(rpath)...)
 	}
+	p.RFile = rpath
 	if err := p.Pool.AddResolvers(p.QPS, list...); err != nil {
 		p.Pool.Stop()
 		return fmt.Errorf("failed to add the resolvers at a QPS of %d: %v", p.QPS, err)
 	}
+	if len(p.TLS) > 0 {
+		if err := p.Pool.AddTLSResolvers(p.QPS, nil, p.TLS...); err != nil {
+			p.Pool.Stop()
+			return fmt.Errorf("failed to add the DNS-over-TLS resolvers: %v", err)
+		}
+	}
 	// Set the DNS query timeout value using the provided interval
 	if timeout > 0 {
 		p.Pool.SetTimeout(time.Duration(timeout) * time.Millisecond)
 	}
 	// Attempt to set a resolver to perform DNS wildcard detection
-	if detector != "" {
-		if _, _, err := net.SplitHostPort(detector); err != nil && net.ParseIP(detector) == nil {
-			p.Pool.Stop()
-			return fmt.Errorf("failed to provide a valid IP address for DNS wildcard detection: %s", detector)
+	if detector == "auto" {
+		p.Pool.SetAuthoritativeDetection(p.QPS)
+		p.Pool.SetAuthoritativeAnswers(p.RequireAA)
+		p.Detection = true
+	} else if detector != "" {
+		detectors := strings.Split(detector, ",")
+
+		for _, d := range detectors {
+			if _, _, err := net.SplitHostPort(d); err != nil && net.ParseIP(d) == nil {
+				p.Pool.Stop()
+				return fmt.Errorf("failed to provide a valid IP address for DNS wildcard detection: %s", d)
+			}
 		}
-		p.Pool.SetDetectionResolver(p.QPS, detector)
+		p.Pool.SetDetectionResolvers(p.QPS, p.Quorum, detectors...)
 		p.Detection = true
 	}
 	return nil
 }
 
+// SetupRoutingRules replaces the rules of the resolver pool with those found in the file.
+func (p *params) SetupRoutingRules(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rules, err := ReadRoutingRules(f)
+	if err != nil {
+		return err
+	}
+
+	if err := p.Pool.SetRoutingRules(p.QPS, rules...); err != nil {
+		return err
+	}
+
+	p.Routes = path
+	return nil
+}
+
+// SetupEnricher assigns the pfx2as file as the source of details for the resolved addresses.
+func (p *params) SetupEnricher(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pfx, err := ReadPfx2AS(f)
+	if err != nil {
+		return err
+	}
+
+	p.Pool.SetEnricher(pfx)
+	return nil
+}
+
+// SetupCache loads the results in the JSON output file of a previous run into the response cache
+// of the pool, which also stores the responses received during this run. The cache starts empty
+// without the path, and the TTLs of the responses are clamped by the "min,max" limits when provided.
+func (p *params) SetupCache(path, limits string) error {
+	cache := resolve.NewCache()
+
+	if limits != "" {
+		min, max, err := parseDurationRange(limits)
+		if err != nil {
+			return err
+		}
+		cache.SetTTLLimits(min, max)
+	}
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if _, err := cache.Load(f); err != nil {
+			return err
+		}
+	}
+
+	p.Pool.SetCache(cache)
+	return nil
+}
+
+// SetupReplay reads the queries to be replayed from the output file of a previous run.
+func (p *params) SetupReplay(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	queries, err := ReadReplayQueries(f)
+	if err != nil {
+		return err
+	}
+	if len(queries) == 0 {
+		return fmt.Errorf("no queries were found in %s", path)
+	}
+
+	p.Replay = queries
+	return nil
+}
+
+// SetupWildcards reads the wildcards found by a previous run from its JSON zone reports.
+func (p *params) SetupWildcards(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	levels, err := ReadWildcardLevels(f)
+	if err != nil {
+		return err
+	}
+
+	p.Wildcards = levels
+	return nil
+}
+
+// SetupSearch configures the pool to expand names using the provided search domains, or the
+// search domains and ndots value of the host system when "system" is provided.
+func (p *params) SetupSearch(search []string, ndots int) error {
+	if len(search) == 1 && search[0] == "system" {
+		cfg, err := resolve.SystemResolverConfig()
+		if err != nil {
+			return err
+		}
+
+		search = cfg.Search
+		if ndots <= 0 {
+			ndots = cfg.Ndots
+		}
+	}
+
+	if len(search) > 0 {
+		p.Pool.SetSearchDomains(ndots, search...)
+	}
+	return nil
+}
+
+// LogExpired writes the details of a request that expired without a response to the log.
+func (p *params) LogExpired(name string, qtype uint16, server string, sent time.Time) {
+	p.Log.Printf("Expired: %s %s sent to %s at %s\n", name, dns.TypeToString[qtype], server, sent.Format(time.RFC3339Nano))
+}
+
+// LogThrottle writes the details of a resolver that appears to be rate limiting the queries to the log.
+func (p *params) LogThrottle(ev *resolve.ThrottleEvent) {
+	p.Log.Printf("Throttling: %s %s (%d of %d) at %s\n", ev.Server, ev.Reason, ev.Count, ev.Total, ev.At.Format(time.RFC3339))
+}
+
+// SetupHeaderFlags configures the pool to set the named header bits on each query.
+func (p *params) SetupHeaderFlags(names []string) error {
+	var flags resolve.HeaderFlags
+
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case "rd":
+			flags.RecursionDesired = true
+		case "cd":
+			flags.CheckingDisabled = true
+		case "ad":
+			flags.AuthenticatedData = true
+		default:
+			return fmt.Errorf("%s is not a supported header flag", name)
+		}
+	}
+
+	p.Pool.SetHeaderFlags(flags)
+	return nil
+}
+
+// SetupCircuitBreaker pauses the queries while the fraction of them timing out reaches the threshold.
+func (p *params) SetupCircuitBreaker(threshold float64) error {
+	if threshold <= 0 || threshold > 1 {
+		return fmt.Errorf("%v is not a fraction of the queries", threshold)
+	}
+
+	opts := resolve.DefaultCircuitBreakerOptions
+	opts.Threshold = threshold
+	p.Pool.SetCircuitBreaker(&opts)
+	return nil
+}
+
+// SetupHijacking removes the resolvers found answering random nonexistent names with the same addresses.
+func (p *params) SetupHijacking() error {
+	for _, rep := range p.Pool.DetectHijacking(context.Background(), resolve.DefaultHijackProbes) {
+		p.Log.Printf("Hijacking resolver: %s answered nonexistent names with %s", rep.Address, strings.Join(rep.Answers, ", "))
+	}
+	if p.Pool.Len() == 0 {
+		return errors.New("all the resolvers answer nonexistent names")
+	}
+	return nil
+}
+
+// SetupFiltering probes the resolvers with the canary names and excludes or annotates the
+// resolvers found filtering answers, as selected by the mode.
+func (p *params) SetupFiltering(mode string) error {
+	var exclude bool
+
+	switch strings.ToLower(mode) {
+	case "exclude":
+		exclude = true
+	case "annotate":
+	default:
+		return fmt.Errorf("%s is not a supported filtering mode", mode)
+	}
+
+	for _, rep := range p.Pool.DetectFiltering(context.Background(), exclude) {
+		p.Log.Printf("Filtering resolver: %s blocked %s", rep.Address, strings.Join(rep.Blocked, ", "))
+	}
+	if p.Pool.Len() == 0 {
+		return errors.New("all the resolvers filter answers")
+	}
+	return nil
+}
+
+// SetupPacketLogging writes the packets sent and received for the names matched by the glob
+// pattern to the log.
+func (p *params) SetupPacketLogging(glob string, hexdump bool) error {
+	filter, err := resolve.GlobFilter(glob)
+	if err != nil {
+		return err
+	}
+
+	p.Pool.SetLogger(p.Log)
+	p.Pool.SetPacketLogging(filter, hexdump)
+	return nil
+}
+
+// SetupZoneJitter parses the "min,max" bounds of the random delay between the queries for names
+// within the same zone. A single duration is used as the max with a min of zero.
+func (p *params) SetupZoneJitter(bounds string) error {
+	min, max, err := parseDurationRange(bounds)
+	if err != nil {
+		return err
+	}
+
+	p.Pool.SetZoneJitter(min, max)
+	return nil
+}
+
+// Parses the "min,max" range of durations, where a single duration provides the max.
+func parseDurationRange(bounds string) (time.Duration, time.Duration, error) {
+	var min, max time.Duration
+
+	parts := strings.Split(bounds, ",")
+	if len(parts) > 2 {
+		return 0, 0, fmt.Errorf("%s is not a valid range of durations", bounds)
+	}
+	for i, part := range parts {
+		d, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil {
+			return 0, 0, err
+		}
+		if d < 0 {
+			return 0, 0, fmt.Errorf("%s is not a valid range of durations", bounds)
+		}
+		if i == 0 && len(parts) == 2 {
+			min = d
+		} else {
+			max = d
+		}
+	}
+	if max < min {
+		return 0, 0, fmt.Errorf("the min of %s is greater than the max", bounds)
+	}
+	return min, max, nil
+}
+
+// SetupQueryKey selects how the names are compared while tracking the queries sent for them.
+func (p *params) SetupQueryKey(mode string) error {
+	switch strings.ToLower(mode) {
+	case "fold":
+		p.Key = resolve.FoldedQueryKey
+	case "exact":
+		p.Key = resolve.ExactQueryKey
+	default:
+		return fmt.Errorf("%s is not a supported dedup mode", mode)
+	}
+	return nil
+}
+
+// SetupNameOrder selects the order in which the names read from the input are resolved.
+func (p *params) SetupNameOrder(mode string) error {
+	switch strings.ToLower(mode) {
+	case "input":
+		p.Order = resolve.InputOrder
+	case "shuffle":
+		p.Order = resolve.ShuffleOrder
+	case "interleave":
+		p.Order = resolve.InterleaveOrder
+	case "depth":
+		p.Order = resolve.DepthFirstOrder
+	case "breadth":
+		p.Order = resolve.BreadthFirstOrder
+	default:
+		return fmt.Errorf("%s is not a supported name order", mode)
+	}
+	return nil
+}
+
+// Returns the key identifying the tracker of the name, so names considered the same share one.
+func trackerKey(p *params, name string) string {
+	key := p.Key
+	if key == nil {
+		key = resolve.FoldedQueryKey
+	}
+	return key(dns.Question{Name: dns.Fqdn(name), Qtype: dns.TypeNone, Qclass: dns.ClassINET})
+}
+
+// Returns the tracker of the name in the question of a response. When the names are compared
+// exactly and the server changed the case of the name, the tracker with the same name ignoring
+// the case is returned, as long as only one exists.
+func findTracker(p *params, names map[string]*nameTracker, name string) (*nameTracker, bool) {
+	if tracker, found := names[trackerKey(p, name)]; found {
+		return tracker, true
+	}
+
+	var match *nameTracker
+	name = resolve.RemoveLastDot(name)
+	for _, tracker := range names {
+		if strings.EqualFold(tracker.Name, name) {
+			if match != nil {
+				return nil, false
+			}
+			match = tracker
+		}
+	}
+	return match, match != nil
+}
+
+// nameTracker follows the queries for each type requested for a DNS name, so the responses
+// can be output together once all the types have been handled.
+type nameTracker struct {
+	Name      string
+	Attempts  map[uint16]int
+	Pending   int
+	Failures  int
+	Filtered  int
+	Responses []*dns.Msg
+}
+
+// Resolved returns true when a response with answers was obtained for the name.
+func (t *nameTracker) Resolved() bool {
+	for _, resp := range t.Responses {
+		if resp.Rcode == dns.RcodeSuccess && len(resp.Answer) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func newNameTracker(name string, qtypes []uint16) *nameTracker {
+	t := &nameTracker{
+		Name:     name,
+		Attempts: make(map[uint16]int, len(qtypes)),
+		Pending:  len(qtypes),
+	}
+
+	for _, qtype := range qtypes {
+		t.Attempts[qtype] = 1
+	}
+	return t
+}
+
 func EventLoop(p *params) {
 	var avg float32 = 1.0
-	var count, persec, processing int
+	var persec, processing, searching int
+	stats := new(ScanStats)
+	reports := NewZoneReports()
 	finished := queue.NewQueue()
 	responses := make(chan *dns.Msg, p.QPS*2)
-	queries := make(map[string]int, p.QPS)
+	searched := make(chan *dns.Msg)
+	names := make(map[string]*nameTracker, p.QPS)
+	zones := make(map[string]struct{})
+	seen := make(map[string]struct{})
+	var addrs []net.IP
 	t := time.NewTicker(time.Second)
 	defer t.Stop()
 
+	complete := func(tracker *nameTracker) {
+		stats.Add(tracker)
+		if p.Report != "" {
+			reports.Add(tracker)
+		}
+		if p.Scheduler != nil {
+			p.Scheduler.Observe(tracker.Name, tracker.Resolved())
+		}
+		if p.CIDRs {
+			for _, resp := range tracker.Responses {
+				addrs = append(addrs, resolve.IPs(resp)...)
+			}
+		}
+	}
+
 	for {
+		// Stop reading new names while the memory budget is exceeded and names are outstanding
+		requests := p.Requests
+		if len(names) > 0 && p.Pool.OverMemoryBudget() {
+			requests = nil
+		}
+
 		select {
 		case <-t.C:
 			p.Log.Printf("Resolved %d DNS names that averaged %.2f query attempts\n", persec, avg)
 			avg, persec = 1.0, 0
-		case name := <-p.Requests:
-			count += len(p.Qtypes)
-			sendInitialRequests(context.Background(), name, queries, responses, p)
+		case name := <-requests:
+			if p.Profile != nil && !p.Profile.Permits(name) {
+				continue
+			}
+			// The search candidates are tried in order, and only the name that resolves is tracked
+			if candidates := p.Pool.SearchNames(name); len(candidates) > 1 {
+				searching++
+				go searchName(context.Background(), name, searched, p)
+			} else if key := trackerKey(p, candidates[0]); names[key] == nil {
+				names[key] = newNameTracker(candidates[0], p.Qtypes)
+				sendInitialRequests(context.Background(), candidates[0], responses, p)
+			}
+		case resp := <-searched:
+			searching--
+			name := resolve.RemoveLastDot(resp.Question[0].Name)
+			if key := trackerKey(p, name); names[key] == nil {
+				names[key] = newNameTracker(name, p.Qtypes)
+				// The response obtained by the search is handled as the response for the first query type
+				for _, qtype := range p.Qtypes[1:] {
+					p.Pool.Query(context.Background(), resolve.QueryMsg(name, qtype), responses)
+				}
+				go func() { responses <- resp }()
+			}
 		case resp := <-responses:
-			name := resolve.RemoveLastDot(strings.ToLower(resp.Question[0].Name))
-			k := key(name, resp.Question[0].Qtype)
+			qtype := resp.Question[0].Qtype
+
+			tracker, found := findTracker(p, names, resp.Question[0].Name)
+			if !found {
+				continue
+			}
+			name := tracker.Name
 			// Check if there was an error or timeout requiring another attempt
 			if resp.Rcode == resolve.RcodeNoResponse {
-				queries[k]++
-				if queries[k] <= p.Retries {
-					p.Pool.Query(context.Background(), resolve.QueryMsg(name, resp.Question[0].Qtype), responses)
+				tracker.Attempts[qtype]++
+				if tracker.Attempts[qtype] <= p.Retries {
+					p.Pool.Query(context.Background(), resolve.QueryMsg(name, qtype), responses)
 					continue
 				}
+				tracker.Failures++
 			} else {
 				persec++
-				avg = update(avg, float32(queries[k]), float32(persec))
-				if p.Output != nil {
-					processing++
-					go processResponse(context.Background(), name, resp, finished, p)
+				avg = update(avg, float32(tracker.Attempts[qtype]), float32(persec))
+				tracker.Responses = append(tracker.Responses, resp)
+			}
+
+			tracker.Pending--
+			if tracker.Pending > 0 {
+				continue
+			}
+			if p.Mine {
+				for _, n := range MinedNames(tracker, seen) {
+					if k := trackerKey(p, n); names[k] == nil {
+						names[k] = newNameTracker(n, p.Qtypes)
+						sendInitialRequests(context.Background(), n, responses, p)
+					}
 				}
 			}
-			count--
-			delete(queries, k)
+			if p.Health && len(tracker.Responses) > 0 {
+				if zone, err := publicsuffix.EffectiveTLDPlusOne(name); err == nil {
+					zones[zone] = struct{}{}
+				}
+			}
+			if (p.Output != nil || p.Stats || p.Report != "" || p.CIDRs || p.MinTTL > 0) && len(tracker.Responses) > 0 {
+				processing++
+				go processResponses(context.Background(), tracker, finished, p)
+			} else {
+				complete(tracker)
+			}
+			delete(names, trackerKey(p, name))
 		case <-finished.Signal():
 			if e, ok := finished.Next(); ok && e != nil {
-				fmt.Fprintf(p.Output, "\n%s\n", e.(*dns.Msg))
+				tracker := e.(*nameTracker)
+
+				complete(tracker)
+				if !p.Stats && p.Output != nil {
+					WriteResponses(p, tracker)
+				}
 			}
 			processing--
 		}
 		// Have all the queries been handled?
-		if count == 0 && processing == 0 && len(queries) == 0 {
+		if processing == 0 && searching == 0 && len(names) == 0 {
+			// Responses lost in the local socket buffers are not the fault of the resolvers
+			if stats.Dropped = p.Pool.ReceiveDrops(); stats.Dropped > 0 {
+				p.Log.Printf("Responses dropped by the kernel on the local sockets: %d", stats.Dropped)
+			}
+			if n := p.Pool.ReplacedSockets(); n > 0 {
+				p.Log.Printf("Stuck sockets replaced during the run: %d", n)
+			}
+			if n := p.Pool.CircuitTrips(); n > 0 {
+				p.Log.Printf("Queries paused by the circuit breaker during the run: %d times", n)
+			}
+			if rep := p.Pool.LatencyReport(); rep.Samples > 0 {
+				WriteLatencyReport(p.Log.Writer(), rep)
+			}
+			if p.Stats {
+				WriteStats(p, stats)
+			}
+			if p.Report != "" {
+				if err := WriteZoneReports(context.Background(), p, reports); err != nil {
+					p.Log.Printf("Failed to write the zone reports: %v", err)
+				}
+			}
+			if p.Health {
+				WriteHealth(context.Background(), p, zones)
+			}
+			if p.CIDRs {
+				WriteCIDRSummary(p, addrs)
+			}
+			if p.RStats {
+				WriteResolverStats(p.Log.Writer(), p.Pool.ResolverStats())
+			}
+			if p.VerifyNX {
+				p.Log.Printf("NXDOMAIN responses overturned by the authoritative servers: %d", p.Pool.OverturnedNXDomains())
+			}
 			return
 		}
 	}
 }
 
-func key(name string, qtype uint16) string {
-	return name + strconv.Itoa(int(qtype))
-}
-
 func update(avg, item, n float32) float32 {
 	if a := ((avg * (n - 1)) / n) + (item / n); a > 1.0 {
 		return a
@@ -232,21 +1039,80 @@ func update(avg, item, n float32) float32 {
 	return 1.0
 }
 
+// MinedNames returns the hostnames found in the responses of the tracker that are within the same
+// registered domain as the tracked name, and have not already been returned according to seen.
+func MinedNames(tracker *nameTracker, seen map[string]struct{}) []string {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(tracker.Name)
+	if err != nil {
+		return nil
+	}
+	seen[tracker.Name] = struct{}{}
+
+	var mined []string
+	for _, resp := range tracker.Responses {
+		for _, n := range resolve.MineNames(resp) {
+			if _, found := seen[n]; found || !dns.IsSubDomain(domain, n) {
+				continue
+			}
+			seen[n] = struct{}{}
+			mined = append(mined, n)
+		}
+	}
+	return mined
+}
+
+// Queries the search candidates of the name in order for the first query type, and sends the
+// response of the first candidate that resolves, or of the last candidate when none of them do.
+func searchName(ctx context.Context, name string, searched chan *dns.Msg, p *params) {
+	resp, err := p.Pool.QuerySearch(ctx, name, p.Qtypes[0])
+	if err != nil || resp == nil || len(resp.Question) == 0 {
+		candidates := p.Pool.SearchNames(name)
+		resp = resolve.QueryMsg(candidates[len(candidates)-1], p.Qtypes[0])
+		resp.Rcode = resolve.RcodeNoResponse
+	}
+	searched <- resp
+}
+
 // New names generate a request for each query type.
-func sendInitialRequests(ctx context.Context, name string, queries map[string]int, responses chan *dns.Msg, p *params) {
+func sendInitialRequests(ctx context.Context, name string, responses chan *dns.Msg, p *params) {
 	for _, qtype := range p.Qtypes {
-		queries[key(name, qtype)] = 1
 		p.Pool.Query(ctx, resolve.QueryMsg(name, qtype), responses)
 	}
 }
 
-func processResponse(ctx context.Context, name string, resp *dns.Msg, out queue.Queue, p *params) {
-	if p.Detection {
-		domain, err := publicsuffix.EffectiveTLDPlusOne(name)
+// The responses for a name are filtered for DNS wildcards before being queued for output.
+func processResponses(ctx context.Context, tracker *nameTracker, out queue.Queue, p *params) {
+	var group []*dns.Msg
+
+	for _, resp := range tracker.Responses {
+		if p.Detection {
+			domain, err := publicsuffix.EffectiveTLDPlusOne(tracker.Name)
 
-		if err != nil || p.Pool.WildcardDetected(ctx, resp, domain) {
-			resp = nil
+			if err != nil || p.Pool.WildcardDetected(ctx, resp, domain) {
+				tracker.Filtered++
+				continue
+			}
 		}
+		group = append(group, resp)
 	}
-	out.Append(resp)
+
+	if p.MinTTL > 0 {
+		var flagged bool
+		for _, resp := range group {
+			for _, a := range p.Pool.CheckAnswerAnomalies(ctx, resp, uint32(p.MinTTL)) {
+				p.Log.Println(FormatAnomaly(a))
+				if a.Reason != resolve.AnomalyPrivateAddress {
+					flagged = true
+				}
+			}
+		}
+		if flagged && p.Flux > 0 {
+			if report, err := p.Pool.CheckFastFlux(ctx, tracker.Name, p.Flux, fluxSamples); err == nil {
+				p.Log.Println(FormatFastFlux(report))
+			}
+		}
+	}
+
+	tracker.Responses = group
+	out.Append(tracker)
 }
@@ -5,17 +5,21 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
 )
 
 func TestObtainParams(t *testing.T) {
@@ -201,6 +205,30 @@ func TestSetupFiles(t *testing.T) {
 	}
 }
 
+func TestValidateOptions(t *testing.T) {
+	p := &params{QPS: 10, Retries: 3, Quorum: 2}
+
+	if err := ValidateOptions(p, []string{"8.8.8.8", "dns.google:53"}, 500, 0, 0, "8.8.8.8,1.1.1.1"); err != nil {
+		t.Errorf("the valid options were rejected: %v", err)
+	}
+	if err := ValidateOptions(p, nil, 500, 0, 0, "auto"); err != nil {
+		t.Errorf("the automatic detection was rejected: %v", err)
+	}
+	if err := ValidateOptions(p, []string{"8.8.8.8:99999"}, 500, 0, 0, ""); err == nil {
+		t.Errorf("the resolver with an invalid port was accepted")
+	}
+	if err := ValidateOptions(p, nil, 500, -1, 0, ""); err == nil {
+		t.Errorf("the negative in-flight limit was accepted")
+	}
+	if err := ValidateOptions(p, nil, 500, 0, 0, "8.8.8.8"); err == nil {
+		t.Errorf("the quorum greater than the detectors was accepted")
+	}
+	p.QPS = 0
+	if err := ValidateOptions(p, nil, 500, 0, 0, ""); err == nil {
+		t.Errorf("the QPS of zero was accepted")
+	}
+}
+
 func TestSetupResolverPool(t *testing.T) {
 	cases := []struct {
 		label    string
@@ -220,6 +248,11 @@ func TestSetupResolverPool(t *testing.T) {
 			label:    "Invalid detector resolver",
 			qps:      1,
 			detector: "Not an IP",
+		}, {
+			label:    "Authoritative detection",
+			qps:      1,
+			detector: "auto",
+			ok:       true,
 		},
 	}
 
@@ -235,6 +268,342 @@ func TestSetupResolverPool(t *testing.T) {
 	}
 }
 
+func TestSetupResolverPoolTCPOnly(t *testing.T) {
+	dns.HandleFunc("caffix.net.", eventLoopHandler)
+	defer dns.HandleRemove("caffix.net.")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen for TCP: %v", err)
+	}
+	s, addrstr, _, err := RunLocalServer(nil, l)
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	p := &params{QPS: 10, TCPOnly: true}
+	if err := p.SetupResolverPool([]string{addrstr}, "", 0, ""); err != nil {
+		t.Fatalf("failed to setup the resolver pool: %v", err)
+	}
+	defer p.Pool.Stop()
+
+	resp, err := p.Pool.QueryBlocking(context.Background(), resolve.QueryMsg("www.caffix.net", dns.TypeA))
+	if err != nil || len(resolve.ExtractAnswers(resp)) == 0 {
+		t.Errorf("the query was not answered over TCP: %v", err)
+	}
+}
+
+func TestSetupResolverPoolDenylist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	if err := os.WriteFile(path, []byte("127.0.0.1:5301\n"), 0644); err != nil {
+		t.Fatalf("failed to write the denylist: %v", err)
+	}
+
+	p := &params{QPS: 10, Denylist: path}
+	if err := p.SetupResolverPool([]string{"127.0.0.1:5301", "127.0.0.1:5302"}, "", 0, ""); err != nil {
+		t.Fatalf("failed to setup the resolver pool: %v", err)
+	}
+	defer p.Pool.Stop()
+
+	if l := p.Pool.Len(); l != 1 {
+		t.Errorf("Got: %d resolvers; Expected: 1", l)
+	}
+
+	p = &params{QPS: 10, Denylist: t.TempDir()}
+	if err := p.SetupResolverPool([]string{"127.0.0.1:5302"}, "", 0, ""); err == nil {
+		p.Pool.Stop()
+		t.Errorf("the resolver pool was setup with a denylist that could not be read")
+	}
+}
+
+func TestSetupSearch(t *testing.T) {
+	p := &params{Pool: resolve.NewResolvers()}
+	defer p.Pool.Stop()
+
+	if err := p.SetupSearch([]string{"corp.caffix.net"}, 2); err != nil {
+		t.Fatalf("failed to setup the search domains: %v", err)
+	}
+	if names := p.Pool.SearchNames("www.dev"); len(names) != 2 || names[0] != "www.dev.corp.caffix.net" {
+		t.Errorf("the search domains were not applied to the pool: %v", names)
+	}
+}
+
+func TestSetupHeaderFlags(t *testing.T) {
+	p := &params{Pool: resolve.NewResolvers()}
+	defer p.Pool.Stop()
+
+	if err := p.SetupHeaderFlags([]string{"RD", "cd"}); err != nil {
+		t.Errorf("failed to setup the header flags: %v", err)
+	}
+	if err := p.SetupHeaderFlags([]string{"rd", "do"}); err == nil {
+		t.Errorf("an unsupported header flag was accepted")
+	}
+}
+
+func TestSetupPacketLogging(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p := &params{Log: log.New(buf, "", 0), Pool: resolve.NewResolvers()}
+	defer p.Pool.Stop()
+
+	if err := p.SetupPacketLogging("*.owasp.org", true); err != nil {
+		t.Errorf("failed to setup the packet logging: %v", err)
+	}
+}
+
+func TestObtainParamsProfile(t *testing.T) {
+	p, _, err := ObtainParams([]string{"-screen", "-maxlabel", "20", "-maxentropy", "3.5"})
+	if err != nil {
+		t.Fatalf("failed to obtain the parameters: %v", err)
+	}
+	defer p.Pool.Stop()
+
+	if p.Profile == nil || p.Profile.MaxLabelLength != 20 || p.Profile.MaxEntropy != 3.5 || !p.Profile.AllowUnderscore {
+		t.Errorf("the name profile was not setup from the flags: %+v", p.Profile)
+	}
+	if !p.Profile.Permits("_dmarc.caffix.net") || p.Profile.Permits("bad_name.caffix.net") {
+		t.Errorf("the name profile did not screen the names as expected")
+	}
+
+	p, _, err = ObtainParams([]string{})
+	if err != nil {
+		t.Fatalf("failed to obtain the parameters: %v", err)
+	}
+	defer p.Pool.Stop()
+
+	if p.Profile != nil {
+		t.Errorf("the names are screened without the flags being provided")
+	}
+}
+
+func TestObtainParamsBufferSize(t *testing.T) {
+	p, _, err := ObtainParams([]string{"-bufsize", "1232", "-noedns", "-cookies"})
+	if err != nil {
+		t.Fatalf("failed to obtain the parameters: %v", err)
+	}
+	p.Pool.Stop()
+
+	for _, size := range []string{"-1", "70000"} {
+		if p, _, err := ObtainParams([]string{"-bufsize", size}); err == nil {
+			p.Pool.Stop()
+			t.Errorf("the invalid UDP payload size %s was accepted", size)
+		}
+	}
+}
+
+func TestSetupZoneJitter(t *testing.T) {
+	p := &params{Pool: resolve.NewResolvers()}
+	defer p.Pool.Stop()
+
+	for _, bounds := range []string{"100ms,2s", "500ms", "0s, 1s"} {
+		if err := p.SetupZoneJitter(bounds); err != nil {
+			t.Errorf("failed to setup the zone jitter %s: %v", bounds, err)
+		}
+	}
+	for _, bounds := range []string{"2s,100ms", "1s,2s,3s", "fast", "-1s"} {
+		if err := p.SetupZoneJitter(bounds); err == nil {
+			t.Errorf("the invalid zone jitter %s was accepted", bounds)
+		}
+	}
+}
+
+func TestSetupCache(t *testing.T) {
+	f, err := os.CreateTemp("", "cache")
+	if err != nil {
+		t.Fatalf("failed to create the cache file: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, _ = f.WriteString(`{"name":"www.caffix.net","records":{"A":["192.168.1.1"]}}` + "\n")
+	f.Close()
+
+	p := &params{Pool: resolve.NewResolvers()}
+	defer p.Pool.Stop()
+
+	if err := p.SetupCache(f.Name(), ""); err != nil {
+		t.Fatalf("failed to setup the cache: %v", err)
+	}
+
+	resp, err := p.Pool.QueryBlocking(context.Background(), resolve.QueryMsg("www.caffix.net", dns.TypeA))
+	if err != nil || len(resolve.ExtractAnswers(resp)) != 1 {
+		t.Errorf("the query was not answered from the cache")
+	}
+	if err := p.SetupCache(f.Name()+".missing", ""); err == nil {
+		t.Errorf("a missing cache file was accepted")
+	}
+	if err := p.SetupCache("", "60s,24h"); err != nil {
+		t.Errorf("failed to setup the cache with TTL limits: %v", err)
+	}
+	if err := p.SetupCache("", "24h,60s"); err == nil {
+		t.Errorf("the invalid cache TTL limits were accepted")
+	}
+}
+
+func TestSetupNameOrder(t *testing.T) {
+	p := new(params)
+
+	for mode, order := range map[string]resolve.NameOrder{
+		"input":      resolve.InputOrder,
+		"Shuffle":    resolve.ShuffleOrder,
+		"interleave": resolve.InterleaveOrder,
+		"depth":      resolve.DepthFirstOrder,
+		"breadth":    resolve.BreadthFirstOrder,
+	} {
+		if err := p.SetupNameOrder(mode); err != nil || p.Order != order {
+			t.Errorf("failed to setup the %s name order: %v", mode, err)
+		}
+	}
+	if err := p.SetupNameOrder("random"); err == nil {
+		t.Errorf("an unsupported name order was accepted")
+	}
+}
+
+func TestSetupQueryKey(t *testing.T) {
+	p := new(params)
+
+	if trackerKey(p, "WWW.owasp.org") != trackerKey(p, "www.owasp.org.") {
+		t.Errorf("the names were not folded without a query key")
+	}
+	if err := p.SetupQueryKey("Exact"); err != nil {
+		t.Errorf("failed to setup the query key: %v", err)
+	}
+	if trackerKey(p, "WWW.owasp.org") == trackerKey(p, "www.owasp.org") ||
+		trackerKey(p, "www.owasp.org") != trackerKey(p, "www.owasp.org.") {
+		t.Errorf("the exact query key was not used for the names")
+	}
+	if err := p.SetupQueryKey("class"); err == nil {
+		t.Errorf("an unsupported dedup mode was accepted")
+	}
+}
+
+func TestFindTracker(t *testing.T) {
+	p := new(params)
+	_ = p.SetupQueryKey("exact")
+
+	names := make(map[string]*nameTracker)
+	for _, name := range []string{"www.owasp.org", "Mail.owasp.org", "mail.owasp.org"} {
+		names[trackerKey(p, name)] = newNameTracker(name, []uint16{dns.TypeA})
+	}
+
+	if tracker, found := findTracker(p, names, "Mail.owasp.org."); !found || tracker.Name != "Mail.owasp.org" {
+		t.Errorf("the tracker with the exact name was not returned: %v", tracker)
+	}
+	// The server changed the case of the name in the response
+	if tracker, found := findTracker(p, names, "WwW.OWASP.org."); !found || tracker.Name != "www.owasp.org" {
+		t.Errorf("the tracker was not found after the case of the name changed: %v", tracker)
+	}
+	if _, found := findTracker(p, names, "MAIL.owasp.org."); found {
+		t.Errorf("a tracker was returned when the case-folded name matched multiple trackers")
+	}
+	if _, found := findTracker(p, names, "ftp.owasp.org."); found {
+		t.Errorf("a tracker was returned for an unknown name")
+	}
+}
+
+func TestSetupFiltering(t *testing.T) {
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET},
+			A:   net.ParseIP("192.0.2.1"),
+		})
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	buf := new(bytes.Buffer)
+	p := &params{Log: log.New(buf, "", 0), Pool: resolve.NewResolvers()}
+	defer p.Pool.Stop()
+	_ = p.Pool.AddResolvers(100, addrstr)
+
+	if err := p.SetupFiltering("Exclude"); err != nil || p.Pool.Len() != 1 || buf.Len() != 0 {
+		t.Errorf("the resolver was reported as filtering: %v %s", err, buf.String())
+	}
+	if err := p.SetupFiltering("remove"); err == nil {
+		t.Errorf("an unsupported filtering mode was accepted")
+	}
+}
+
+func TestSetupCircuitBreaker(t *testing.T) {
+	p := &params{Pool: resolve.NewResolvers()}
+	defer p.Pool.Stop()
+
+	if err := p.SetupCircuitBreaker(0.9); err != nil {
+		t.Errorf("failed to setup the circuit breaker: %v", err)
+	}
+	for _, threshold := range []float64{0, -0.5, 1.5} {
+		if err := p.SetupCircuitBreaker(threshold); err == nil {
+			t.Errorf("the invalid threshold %v was accepted", threshold)
+		}
+	}
+}
+
+func TestSetupHijacking(t *testing.T) {
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET},
+			A:   net.ParseIP("192.0.2.1"),
+		})
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	buf := new(bytes.Buffer)
+	p := &params{Log: log.New(buf, "", 0), Pool: resolve.NewResolvers()}
+	defer p.Pool.Stop()
+	_ = p.Pool.AddResolvers(100, addrstr)
+
+	if err := p.SetupHijacking(); err == nil || p.Pool.Len() != 0 || !strings.Contains(buf.String(), "192.0.2.1") {
+		t.Errorf("the resolver answering every name was not removed: %v %s", err, buf.String())
+	}
+}
+
+func TestLogExpired(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p := &params{Log: log.New(buf, "", 0)}
+
+	sent := time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC)
+	p.LogExpired("www.caffix.net", dns.TypeA, "192.168.1.1:53", sent)
+
+	expected := "Expired: www.caffix.net A sent to 192.168.1.1:53 at 2024-01-02T03:04:05Z\n"
+	if got := buf.String(); got != expected {
+		t.Errorf("Got: %q; Expected: %q", got, expected)
+	}
+}
+
+func TestLogThrottle(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p := &params{Log: log.New(buf, "", 0)}
+
+	p.LogThrottle(&resolve.ThrottleEvent{
+		Server: "192.168.1.1:53",
+		Reason: resolve.ThrottleRefusals,
+		Count:  20,
+		Total:  25,
+		At:     time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC),
+	})
+
+	expected := "Throttling: 192.168.1.1:53 REFUSED spike (20 of 25) at 2024-01-02T03:04:05Z\n"
+	if got := buf.String(); got != expected {
+		t.Errorf("Got: %q; Expected: %q", got, expected)
+	}
+}
+
 func TestEventLoop(t *testing.T) {
 	dns.HandleFunc("caffix.net.", eventLoopHandler)
 	defer dns.HandleRemove("caffix.net.")
@@ -343,3 +712,142 @@ func RunLocalServer(pc net.PacketConn, l net.Listener, opts ...func(*dns.Server)
 	waitLock.Lock()
 	return server, addr, fin, nil
 }
+
+func TestEventLoopGroupsByName(t *testing.T) {
+	dns.HandleFunc("caffix.net.", eventLoopHandler)
+	defer dns.HandleRemove("caffix.net.")
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("Unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	output, err := os.CreateTemp("", "output")
+	if err != nil {
+		t.Fatalf("Failed to open the temporary output file: %v", err)
+	}
+	defer os.Remove(output.Name())
+
+	p := &params{
+		Log:     log.New(io.Discard, "", 0),
+		QPS:     10,
+		Qtypes:  []uint16{dns.TypeA, dns.TypeTXT, dns.TypeMX},
+		Output:  output,
+		Retries: 2,
+	}
+	if err := p.SetupResolverPool([]string{addrstr}, "", 100, ""); err != nil {
+		t.Fatalf("Failed to setup the resolver pool: %v", err)
+	}
+	defer p.Pool.Stop()
+
+	p.Requests = make(chan string, p.QPS)
+	go InputDomainNames(strings.NewReader("www.caffix.net\nmail.caffix.net\nftp.caffix.net"), p.Requests)
+	EventLoop(p)
+	output.Close()
+
+	data, err := os.ReadFile(output.Name())
+	if err != nil {
+		t.Fatalf("Failed to read the output file: %v", err)
+	}
+
+	var order []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, ";") && strings.Contains(line, "\tIN\t") {
+			order = append(order, strings.Fields(strings.TrimPrefix(line, ";"))[0])
+		}
+	}
+	if len(order) != 9 {
+		t.Fatalf("Expected 9 responses in the output, got %d", len(order))
+	}
+	for i := 0; i < len(order); i += 3 {
+		if order[i] != order[i+1] || order[i] != order[i+2] {
+			t.Errorf("The responses for %s were not grouped together: %v", order[i], order)
+		}
+	}
+}
+
+func TestEventLoopSearch(t *testing.T) {
+	dns.HandleFunc("caffix.net.", eventLoopHandler)
+	defer dns.HandleRemove("caffix.net.")
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("Unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	output, err := os.CreateTemp("", "output")
+	if err != nil {
+		t.Fatalf("Failed to open the temporary output file: %v", err)
+	}
+	defer os.Remove(output.Name())
+
+	p := &params{
+		Log:     log.New(io.Discard, "", 0),
+		QPS:     10,
+		Qtypes:  []uint16{dns.TypeA, dns.TypeTXT},
+		Output:  output,
+		Retries: 2,
+	}
+	if err := p.SetupResolverPool([]string{addrstr}, "", 100, ""); err != nil {
+		t.Fatalf("Failed to setup the resolver pool: %v", err)
+	}
+	defer p.Pool.Stop()
+	if err := p.SetupSearch([]string{"caffix.net"}, 2); err != nil {
+		t.Fatalf("Failed to setup the search domains: %v", err)
+	}
+
+	p.Requests = make(chan string, p.QPS)
+	go InputDomainNames(strings.NewReader("www\nmail"), p.Requests)
+	EventLoop(p)
+	output.Close()
+
+	data, err := os.ReadFile(output.Name())
+	if err != nil {
+		t.Fatalf("Failed to read the output file: %v", err)
+	}
+
+	names := make(map[string]int)
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, ";") && strings.Contains(line, "\tIN\t") {
+			names[strings.Fields(strings.TrimPrefix(line, ";"))[0]]++
+		}
+	}
+	// Only the candidates that resolved are tracked, with a response for each query type
+	if len(names) != 2 || names["www.caffix.net."] != 2 || names["mail.caffix.net."] != 2 {
+		t.Errorf("The output did not contain only the names that resolved: %v", names)
+	}
+}
+
+func TestNewNameTracker(t *testing.T) {
+	tracker := newNameTracker("caffix.net", []uint16{dns.TypeA, dns.TypeAAAA})
+
+	if tracker.Pending != 2 || tracker.Attempts[dns.TypeA] != 1 || tracker.Attempts[dns.TypeAAAA] != 1 {
+		t.Errorf("The tracker was not initialized for each query type: %v", tracker)
+	}
+}
+
+func TestMinedNames(t *testing.T) {
+	tracker := newNameTracker("www.caffix.net", []uint16{dns.TypeMX})
+
+	resp := resolve.QueryMsg("www.caffix.net", dns.TypeMX)
+	for _, s := range []string{
+		"www.caffix.net. 300 IN MX 10 mail.caffix.net.",
+		"www.caffix.net. 300 IN MX 20 mx.owasp.org.",
+		"www.caffix.net. 300 IN MX 30 www.caffix.net.",
+	} {
+		if rr, err := dns.NewRR(s); err == nil {
+			resp.Answer = append(resp.Answer, rr)
+		}
+	}
+	tracker.Responses = append(tracker.Responses, resp)
+
+	seen := make(map[string]struct{})
+	if names := MinedNames(tracker, seen); len(names) != 1 || names[0] != "mail.caffix.net" {
+		t.Errorf("Expected only mail.caffix.net to be mined, got %v", names)
+	}
+	if names := MinedNames(tracker, seen); len(names) != 0 {
+		t.Errorf("The names already seen were mined again: %v", names)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+)
+
+// NameRecord aggregates the records obtained across all the query types for a DNS name.
+type NameRecord struct {
+	Name       string                             `json:"name"`
+	Records    map[string][]string                `json:"records"`
+	Chain      []string                           `json:"chain,omitempty"`
+	Filtered   bool                               `json:"filtered,omitempty"`
+	NXDomain   bool                               `json:"nxdomain,omitempty"`
+	NoData     []string                           `json:"nodata,omitempty"`
+	Enrichment map[string]*resolve.Enrichment     `json:"enrichment,omitempty"`
+	Sources    map[string]*resolve.ResponseSource `json:"sources,omitempty"`
+	CDN        []string                           `json:"cdn,omitempty"`
+	Proofs     map[string]*DenialRecord           `json:"proofs,omitempty"`
+}
+
+// DenialRecord is the DNSSEC proof of non-existence returned for a query type, holding the
+// NSEC/NSEC3 records and the signatures covering them in presentation format.
+type DenialRecord struct {
+	Rcode   string   `json:"rcode"`
+	Records []string `json:"records"`
+}
+
+// NewDenialRecord returns the DenialRecord for the proof extracted from a negative response.
+func NewDenialRecord(proof *resolve.DenialProof) *DenialRecord {
+	rec := &DenialRecord{Rcode: dns.RcodeToString[proof.Rcode]}
+
+	for _, rr := range proof.NSEC {
+		rec.Records = append(rec.Records, rr.String())
+	}
+	for _, rr := range proof.NSEC3 {
+		rec.Records = append(rec.Records, rr.String())
+	}
+	for _, rr := range proof.RRSIGs {
+		rec.Records = append(rec.Records, rr.String())
+	}
+	return rec
+}
+
+// NewNameRecord returns a NameRecord containing the answers from the provided responses.
+func NewNameRecord(name string, msgs []*dns.Msg) *NameRecord {
+	rec := &NameRecord{
+		Name:    name,
+		Records: make(map[string][]string),
+	}
+
+	// Records returned for multiple query types are only included once
+	for _, ans := range resolve.ExtractAnswers(&dns.Msg{Answer: resolve.MergeAnswers(msgs...)}) {
+		t := dns.TypeToString[ans.Type]
+
+		rec.Records[t] = append(rec.Records[t], ans.Data)
+	}
+	for _, msg := range msgs {
+		if resolve.PotentiallyFiltered(msg) {
+			rec.Filtered = true
+		}
+
+		// Keep the query types that produced NODATA apart from a name that does not exist
+		switch resolve.StatusOf(msg) {
+		case resolve.StatusNXDomain:
+			rec.NXDomain = true
+		case resolve.StatusNoData:
+			if len(msg.Question) > 0 {
+				rec.NoData = append(rec.NoData, dns.TypeToString[msg.Question[0].Qtype])
+			}
+		}
+		// The proofs of non-existence are only returned when requested with the -dnssec flag
+		if proof := resolve.ExtractDenialProof(msg); proof != nil {
+			if rec.Proofs == nil {
+				rec.Proofs = make(map[string]*DenialRecord)
+			}
+			rec.Proofs[dns.TypeToString[proof.Qtype]] = NewDenialRecord(proof)
+		}
+	}
+	return rec
+}
+
+// WriteResponses outputs the responses obtained for the name handled by the tracker.
+func WriteResponses(p *params, tracker *nameTracker) {
+	if len(tracker.Responses) == 0 {
+		return
+	}
+
+	if p.JSON {
+		rec := NewNameRecord(tracker.Name, tracker.Responses)
+		rec.Chain = aliasChain(p, tracker.Responses)
+		if p.CDN {
+			rec.CDN = cdnProviders(tracker.Responses)
+		}
+		if p.Sources {
+			for _, msg := range tracker.Responses {
+				if src := responseSource(tracker, msg); src != nil {
+					if rec.Sources == nil {
+						rec.Sources = make(map[string]*resolve.ResponseSource)
+					}
+					rec.Sources[dns.TypeToString[msg.Question[0].Qtype]] = src
+				}
+			}
+		}
+		for _, msg := range tracker.Responses {
+			for addr, e := range enrichAnswers(p, msg) {
+				if rec.Enrichment == nil {
+					rec.Enrichment = make(map[string]*resolve.Enrichment)
+				}
+				rec.Enrichment[addr] = e
+			}
+		}
+
+		if data, err := json.Marshal(rec); err == nil {
+			fmt.Fprintln(p.Output, string(data))
+		}
+		return
+	}
+
+	var printed []dns.RR
+	for _, msg := range tracker.Responses {
+		// Suppress the records already written for another query type
+		out := msg.Copy()
+		out.Answer = nil
+		for _, rr := range msg.Answer {
+			if !resolve.ContainsRR(printed, rr) {
+				out.Answer = append(out.Answer, rr)
+				printed = append(printed, rr)
+			}
+		}
+		fmt.Fprintf(p.Output, "\n%s\n", out)
+
+		enriched := enrichAnswers(p, msg)
+		for _, addr := range sortedKeys(enriched) {
+			fmt.Fprintln(p.Output, FormatEnrichment(addr, enriched[addr]))
+		}
+		if src := responseSource(tracker, msg); p.Sources && src != nil {
+			fmt.Fprintln(p.Output, FormatSource(src))
+		}
+	}
+	if rec := NewNameRecord(tracker.Name, tracker.Responses); len(rec.NoData) > 0 {
+		fmt.Fprintln(p.Output, ";; NODATA: "+strings.Join(rec.NoData, ", "))
+	}
+	if p.CDN {
+		if labels := cdnProviders(tracker.Responses); len(labels) > 0 {
+			fmt.Fprintln(p.Output, ";; CDN: "+strings.Join(labels, ", "))
+		}
+	}
+}
+
+// Returns the sorted CDN provider labels identified across the responses.
+func cdnProviders(msgs []*dns.Msg) []string {
+	return resolve.CDNProviders(&dns.Msg{Answer: resolve.MergeAnswers(msgs...)})
+}
+
+// Returns the source of the response, with the attempts made by the event loop for the query type.
+func responseSource(tracker *nameTracker, msg *dns.Msg) *resolve.ResponseSource {
+	src := resolve.SourceOf(msg)
+	if src == nil || len(msg.Question) == 0 {
+		return nil
+	}
+
+	if attempts := tracker.Attempts[msg.Question[0].Qtype]; attempts > 0 {
+		src.Attempts = attempts
+	}
+	return src
+}
+
+// FormatSource returns the comment line written after a response describing how it was obtained.
+func FormatSource(src *resolve.ResponseSource) string {
+	line := ";; SOURCE: " + src.Transport
+	if src.Server != "" {
+		line += " " + src.Server
+	}
+	line += fmt.Sprintf(" attempts=%d", src.Attempts)
+	if src.WildcardChecked {
+		line += fmt.Sprintf(" wildcard=%t", src.Wildcard)
+	}
+	return line
+}
+
+// Returns the longest CNAME chain found in the responses, logging the chains that could not be completed.
+func aliasChain(p *params, msgs []*dns.Msg) []string {
+	if p.Pool == nil {
+		return nil
+	}
+
+	var longest []string
+	for _, msg := range msgs {
+		chain, err := p.Pool.AliasChain(msg)
+		if err != nil && p.Log != nil {
+			p.Log.Printf("%s: %v", strings.Join(chain, " -> "), err)
+		}
+		if len(chain) > 1 && len(chain) > len(longest) {
+			longest = chain
+		}
+	}
+	return longest
+}
+
+// Returns the details for the addresses in the response when an enricher has been assigned to the pool.
+func enrichAnswers(p *params, msg *dns.Msg) map[string]*resolve.Enrichment {
+	if p.Pool == nil {
+		return nil
+	}
+	return p.Pool.EnrichAnswers(context.Background(), msg)
+}
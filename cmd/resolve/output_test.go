@@ -0,0 +1,402 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+)
+
+func TestNewNameRecord(t *testing.T) {
+	a := new(dns.Msg)
+	a.SetQuestion("www.caffix.net.", dns.TypeA)
+	a.Answer = append(a.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: "www.caffix.net.", Rrtype: dns.TypeA, Class: dns.ClassINET},
+		A:   net.ParseIP("192.168.1.1"),
+	})
+
+	txt := new(dns.Msg)
+	txt.SetQuestion("www.caffix.net.", dns.TypeTXT)
+	txt.Answer = append(txt.Answer, &dns.TXT{
+		Hdr: dns.RR_Header{Name: "www.caffix.net.", Rrtype: dns.TypeTXT, Class: dns.ClassINET},
+		Txt: []string{"v=spf1 -all"},
+	})
+
+	rec := NewNameRecord("www.caffix.net", []*dns.Msg{a, txt})
+	if rec.Name != "www.caffix.net" {
+		t.Errorf("Got: %s; Expected: %s", rec.Name, "www.caffix.net")
+	}
+	if got := rec.Records["A"]; len(got) != 1 || got[0] != "192.168.1.1" {
+		t.Errorf("Got: %v; Expected: %v", got, []string{"192.168.1.1"})
+	}
+	if got := rec.Records["TXT"]; len(got) != 1 || got[0] != "v=spf1 -all" {
+		t.Errorf("Got: %v; Expected: %v", got, []string{"v=spf1 -all"})
+	}
+}
+
+func TestWriteResponsesJSON(t *testing.T) {
+	output, err := os.CreateTemp("", "output")
+	if err != nil {
+		t.Fatalf("Failed to open the temporary output file: %v", err)
+	}
+	defer os.Remove(output.Name())
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("www.caffix.net.", dns.TypeA)
+	msg.Answer = append(msg.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: "www.caffix.net.", Rrtype: dns.TypeA, Class: dns.ClassINET},
+		A:   net.ParseIP("192.168.1.1"),
+	})
+
+	p := &params{Output: output, JSON: true}
+	WriteResponses(p, &nameTracker{Name: "www.caffix.net", Responses: []*dns.Msg{msg}})
+	WriteResponses(p, &nameTracker{Name: "mail.caffix.net"})
+	output.Close()
+
+	data, err := os.ReadFile(output.Name())
+	if err != nil {
+		t.Fatalf("Failed to read the output file: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Got %d lines of output; Expected 1", len(lines))
+	}
+
+	var rec NameRecord
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("Failed to unmarshal the JSON output: %v", err)
+	}
+	if rec.Name != "www.caffix.net" || len(rec.Records["A"]) != 1 {
+		t.Errorf("Unexpected JSON output: %s", lines[0])
+	}
+}
+
+func TestWriteResponsesCDN(t *testing.T) {
+	output, err := os.CreateTemp("", "output")
+	if err != nil {
+		t.Fatalf("Failed to open the temporary output file: %v", err)
+	}
+	defer os.Remove(output.Name())
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("www.caffix.net.", dns.TypeA)
+	msg.Answer = append(msg.Answer, &dns.CNAME{
+		Hdr:    dns.RR_Header{Name: "www.caffix.net.", Rrtype: dns.TypeCNAME, Class: dns.ClassINET},
+		Target: "d1234abcd.cloudfront.net.",
+	}, &dns.A{
+		Hdr: dns.RR_Header{Name: "d1234abcd.cloudfront.net.", Rrtype: dns.TypeA, Class: dns.ClassINET},
+		A:   net.ParseIP("192.168.1.1"),
+	})
+
+	p := &params{Output: output, JSON: true, CDN: true}
+	WriteResponses(p, &nameTracker{Name: "www.caffix.net", Responses: []*dns.Msg{msg}})
+	p.JSON = false
+	WriteResponses(p, &nameTracker{Name: "www.caffix.net", Responses: []*dns.Msg{msg}})
+	output.Close()
+
+	data, err := os.ReadFile(output.Name())
+	if err != nil {
+		t.Fatalf("Failed to read the output file: %v", err)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	var rec NameRecord
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("Failed to unmarshal the JSON output: %v", err)
+	}
+	if len(rec.CDN) != 1 || rec.CDN[0] != "cloudfront" {
+		t.Errorf("The JSON output was not labeled with the CDN: %s", lines[0])
+	}
+	if !strings.Contains(string(data), ";; CDN: cloudfront") {
+		t.Errorf("The text output was not labeled with the CDN: %s", string(data))
+	}
+}
+
+func TestWriteResponsesEnrichment(t *testing.T) {
+	output, err := os.CreateTemp("", "output")
+	if err != nil {
+		t.Fatalf("Failed to open the temporary output file: %v", err)
+	}
+	defer os.Remove(output.Name())
+
+	pfx, err := ReadPfx2AS(strings.NewReader("192.168.0.0\t16\t64512\n"))
+	if err != nil {
+		t.Fatalf("Failed to read the pfx2as data: %v", err)
+	}
+
+	pool := resolve.NewResolvers()
+	defer pool.Stop()
+	pool.SetEnricher(pfx)
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("www.caffix.net.", dns.TypeA)
+	msg.Answer = append(msg.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: "www.caffix.net.", Rrtype: dns.TypeA, Class: dns.ClassINET},
+		A:   net.ParseIP("192.168.1.1"),
+	})
+
+	p := &params{Output: output, Pool: pool, JSON: true}
+	WriteResponses(p, &nameTracker{Name: "www.caffix.net", Responses: []*dns.Msg{msg}})
+	p.JSON = false
+	WriteResponses(p, &nameTracker{Name: "www.caffix.net", Responses: []*dns.Msg{msg}})
+	output.Close()
+
+	data, err := os.ReadFile(output.Name())
+	if err != nil {
+		t.Fatalf("Failed to read the output file: %v", err)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	var rec NameRecord
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("Failed to unmarshal the JSON output: %v", err)
+	}
+	if e, found := rec.Enrichment["192.168.1.1"]; !found || e.ASN != 64512 || e.Prefix != "192.168.0.0/16" {
+		t.Errorf("The JSON output was not enriched: %s", lines[0])
+	}
+	if !strings.Contains(string(data), ";; 192.168.1.1 AS64512 192.168.0.0/16") {
+		t.Errorf("The text output was not enriched: %s", string(data))
+	}
+}
+
+func TestWriteResponsesChain(t *testing.T) {
+	output, err := os.CreateTemp("", "output")
+	if err != nil {
+		t.Fatalf("Failed to open the temporary output file: %v", err)
+	}
+	defer os.Remove(output.Name())
+
+	pool := resolve.NewResolvers()
+	defer pool.Stop()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("www.caffix.net.", dns.TypeA)
+	msg.Answer = append(msg.Answer, &dns.CNAME{
+		Hdr:    dns.RR_Header{Name: "www.caffix.net.", Rrtype: dns.TypeCNAME, Class: dns.ClassINET},
+		Target: "cdn.caffix.net.",
+	}, &dns.A{
+		Hdr: dns.RR_Header{Name: "cdn.caffix.net.", Rrtype: dns.TypeA, Class: dns.ClassINET},
+		A:   net.ParseIP("192.168.1.1"),
+	})
+
+	p := &params{Output: output, Pool: pool, JSON: true}
+	WriteResponses(p, &nameTracker{Name: "www.caffix.net", Responses: []*dns.Msg{msg}})
+	output.Close()
+
+	data, err := os.ReadFile(output.Name())
+	if err != nil {
+		t.Fatalf("Failed to read the output file: %v", err)
+	}
+
+	var rec NameRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("Failed to unmarshal the JSON output: %v", err)
+	}
+	if strings.Join(rec.Chain, ",") != "www.caffix.net,cdn.caffix.net" {
+		t.Errorf("The chain was not included in the JSON output: %s", string(data))
+	}
+}
+
+func TestWriteResponsesDuplicates(t *testing.T) {
+	output, err := os.CreateTemp("", "output")
+	if err != nil {
+		t.Fatalf("Failed to open the temporary output file: %v", err)
+	}
+	defer os.Remove(output.Name())
+
+	cname := &dns.CNAME{
+		Hdr:    dns.RR_Header{Name: "www.caffix.net.", Rrtype: dns.TypeCNAME, Class: dns.ClassINET},
+		Target: "web.caffix.net.",
+	}
+
+	a := new(dns.Msg)
+	a.SetQuestion("www.caffix.net.", dns.TypeA)
+	a.Answer = append(a.Answer, cname, &dns.A{
+		Hdr: dns.RR_Header{Name: "web.caffix.net.", Rrtype: dns.TypeA, Class: dns.ClassINET},
+		A:   net.ParseIP("192.168.1.1"),
+	})
+
+	c := new(dns.Msg)
+	c.SetQuestion("www.caffix.net.", dns.TypeCNAME)
+	c.Answer = append(c.Answer, dns.Copy(cname))
+
+	tracker := &nameTracker{Name: "www.caffix.net", Responses: []*dns.Msg{a, c}}
+	if rec := NewNameRecord(tracker.Name, tracker.Responses); len(rec.Records["CNAME"]) != 1 || len(rec.Records["A"]) != 1 {
+		t.Errorf("The duplicate records were not merged: %v", rec.Records)
+	}
+	if rec := NewNameRecord(tracker.Name, tracker.Responses); rec.Filtered {
+		t.Errorf("The records were reported as filtered")
+	}
+
+	p := &params{Output: output}
+	WriteResponses(p, tracker)
+	output.Close()
+
+	data, err := os.ReadFile(output.Name())
+	if err != nil {
+		t.Fatalf("Failed to read the output file: %v", err)
+	}
+	if n := strings.Count(string(data), "CNAME\tweb.caffix.net."); n != 1 {
+		t.Errorf("The CNAME record was written %d times: %s", n, string(data))
+	}
+	if len(c.Answer) != 1 {
+		t.Errorf("The response was modified while suppressing the duplicates")
+	}
+}
+
+func TestNewNameRecordFiltered(t *testing.T) {
+	msg := new(dns.Msg)
+	msg.SetQuestion("www.caffix.net.", dns.TypeA)
+	msg.SetRcode(msg, dns.RcodeNameError)
+	msg.SetEdns0(dns.DefaultMsgSize, false)
+	msg.IsEdns0().Option = append(msg.IsEdns0().Option, &dns.EDNS0_EDE{InfoCode: dns.ExtendedErrorCodeFiltered})
+
+	if rec := NewNameRecord("www.caffix.net", []*dns.Msg{msg}); !rec.Filtered {
+		t.Errorf("The filtered response was not reported")
+	}
+}
+
+func TestWriteResponsesSources(t *testing.T) {
+	output, err := os.CreateTemp("", "output")
+	if err != nil {
+		t.Fatalf("Failed to open the temporary output file: %v", err)
+	}
+	defer os.Remove(output.Name())
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc("caffix.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET},
+			A:   net.ParseIP("192.168.1.1"),
+		})
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	p := &params{Output: output, Pool: resolve.NewResolvers()}
+	defer p.Pool.Stop()
+	_ = p.Pool.AddResolvers(100, addrstr)
+
+	resp, err := p.Pool.QueryBlocking(context.Background(), resolve.QueryMsg("www.caffix.net", dns.TypeA))
+	if err != nil {
+		t.Fatalf("The query failed: %v", err)
+	}
+
+	tracker := newNameTracker("www.caffix.net", []uint16{dns.TypeA})
+	tracker.Attempts[dns.TypeA] = 3
+	tracker.Responses = append(tracker.Responses, resp)
+
+	if src := responseSource(tracker, resp); src == nil || src.Server != addrstr || src.Attempts != 3 {
+		t.Errorf("Unexpected source for the response: %+v", src)
+	}
+
+	WriteResponses(p, tracker)
+	p.Sources = true
+	WriteResponses(p, tracker)
+	p.JSON = true
+	WriteResponses(p, tracker)
+	output.Close()
+
+	data, err := os.ReadFile(output.Name())
+	if err != nil {
+		t.Fatalf("Failed to read the output file: %v", err)
+	}
+	out := string(data)
+	if strings.Count(out, ";; SOURCE: udp "+addrstr+" attempts=3") != 1 || strings.Contains(out, "LOCAL OPT") {
+		t.Errorf("The source was not written with the text output: %s", out)
+	}
+	if !strings.Contains(out, `"sources":{"A":{"server":"`+addrstr+`","transport":"udp","attempts":3}}`) {
+		t.Errorf("The source was not written with the JSON output: %s", out)
+	}
+}
+
+func TestNewNameRecordStatus(t *testing.T) {
+	a := new(dns.Msg)
+	a.SetQuestion("www.caffix.net.", dns.TypeA)
+	a.Answer = append(a.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: "www.caffix.net.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+		A:   net.ParseIP("192.168.1.1"),
+	})
+	aaaa := new(dns.Msg)
+	aaaa.SetQuestion("www.caffix.net.", dns.TypeAAAA)
+
+	rec := NewNameRecord("www.caffix.net", []*dns.Msg{a, aaaa})
+	if rec.NXDomain || len(rec.NoData) != 1 || rec.NoData[0] != "AAAA" {
+		t.Errorf("Expected NODATA for only the AAAA query, got NXDomain: %t, NoData: %v", rec.NXDomain, rec.NoData)
+	}
+
+	nx := new(dns.Msg)
+	nx.SetQuestion("www.caffix.net.", dns.TypeA)
+	nx.Rcode = dns.RcodeNameError
+
+	rec = NewNameRecord("www.caffix.net", []*dns.Msg{nx})
+	if !rec.NXDomain || len(rec.NoData) != 0 {
+		t.Errorf("Expected only NXDOMAIN, got NXDomain: %t, NoData: %v", rec.NXDomain, rec.NoData)
+	}
+}
+
+func TestWriteResponsesProofs(t *testing.T) {
+	output, err := os.CreateTemp("", "output")
+	if err != nil {
+		t.Fatalf("Failed to open the temporary output file: %v", err)
+	}
+	defer os.Remove(output.Name())
+
+	nx := new(dns.Msg)
+	nx.SetQuestion("missing.caffix.net.", dns.TypeA)
+	nx.Rcode = dns.RcodeNameError
+	nx.Ns = append(nx.Ns, &dns.NSEC{
+		Hdr:        dns.RR_Header{Name: "caffix.net.", Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 60},
+		NextDomain: "www.caffix.net.",
+		TypeBitMap: []uint16{dns.TypeA, dns.TypeNS},
+	}, &dns.RRSIG{
+		Hdr:         dns.RR_Header{Name: "caffix.net.", Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 60},
+		TypeCovered: dns.TypeNSEC,
+		SignerName:  "caffix.net.",
+	})
+	// The negative response without a proof is not given an entry
+	aaaa := new(dns.Msg)
+	aaaa.SetQuestion("missing.caffix.net.", dns.TypeAAAA)
+	aaaa.Rcode = dns.RcodeNameError
+
+	p := &params{Output: output, JSON: true}
+	WriteResponses(p, &nameTracker{Name: "missing.caffix.net", Responses: []*dns.Msg{nx, aaaa}})
+	output.Close()
+
+	data, err := os.ReadFile(output.Name())
+	if err != nil {
+		t.Fatalf("Failed to read the output file: %v", err)
+	}
+
+	var rec NameRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("Failed to unmarshal the JSON output: %v", err)
+	}
+	if len(rec.Proofs) != 1 {
+		t.Fatalf("Expected a proof for only the A query, got %v", rec.Proofs)
+	}
+	proof := rec.Proofs["A"]
+	if proof == nil || proof.Rcode != "NXDOMAIN" || len(proof.Records) != 2 {
+		t.Fatalf("Unexpected proof in the JSON output: %s", data)
+	}
+	if !strings.Contains(proof.Records[0], "NSEC\twww.caffix.net.") || !strings.Contains(proof.Records[1], "RRSIG\tNSEC") {
+		t.Errorf("The proof records were not in presentation format: %v", proof.Records)
+	}
+}
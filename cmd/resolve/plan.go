@@ -0,0 +1,164 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+	"golang.org/x/net/publicsuffix"
+)
+
+// QueryPlan counts the queries a run would send, grouped by zone and destination. The queries
+// sent to stub zones and routing rule servers are also counted for each server that may receive
+// them, and the queries for names beneath the wildcards found by a previous run are counted for
+// each wildcard.
+type QueryPlan struct {
+	Names        int
+	Queries      int
+	Zones        map[string]int
+	Destinations map[string]int
+	Servers      map[string]int
+	Wildcards    map[string]int
+	seen         map[string]struct{}
+}
+
+// NewQueryPlan returns an empty QueryPlan.
+func NewQueryPlan() *QueryPlan {
+	return &QueryPlan{
+		Zones:        make(map[string]int),
+		Destinations: make(map[string]int),
+		Servers:      make(map[string]int),
+		Wildcards:    make(map[string]int),
+		seen:         make(map[string]struct{}),
+	}
+}
+
+// ReadWildcardLevels returns the subdomains reported as DNS wildcards by the JSON zone reports
+// of a previous run.
+func ReadWildcardLevels(r io.Reader) ([]string, error) {
+	var levels []string
+
+	err := ExtractLines(r, func(str string) error {
+		var rep ZoneReport
+		if err := json.Unmarshal([]byte(str), &rep); err != nil {
+			return nil
+		}
+		for sub, detected := range rep.Wildcards {
+			if detected {
+				levels = append(levels, strings.ToLower(resolve.RemoveLastDot(sub)))
+			}
+		}
+		return nil
+	})
+	sort.Strings(levels)
+	return levels, err
+}
+
+// Add plans the queries for the name read from the input, after validation, expansion
+// using the search domains and the removal of duplicates.
+func (qp *QueryPlan) Add(p *params, input string) {
+	name := resolve.RemoveLastDot(strings.ToLower(strings.TrimSpace(input)))
+	if _, ok := dns.IsDomainName(name); !ok || name == "" {
+		return
+	}
+
+	for _, n := range p.Pool.SearchNames(name) {
+		if _, found := qp.seen[n]; found {
+			continue
+		}
+		qp.seen[n] = struct{}{}
+		qp.Names++
+
+		zone, err := publicsuffix.EffectiveTLDPlusOne(n)
+		if err != nil {
+			zone = n
+		}
+		wildcard := wildcardFor(p.Wildcards, n)
+		servers := p.Pool.QueryServers(n)
+		for _, qtype := range p.Qtypes {
+			dest := p.Pool.QueryDestination(n, qtype)
+
+			qp.Destinations[dest]++
+			if dest == resolve.DestinationHosts || dest == resolve.DestinationPruned {
+				continue
+			}
+			qp.Queries++
+			qp.Zones[zone]++
+			for _, srv := range servers {
+				qp.Servers[srv]++
+			}
+			if wildcard != "" {
+				qp.Wildcards[wildcard]++
+			}
+		}
+	}
+}
+
+// Returns the most specific of the wildcard levels the name is beneath, or an empty string.
+func wildcardFor(levels []string, name string) string {
+	var found string
+
+	for _, level := range levels {
+		if strings.HasSuffix(name, "."+level) && len(level) > len(found) {
+			found = level
+		}
+	}
+	return found
+}
+
+// Write outputs the plan to the provided writer.
+func (qp *QueryPlan) Write(w io.Writer) {
+	fmt.Fprintf(w, "Names: %d\n", qp.Names)
+	fmt.Fprintf(w, "Queries: %d\n", qp.Queries)
+	for _, zone := range sortedKeys(qp.Zones) {
+		fmt.Fprintf(w, "Zone %s: %d\n", zone, qp.Zones[zone])
+	}
+	for _, dest := range sortedKeys(qp.Destinations) {
+		fmt.Fprintf(w, "Destination %s: %d\n", dest, qp.Destinations[dest])
+	}
+	for _, srv := range sortedKeys(qp.Servers) {
+		fmt.Fprintf(w, "Server %s: %d\n", srv, qp.Servers[srv])
+	}
+	for _, level := range sortedKeys(qp.Wildcards) {
+		fmt.Fprintf(w, "Wildcard %s: %d\n", level, qp.Wildcards[level])
+	}
+}
+
+// DryRun reads the names from the input and reports the queries that would be sent,
+// without sending any packets. The plan is sent to the output file, or the log when
+// running in quiet mode.
+func DryRun(p *params) error {
+	qp := NewQueryPlan()
+
+	if err := ExtractLines(p.Input, func(str string) error {
+		qp.Add(p, str)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	w := p.Log.Writer()
+	if p.Output != nil {
+		w = p.Output
+	}
+	qp.Write(w)
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	var keys []string
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,45 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+)
+
+func TestQueryPlan(t *testing.T) {
+	p := &params{
+		Pool:   resolve.NewResolvers(),
+		Qtypes: []uint16{dns.TypeA, dns.TypeMX},
+	}
+	levels, err := ReadWildcardLevels(strings.NewReader(
+		`{"zone":"caffix.net","names":2,"wildcards":{"caffix.net":true,"dev.caffix.net":false},"rcodes":{}}` + "\n"))
+	if err != nil || len(levels) != 1 || levels[0] != "caffix.net" {
+		t.Fatalf("unexpected wildcard levels: %v %v", levels, err)
+	}
+	p.Wildcards = levels
+	defer p.Pool.Stop()
+	_ = p.Pool.AddHostsOverride("pinned.caffix.net", "192.168.1.1")
+	_ = p.Pool.AddStubZone(10, "corp.owasp.org", "192.168.1.53")
+
+	qp := NewQueryPlan()
+	for _, name := range []string{"www.caffix.net", "WWW.caffix.net.", "pinned.caffix.net", "", "www.corp.owasp.org", "a..caffix.net"} {
+		qp.Add(p, name)
+	}
+
+	buf := new(bytes.Buffer)
+	qp.Write(buf)
+
+	expected := "Names: 3\nQueries: 5\nZone caffix.net: 3\nZone owasp.org: 2\n" +
+		"Destination hosts: 1\nDestination pool: 3\nDestination stub:corp.owasp.org: 2\n" +
+		"Server 192.168.1.53:53: 2\nWildcard caffix.net: 3\n"
+	if got := buf.String(); got != expected {
+		t.Errorf("Got: %q; Expected: %q", got, expected)
+	}
+}
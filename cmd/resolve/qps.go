@@ -0,0 +1,30 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+// AdjustQPS raises or lowers the QPS of the resolver pool by a quarter of the current value
+// while a scan is running, and returns the new value. The QPS of each resolver is changed in
+// the same proportion, since the resolvers would otherwise cap the raised QPS of the pool.
+func AdjustQPS(p *params, raise bool) int {
+	prev := p.Pool.QPS()
+	qps := prev
+
+	delta := qps / 4
+	if delta < 1 {
+		delta = 1
+	}
+	if raise {
+		qps += delta
+	} else if qps -= delta; qps < 1 {
+		qps = 1
+	}
+
+	if prev > 0 {
+		p.Pool.ScaleResolverQPS(float64(qps) / float64(prev))
+	}
+	p.Pool.SetMaxQPS(qps)
+	p.Log.Printf("The QPS of the resolver pool has been set to %d\n", qps)
+	return qps
+}
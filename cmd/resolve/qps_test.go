@@ -0,0 +1,34 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"io"
+	"log"
+	"testing"
+
+	"github.com/owasp-amass/resolve"
+)
+
+func TestAdjustQPS(t *testing.T) {
+	p := &params{
+		Log:  log.New(io.Discard, "", 0),
+		Pool: resolve.NewResolvers(),
+	}
+	defer p.Pool.Stop()
+	p.Pool.SetMaxQPS(100)
+
+	if qps := AdjustQPS(p, true); qps != 125 || p.Pool.QPS() != 125 {
+		t.Errorf("Got: %d; Expected: %d", qps, 125)
+	}
+	if qps := AdjustQPS(p, false); qps != 94 || p.Pool.QPS() != 94 {
+		t.Errorf("Got: %d; Expected: %d", qps, 94)
+	}
+
+	p.Pool.SetMaxQPS(1)
+	if qps := AdjustQPS(p, false); qps != 1 {
+		t.Errorf("the QPS was lowered below one: %d", qps)
+	}
+}
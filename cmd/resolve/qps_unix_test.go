@@ -0,0 +1,47 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package main
+
+import (
+	"io"
+	"log"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/resolve"
+)
+
+func TestWatchQPSSignals(t *testing.T) {
+	p := &params{
+		Log:  log.New(io.Discard, "", 0),
+		Pool: resolve.NewResolvers(),
+	}
+	defer p.Pool.Stop()
+	p.Pool.SetMaxQPS(100)
+
+	stop := WatchQPSSignals(p)
+	defer stop()
+
+	for _, c := range []struct {
+		sig  syscall.Signal
+		want int
+	}{
+		{sig: syscall.SIGUSR1, want: 125},
+		{sig: syscall.SIGUSR2, want: 94},
+	} {
+		_ = syscall.Kill(syscall.Getpid(), c.sig)
+
+		deadline := time.Now().Add(time.Second)
+		for p.Pool.QPS() != c.want && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if qps := p.Pool.QPS(); qps != c.want {
+			t.Errorf("Got: %d; Expected: %d", qps, c.want)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+
+package main
+
+// WatchQPSSignals does nothing on Windows, since SIGUSR1 and SIGUSR2 are not available.
+func WatchQPSSignals(p *params) func() {
+	return func() {}
+}
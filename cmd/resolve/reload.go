@@ -0,0 +1,69 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Reload reads the resolvers file and the routing rules file again, when provided, so the
+// resolvers and rules are replaced without dropping the queries already sent. The QPS set
+// by -qps applies to the servers added, while the QPS of the pool adjusted by the signals
+// is kept.
+func Reload(p *params) error {
+	if p.RFile == "" && p.Routes == "" {
+		return errors.New("no resolvers file or routing rules file was provided")
+	}
+
+	if p.RFile != "" {
+		if err := ReloadResolvers(p); err != nil {
+			return err
+		}
+	}
+	if p.Routes != "" {
+		if err := p.SetupRoutingRules(p.Routes); err != nil {
+			return fmt.Errorf("failed to reload the routing rules: %v", err)
+		}
+		p.Log.Printf("The routing rules have been reloaded from %s\n", p.Routes)
+	}
+	return nil
+}
+
+// ReloadResolvers reads the resolvers file again and replaces the resolvers of the pool with
+// those listed, allowing the queries already sent to the removed resolvers to complete.
+func ReloadResolvers(p *params) error {
+	if p.RFile == "" {
+		return errors.New("no resolvers file was provided with -rf")
+	}
+
+	f, err := os.Open(p.RFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var list []string
+	if err := ExtractLines(f, func(str string) error {
+		if str = strings.TrimSpace(str); str != "" {
+			list = append(list, str)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := ValidateOptions(p, list, 0, 0, 0, ""); err != nil {
+		return err
+	}
+	if err := p.Pool.SetResolvers(p.QPS, list...); err != nil {
+		return err
+	}
+
+	p.Log.Printf("The resolver pool has been reloaded with %d resolvers from %s\n", p.Pool.Len(), p.RFile)
+	return nil
+}
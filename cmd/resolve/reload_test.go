@@ -0,0 +1,145 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+)
+
+func TestReloadResolvers(t *testing.T) {
+	p := &params{
+		Log:  log.New(io.Discard, "", 0),
+		Pool: resolve.NewResolvers(),
+		QPS:  10,
+	}
+	defer p.Pool.Stop()
+	_ = p.Pool.AddResolvers(p.QPS, "127.0.0.1:5301")
+
+	if err := ReloadResolvers(p); err == nil {
+		t.Errorf("the resolvers were reloaded without a resolvers file")
+	}
+
+	f, err := os.CreateTemp("", "resolvers")
+	if err != nil {
+		t.Fatalf("failed to create the resolvers file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	_, _ = f.WriteString("127.0.0.1:5302\n\n127.0.0.1:5303\n")
+	f.Close()
+
+	p.RFile = f.Name()
+	if err := ReloadResolvers(p); err != nil {
+		t.Fatalf("failed to reload the resolvers: %v", err)
+	}
+	if l := p.Pool.Len(); l != 2 {
+		t.Errorf("the pool has %d resolvers after the reload; expected 2", l)
+	}
+
+	if err := os.WriteFile(f.Name(), []byte("127.0.0.1:99999\n"), 0644); err != nil {
+		t.Fatalf("failed to write the resolvers file: %v", err)
+	}
+	if err := ReloadResolvers(p); err == nil {
+		t.Errorf("the resolvers file with an invalid address was accepted")
+	}
+	if l := p.Pool.Len(); l != 2 {
+		t.Errorf("the pool has %d resolvers after the failed reload; expected 2", l)
+	}
+}
+
+func TestReloadRoutingRules(t *testing.T) {
+	answer := func(addr string) dns.HandlerFunc {
+		return func(w dns.ResponseWriter, req *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(req)
+			m.Answer = append(m.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: m.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET},
+				A:   net.ParseIP(addr),
+			})
+			_ = w.WriteMsg(m)
+		}
+	}
+
+	pmux := dns.NewServeMux()
+	pmux.HandleFunc(".", answer("192.0.2.1"))
+	ps, paddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = pmux })
+	if err != nil {
+		t.Fatalf("Unable to run test server: %v", err)
+	}
+	defer func() { _ = ps.Shutdown() }()
+
+	rmux := dns.NewServeMux()
+	rmux.HandleFunc(".", answer("10.0.0.1"))
+	rs, raddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = rmux })
+	if err != nil {
+		t.Fatalf("Unable to run test server: %v", err)
+	}
+	defer func() { _ = rs.Shutdown() }()
+
+	p := &params{
+		Log:  log.New(io.Discard, "", 0),
+		Pool: resolve.NewResolvers(),
+		QPS:  10,
+	}
+	defer p.Pool.Stop()
+	_ = p.Pool.AddResolvers(p.QPS, paddr)
+
+	if err := Reload(p); err == nil {
+		t.Errorf("the configuration was reloaded without any files")
+	}
+
+	f, err := os.CreateTemp("", "routes")
+	if err != nil {
+		t.Fatalf("failed to create the routing rules file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	_, _ = f.WriteString("*.internal.example.com " + raddr + "\n")
+	f.Close()
+
+	if err := p.SetupRoutingRules(f.Name()); err != nil {
+		t.Fatalf("failed to load the routing rules: %v", err)
+	}
+
+	resolved := func(name string) string {
+		resp, err := p.Pool.QueryBlocking(context.Background(), resolve.QueryMsg(name, dns.TypeA))
+		if ips := resolve.IPs(resp); err == nil && len(ips) == 1 {
+			return ips[0].String()
+		}
+		return ""
+	}
+	if got := resolved("www.internal.example.com"); got != "10.0.0.1" {
+		t.Errorf("the name was not sent to the server of the rule: %s", got)
+	}
+
+	if err := os.WriteFile(f.Name(), []byte("*.dev.example.com "+raddr+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write the routing rules file: %v", err)
+	}
+	if err := Reload(p); err != nil {
+		t.Fatalf("failed to reload the routing rules: %v", err)
+	}
+	if got := resolved("www.internal.example.com"); got != "192.0.2.1" {
+		t.Errorf("the name was sent using the rule removed by the reload: %s", got)
+	}
+	if got := resolved("www.dev.example.com"); got != "10.0.0.1" {
+		t.Errorf("the name was not sent to the server of the reloaded rule: %s", got)
+	}
+
+	if err := os.WriteFile(f.Name(), []byte("/[/ "+raddr+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write the routing rules file: %v", err)
+	}
+	if err := Reload(p); err == nil {
+		t.Errorf("the routing rules file with an invalid pattern was accepted")
+	}
+	if got := resolved("www.dev.example.com"); got != "10.0.0.1" {
+		t.Errorf("the routing rules were changed by the failed reload: %s", got)
+	}
+}
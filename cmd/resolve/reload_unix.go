@@ -0,0 +1,39 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WatchReloadSignal reloads the resolvers and routing rules files when SIGHUP is received.
+// The returned function stops watching for the signal.
+func WatchReloadSignal(p *params) func() {
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigs, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-sigs:
+				if err := Reload(p); err != nil {
+					p.Log.Printf("Failed to reload the configuration: %v\n", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/resolve"
+)
+
+func TestWatchReloadSignal(t *testing.T) {
+	f, err := os.CreateTemp("", "resolvers")
+	if err != nil {
+		t.Fatalf("failed to create the resolvers file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	_, _ = f.WriteString("127.0.0.1:5302\n127.0.0.1:5303\n")
+	f.Close()
+
+	p := &params{
+		Log:   log.New(io.Discard, "", 0),
+		Pool:  resolve.NewResolvers(),
+		QPS:   10,
+		RFile: f.Name(),
+	}
+	defer p.Pool.Stop()
+	_ = p.Pool.AddResolvers(p.QPS, "127.0.0.1:5301")
+
+	stop := WatchReloadSignal(p)
+	defer stop()
+	_ = syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
+
+	deadline := time.Now().Add(time.Second)
+	for p.Pool.Len() != 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if l := p.Pool.Len(); l != 2 {
+		t.Errorf("Got: %d resolvers; Expected: 2", l)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+	"go.uber.org/ratelimit"
+)
+
+// ReplayQuery is a query obtained from the output of a previous run.
+type ReplayQuery struct {
+	Name  string
+	Qtype uint16
+}
+
+// ReadReplayQueries extracts the unique queries from the output of a previous run, written in
+// either the text or the JSON format. The text format provides the question of each response,
+// while the JSON format only provides the types that received answers.
+func ReadReplayQueries(r io.Reader) ([]*ReplayQuery, error) {
+	var queries []*ReplayQuery
+	seen := make(map[ReplayQuery]struct{})
+
+	add := func(name string, qtype uint16) {
+		name = resolve.RemoveLastDot(strings.ToLower(name))
+		if _, ok := dns.IsDomainName(name); !ok || name == "" || qtype == dns.TypeNone {
+			return
+		}
+
+		q := ReplayQuery{Name: name, Qtype: qtype}
+		if _, found := seen[q]; !found {
+			seen[q] = struct{}{}
+			queries = append(queries, &q)
+		}
+	}
+
+	err := ExtractLines(r, func(str string) error {
+		line := strings.TrimSpace(str)
+
+		if strings.HasPrefix(line, "{") {
+			var rec NameRecord
+			if err := json.Unmarshal([]byte(line), &rec); err == nil {
+				for t := range rec.Records {
+					add(rec.Name, StringToQtype(t))
+				}
+			}
+		} else if strings.HasPrefix(line, ";") && strings.Contains(line, "\tIN\t") {
+			// Question lines have the format ";name.\tIN\t TYPE"
+			if fields := strings.Fields(strings.TrimPrefix(line, ";")); len(fields) == 3 {
+				add(fields[0], StringToQtype(fields[2]))
+			}
+		}
+		return nil
+	})
+	return queries, err
+}
+
+// Replay sends the queries through the resolver pool at the provided rate, and writes the responses
+// grouped by name once all have been received. The pool QPS is used when qps is zero.
+func Replay(p *params, queries []*ReplayQuery, qps int) {
+	if qps <= 0 {
+		qps = p.Pool.QPS()
+	}
+	rate := ratelimit.New(qps)
+
+	var order []string
+	trackers := make(map[string]*nameTracker)
+	for _, q := range queries {
+		t, found := trackers[q.Name]
+		if !found {
+			t = newNameTracker(q.Name, nil)
+			trackers[q.Name] = t
+			order = append(order, q.Name)
+		}
+		t.Attempts[q.Qtype] = 1
+		t.Pending++
+	}
+
+	responses := make(chan *dns.Msg, len(queries))
+	for _, q := range queries {
+		_ = rate.Take()
+		p.Pool.Query(context.Background(), resolve.QueryMsg(q.Name, q.Qtype), responses)
+	}
+
+	for pending := len(queries); pending > 0; {
+		resp := <-responses
+		name := resolve.RemoveLastDot(strings.ToLower(resp.Question[0].Name))
+		qtype := resp.Question[0].Qtype
+
+		t := trackers[name]
+		if resp.Rcode == resolve.RcodeNoResponse {
+			if t.Attempts[qtype]++; t.Attempts[qtype] <= p.Retries {
+				_ = rate.Take()
+				p.Pool.Query(context.Background(), resolve.QueryMsg(name, qtype), responses)
+				continue
+			}
+			t.Failures++
+		} else {
+			t.Responses = append(t.Responses, resp)
+		}
+		pending--
+	}
+
+	if p.Output == nil {
+		return
+	}
+	for _, name := range order {
+		WriteResponses(p, trackers[name])
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+)
+
+func TestReadReplayQueries(t *testing.T) {
+	var sb strings.Builder
+	for _, q := range []ReplayQuery{
+		{Name: "www.caffix.net", Qtype: dns.TypeA},
+		{Name: "www.caffix.net", Qtype: dns.TypeMX},
+		{Name: "WWW.caffix.net", Qtype: dns.TypeA},
+	} {
+		fmt.Fprintf(&sb, "\n%s\n", resolve.QueryMsg(q.Name, q.Qtype))
+	}
+	sb.WriteString(`{"name":"mail.caffix.net","records":{"A":["192.168.1.15"]}}` + "\n")
+
+	queries, err := ReadReplayQueries(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("failed to read the queries: %v", err)
+	}
+
+	expected := []*ReplayQuery{
+		{Name: "www.caffix.net", Qtype: dns.TypeA},
+		{Name: "www.caffix.net", Qtype: dns.TypeMX},
+		{Name: "mail.caffix.net", Qtype: dns.TypeA},
+	}
+	if !reflect.DeepEqual(queries, expected) {
+		t.Errorf("Got: %v; Expected: %v", queries, expected)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	dns.HandleFunc("caffix.net.", eventLoopHandler)
+	defer dns.HandleRemove("caffix.net.")
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("Unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	output, err := os.CreateTemp("", "output")
+	if err != nil {
+		t.Fatalf("Failed to open the temporary output file: %v", err)
+	}
+	defer os.Remove(output.Name())
+
+	p := &params{
+		Log:     log.New(io.Discard, "", 0),
+		QPS:     10,
+		Output:  output,
+		Retries: 2,
+		JSON:    true,
+	}
+	if err := p.SetupResolverPool([]string{addrstr}, "", 100, ""); err != nil {
+		t.Fatalf("Failed to setup the resolver pool: %v", err)
+	}
+	defer p.Pool.Stop()
+
+	Replay(p, []*ReplayQuery{
+		{Name: "www.caffix.net", Qtype: dns.TypeA},
+		{Name: "mail.caffix.net", Qtype: dns.TypeA},
+	}, 100)
+	output.Close()
+
+	data, err := os.ReadFile(output.Name())
+	if err != nil {
+		t.Fatalf("Failed to read the output file: %v", err)
+	}
+
+	expected := `{"name":"www.caffix.net","records":{"A":["192.168.1.14"]}}` + "\n" +
+		`{"name":"mail.caffix.net","records":{"A":["192.168.1.15"]}}` + "\n"
+	if got := string(data); got != expected {
+		t.Errorf("Got: %q; Expected: %q", got, expected)
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+	"github.com/owasp-amass/resolve/mailsec"
+	"golang.org/x/net/publicsuffix"
+)
+
+// ZoneReport summarizes the results obtained for the names within a registered domain.
+// The SPF and DMARC records are only reported missing when the TXT queries were answered.
+type ZoneReport struct {
+	Zone         string          `json:"zone"`
+	Names        int             `json:"names"`
+	Nameservers  []string        `json:"nameservers,omitempty"`
+	Wildcards    map[string]bool `json:"wildcards,omitempty"`
+	Rcodes       map[string]int  `json:"rcodes"`
+	MissingSPF   bool            `json:"missing_spf,omitempty"`
+	MissingDMARC bool            `json:"missing_dmarc,omitempty"`
+	Dangling     []string        `json:"dangling_cnames,omitempty"`
+	spfChecked   bool
+	spfFound     bool
+	dmarcChecked bool
+	dmarcFound   bool
+}
+
+// ZoneReports collects the results for each registered domain during a run.
+type ZoneReports struct {
+	zones map[string]*ZoneReport
+}
+
+// NewZoneReports returns an empty collection of zone reports.
+func NewZoneReports() *ZoneReports {
+	return &ZoneReports{zones: make(map[string]*ZoneReport)}
+}
+
+// Add updates the report for the registered domain of the name handled by the tracker.
+func (z *ZoneReports) Add(tracker *nameTracker) {
+	name := strings.ToLower(resolve.RemoveLastDot(tracker.Name))
+	zone, err := publicsuffix.EffectiveTLDPlusOne(name)
+	if err != nil {
+		return
+	}
+
+	rep, found := z.zones[zone]
+	if !found {
+		rep = &ZoneReport{
+			Zone:   zone,
+			Rcodes: make(map[string]int),
+		}
+		z.zones[zone] = rep
+	}
+	rep.Names++
+
+	var dangling bool
+	for _, resp := range tracker.Responses {
+		rep.Rcodes[rcodeString(resp.Rcode)]++
+
+		for _, ns := range append(resolve.Answers[*dns.NS](resp), resolve.Records[*dns.NS](resp.Ns)...) {
+			if strings.EqualFold(resolve.RemoveLastDot(ns.Hdr.Name), zone) {
+				rep.addNameserver(strings.ToLower(resolve.RemoveLastDot(ns.Ns)))
+			}
+		}
+		// An alias leading to a name that does not exist is a takeover candidate
+		if resolve.StatusOf(resp) == resolve.StatusNXDomain && len(resolve.CNAMETargets(resp)) > 0 {
+			dangling = true
+		}
+		if len(resp.Question) == 0 || resp.Question[0].Qtype != dns.TypeTXT {
+			continue
+		}
+		switch name {
+		case zone:
+			rep.spfChecked = true
+			if hasSPF(resp) {
+				rep.spfFound = true
+			}
+		case "_dmarc." + zone:
+			rep.dmarcChecked = true
+			if hasDMARC(resp) {
+				rep.dmarcFound = true
+			}
+		}
+	}
+	if dangling {
+		rep.Dangling = append(rep.Dangling, name)
+	}
+}
+
+// Reports returns the zone reports sorted by the registered domain. The wildcard status of
+// each level tested is included when the resolver pool performed DNS wildcard detection.
+func (z *ZoneReports) Reports(ctx context.Context, pool *resolve.Resolvers) []*ZoneReport {
+	var reports []*ZoneReport
+	for _, rep := range z.zones {
+		rep.MissingSPF = rep.spfChecked && !rep.spfFound
+		rep.MissingDMARC = rep.dmarcChecked && !rep.dmarcFound
+		if pool != nil {
+			if levels := pool.WildcardLevels(ctx, rep.Zone); len(levels) > 0 {
+				rep.Wildcards = levels
+			}
+		}
+		sort.Strings(rep.Dangling)
+		reports = append(reports, rep)
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Zone < reports[j].Zone
+	})
+	return reports
+}
+
+func (rep *ZoneReport) addNameserver(ns string) {
+	for _, n := range rep.Nameservers {
+		if n == ns {
+			return
+		}
+	}
+	rep.Nameservers = append(rep.Nameservers, ns)
+	sort.Strings(rep.Nameservers)
+}
+
+// WriteZoneReportsJSON outputs each zone report as a line of JSON to the provided writer.
+func WriteZoneReportsJSON(w io.Writer, reports []*ZoneReport) {
+	for _, rep := range reports {
+		if data, err := json.Marshal(rep); err == nil {
+			fmt.Fprintln(w, string(data))
+		}
+	}
+}
+
+// WriteZoneReportsMarkdown outputs the zone reports as a Markdown document to the provided writer.
+func WriteZoneReportsMarkdown(w io.Writer, reports []*ZoneReport) {
+	for _, rep := range reports {
+		fmt.Fprintf(w, "## %s\n\n", rep.Zone)
+		fmt.Fprintf(w, "- Names: %d\n", rep.Names)
+		if len(rep.Nameservers) > 0 {
+			fmt.Fprintf(w, "- Nameservers: %s\n", strings.Join(rep.Nameservers, ", "))
+		}
+		if rep.MissingSPF {
+			fmt.Fprintln(w, "- Missing SPF record")
+		}
+		if rep.MissingDMARC {
+			fmt.Fprintln(w, "- Missing DMARC record")
+		}
+
+		fmt.Fprintln(w, "\n| Rcode | Responses |\n|---|---|")
+		for _, rcode := range sortedKeys(rep.Rcodes) {
+			fmt.Fprintf(w, "| %s | %d |\n", rcode, rep.Rcodes[rcode])
+		}
+		if len(rep.Wildcards) > 0 {
+			fmt.Fprintln(w, "\n| Subdomain | Wildcard |\n|---|---|")
+			for _, sub := range sortedKeys(rep.Wildcards) {
+				fmt.Fprintf(w, "| %s | %t |\n", sub, rep.Wildcards[sub])
+			}
+		}
+		if len(rep.Dangling) > 0 {
+			fmt.Fprintln(w, "\nDangling CNAMEs:")
+			for _, name := range rep.Dangling {
+				fmt.Fprintf(w, "- %s\n", name)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// WriteZoneReports sends the zone reports in the selected format to the report file, or the
+// log when a report file was not provided, so the reports are kept apart from the responses.
+func WriteZoneReports(ctx context.Context, p *params, z *ZoneReports) error {
+	w := p.Log.Writer()
+	if p.ReportOut != "" {
+		f, err := os.Create(p.ReportOut)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var pool *resolve.Resolvers
+	if p.Detection {
+		pool = p.Pool
+	}
+
+	reports := z.Reports(ctx, pool)
+	if p.Report == "markdown" {
+		WriteZoneReportsMarkdown(w, reports)
+		return nil
+	}
+	WriteZoneReportsJSON(w, reports)
+	return nil
+}
+
+func rcodeString(rcode int) string {
+	if s, found := dns.RcodeToString[rcode]; found {
+		return s
+	}
+	return strconv.Itoa(rcode)
+}
+
+func hasSPF(msg *dns.Msg) bool {
+	for _, txt := range resolve.TXTRecords(msg) {
+		if _, err := mailsec.ParseSPFPolicy(txt); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func hasDMARC(msg *dns.Msg) bool {
+	for _, txt := range resolve.TXTRecords(msg) {
+		if _, err := mailsec.ParseDMARC(txt); err == nil {
+			return true
+		}
+	}
+	return false
+}
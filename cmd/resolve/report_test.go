@@ -0,0 +1,138 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestZoneReports(t *testing.T) {
+	www := new(dns.Msg)
+	www.SetQuestion("www.caffix.net.", dns.TypeA)
+	www.Answer = append(www.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: "www.caffix.net.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+		A:   net.ParseIP("192.168.1.1"),
+	})
+
+	apex := new(dns.Msg)
+	apex.SetQuestion("caffix.net.", dns.TypeTXT)
+	apex.Answer = append(apex.Answer, &dns.TXT{
+		Hdr: dns.RR_Header{Name: "caffix.net.", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60},
+		Txt: []string{"google-site-verification=abc"},
+	})
+	apex.Ns = append(apex.Ns, &dns.NS{
+		Hdr: dns.RR_Header{Name: "caffix.net.", Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 60},
+		Ns:  "NS1.caffix.net.",
+	})
+
+	dangling := new(dns.Msg)
+	dangling.SetQuestion("old.caffix.net.", dns.TypeA)
+	dangling.Rcode = dns.RcodeNameError
+	dangling.Answer = append(dangling.Answer, &dns.CNAME{
+		Hdr:    dns.RR_Header{Name: "old.caffix.net.", Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 60},
+		Target: "gone.cloudapp.net.",
+	})
+
+	nx := new(dns.Msg)
+	nx.SetQuestion("bad.owasp.org.", dns.TypeA)
+	nx.Rcode = dns.RcodeNameError
+
+	// The version tags of the SPF and DMARC records are not case-sensitive
+	spf := new(dns.Msg)
+	spf.SetQuestion("owasp.org.", dns.TypeTXT)
+	spf.Answer = append(spf.Answer, &dns.TXT{
+		Hdr: dns.RR_Header{Name: "owasp.org.", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60},
+		Txt: []string{"V=SPF1 ", "-all"},
+	})
+	dmarc := new(dns.Msg)
+	dmarc.SetQuestion("_dmarc.owasp.org.", dns.TypeTXT)
+	dmarc.Answer = append(dmarc.Answer, &dns.TXT{
+		Hdr: dns.RR_Header{Name: "_dmarc.owasp.org.", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60},
+		Txt: []string{"v=dmarc1; p=reject"},
+	})
+
+	z := NewZoneReports()
+	z.Add(&nameTracker{Name: "www.caffix.net", Responses: []*dns.Msg{www}})
+	z.Add(&nameTracker{Name: "caffix.net", Responses: []*dns.Msg{apex}})
+	z.Add(&nameTracker{Name: "old.caffix.net", Responses: []*dns.Msg{dangling}})
+	z.Add(&nameTracker{Name: "bad.owasp.org", Responses: []*dns.Msg{nx}})
+	z.Add(&nameTracker{Name: "owasp.org", Responses: []*dns.Msg{spf}})
+	z.Add(&nameTracker{Name: "_dmarc.owasp.org", Responses: []*dns.Msg{dmarc}})
+
+	reports := z.Reports(context.Background(), nil)
+	if len(reports) != 2 || reports[0].Zone != "caffix.net" || reports[1].Zone != "owasp.org" {
+		t.Fatalf("Expected reports for caffix.net and owasp.org, got %d", len(reports))
+	}
+
+	rep := reports[0]
+	if rep.Names != 3 || rep.Rcodes["NOERROR"] != 2 || rep.Rcodes["NXDOMAIN"] != 1 {
+		t.Errorf("Unexpected counts in the report: %d names and %v", rep.Names, rep.Rcodes)
+	}
+	if len(rep.Nameservers) != 1 || rep.Nameservers[0] != "ns1.caffix.net" {
+		t.Errorf("Unexpected nameservers in the report: %v", rep.Nameservers)
+	}
+	if !rep.MissingSPF || rep.MissingDMARC {
+		t.Errorf("Expected only the SPF record to be reported missing")
+	}
+	if len(rep.Dangling) != 1 || rep.Dangling[0] != "old.caffix.net" {
+		t.Errorf("Unexpected dangling CNAMEs in the report: %v", rep.Dangling)
+	}
+	if owasp := reports[1]; !owasp.spfChecked || !owasp.dmarcChecked || owasp.MissingSPF ||
+		owasp.MissingDMARC || owasp.Rcodes["NXDOMAIN"] != 1 {
+		t.Errorf("Unexpected results in the owasp.org report")
+	}
+
+	buf := new(bytes.Buffer)
+	WriteZoneReportsJSON(buf, reports)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected two lines of JSON, got %d", len(lines))
+	}
+	var decoded ZoneReport
+	if err := json.Unmarshal([]byte(lines[0]), &decoded); err != nil || decoded.Zone != "caffix.net" || !decoded.MissingSPF {
+		t.Errorf("The JSON report was not written as expected: %s", lines[0])
+	}
+
+	buf.Reset()
+	WriteZoneReportsMarkdown(buf, reports)
+	for _, want := range []string{"## caffix.net", "| NXDOMAIN | 1 |", "- Missing SPF record", "- old.caffix.net"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("The Markdown report is missing %q", want)
+		}
+	}
+}
+
+func TestWriteZoneReportsFile(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p := &params{
+		Log:       log.New(buf, "", 0),
+		Report:    "json",
+		ReportOut: filepath.Join(t.TempDir(), "reports.json"),
+	}
+
+	z := NewZoneReports()
+	z.Add(&nameTracker{Name: "www.caffix.net", Responses: []*dns.Msg{new(dns.Msg).SetQuestion("www.caffix.net.", dns.TypeA)}})
+	if err := WriteZoneReports(context.Background(), p, z); err != nil {
+		t.Fatalf("failed to write the zone reports: %v", err)
+	}
+
+	data, err := os.ReadFile(p.ReportOut)
+	if err != nil || !strings.Contains(string(data), `"zone":"caffix.net"`) {
+		t.Errorf("the report file did not contain the zone report: %s %v", data, err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("the zone reports were written to the log: %s", buf.String())
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/owasp-amass/resolve"
+)
+
+// ReadRoutingRules parses a rules file with a rule on each line, in the order they are evaluated.
+// A rule starts with a glob pattern, or a regular expression enclosed in slashes, followed by
+// the servers comma-separated and the "norecurse" and "nowildcards" options, each optional.
+// Lines starting with '#' are ignored.
+//
+//	*.internal.example.com 10.0.0.53,10.0.1.53 nowildcards
+//	/^dev[0-9]+\.example\.com$/ nowildcards
+func ReadRoutingRules(r io.Reader) ([]*resolve.RoutingRule, error) {
+	var rules []*resolve.RoutingRule
+
+	err := ExtractLines(r, func(str string) error {
+		fields := strings.Fields(str)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			return nil
+		}
+
+		rule := new(resolve.RoutingRule)
+		if p := fields[0]; len(p) > 2 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+			re, err := regexp.Compile(p[1 : len(p)-1])
+			if err != nil {
+				return fmt.Errorf("failed to parse the pattern %s: %v", p, err)
+			}
+			rule.Pattern = re
+		} else if re, err := resolve.GlobFilter(p); err == nil {
+			rule.Pattern = re
+		} else {
+			return fmt.Errorf("failed to parse the pattern %s: %v", p, err)
+		}
+
+		for _, f := range fields[1:] {
+			switch strings.ToLower(f) {
+			case "norecurse":
+				rule.NoRecursion = true
+			case "nowildcards":
+				rule.SkipWildcards = true
+			default:
+				var servers CommaSep
+				if err := servers.Set(f); err != nil {
+					return err
+				}
+				rule.Servers = append(rule.Servers, servers...)
+			}
+		}
+		rules = append(rules, rule)
+		return nil
+	})
+	return rules, err
+}
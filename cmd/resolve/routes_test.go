@@ -0,0 +1,38 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadRoutingRules(t *testing.T) {
+	input := `# internal names
+*.internal.example.com 10.0.0.53,10.0.1.53 nowildcards
+
+/^dev[0-9]+\.example\.com$/ NoWildcards norecurse
+`
+	rules, err := ReadRoutingRules(strings.NewReader(input))
+	if err != nil || len(rules) != 2 {
+		t.Fatalf("failed to read the rules: %v %v", rules, err)
+	}
+
+	first, second := rules[0], rules[1]
+	if !first.Pattern.MatchString("www.internal.example.com") || first.Pattern.MatchString("internal.example.com") {
+		t.Errorf("the glob pattern was not parsed correctly: %s", first.Pattern)
+	}
+	if !reflect.DeepEqual(first.Servers, []string{"10.0.0.53", "10.0.1.53"}) || !first.SkipWildcards || first.NoRecursion {
+		t.Errorf("the first rule was not parsed correctly: %+v", first)
+	}
+	if !second.Pattern.MatchString("dev12.example.com") || second.Servers != nil || !second.SkipWildcards || !second.NoRecursion {
+		t.Errorf("the second rule was not parsed correctly: %+v", second)
+	}
+
+	if _, err := ReadRoutingRules(strings.NewReader("/dev[/ nowildcards")); err == nil {
+		t.Errorf("an invalid regular expression was accepted")
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/owasp-amass/resolve"
+)
+
+// ScanStats summarizes the outcomes for the DNS names handled during a run.
+type ScanStats struct {
+	Names     int
+	Resolved  int
+	NXDomain  int
+	NoData    int
+	Wildcards int
+	Timeouts  int
+	Dropped   uint64
+}
+
+// Add classifies the name handled by the tracker and updates the counters.
+func (s *ScanStats) Add(tracker *nameTracker) {
+	s.Names++
+
+	var answers, nxdomain bool
+	for _, resp := range tracker.Responses {
+		switch resolve.StatusOf(resp) {
+		case resolve.StatusAnswer:
+			answers = true
+		case resolve.StatusNXDomain:
+			nxdomain = true
+		}
+	}
+
+	switch {
+	case answers:
+		s.Resolved++
+	case tracker.Filtered > 0:
+		s.Wildcards++
+	case nxdomain:
+		s.NXDomain++
+	case len(tracker.Responses) == 0 && tracker.Failures > 0:
+		s.Timeouts++
+	default:
+		s.NoData++
+	}
+}
+
+// Write outputs the statistics to the provided writer.
+func (s *ScanStats) Write(w io.Writer) {
+	fmt.Fprintf(w, "Names: %d\n", s.Names)
+	fmt.Fprintf(w, "Resolved: %d\n", s.Resolved)
+	fmt.Fprintf(w, "NXDOMAIN: %d\n", s.NXDomain)
+	fmt.Fprintf(w, "NODATA: %d\n", s.NoData)
+	fmt.Fprintf(w, "Wildcards: %d\n", s.Wildcards)
+	fmt.Fprintf(w, "Timeouts: %d\n", s.Timeouts)
+	fmt.Fprintf(w, "Dropped locally: %d\n", s.Dropped)
+}
+
+// WriteStats sends the statistics to the output file, or the log when running in quiet mode.
+func WriteStats(p *params, s *ScanStats) {
+	if p.Output != nil {
+		s.Write(p.Output)
+		return
+	}
+	s.Write(p.Log.Writer())
+}
+
+// WriteResolverStats outputs a table with the statistics of each resolver to the provided writer.
+func WriteResolverStats(w io.Writer, all []*resolve.ResolverStats) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "Resolver\tQueries\tSuccess\tAvg RTT\tTimeouts\tAvg Size\tP95 Size\tTruncated")
+	for _, s := range all {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f%%\t%s\t%d\t%d\t%d\t%.1f%%\n", s.Address, s.Queries, s.SuccessRate(),
+			s.AvgRTT.Round(time.Microsecond), s.Timeouts, s.AvgSize, s.P95Size, s.TruncationRate())
+	}
+	_ = tw.Flush()
+}
+
+// WriteLatencyReport outputs the latency breakdown across the stages for the sampled requests.
+func WriteLatencyReport(w io.Writer, rep *resolve.LatencyReport) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Stage (%d samples)\tMean\tP50\tP95\tMax\n", rep.Samples)
+	for _, s := range rep.Stages {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Stage, s.Mean.Round(time.Microsecond),
+			s.P50.Round(time.Microsecond), s.P95.Round(time.Microsecond), s.Max.Round(time.Microsecond))
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,98 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+)
+
+func TestScanStats(t *testing.T) {
+	answer := new(dns.Msg)
+	answer.SetQuestion("www.caffix.net.", dns.TypeA)
+	answer.Answer = append(answer.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: "www.caffix.net.", Rrtype: dns.TypeA, Class: dns.ClassINET},
+		A:   net.ParseIP("192.168.1.1"),
+	})
+
+	nxdomain := new(dns.Msg)
+	nxdomain.SetQuestion("bad.caffix.net.", dns.TypeA)
+	nxdomain.Rcode = dns.RcodeNameError
+
+	nodata := new(dns.Msg)
+	nodata.SetQuestion("empty.caffix.net.", dns.TypeA)
+
+	stats := new(ScanStats)
+	stats.Add(&nameTracker{Responses: []*dns.Msg{answer}})
+	stats.Add(&nameTracker{Responses: []*dns.Msg{nxdomain}})
+	stats.Add(&nameTracker{Responses: []*dns.Msg{nodata}})
+	stats.Add(&nameTracker{Filtered: 1})
+	stats.Add(&nameTracker{Failures: 1})
+
+	expected := ScanStats{Names: 5, Resolved: 1, NXDomain: 1, NoData: 1, Wildcards: 1, Timeouts: 1}
+	if *stats != expected {
+		t.Errorf("Got: %v; Expected: %v", *stats, expected)
+	}
+
+	stats.Dropped = 3
+	buf := new(bytes.Buffer)
+	stats.Write(buf)
+	if !strings.Contains(buf.String(), "Resolved: 1") || !strings.Contains(buf.String(), "Dropped locally: 3") {
+		t.Errorf("The statistics were not written as expected: %s", buf.String())
+	}
+}
+
+func TestWriteResolverStats(t *testing.T) {
+	all := []*resolve.ResolverStats{
+		{
+			Address:   "192.168.1.1:53",
+			Queries:   4,
+			Successes: 3,
+			Timeouts:  1,
+			AvgRTT:    1500 * time.Microsecond,
+			Responses: 4,
+			Truncated: 1,
+			AvgSize:   120,
+			P95Size:   512,
+		},
+		{Address: "192.168.1.2:53"},
+	}
+
+	buf := new(bytes.Buffer)
+	WriteResolverStats(buf, all)
+
+	expected := "Resolver        Queries  Success  Avg RTT  Timeouts  Avg Size  P95 Size  Truncated\n" +
+		"192.168.1.1:53  4        75.0%    1.5ms    1         120       512       25.0%\n" +
+		"192.168.1.2:53  0        0.0%     0s       0         0         0         0.0%\n"
+	if got := buf.String(); got != expected {
+		t.Errorf("Got: %q; Expected: %q", got, expected)
+	}
+}
+
+func TestWriteLatencyReport(t *testing.T) {
+	rep := &resolve.LatencyReport{
+		Samples: 2,
+		Stages: []resolve.LatencyStats{
+			{Stage: resolve.StageSelection, Mean: 10 * time.Microsecond, P50: 10 * time.Microsecond, P95: 12 * time.Microsecond, Max: 12 * time.Microsecond},
+			{Stage: resolve.StageNetwork, Mean: 1500 * time.Microsecond, P50: time.Millisecond, P95: 2 * time.Millisecond, Max: 2 * time.Millisecond},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	WriteLatencyReport(buf, rep)
+
+	expected := "Stage (2 samples)  Mean   P50   P95   Max\n" +
+		"selection          10µs   10µs  12µs  12µs\n" +
+		"network            1.5ms  1ms   2ms   2ms\n"
+	if got := buf.String(); got != expected {
+		t.Errorf("Got: %q; Expected: %q", got, expected)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+)
+
+// The names queried in the CHAOS class to identify the software and instance of a resolver.
+var surveyChaosNames = []string{"version.bind.", "hostname.bind.", "id.server."}
+
+// SurveyResult contains what a resolver revealed about itself while being surveyed.
+type SurveyResult struct {
+	Address    string
+	Responsive bool
+	Chaos      map[string]string
+	EDNS       bool
+	UDPSize    uint16
+	DNSSECOK   bool
+	NSID       string
+	Err        error
+}
+
+// Survey runs the survey subcommand, which queries each resolver in the list for the CHAOS
+// class identification values and EDNS capabilities, and writes the results as CSV.
+func Survey(args []string) error {
+	var rlist CommaSep
+	var rpath, opath string
+	var timeout, concurrency int
+
+	buf := new(bytes.Buffer)
+	flags := flag.NewFlagSet("survey", flag.ContinueOnError)
+	flags.SetOutput(buf)
+	flags.Var(&rlist, "r", "DNS resolver IP addresses, optionally with a port, comma-separated")
+	flags.StringVar(&rpath, "rf", "", "File containing a DNS resolver IP address, optionally with a port, on each line")
+	flags.IntVar(&timeout, "timeout", 2000, "Number of milliseconds to wait for each response")
+	flags.IntVar(&concurrency, "c", 50, "Number of resolvers surveyed at the same time")
+	flags.StringVar(&opath, "o", "", "Write the CSV results to the specified output file (default stdout)")
+	if err := flags.Parse(args); err != nil {
+		return errors.New(buf.String())
+	}
+	if timeout <= 0 || concurrency <= 0 {
+		return errors.New("the timeout and concurrency must be greater than zero")
+	}
+
+	list := []string(rlist)
+	if rpath != "" {
+		list = append(list, ResolverFileList(rpath)...)
+	}
+	if len(list) == 0 {
+		return errors.New("no resolvers were provided to survey")
+	}
+
+	w := io.Writer(os.Stdout)
+	if opath != "" {
+		f, err := os.OpenFile(opath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open the output file: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	results := SurveyResolvers(context.Background(), list, time.Duration(timeout)*time.Millisecond, concurrency)
+	return WriteSurveyCSV(w, results)
+}
+
+// SurveyResolvers surveys the resolvers in the list, with up to concurrency of them at the
+// same time, and returns the results in the order of the list.
+func SurveyResolvers(ctx context.Context, list []string, timeout time.Duration, concurrency int) []*SurveyResult {
+	results := make([]*SurveyResult, len(list))
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, addr := range list {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, addr string) {
+			defer func() { <-sem; wg.Done() }()
+			results[i] = SurveyResolver(ctx, addr, timeout)
+		}(i, addr)
+	}
+	wg.Wait()
+	return results
+}
+
+// SurveyResolver queries the resolver for the CHAOS class TXT records identifying it, and
+// checks the EDNS support using a query for the root NS records that requests the DNSSEC
+// records and nameserver identifier (RFC 5001).
+func SurveyResolver(ctx context.Context, addr string, timeout time.Duration) *SurveyResult {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "53")
+	}
+
+	res := &SurveyResult{
+		Address: addr,
+		Chaos:   make(map[string]string),
+	}
+	client := dns.Client{Timeout: timeout}
+
+	for _, name := range surveyChaosNames {
+		msg := new(dns.Msg)
+		msg.SetQuestion(name, dns.TypeTXT)
+		msg.Question[0].Qclass = dns.ClassCHAOS
+
+		resp, _, err := client.ExchangeContext(ctx, msg, addr)
+		if err != nil {
+			continue
+		}
+		res.Responsive = true
+		if txts := resolve.Answers[*dns.TXT](resp); len(txts) > 0 {
+			res.Chaos[name] = strings.Join(txts[0].Txt, " ")
+		}
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(".", dns.TypeNS)
+	msg.SetEdns0(dns.DefaultMsgSize, true)
+	opt := msg.IsEdns0()
+	opt.Option = append(opt.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID})
+
+	resp, _, err := client.ExchangeContext(ctx, msg, addr)
+	if err != nil {
+		if !res.Responsive {
+			res.Err = err
+		}
+		return res
+	}
+	res.Responsive = true
+
+	if opt := resp.IsEdns0(); opt != nil {
+		res.EDNS = true
+		res.UDPSize = opt.UDPSize()
+		res.DNSSECOK = opt.Do()
+		for _, o := range opt.Option {
+			if nsid, ok := o.(*dns.EDNS0_NSID); ok {
+				// The identifier is represented as a hex string by the dns package
+				if b, err := hex.DecodeString(nsid.Nsid); err == nil {
+					res.NSID = string(b)
+				} else {
+					res.NSID = nsid.Nsid
+				}
+			}
+		}
+	}
+	return res
+}
+
+// WriteSurveyCSV writes the header and a row for each survey result to the provided writer.
+func WriteSurveyCSV(w io.Writer, results []*SurveyResult) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{"resolver", "responsive"}
+	for _, name := range surveyChaosNames {
+		header = append(header, strings.TrimSuffix(name, "."))
+	}
+	header = append(header, "edns", "udp_size", "dnssec_ok", "nsid", "error")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, res := range results {
+		row := []string{res.Address, strconv.FormatBool(res.Responsive)}
+		for _, name := range surveyChaosNames {
+			row = append(row, res.Chaos[name])
+		}
+
+		var size, errstr string
+		if res.EDNS {
+			size = strconv.Itoa(int(res.UDPSize))
+		}
+		if res.Err != nil {
+			errstr = res.Err.Error()
+		}
+		row = append(row, strconv.FormatBool(res.EDNS), size, strconv.FormatBool(res.DNSSECOK), res.NSID, errstr)
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
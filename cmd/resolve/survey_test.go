@@ -0,0 +1,93 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func surveyHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	q := req.Question[0]
+	if q.Qclass == dns.ClassCHAOS && q.Name == "version.bind." {
+		m.Answer = append(m.Answer, &dns.TXT{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassCHAOS},
+			Txt: []string{"9.18.0"},
+		})
+	}
+	if opt := req.IsEdns0(); opt != nil {
+		m.SetEdns0(1232, opt.Do())
+		m.IsEdns0().Option = append(m.IsEdns0().Option, &dns.EDNS0_NSID{
+			Code: dns.EDNS0NSID,
+			Nsid: hex.EncodeToString([]byte("ns1")),
+		})
+	}
+	_ = w.WriteMsg(m)
+}
+
+func TestSurveyResolvers(t *testing.T) {
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", surveyHandler)
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	silent, silentaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = silent.Shutdown() }()
+
+	results := SurveyResolvers(context.Background(), []string{addrstr, silentaddr}, 250*time.Millisecond, 2)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 survey results, got %d", len(results))
+	}
+
+	res := results[0]
+	if !res.Responsive || res.Chaos["version.bind."] != "9.18.0" || res.Chaos["hostname.bind."] != "" {
+		t.Errorf("the CHAOS class values were not obtained as expected: %v", res.Chaos)
+	}
+	if !res.EDNS || res.UDPSize != 1232 || !res.DNSSECOK || res.NSID != "ns1" {
+		t.Errorf("the EDNS capabilities were not obtained as expected: %+v", res)
+	}
+	if res := results[1]; res.Responsive || res.Err == nil {
+		t.Errorf("the silent resolver was reported as responsive")
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSurveyCSV(&buf, results); err != nil {
+		t.Fatalf("failed to write the survey results: %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("the CSV output did not contain the header and two rows: %v", err)
+	}
+	if rows[0][2] != "version.bind" || rows[1][0] != addrstr || rows[1][2] != "9.18.0" || rows[1][6] != "1232" || rows[1][8] != "ns1" {
+		t.Errorf("the CSV output was not written as expected: %v", rows[:2])
+	}
+}
+
+func TestSurveyFlags(t *testing.T) {
+	if err := Survey([]string{"-timeout", "0", "-r", "127.0.0.1"}); err == nil {
+		t.Errorf("a timeout of zero was accepted")
+	}
+	if err := Survey([]string{"-c", "1"}); err == nil {
+		t.Errorf("the survey ran without any resolvers")
+	}
+}
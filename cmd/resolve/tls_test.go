@@ -0,0 +1,23 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !notls
+
+package main
+
+import "testing"
+
+func TestSetupResolverPoolTLS(t *testing.T) {
+	p := &params{QPS: 10, TLS: []string{"127.0.0.1"}}
+
+	if err := p.SetupResolverPool(nil, "", 0, ""); err != nil {
+		t.Fatalf("failed to setup the resolver pool: %v", err)
+	}
+	defer p.Pool.Stop()
+
+	// The default resolvers are not added when only the DNS-over-TLS resolvers are provided
+	if n := p.Pool.Len(); n != 1 {
+		t.Errorf("Expected only the DNS-over-TLS resolver in the pool, got %d", n)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Config provides the settings of a resolver pool created by NewResolversWithConfig.
+type Config struct {
+	// Resolvers are the IP addresses or hostnames, optionally with a port, of the resolvers.
+	Resolvers []string
+	// QPS is the maximum queries per second sent to each resolver.
+	QPS int
+	// MaxQPS is the maximum queries per second sent by the pool, or zero for no limit.
+	MaxQPS int
+	// Timeout is the time waited for each response, or zero for the DefaultTimeout.
+	Timeout time.Duration
+	// Retries is the number of times QueryBlocking sends a query again without a response.
+	Retries int
+	// MaxInFlight limits the outstanding requests for each resolver, or zero for no limit.
+	MaxInFlight int
+	// MaxAliasDepth is the number of CNAME records followed, or zero for the DefaultMaxAliasDepth.
+	MaxAliasDepth int
+	// Detectors are the IP addresses, optionally with a port, used for wildcard detection.
+	Detectors []string
+	// Quorum is the number of detectors that must agree on a wildcard, or zero for a majority.
+	Quorum int
+}
+
+// ConfigError reports a Config field with an invalid value.
+type ConfigError struct {
+	Field  string
+	Value  interface{}
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid %s %v: %s", e.Field, e.Value, e.Reason)
+}
+
+// Validate checks each setting of the Config, returning a ConfigError for each invalid value.
+func Validate(cfg *Config) error {
+	if cfg == nil {
+		return errors.New("no config was provided")
+	}
+
+	var errs []error
+	check := func(valid bool, field string, value interface{}, reason string) {
+		if !valid {
+			errs = append(errs, &ConfigError{Field: field, Value: value, Reason: reason})
+		}
+	}
+
+	check(cfg.QPS > 0, "QPS", cfg.QPS, "must be greater than zero")
+	check(cfg.MaxQPS >= 0, "MaxQPS", cfg.MaxQPS, "cannot be negative")
+	check(cfg.Timeout >= 0, "Timeout", cfg.Timeout, "cannot be negative")
+	check(cfg.Retries >= 0, "Retries", cfg.Retries, "cannot be negative")
+	check(cfg.MaxInFlight >= 0, "MaxInFlight", cfg.MaxInFlight, "cannot be negative")
+	check(cfg.MaxAliasDepth >= 0, "MaxAliasDepth", cfg.MaxAliasDepth, "cannot be negative")
+	check(cfg.Quorum >= 0 && cfg.Quorum <= len(cfg.Detectors), "Quorum", cfg.Quorum,
+		fmt.Sprintf("must be between zero and the %d detectors", len(cfg.Detectors)))
+	for _, addr := range cfg.Resolvers {
+		check(validServerAddress(addr, true), "resolver", addr, "must be an IP address or hostname with an optional port")
+	}
+	for _, addr := range cfg.Detectors {
+		check(validServerAddress(addr, false), "detector", addr, "must be an IP address with an optional port")
+	}
+	return errors.Join(errs...)
+}
+
+// Returns true when the address is an IP address, or a hostname if allowed, with an optional port.
+func validServerAddress(addr string, hostnames bool) bool {
+	host := addr
+	if h, port, err := net.SplitHostPort(addr); err == nil {
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			return false
+		}
+		host = h
+	}
+
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	if !hostnames || host == "" {
+		return false
+	}
+	_, ok := dns.IsDomainName(host)
+	return ok
+}
+
+// NewResolversWithConfig returns a started resolver pool using the settings of the Config, after
+// validating them. An error is returned when the sockets or the resolvers cannot be set up.
+func NewResolversWithConfig(cfg *Config) (*Resolvers, error) {
+	if err := Validate(cfg); err != nil {
+		return nil, err
+	}
+
+	r := NewResolvers()
+	if err := r.Start(); err != nil {
+		r.Stop()
+		return nil, fmt.Errorf("failed to open the sockets for the resolver pool: %v", err)
+	}
+	if len(cfg.Resolvers) > 0 {
+		if err := r.AddResolvers(cfg.QPS, cfg.Resolvers...); err != nil {
+			r.Stop()
+			return nil, err
+		}
+	}
+	if cfg.Timeout > 0 {
+		r.SetTimeout(cfg.Timeout)
+	}
+	if len(cfg.Detectors) > 0 {
+		r.SetDetectionResolvers(cfg.QPS, cfg.Quorum, cfg.Detectors...)
+	}
+	// Without a maximum, the pool keeps the combined QPS of the resolvers as its rate limit
+	if cfg.MaxQPS > 0 {
+		r.SetMaxQPS(cfg.MaxQPS)
+	}
+	r.SetRetries(cfg.Retries)
+	r.SetMaxInFlight(cfg.MaxInFlight)
+	r.SetMaxAliasDepth(cfg.MaxAliasDepth)
+	return r, nil
+}
@@ -0,0 +1,110 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestValidate(t *testing.T) {
+	if err := Validate(nil); err == nil {
+		t.Errorf("a nil config was accepted")
+	}
+
+	valid := &Config{
+		Resolvers: []string{"8.8.8.8", "1.1.1.1:53", "[2001:db8::1]:5353", "dns.google"},
+		QPS:       10,
+		Timeout:   time.Second,
+		Detectors: []string{"8.8.8.8", "127.0.0.1:5353"},
+		Quorum:    2,
+	}
+	if err := Validate(valid); err != nil {
+		t.Errorf("the valid config was rejected: %v", err)
+	}
+
+	invalid := &Config{
+		Resolvers:     []string{"8.8.8.8:0", "bad..name"},
+		QPS:           0,
+		MaxQPS:        -1,
+		Timeout:       -time.Second,
+		Retries:       -1,
+		MaxInFlight:   -1,
+		MaxAliasDepth: -1,
+		Detectors:     []string{"dns.google"},
+		Quorum:        2,
+	}
+	err := Validate(invalid)
+	if err == nil {
+		t.Fatalf("the invalid config was accepted")
+	}
+
+	fields := make(map[string]int)
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var cerr *ConfigError
+		if !errors.As(e, &cerr) {
+			t.Fatalf("%v is not a ConfigError", e)
+		}
+		fields[cerr.Field]++
+	}
+	for field, count := range map[string]int{
+		"QPS":           1,
+		"MaxQPS":        1,
+		"Timeout":       1,
+		"Retries":       1,
+		"MaxInFlight":   1,
+		"MaxAliasDepth": 1,
+		"Quorum":        1,
+		"resolver":      2,
+		"detector":      1,
+	} {
+		if fields[field] != count {
+			t.Errorf("got %d errors for the %s field; expected %d", fields[field], field, count)
+		}
+	}
+}
+
+func TestNewResolversWithConfig(t *testing.T) {
+	if _, err := NewResolversWithConfig(&Config{QPS: -1}); err == nil {
+		t.Errorf("a pool was created with an invalid config")
+	}
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc("config.net.", typeAHandler)
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("failed to start the DNS server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r, err := NewResolversWithConfig(&Config{
+		Resolvers:     []string{addrstr},
+		QPS:           10,
+		Timeout:       500 * time.Millisecond,
+		Retries:       2,
+		MaxAliasDepth: 5,
+	})
+	if err != nil {
+		t.Fatalf("failed to create the pool: %v", err)
+	}
+	defer r.Stop()
+
+	if r.Len() != 1 || r.getRetries() != 2 || r.maxAliasDepth() != 5 {
+		t.Errorf("the config was not applied to the pool")
+	}
+	if qps := r.QPS(); qps != 10 || r.maxRate() == nil {
+		t.Errorf("the pool did not keep the combined QPS of the resolvers: %d", qps)
+	}
+
+	msg := QueryMsg("www.config.net", dns.TypeA)
+	if resp, err := r.QueryBlocking(context.Background(), msg); err != nil || len(resp.Answer) == 0 {
+		t.Errorf("the query sent through the pool failed: %v", err)
+	}
+}
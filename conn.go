@@ -5,26 +5,48 @@
 package resolve
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/caffix/queue"
 	"github.com/miekg/dns"
 )
 
-const headerSize = 12
+const (
+	headerSize = 12
+	// batchSize is the maximum number of datagrams sent or received in a system call.
+	batchSize = 8
+)
 
 type resp struct {
-	Msg  *dns.Msg
-	Addr net.Addr
+	Msg       *dns.Msg
+	Addr      net.Addr
+	Res       []*resolver
+	Size      int
+	Transport string
 }
 
 type connection struct {
-	conn net.PacketConn
-	done chan struct{}
+	conn     net.PacketConn
+	batch    *batchConn
+	writes   chan *datagram
+	done     chan struct{}
+	reading  atomic.Int64
+	failures atomic.Int32
+}
+
+// datagram is a UDP message sent or received through a batch of system calls.
+type datagram struct {
+	b    []byte
+	n    int
+	addr net.Addr
+	err  chan error
 }
 
 type connections struct {
@@ -34,37 +56,129 @@ type connections struct {
 	resps     queue.Queue
 	nextWrite int
 	cpus      int
+	rcvbuf    int
+	drops     *dropCounter
+	deadline  atomic.Int64
+	replaced  uint64
+	registry  *addrRegistry
+	started   bool
+	closed    bool
+}
+
+// addrRegistry maps the nameserver addresses that requests were written to on the connections
+// to the resolvers that sent them, so responses are routed independent of selector mutations.
+type addrRegistry struct {
+	sync.Mutex
+	addrs map[string][]*resolver
+}
+
+func newAddrRegistry() *addrRegistry {
+	return &addrRegistry{addrs: make(map[string][]*resolver)}
 }
 
+// Returns the IP:port string used to identify the nameserver at the provided address.
+func addrKey(addr net.Addr) string {
+	if uaddr, ok := addr.(*net.UDPAddr); ok {
+		return net.JoinHostPort(uaddr.IP.String(), strconv.Itoa(uaddr.Port))
+	}
+	return addr.String()
+}
+
+func (a *addrRegistry) register(res *resolver) {
+	a.Lock()
+	defer a.Unlock()
+
+	key := addrKey(res.address)
+	for _, r := range a.addrs[key] {
+		if r == res {
+			return
+		}
+	}
+	a.addrs[key] = append(a.addrs[key], res)
+}
+
+func (a *addrRegistry) unregister(res *resolver) {
+	a.Lock()
+	defer a.Unlock()
+
+	key := addrKey(res.address)
+	list := a.addrs[key]
+	for i, r := range list {
+		if r == res {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+
+	if len(list) == 0 {
+		delete(a.addrs, key)
+	} else {
+		a.addrs[key] = list
+	}
+}
+
+func (a *addrRegistry) lookup(addr net.Addr) []*resolver {
+	a.Lock()
+	defer a.Unlock()
+
+	return append([]*resolver(nil), a.addrs[addrKey(addr)]...)
+}
+
+// newConnections returns the connections without opening the sockets, which happens when
+// they are started or the first message is written.
 func newConnections(cpus int, resps queue.Queue) *connections {
 	if cpus < 100 {
 		cpus = 100
 	}
 
 	conns := &connections{
-		resps: resps,
-		done:  make(chan struct{}),
-		cpus:  cpus,
+		resps:    resps,
+		done:     make(chan struct{}),
+		cpus:     cpus,
+		drops:    newDropCounter(),
+		registry: newAddrRegistry(),
 	}
+	conns.deadline.Store(int64(DefaultReadDeadline))
+	return conns
+}
 
-	conns.Lock()
-	defer conns.Unlock()
+// Start opens the sockets and begins rotating them, unless the connections are already started.
+func (r *connections) Start() error {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.start()
+}
+
+// The caller must hold the lock.
+func (r *connections) start() error {
+	if r.started {
+		return nil
+	}
+	if r.closed {
+		return errors.New("the connections have been closed")
+	}
 
-	for i := 0; i < cpus; i++ {
-		if err := conns.Add(); err != nil {
-			conns.Close()
-			return nil
+	for i := 0; i < r.cpus; i++ {
+		if err := r.Add(); err != nil {
+			for _, c := range r.conns {
+				close(c.done)
+			}
+			r.conns = nil
+			return err
 		}
 	}
-	go conns.rotations()
-	return conns
+	r.started = true
+	go r.rotations()
+	return nil
 }
 
 func (r *connections) Close() {
 	r.Lock()
 	defer r.Unlock()
 
-	if r.conns != nil {
+	if !r.closed {
+		r.closed = true
 		close(r.done)
 		for _, c := range r.conns {
 			close(c.done)
@@ -76,86 +190,311 @@ func (r *connections) Close() {
 func (r *connections) rotations() {
 	t := time.NewTicker(10 * time.Second)
 	defer t.Stop()
+	stuck := time.NewTicker(stuckCheckInterval)
+	defer stuck.Stop()
 
 	for {
 		select {
 		case <-r.done:
 			return
 		case <-t.C:
+			// Collect the drops of the sockets before they are replaced
+			r.pollDrops()
 			r.rotate()
+		case <-stuck.C:
+			r.replaceStuck()
 		}
 	}
 }
 
+// Replaces the sockets with new sockets. The new sockets are opened without holding the lock,
+// so the messages continue to be written on the current sockets until they are swapped in.
 func (r *connections) rotate() {
+	r.Lock()
+	rcvbuf := r.rcvbuf
+	r.Unlock()
+
+	var fresh []*connection
+	for i := 0; i < r.cpus; i++ {
+		for attempt := 0; attempt < maxQueryAttempts; attempt++ {
+			c, err := r.listen(rcvbuf)
+			if err == nil {
+				fresh = append(fresh, c)
+				break
+			}
+			if !r.backoff(attempt) {
+				closeUnserved(fresh)
+				return
+			}
+		}
+	}
+
 	r.Lock()
 	defer r.Unlock()
 
+	if r.closed || len(fresh) == 0 {
+		closeUnserved(fresh)
+		return
+	}
 	for _, c := range r.conns {
-		go func(c *connection) {
-			t := time.NewTimer(10 * time.Second)
-			defer t.Stop()
+		go r.retire(c)
+	}
+	for _, c := range fresh {
+		r.serve(c)
+	}
+	r.conns = fresh
+	r.nextWrite = 0
+}
 
-			<-t.C
-			close(c.done)
-		}(c)
+// Waits for the delay after the failed attempts to open a socket. Returns false when the
+// connections are closed first.
+func (r *connections) backoff(attempt int) bool {
+	t := time.NewTimer(connBackoff.NextDelay(attempt))
+	defer t.Stop()
+
+	select {
+	case <-r.done:
+		return false
+	case <-t.C:
 	}
+	return true
+}
 
-	r.conns = []*connection{}
-	for i := 0; i < r.cpus; i++ {
-		_ = r.Add()
+// Closes the connection replaced by a rotation once the responses in flight have been received,
+// or when the connections are closed.
+func (r *connections) retire(c *connection) {
+	t := time.NewTimer(10 * time.Second)
+	defer t.Stop()
+
+	select {
+	case <-r.done:
+	case <-t.C:
+	}
+	close(c.done)
+}
+
+// Closes the sockets of the connections that were never handed to serve.
+func closeUnserved(conns []*connection) {
+	for _, c := range conns {
+		_ = c.conn.Close()
 	}
 }
 
 func (r *connections) Next() net.PacketConn {
+	if c := r.next(); c != nil {
+		return c.conn
+	}
+	return nil
+}
+
+func (r *connections) next() *connection {
 	r.Lock()
 	defer r.Unlock()
 
-	if r.conns == nil || len(r.conns) == 0 {
+	if err := r.start(); err != nil || len(r.conns) == 0 {
 		return nil
 	}
 
 	cur := r.nextWrite
 	r.nextWrite = (r.nextWrite + 1) % len(r.conns)
-	return r.conns[cur].conn
+	return r.conns[cur]
 }
 
 func (r *connections) Add() error {
-	conn, err := r.ListenPacket()
+	c, err := r.open()
 	if err != nil {
 		return err
 	}
 
+	r.conns = append(r.conns, c)
+	return nil
+}
+
+// Returns a new connection with the goroutines handling the socket started.
+// The caller must hold the lock.
+func (r *connections) open() (*connection, error) {
+	c, err := r.listen(r.rcvbuf)
+	if err != nil {
+		return nil, err
+	}
+
+	r.serve(c)
+	return c, nil
+}
+
+// Returns a new connection for a socket with the receive buffer size, without the goroutines
+// handling the socket. The lock is not required.
+func (r *connections) listen(rcvbuf int) (*connection, error) {
+	conn, err := r.ListenPacket()
+	if err != nil {
+		return nil, err
+	}
+
 	_ = conn.SetDeadline(time.Time{})
-	c := &connection{
+	setReadBuffer(conn, rcvbuf)
+	return &connection{
 		conn: conn,
 		done: make(chan struct{}),
+		// Datagrams are batched when the platform supports sending multiple per system call
+		batch: newBatchConn(conn),
+	}, nil
+}
+
+// Tracks the drops of the socket and starts the goroutines handling the connection.
+// The caller must hold the lock.
+func (r *connections) serve(c *connection) {
+	r.trackDrops(c.conn)
+
+	if c.batch != nil {
+		c.writes = make(chan *datagram)
+		go r.batchWrites(c)
+		go r.batchResponses(c)
+		return
 	}
-	r.conns = append(r.conns, c)
 	go r.responses(c)
-	return nil
 }
 
-func (r *connections) WriteMsg(msg *dns.Msg, addr net.Addr) error {
+// WriteMsg sends the message to the address on the next socket. The write is abandoned when
+// the context expires first.
+func (r *connections) WriteMsg(ctx context.Context, msg *dns.Msg, addr net.Addr) error {
 	var n int
 	var err error
 	var out []byte
 
+	if err = ctx.Err(); err != nil {
+		return err
+	}
 	if out, err = msg.Pack(); err == nil {
 		err = errors.New("failed to obtain a connection")
 
-		if conn := r.Next(); conn != nil {
-			_ = conn.SetWriteDeadline(time.Now().Add(500 * time.Millisecond))
+		if c := r.next(); c != nil && c.writes != nil {
+			err = c.writeBatched(ctx, out, addr)
+		} else if c != nil {
+			_ = c.conn.SetWriteDeadline(writeDeadline(ctx))
 
-			n, err = conn.WriteTo(out, addr)
+			n, err = c.conn.WriteTo(out, addr)
 			if err == nil && n < len(out) {
 				err = fmt.Errorf("only wrote %d bytes of the %d byte message", n, len(out))
 			}
+			c.writeResult(err)
 		}
 	}
 	return err
 }
 
+// Returns the deadline for writing on a socket, which is brought forward by the deadline of the context.
+func writeDeadline(ctx context.Context) time.Time {
+	d := time.Now().Add(500 * time.Millisecond)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(d) {
+		return dl
+	}
+	return d
+}
+
+// Hands the message to the goroutine sending batches on the connection and waits for the result.
+func (c *connection) writeBatched(ctx context.Context, out []byte, addr net.Addr) error {
+	d := &datagram{
+		b:    out,
+		addr: addr,
+		err:  make(chan error, 1),
+	}
+
+	select {
+	case c.writes <- d:
+	case <-c.done:
+		return errors.New("the connection was closed")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-d.err:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Sends the messages written concurrently on the connection together in each system call.
+func (r *connections) batchWrites(c *connection) {
+	dgrams := make([]*datagram, 0, batchSize)
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case d := <-c.writes:
+			dgrams = append(dgrams[:0], d)
+		}
+	fill:
+		for len(dgrams) < batchSize {
+			select {
+			case d := <-c.writes:
+				dgrams = append(dgrams, d)
+			default:
+				break fill
+			}
+		}
+
+		_ = c.conn.SetWriteDeadline(time.Now().Add(500 * time.Millisecond))
+		for pending := dgrams; len(pending) > 0; {
+			n, err := c.batch.writeBatch(pending)
+			if n > 0 {
+				c.writeResult(nil)
+			} else {
+				c.writeResult(err)
+			}
+			for _, d := range pending[:n] {
+				d.err <- nil
+			}
+
+			pending = pending[n:]
+			if len(pending) > 0 && (err != nil || n == 0) {
+				if err == nil {
+					err = errors.New("failed to write the message")
+				}
+				pending[0].err <- err
+				pending = pending[1:]
+			}
+		}
+	}
+}
+
+// Receives the responses on the connection in batches of datagrams per system call.
+func (r *connections) batchResponses(c *connection) {
+	dgrams := make([]*datagram, batchSize)
+	for i := range dgrams {
+		dgrams[i] = &datagram{b: make([]byte, dns.DefaultMsgSize)}
+	}
+
+	for {
+		select {
+		case <-c.done:
+			_ = c.conn.Close()
+			return
+		default:
+		}
+
+		c.beginRead(r.readDeadline())
+		n, err := c.batch.readBatch(dgrams)
+		c.endRead()
+
+		if err != nil {
+			continue
+		}
+		for _, d := range dgrams[:n] {
+			if m := parseResponse(d.b[:d.n]); m != nil {
+				r.resps.Append(&resp{
+					Msg:  m,
+					Addr: d.addr,
+					Res:  r.registry.lookup(d.addr),
+					Size: d.n,
+				})
+			}
+		}
+	}
+}
+
 func (r *connections) responses(c *connection) {
 	b := make([]byte, dns.DefaultMsgSize)
 
@@ -166,15 +505,39 @@ func (r *connections) responses(c *connection) {
 			return
 		default:
 		}
-		if n, addr, err := c.conn.ReadFrom(b); err == nil && n >= headerSize {
-			m := new(dns.Msg)
+		c.beginRead(r.readDeadline())
+		n, addr, err := c.conn.ReadFrom(b)
+		c.endRead()
 
-			if err := m.Unpack(b[:n]); err == nil && len(m.Question) > 0 {
+		if err == nil {
+			if m := parseResponse(b[:n]); m != nil {
 				r.resps.Append(&resp{
 					Msg:  m,
 					Addr: addr,
+					Res:  r.registry.lookup(addr),
+					Size: n,
 				})
 			}
 		}
 	}
 }
+
+// Returns the DNS message unpacked from the data, or nil when the message cannot be safely processed.
+func parseResponse(b []byte) (m *dns.Msg) {
+	// A panic while unpacking the data drops the message instead of the connection
+	defer func() {
+		if recover() != nil {
+			m = nil
+		}
+	}()
+
+	if len(b) < headerSize {
+		return nil
+	}
+
+	m = new(dns.Msg)
+	if err := m.Unpack(b); err != nil || ValidateMsg(m) != nil {
+		return nil
+	}
+	return m
+}
@@ -5,6 +5,8 @@
 package resolve
 
 import (
+	"context"
+	"fmt"
 	"net"
 	"runtime"
 	"testing"
@@ -33,7 +35,7 @@ func TestConnections(t *testing.T) {
 		msg := QueryMsg(name, 1)
 
 		if addr, err := net.ResolveUDPAddr("udp", addrstr); err == nil {
-			_ = conn.WriteMsg(msg, addr)
+			_ = conn.WriteMsg(context.Background(), msg, addr)
 		}
 		if i%10 == 0 {
 			time.Sleep(100 * time.Millisecond)
@@ -58,3 +60,167 @@ loop:
 		t.Errorf("received only %f%% of the DNS responses", percent)
 	}
 }
+
+func TestWriteMsgContext(t *testing.T) {
+	conn := newConnections(1, queue.NewQueue())
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 53}
+	if err := conn.WriteMsg(ctx, QueryMsg("caffix.net", dns.TypeA), addr); err == nil {
+		t.Errorf("the message was written after the context expired")
+	}
+}
+
+func TestRotate(t *testing.T) {
+	conns := newConnections(1, queue.NewQueue())
+	if err := conns.Start(); err != nil {
+		t.Fatalf("unable to open the sockets: %v", err)
+	}
+
+	conns.Lock()
+	old := append([]*connection(nil), conns.conns...)
+	conns.Unlock()
+
+	conns.rotate()
+	conns.Lock()
+	cur := append([]*connection(nil), conns.conns...)
+	conns.Unlock()
+	if len(cur) != len(old) {
+		t.Fatalf("expected %d connections after the rotation, got %d", len(old), len(cur))
+	}
+	for i := range cur {
+		if cur[i] == old[i] {
+			t.Errorf("the connection %d was not replaced by the rotation", i)
+		}
+	}
+
+	// The replaced connections are closed with the connections instead of after the delay
+	conns.Close()
+	for _, c := range old {
+		select {
+		case <-c.done:
+		case <-time.After(time.Second):
+			t.Errorf("the replaced connection was not closed with the connections")
+		}
+	}
+
+	conns.rotate()
+	conns.Lock()
+	n := len(conns.conns)
+	conns.Unlock()
+	if n != 0 {
+		t.Errorf("the rotation opened %d connections after the connections were closed", n)
+	}
+}
+
+func TestMalformedResponses(t *testing.T) {
+	resps := queue.NewQueue()
+	conn := newConnections(1, resps)
+	defer conn.Close()
+
+	_, port, _ := net.SplitHostPort(conn.Next().LocalAddr().String())
+	sender, err := net.Dial("udp", fmt.Sprintf("127.0.0.1:%s", port))
+	if err != nil {
+		t.Fatalf("unable to create the sending socket: %v", err)
+	}
+	defer sender.Close()
+
+	valid := QueryMsg("caffix.net", dns.TypeA)
+	valid.Response = true
+
+	noquestion := valid.Copy()
+	noquestion.Question = nil
+
+	multiple := valid.Copy()
+	multiple.Question = append(multiple.Question, dns.Question{Name: "owasp.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET})
+
+	packets := [][]byte{{0x00, 0x01, 0x81}, {0xff, 0xff, 0x81, 0x80, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0x03}}
+	for _, msg := range []*dns.Msg{noquestion, multiple, valid} {
+		if b, err := msg.Pack(); err == nil {
+			packets = append(packets, b)
+		}
+	}
+	for _, b := range packets {
+		_, _ = sender.Write(b)
+	}
+
+	timer := time.NewTimer(500 * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		t.Fatalf("the valid response was not received")
+	case <-resps.Signal():
+	}
+	// Allow any malformed packets to be processed
+	time.Sleep(100 * time.Millisecond)
+
+	var num int
+	resps.Process(func(e interface{}) {
+		num++
+		if r, ok := e.(*resp); !ok || ValidateMsg(r.Msg) != nil {
+			t.Errorf("a malformed response was queued")
+		}
+	})
+	if num != 1 {
+		t.Errorf("expected 1 response to be queued, got %d", num)
+	}
+}
+
+func FuzzParseResponse(f *testing.F) {
+	valid := QueryMsg("caffix.net", dns.TypeA)
+	valid.Response = true
+	valid.Answer = append(valid.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: "caffix.net.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+		A:   net.ParseIP("192.168.1.1"),
+	})
+	if b, err := valid.Pack(); err == nil {
+		f.Add(b)
+	}
+	f.Add([]byte{0x00, 0x01, 0x81})
+	f.Add([]byte{0xff, 0xff, 0x81, 0x80, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0x03})
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, "127.0.0.1:53")
+	defer r.Stop()
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 53}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		m := parseResponse(b)
+		if m == nil {
+			return
+		}
+		if err := ValidateMsg(m); err != nil {
+			t.Errorf("parseResponse returned an invalid message: %v", err)
+		}
+		r.processSingleResp(&resp{Msg: m, Addr: addr})
+	})
+}
+
+func TestAddrRegistry(t *testing.T) {
+	reg := newAddrRegistry()
+	addr := &net.UDPAddr{IP: net.ParseIP("192.168.1.1"), Port: 5353}
+	first := &resolver{address: addr}
+	second := &resolver{address: &net.UDPAddr{IP: net.ParseIP("192.168.1.1"), Port: 5353}}
+
+	reg.register(first)
+	reg.register(first)
+	reg.register(second)
+	if l := len(reg.lookup(addr)); l != 2 {
+		t.Errorf("expected 2 resolvers registered for the address, got %d", l)
+	}
+	if l := len(reg.lookup(&net.UDPAddr{IP: net.ParseIP("192.168.1.1"), Port: 53})); l != 0 {
+		t.Errorf("resolvers were returned for a different port")
+	}
+
+	reg.unregister(first)
+	if list := reg.lookup(addr); len(list) != 1 || list[0] != second {
+		t.Errorf("the resolver was not unregistered")
+	}
+	reg.unregister(second)
+	if len(reg.addrs) != 0 {
+		t.Errorf("the address was not removed from the registry")
+	}
+}
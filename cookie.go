@@ -0,0 +1,117 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// clientCookieSize is the length in bytes of the client cookie (RFC 7873, section 4).
+	clientCookieSize = 8
+	// The server cookie is between 8 and 32 bytes long.
+	minServerCookieSize = 8
+	maxServerCookieSize = 32
+)
+
+// cookieJar holds the client cookie sent to a nameserver and the server cookie it returned,
+// stored as the hex strings used by dns.EDNS0_COOKIE.
+type cookieJar struct {
+	sync.Mutex
+	client string
+	server string
+}
+
+func newCookieJar() *cookieJar {
+	b := make([]byte, clientCookieSize)
+	if _, err := rand.Read(b); err != nil {
+		return nil
+	}
+	return &cookieJar{client: hex.EncodeToString(b)}
+}
+
+// SetCookies enables DNS Cookies (RFC 7873) for the queries sent through the pool. Each resolver
+// is sent its own random client cookie along with the server cookie it last returned, so repeated
+// queries are recognized by the server, which lowers the chance of the scan being rate limited or
+// treated as the source of spoofed queries. Queries sent without the EDNS0 OPT record carry no cookie.
+func (r *Resolvers) SetCookies(enabled bool) {
+	r.cookies.Store(enabled)
+}
+
+// Returns the cookie sent to the nameserver, with the server cookie appended once it is known.
+func (c *cookieJar) value() string {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.client + c.server
+}
+
+// Caches the server cookie of the response when it was returned for the client cookie.
+func (c *cookieJar) observe(msg *dns.Msg) {
+	if c == nil {
+		return
+	}
+
+	cookie := extractCookie(msg)
+	if n := len(cookie) / 2; n < clientCookieSize+minServerCookieSize || n > clientCookieSize+maxServerCookieSize {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if strings.EqualFold(cookie[:2*clientCookieSize], c.client) {
+		c.server = strings.ToLower(cookie[2*clientCookieSize:])
+	}
+}
+
+// Returns the hex string of the COOKIE option in the OPT record of the message.
+func extractCookie(msg *dns.Msg) string {
+	if opt := msg.IsEdns0(); opt != nil {
+		for _, o := range opt.Option {
+			if cookie, ok := o.(*dns.EDNS0_COOKIE); ok {
+				return cookie.Cookie
+			}
+		}
+	}
+	return ""
+}
+
+// Replaces the COOKIE option in the OPT record of the message with the cookie of the nameserver,
+// when cookies are enabled for the pool.
+func (r *resolver) addCookie(msg *dns.Msg) {
+	opt := msg.IsEdns0()
+	if opt == nil || r.cookies == nil || !r.pool.cookies.Load() {
+		return
+	}
+
+	var options []dns.EDNS0
+	for _, o := range opt.Option {
+		if o.Option() != dns.EDNS0COOKIE {
+			options = append(options, o)
+		}
+	}
+	opt.Option = append(options, &dns.EDNS0_COOKIE{
+		Code:   dns.EDNS0COOKIE,
+		Cookie: r.cookies.value(),
+	})
+}
+
+// Returns true when the nameserver rejected the server cookie sent with the request, and the
+// request should be sent once more with the server cookie cached from the response (RFC 7873,
+// section 5.3).
+func (r *resolver) cookieRetry(req *request) bool {
+	if req.Resp.Rcode != dns.RcodeBadCookie || req.badCookie || !r.pool.cookies.Load() {
+		return false
+	}
+
+	req.badCookie = true
+	return true
+}
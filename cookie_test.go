@@ -0,0 +1,107 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+const testServerCookie = "0102030405060708090a0b0c0d0e0f10"
+
+func TestCookieJar(t *testing.T) {
+	jar := newCookieJar()
+	if len(jar.value()) != 2*clientCookieSize {
+		t.Fatalf("the client cookie %s does not have the expected length", jar.value())
+	}
+
+	other := newCookieJar()
+	for _, cookie := range []string{
+		other.client + testServerCookie,
+		jar.client + "0102",
+		jar.client,
+	} {
+		jar.observe(cookieMsg(cookie))
+		if jar.value() != jar.client {
+			t.Errorf("the server cookie was cached from %s", cookie)
+		}
+	}
+
+	jar.observe(cookieMsg(jar.client + testServerCookie))
+	if jar.value() != jar.client+testServerCookie {
+		t.Errorf("the server cookie was not cached from the response")
+	}
+}
+
+func TestPoolCookies(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		cookie := extractCookie(req)
+		mu.Lock()
+		received = append(received, cookie)
+		mu.Unlock()
+
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.SetEdns0(dns.DefaultMsgSize, false)
+		var server string
+		if len(cookie) >= 2*clientCookieSize {
+			server = cookie[2*clientCookieSize:]
+			m.IsEdns0().Option = append(m.IsEdns0().Option,
+				&dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: cookie[:2*clientCookieSize] + testServerCookie})
+		}
+		// Queries without the server cookie are rejected, as a server requiring cookies would
+		if server != testServerCookie {
+			m.Rcode = dns.RcodeBadCookie
+		} else {
+			m.Answer = append(m.Answer, testARecord(req.Question[0].Name, "192.168.1.1"))
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addr)
+	defer r.Stop()
+	r.SetCookies(true)
+
+	for _, name := range []string{"www.cookie.net", "mail.cookie.net"} {
+		resp, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA))
+		if err != nil || resp.Rcode != dns.RcodeSuccess || len(resp.Answer) == 0 {
+			t.Fatalf("the query for %s failed with the cookies enabled", name)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// The first query is sent again with the server cookie after being rejected
+	if len(received) != 3 {
+		t.Fatalf("expected 3 queries to be received, got %d", len(received))
+	}
+	if len(received[0]) != 2*clientCookieSize {
+		t.Errorf("the first query did not carry only the client cookie: %s", received[0])
+	}
+	if received[1] != received[2] || received[2][2*clientCookieSize:] != testServerCookie {
+		t.Errorf("the later queries did not carry the cached server cookie: %v", received[1:])
+	}
+}
+
+func cookieMsg(cookie string) *dns.Msg {
+	m := QueryMsg("www.cookie.net", dns.TypeA)
+	opt := m.IsEdns0()
+	opt.Option = append(opt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: cookie})
+	return m
+}
@@ -0,0 +1,91 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package coredns adapts the resolver pool for use as the backend of a CoreDNS plugin.
+package coredns
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+)
+
+// Plugin matches the plugin.Handler interface of CoreDNS. The interface only depends on the dns
+// package, so the adapter does not require this module to import CoreDNS.
+type Plugin interface {
+	ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error)
+	Name() string
+}
+
+// Handler wraps the pool as a CoreDNS plugin backend, forwarding the queries for names
+// within the Zones through the pool, similar to the forward plugin. Queries for other names are
+// passed to the Next plugin, and all names are forwarded when no Zones are provided. The setup
+// function of a plugin registered with CoreDNS only needs to assign the handler:
+//
+//	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+//		return &coredns.Handler{Pool: pool, Zones: zones, Next: next}
+//	})
+type Handler struct {
+	Pool  *resolve.Resolvers
+	Zones []string
+	Next  Plugin
+}
+
+var _ Plugin = (*Handler)(nil)
+
+// Name implements the plugin.Handler interface of CoreDNS.
+func (h *Handler) Name() string {
+	return "resolve"
+}
+
+// ServeDNS implements the plugin.Handler interface of CoreDNS. Following the conventions of
+// CoreDNS, the SERVFAIL response code is returned along with the error when the query failed,
+// so the server writes the response to the client.
+func (h *Handler) ServeDNS(ctx context.Context, w dns.ResponseWriter, req *dns.Msg) (int, error) {
+	if err := resolve.ValidateMsg(req); err != nil {
+		return dns.RcodeFormatError, err
+	}
+	if !h.inZones(req.Question[0].Name) {
+		if h.Next == nil {
+			return dns.RcodeRefused, nil
+		}
+		return h.Next.ServeDNS(ctx, w, req)
+	}
+
+	resp, _, err := h.Pool.ExchangeContext(ctx, req, "")
+	if err != nil {
+		return dns.RcodeServerFailure, err
+	}
+
+	// Responses sent over UDP must fit within the size advertised by the client
+	if _, ok := w.RemoteAddr().(*net.UDPAddr); ok {
+		size := dns.MinMsgSize
+		if opt := req.IsEdns0(); opt != nil && int(opt.UDPSize()) > size {
+			size = int(opt.UDPSize())
+		}
+		resp.Truncate(size)
+	}
+
+	if err := w.WriteMsg(resp); err != nil {
+		return dns.RcodeServerFailure, err
+	}
+	return dns.RcodeSuccess, nil
+}
+
+// Returns true when the name is within one of the zones, or no zones have been provided.
+func (h *Handler) inZones(name string) bool {
+	if len(h.Zones) == 0 {
+		return true
+	}
+
+	for _, zone := range h.Zones {
+		if dns.IsSubDomain(strings.ToLower(dns.Fqdn(zone)), strings.ToLower(name)) {
+			return true
+		}
+	}
+	return false
+}
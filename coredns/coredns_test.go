@@ -0,0 +1,114 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package coredns
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+)
+
+type testPlugin struct {
+	calls int32
+}
+
+func (p *testPlugin) Name() string { return "test" }
+
+func (p *testPlugin) ServeDNS(ctx context.Context, w dns.ResponseWriter, req *dns.Msg) (int, error) {
+	atomic.AddInt32(&p.calls, 1)
+	_ = w.WriteMsg(new(dns.Msg).SetRcode(req, dns.RcodeNameError))
+	return dns.RcodeNameError, nil
+}
+
+func TestHandler(t *testing.T) {
+	mux := dns.NewServeMux()
+	mux.HandleFunc("plugin.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		if req.Question[0].Name == "silent.plugin.net." {
+			return
+		}
+		m := new(dns.Msg).SetReply(req)
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+			A:   net.ParseIP("192.168.1.1"),
+		})
+		_ = w.WriteMsg(m)
+	})
+
+	bs, baddr, err := runLocalUDPServer(mux)
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = bs.Shutdown() }()
+
+	r := resolve.NewResolvers()
+	_ = r.AddResolvers(100, baddr)
+	r.SetTimeout(100 * time.Millisecond)
+	defer r.Stop()
+
+	next := new(testPlugin)
+	h := &Handler{Pool: r, Zones: []string{"Plugin.net"}, Next: next}
+	// Emulate the CoreDNS server writing the responses for the codes not written by plugins
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		switch rcode, _ := h.ServeDNS(context.Background(), w, req); rcode {
+		case dns.RcodeServerFailure, dns.RcodeRefused, dns.RcodeFormatError, dns.RcodeNotImplemented:
+			_ = w.WriteMsg(new(dns.Msg).SetRcode(req, rcode))
+		}
+	})
+
+	fs, faddr, err := runLocalUDPServer(handler)
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = fs.Shutdown() }()
+
+	client := &dns.Client{Timeout: 2 * time.Second}
+	msg := new(dns.Msg).SetQuestion("www.plugin.net.", dns.TypeA)
+	resp, _, err := client.Exchange(msg, faddr)
+	if err != nil || resp.Id != msg.Id || resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+		t.Errorf("the query was not forwarded through the pool: %v %v", resp, err)
+	} else if resolve.SourceOf(resp) != nil {
+		t.Errorf("the response source was written to the client")
+	}
+
+	if resp, _, err := client.Exchange(new(dns.Msg).SetQuestion("silent.plugin.net.", dns.TypeA), faddr); err != nil || resp.Rcode != dns.RcodeServerFailure {
+		t.Errorf("the failed query did not return SERVFAIL: %v %v", resp, err)
+	}
+
+	if resp, _, err := client.Exchange(new(dns.Msg).SetQuestion("www.owasp.org.", dns.TypeA), faddr); err != nil ||
+		resp.Rcode != dns.RcodeNameError || atomic.LoadInt32(&next.calls) != 1 {
+		t.Errorf("the query outside the zones was not passed to the next plugin: %v %v", resp, err)
+	}
+
+	last := &Handler{Pool: r, Zones: []string{"plugin.net"}}
+	if rcode, err := last.ServeDNS(context.Background(), nil, new(dns.Msg).SetQuestion("www.owasp.org.", dns.TypeA)); err != nil || rcode != dns.RcodeRefused {
+		t.Errorf("the query outside the zones was not refused: %d %v", rcode, err)
+	}
+	if rcode, err := last.ServeDNS(context.Background(), nil, new(dns.Msg)); err == nil || rcode != dns.RcodeFormatError {
+		t.Errorf("the message without a question was accepted: %d %v", rcode, err)
+	}
+}
+
+func runLocalUDPServer(handler dns.Handler) (*dns.Server, string, error) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, "", err
+	}
+
+	started := make(chan struct{})
+	server := &dns.Server{
+		PacketConn:        pc,
+		Handler:           handler,
+		NotifyStartedFunc: func() { close(started) },
+	}
+	go func() { _ = server.ActivateAndServe() }()
+
+	<-started
+	return server, pc.LocalAddr().String(), nil
+}
@@ -0,0 +1,159 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DenylistEntry records a resolver disqualified by the pool, with the reason and time.
+type DenylistEntry struct {
+	Address string
+	Reason  string
+	Time    time.Time
+}
+
+// Denylist contains the resolvers disqualified during previous runs. The entries are kept in
+// a file with a line for each resolver, containing the IP:port address, the time in the
+// RFC 3339 format and the reason, separated by tabs.
+type Denylist struct {
+	sync.Mutex
+	path    string
+	entries map[string]*DenylistEntry
+}
+
+// LoadDenylist returns the Denylist kept in the file at the provided path. A missing file
+// is created once the first resolver is disqualified.
+func LoadDenylist(path string) (*Denylist, error) {
+	d := &Denylist{
+		path:    path,
+		entries: make(map[string]*DenylistEntry),
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return d, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, "\t", 3)
+		key := resolverKey(fields[0])
+		if key == "" {
+			return nil, fmt.Errorf("LoadDenylist: %s is not a valid resolver address", fields[0])
+		}
+
+		entry := &DenylistEntry{Address: key}
+		if len(fields) > 1 {
+			entry.Time, _ = time.Parse(time.RFC3339, fields[1])
+		}
+		if len(fields) > 2 {
+			entry.Reason = fields[2]
+		}
+		d.entries[key] = entry
+	}
+	return d, scanner.Err()
+}
+
+// Contains returns true when the resolver at the provided address has been disqualified.
+func (d *Denylist) Contains(addr string) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	_, found := d.entries[resolverKey(addr)]
+	return found
+}
+
+// Add records the resolver at the provided address as disqualified for the reason,
+// appending the entry to the file.
+func (d *Denylist) Add(addr, reason string) error {
+	key := resolverKey(addr)
+	if key == "" {
+		return fmt.Errorf("Denylist: %s is not a valid resolver address", addr)
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if _, found := d.entries[key]; found {
+		return nil
+	}
+
+	entry := &DenylistEntry{Address: key, Reason: reason, Time: time.Now().UTC()}
+	d.entries[key] = entry
+	if d.path == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(d.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s\t%s\t%s\n", entry.Address, entry.Time.Format(time.RFC3339), entry.Reason)
+	return err
+}
+
+// Entries returns the resolvers disqualified, sorted by address.
+func (d *Denylist) Entries() []*DenylistEntry {
+	d.Lock()
+	defer d.Unlock()
+
+	var entries []*DenylistEntry
+	for _, entry := range d.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Address < entries[j].Address
+	})
+	return entries
+}
+
+// SetDenylist causes the pool to skip the resolvers in the Denylist when they are added, and to
+// record the resolvers disqualified by the threshold checks or the filtering detection.
+// This should be called before the resolvers are added to the pool.
+func (r *Resolvers) SetDenylist(d *Denylist) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.deny = d
+}
+
+func (r *Resolvers) denylist() *Denylist {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.deny
+}
+
+// Removes the resolver from service for the provided reason, publishing the removal
+// and recording the resolver in the Denylist.
+func (r *Resolvers) disqualify(res *resolver, reason string) {
+	res.stop()
+	r.events.publishRemoval(res, reason)
+
+	if d := r.denylist(); d != nil {
+		if err := d.Add(res.address.String(), reason); err != nil {
+			r.log.Printf("Failed to record %s in the denylist: %v", res.address, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDenylist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+
+	d, err := LoadDenylist(path)
+	if err != nil {
+		t.Fatalf("failed to load the missing denylist: %v", err)
+	}
+	if len(d.Entries()) != 0 {
+		t.Errorf("the missing denylist has entries")
+	}
+
+	if err := d.Add("192.0.2.1", RemovedThreshold); err != nil {
+		t.Fatalf("failed to add the resolver: %v", err)
+	}
+	if err := d.Add("192.0.2.1:53", RemovedFiltering); err != nil {
+		t.Fatalf("failed to add the resolver again: %v", err)
+	}
+	if err := d.Add("not an address", RemovedThreshold); err == nil {
+		t.Errorf("an invalid address was added to the denylist")
+	}
+	if !d.Contains("192.0.2.1:53") || d.Contains("192.0.2.2") {
+		t.Errorf("the denylist did not contain the expected resolvers")
+	}
+
+	loaded, err := LoadDenylist(path)
+	if err != nil {
+		t.Fatalf("failed to load the denylist: %v", err)
+	}
+	entries := loaded.Entries()
+	if len(entries) != 1 || entries[0].Address != "192.0.2.1:53" ||
+		entries[0].Reason != RemovedThreshold || entries[0].Time.IsZero() {
+		t.Errorf("the denylist was not loaded from the file: %+v", entries)
+	}
+
+	if err := os.WriteFile(path, []byte("# comment\n\n192.0.2.3\nbad\tentry\n"), 0644); err != nil {
+		t.Fatalf("failed to write the denylist: %v", err)
+	}
+	if _, err := LoadDenylist(path); err == nil {
+		t.Errorf("the denylist with an invalid address was loaded")
+	}
+}
+
+func TestSetDenylist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	if err := os.WriteFile(path, []byte("127.0.0.1:5301\t2024-01-02T03:04:05Z\tthreshold violated\n"), 0644); err != nil {
+		t.Fatalf("failed to write the denylist: %v", err)
+	}
+
+	d, err := LoadDenylist(path)
+	if err != nil {
+		t.Fatalf("failed to load the denylist: %v", err)
+	}
+
+	r := NewResolvers()
+	defer r.Stop()
+	r.SetDenylist(d)
+
+	_ = r.AddResolvers(10, "127.0.0.1:5301", "127.0.0.1:5302")
+	if r.Len() != 1 || r.pool.LookupResolver("127.0.0.1:5301") != nil {
+		t.Errorf("the resolver in the denylist was added to the pool")
+	}
+
+	r.disqualify(r.pool.LookupResolver("127.0.0.1:5302"), RemovedFiltering)
+	if r.Len() != 0 || !d.Contains("127.0.0.1:5302") {
+		t.Errorf("the disqualified resolver was not recorded in the denylist")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the denylist: %v", err)
+	}
+	if !strings.Contains(string(data), "127.0.0.1:5302\t") || !strings.HasSuffix(string(data), "\t"+RemovedFiltering+"\n") {
+		t.Errorf("the disqualified resolver was not written to the file: %s", data)
+	}
+}
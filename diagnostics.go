@@ -0,0 +1,113 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// The name queried with recursion desired to identify open recursive nameservers.
+const openRecursiveProbe = "."
+
+// ServerHealth classifies an authoritative nameserver of a zone.
+type ServerHealth struct {
+	Address       string
+	Reachable     bool
+	Lame          bool
+	OpenRecursive bool
+	Rcode         int
+}
+
+// Status returns a description of the classifications for the nameserver.
+func (s *ServerHealth) Status() string {
+	if !s.Reachable {
+		return "unreachable"
+	}
+
+	var status []string
+	if s.Lame {
+		status = append(status, "lame")
+	}
+	if s.OpenRecursive {
+		status = append(status, "open recursive")
+	}
+	if len(status) == 0 {
+		return "healthy"
+	}
+	return strings.Join(status, ", ")
+}
+
+// ZoneHealth is the report produced for the authoritative nameservers of a zone.
+type ZoneHealth struct {
+	Zone    string
+	Servers []*ServerHealth
+}
+
+// Healthy returns true when all the nameservers for the zone are reachable, serve the zone,
+// and do not provide recursion.
+func (z *ZoneHealth) Healthy() bool {
+	for _, s := range z.Servers {
+		if s.Status() != "healthy" {
+			return false
+		}
+	}
+	return len(z.Servers) > 0
+}
+
+// CheckZoneHealth classifies each authoritative nameserver of the provided zone as reachable,
+// lame when it does not provide authoritative data for the zone, and open recursive when it
+// answers recursive queries for names outside of the zone.
+func (r *Resolvers) CheckZoneHealth(ctx context.Context, zone string) (*ZoneHealth, error) {
+	zone = strings.ToLower(RemoveLastDot(zone))
+
+	servers, _ := r.zoneServers(ctx, zone)
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("CheckZoneHealth: failed to obtain the nameservers for %s", zone)
+	}
+
+	report := &ZoneHealth{
+		Zone:    zone,
+		Servers: make([]*ServerHealth, len(servers)),
+	}
+
+	var wg sync.WaitGroup
+	for i, ip := range servers {
+		wg.Add(1)
+		go func(i int, ip string) {
+			defer wg.Done()
+			report.Servers[i] = r.serverHealth(ctx, zone, ip)
+		}(i, ip)
+	}
+	wg.Wait()
+	return report, nil
+}
+
+func (r *Resolvers) serverHealth(ctx context.Context, zone, ip string) *ServerHealth {
+	health := &ServerHealth{Address: ip}
+
+	res := r.authResolver(ip)
+	if res == nil {
+		return health
+	}
+
+	resp, err := r.authExchange(ctx, res, authMsg(zone, dns.TypeSOA))
+	if err != nil {
+		return health
+	}
+	health.Reachable = true
+	health.Rcode = resp.Rcode
+	health.Lame = resp.Rcode != dns.RcodeSuccess || !resp.Authoritative ||
+		len(AnswersByType(ExtractAnswers(resp), dns.TypeSOA)) == 0
+
+	if resp, err := r.authExchange(ctx, res, QueryMsg(openRecursiveProbe, dns.TypeNS)); err == nil {
+		health.OpenRecursive = resp.Rcode == dns.RcodeSuccess && resp.RecursionAvailable && len(resp.Answer) > 0
+	}
+	return health
+}
@@ -0,0 +1,131 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestCheckZoneHealth(t *testing.T) {
+	// The first nameserver serves the zone and refuses recursion
+	goodMux := dns.NewServeMux()
+	goodMux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetRcode(req, dns.RcodeRefused)
+		_ = w.WriteMsg(m)
+	})
+	goodMux.HandleFunc("health.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Authoritative = true
+
+		if req.Question[0].Qtype == dns.TypeSOA {
+			m.Answer = append(m.Answer, &dns.SOA{
+				Hdr:    dns.RR_Header{Name: "health.net.", Rrtype: dns.TypeSOA, Class: dns.ClassINET},
+				Ns:     "ns1.health.net.",
+				Mbox:   "admin.health.net.",
+				Serial: 1,
+			})
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	gs, goodaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = goodMux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = gs.Shutdown() }()
+	_, authport, _ := net.SplitHostPort(goodaddr)
+
+	// The second nameserver does not serve the zone and provides recursion to anyone
+	badMux := dns.NewServeMux()
+	badMux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.RecursionAvailable = true
+
+		if req.Question[0].Name == "." && req.Question[0].Qtype == dns.TypeNS {
+			m.Answer = append(m.Answer, &dns.NS{
+				Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeNS, Class: dns.ClassINET},
+				Ns:  "a.root-servers.net.",
+			})
+		} else {
+			m.Rcode = dns.RcodeRefused
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	bs, _, _, err := RunLocalUDPServer(net.JoinHostPort("127.0.0.2", authport), func(s *dns.Server) { s.Handler = badMux })
+	if err != nil {
+		t.Skipf("unable to run test server on a second loopback address: %v", err)
+	}
+	defer func() { _ = bs.Shutdown() }()
+
+	recMux := dns.NewServeMux()
+	recMux.HandleFunc("health.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		switch name := req.Question[0].Name; {
+		case name == "health.net." && req.Question[0].Qtype == dns.TypeNS:
+			for _, ns := range []string{"ns1.health.net.", "ns2.health.net."} {
+				m.Answer = append(m.Answer, &dns.NS{
+					Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeNS, Class: dns.ClassINET},
+					Ns:  ns,
+				})
+			}
+		case name == "ns1.health.net." && req.Question[0].Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, testARecord(name, "127.0.0.1"))
+		case name == "ns2.health.net." && req.Question[0].Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, testARecord(name, "127.0.0.2"))
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	rs, recaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = recMux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = rs.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, recaddr)
+	defer r.Stop()
+	r.auth.port = authport
+
+	report, err := r.CheckZoneHealth(context.Background(), "health.net")
+	if err != nil {
+		t.Fatalf("CheckZoneHealth failed: %v", err)
+	}
+	if report.Zone != "health.net" || len(report.Servers) != 2 {
+		t.Fatalf("the report did not include both nameservers for the zone")
+	}
+	if report.Healthy() {
+		t.Errorf("the zone was reported as healthy")
+	}
+
+	expected := map[string]string{
+		"127.0.0.1": "healthy",
+		"127.0.0.2": "lame, open recursive",
+	}
+	for _, s := range report.Servers {
+		if status := s.Status(); status != expected[s.Address] {
+			t.Errorf("nameserver %s was classified as %s instead of %s", s.Address, status, expected[s.Address])
+		}
+	}
+}
+
+func TestServerHealthStatus(t *testing.T) {
+	if s := (&ServerHealth{}).Status(); s != "unreachable" {
+		t.Errorf("an unreachable nameserver was classified as %s", s)
+	}
+	if s := (&ServerHealth{Reachable: true, Lame: true}).Status(); s != "lame" {
+		t.Errorf("a lame nameserver was classified as %s", s)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import "github.com/miekg/dns"
+
+// MinUDPSize is the smallest UDP payload size that can be advertised in the EDNS0 OPT record (RFC 6891).
+const MinUDPSize uint16 = 512
+
+// SetEDNSBufferSize sets the UDP payload size advertised in the EDNS0 OPT record of the queries
+// sent through the pool, such as the 1232 bytes recommended by DNS Flag Day 2020, since some
+// servers mishandle large buffers. The size is raised to MinUDPSize and lowered to the size of
+// the buffers reading the responses, dns.DefaultMsgSize. Zero leaves the size of the messages
+// unchanged.
+func (r *Resolvers) SetEDNSBufferSize(size uint16) {
+	if size > 0 && size < MinUDPSize {
+		size = MinUDPSize
+	} else if size > dns.DefaultMsgSize {
+		size = dns.DefaultMsgSize
+	}
+	r.bufSize.Store(uint32(size))
+}
+
+// SetEDNSDisabled causes the queries sent through the pool to omit the EDNS0 OPT record,
+// including the DNSSEC OK bit requested by SetDenialProofs, for servers that mishandle it.
+func (r *Resolvers) SetEDNSDisabled(disabled bool) {
+	r.ednsOff.Store(disabled)
+}
+
+// SetUDPSize sets the UDP payload size advertised in the EDNS0 OPT record of the message, adding
+// the record returned by SetupOptions when the message does not have one. A size of zero removes
+// the OPT record, so the message is sent without EDNS0.
+func SetUDPSize(msg *dns.Msg, size uint16) {
+	if size == 0 {
+		removeEDNS(msg)
+		return
+	}
+
+	opt := msg.IsEdns0()
+	if opt == nil {
+		opt = SetupOptions()
+		msg.Extra = append(msg.Extra, opt)
+	}
+	opt.SetUDPSize(size)
+}
+
+// Applies the EDNS0 settings of the pool to the message before it is sent.
+func (r *Resolvers) applyEDNS(msg *dns.Msg) {
+	if r.ednsOff.Load() {
+		removeEDNS(msg)
+	} else if size := uint16(r.bufSize.Load()); size > 0 {
+		if opt := msg.IsEdns0(); opt != nil {
+			opt.SetUDPSize(size)
+		}
+	}
+}
+
+// Returns true when the response indicates that the server does not support the EDNS0 OPT record
+// sent with the request, and the request should be sent again without it. The downgrade is recorded
+// for the resolver, so later queries sent to the server are also sent without the OPT record.
+func (r *resolver) ednsDowngrade(req *request) bool {
+	if req.Resp.Rcode != dns.RcodeFormatError || req.Msg.IsEdns0() == nil {
+		return false
+	}
+
+	r.noEDNS.Store(true)
+	removeEDNS(req.Msg)
+	return true
+}
+
+// EDNSDisabled returns the addresses of the resolvers in the pool that are sent queries without
+// the EDNS0 OPT record, since they responded with FORMERR to a query that included it.
+func (r *Resolvers) EDNSDisabled() []string {
+	var addrs []string
+
+	for _, res := range r.allResolvers(r.getDetectionResolvers()...) {
+		if res.noEDNS.Load() {
+			addrs = append(addrs, res.address.String())
+		}
+	}
+	return addrs
+}
+
+// Removes the EDNS0 OPT record from the additional section of the provided message.
+func removeEDNS(msg *dns.Msg) {
+	var extra []dns.RR
+
+	for _, rr := range msg.Extra {
+		if rr.Header().Rrtype != dns.TypeOPT {
+			extra = append(extra, rr)
+		}
+	}
+	msg.Extra = extra
+}
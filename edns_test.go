@@ -0,0 +1,131 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestEDNSDowngrade(t *testing.T) {
+	// The server does not support EDNS0 and returns FORMERR for queries with an OPT record
+	var withOPT int32
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		if req.IsEdns0() != nil {
+			atomic.AddInt32(&withOPT, 1)
+			m.Rcode = dns.RcodeFormatError
+		} else {
+			m.Answer = append(m.Answer, testARecord(req.Question[0].Name, "192.168.1.1"))
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	for _, name := range []string{"www.caffix.net", "mail.caffix.net"} {
+		resp, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA))
+		if err != nil {
+			t.Fatalf("the query for %s failed: %v", name, err)
+		}
+		if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) == 0 {
+			t.Errorf("the query for %s was not sent again without the OPT record", name)
+		}
+	}
+
+	if n := atomic.LoadInt32(&withOPT); n != 1 {
+		t.Errorf("expected the downgrade to stick after 1 query with the OPT record, got %d", n)
+	}
+	if addrs := r.EDNSDisabled(); len(addrs) != 1 || addrs[0] != addrstr {
+		t.Errorf("the resolver was not reported as having EDNS0 disabled: %v", addrs)
+	}
+}
+
+func TestRemoveEDNS(t *testing.T) {
+	msg := QueryMsg("caffix.net", dns.TypeA)
+	msg.Extra = append(msg.Extra, testARecord("ns.caffix.net.", "192.168.1.1"))
+
+	removeEDNS(msg)
+	if msg.IsEdns0() != nil || len(msg.Extra) != 1 {
+		t.Errorf("the OPT record was not removed from the message")
+	}
+}
+
+func TestSetUDPSize(t *testing.T) {
+	msg := QueryMsg("caffix.net", dns.TypeA)
+	SetUDPSize(msg, 1232)
+	if opt := msg.IsEdns0(); opt == nil || opt.UDPSize() != 1232 {
+		t.Errorf("the UDP payload size was not set on the OPT record")
+	}
+
+	SetUDPSize(msg, 0)
+	if msg.IsEdns0() != nil {
+		t.Errorf("the OPT record was not removed")
+	}
+
+	SetUDPSize(msg, 1400)
+	if opt := msg.IsEdns0(); opt == nil || opt.UDPSize() != 1400 {
+		t.Errorf("the OPT record was not added with the UDP payload size")
+	}
+}
+
+func TestPoolEDNSOptions(t *testing.T) {
+	var size atomic.Int32
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		size.Store(0)
+		if opt := req.IsEdns0(); opt != nil {
+			size.Store(int32(opt.UDPSize()))
+		}
+		typeAHandler(w, req)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	for _, tc := range []struct {
+		size     uint16
+		disabled bool
+		expected int32
+	}{
+		{0, false, int32(dns.DefaultMsgSize)},
+		{1232, false, 1232},
+		{100, false, int32(MinUDPSize)},
+		{65535, false, int32(dns.DefaultMsgSize)},
+		{1232, true, 0},
+	} {
+		r.SetEDNSBufferSize(tc.size)
+		r.SetEDNSDisabled(tc.disabled)
+
+		if _, err := r.QueryBlocking(context.Background(), QueryMsg("caffix.net", dns.TypeA)); err != nil {
+			t.Errorf("the query failed: %v", err)
+		}
+		if got := size.Load(); got != tc.expected {
+			t.Errorf("size %d, disabled %t: the server received a UDP payload size of %d, expected %d",
+				tc.size, tc.disabled, got, tc.expected)
+		}
+	}
+}
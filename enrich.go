@@ -0,0 +1,65 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// Enrichment contains the network and location details associated with an IP address.
+type Enrichment struct {
+	ASN          int    `json:"asn,omitempty"`
+	Organization string `json:"org,omitempty"`
+	Prefix       string `json:"prefix,omitempty"`
+	Country      string `json:"country,omitempty"`
+}
+
+// Enricher is implemented by the sources, such as MaxMind or pfx2as databases, that provide
+// the details for the IP addresses found in the answers. The package does not bundle any data.
+type Enricher interface {
+	// Enrich returns the details for the IP address, or an error when none are available.
+	Enrich(ctx context.Context, ip net.IP) (*Enrichment, error)
+}
+
+// SetEnricher assigns the source of details for the IP addresses found in the answers.
+// A nil enricher disables the enrichment.
+func (r *Resolvers) SetEnricher(e Enricher) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.enricher = e
+}
+
+func (r *Resolvers) getEnricher() Enricher {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.enricher
+}
+
+// EnrichAnswers returns the details provided by the enricher for each IP address in the
+// A and AAAA records of the response, keyed by the address string. Nil is returned when
+// no enricher has been assigned.
+func (r *Resolvers) EnrichAnswers(ctx context.Context, resp *dns.Msg) map[string]*Enrichment {
+	e := r.getEnricher()
+	if e == nil || resp == nil {
+		return nil
+	}
+
+	results := make(map[string]*Enrichment)
+	for _, ip := range IPs(resp) {
+		addr := ip.String()
+		if _, found := results[addr]; found {
+			continue
+		}
+		if info, err := e.Enrich(ctx, ip); err == nil && info != nil {
+			results[addr] = info
+		}
+	}
+	return results
+}
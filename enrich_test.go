@@ -0,0 +1,56 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+type testEnricher struct {
+	calls int32
+}
+
+func (e *testEnricher) Enrich(ctx context.Context, ip net.IP) (*Enrichment, error) {
+	atomic.AddInt32(&e.calls, 1)
+
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 192 {
+		return &Enrichment{ASN: 64500 + int(ip4[1]), Country: "US"}, nil
+	}
+	return nil, errors.New("no details available")
+}
+
+func TestEnrichAnswers(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	msg := QueryMsg("www.caffix.net", dns.TypeA)
+	msg.Answer = append(msg.Answer, testARecord("www.caffix.net.", "192.0.2.1"),
+		testARecord("www.caffix.net.", "192.0.2.1"), testARecord("www.caffix.net.", "10.0.0.1"))
+
+	if r.EnrichAnswers(context.Background(), msg) != nil {
+		t.Errorf("answers were enriched without an enricher")
+	}
+
+	e := new(testEnricher)
+	r.SetEnricher(e)
+	results := r.EnrichAnswers(context.Background(), msg)
+	if len(results) != 1 || results["192.0.2.1"] == nil || results["192.0.2.1"].ASN != 64500 {
+		t.Errorf("unexpected enrichment results: %v", results)
+	}
+	if calls := atomic.LoadInt32(&e.calls); calls != 2 {
+		t.Errorf("the enricher was called %d times; expected 2", calls)
+	}
+
+	r.SetEnricher(nil)
+	if r.EnrichAnswers(context.Background(), msg) != nil {
+		t.Errorf("answers were enriched after the enricher was removed")
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// The number of events buffered for each subscriber before new events are dropped.
+const eventBufferSize int = 1000
+
+// EventType identifies the kind of Event published by the pool.
+type EventType int
+
+// The events published to the subscribers of the pool.
+const (
+	// EventResolved is published for each response with answers received from a server.
+	EventResolved EventType = iota + 1
+	// EventNXDomain is published for each NXDOMAIN response received from a server.
+	EventNXDomain
+	// EventWildcardFiltered is published when a response is found to match a DNS wildcard.
+	EventWildcardFiltered
+	// EventServerRemoved is published when a resolver is removed from the pool.
+	EventServerRemoved
+)
+
+// String implements the fmt.Stringer interface.
+func (t EventType) String() string {
+	switch t {
+	case EventResolved:
+		return "resolved"
+	case EventNXDomain:
+		return "nxdomain"
+	case EventWildcardFiltered:
+		return "wildcard-filtered"
+	case EventServerRemoved:
+		return "server-removed"
+	}
+	return "unknown"
+}
+
+// The reasons reported by the EventServerRemoved events.
+const (
+	RemovedThreshold string = "threshold violated"
+	RemovedFiltering string = "filtering answers"
+	RemovedHijacking string = "answering nonexistent names"
+	RemovedByCaller  string = "removed by the caller"
+)
+
+// Event describes something that happened within the pool. The Msg is shared with the
+// caller of the query and must not be modified.
+type Event struct {
+	Type   EventType
+	Name   string
+	Server string
+	Reason string
+	Msg    *dns.Msg
+	At     time.Time
+}
+
+type subscription struct {
+	ch    chan *Event
+	types map[EventType]struct{}
+}
+
+// eventBus delivers the events published by the pool to the subscribers.
+type eventBus struct {
+	sync.Mutex
+	subs map[*subscription]struct{}
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[*subscription]struct{})}
+}
+
+// Subscribe returns a channel receiving the events of the provided types, or all the events when
+// no types are provided, and the function that ends the subscription and closes the channel.
+// Events are dropped instead of blocking the pool when the subscriber falls behind, and the
+// channel is closed when the pool is stopped.
+func (r *Resolvers) Subscribe(types ...EventType) (<-chan *Event, func()) {
+	sub := &subscription{
+		ch:    make(chan *Event, eventBufferSize),
+		types: make(map[EventType]struct{}, len(types)),
+	}
+	for _, t := range types {
+		sub.types[t] = struct{}{}
+	}
+
+	r.events.Lock()
+	select {
+	case <-r.done:
+		close(sub.ch)
+	default:
+		r.events.subs[sub] = struct{}{}
+	}
+	r.events.Unlock()
+
+	return sub.ch, func() { r.events.unsubscribe(sub) }
+}
+
+func (b *eventBus) unsubscribe(sub *subscription) {
+	b.Lock()
+	defer b.Unlock()
+
+	if _, found := b.subs[sub]; found {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Ends all the subscriptions when the pool is stopped.
+func (b *eventBus) close() {
+	b.Lock()
+	defer b.Unlock()
+
+	for sub := range b.subs {
+		close(sub.ch)
+	}
+	b.subs = make(map[*subscription]struct{})
+}
+
+func (b *eventBus) publish(ev *Event) {
+	b.Lock()
+	defer b.Unlock()
+
+	if len(b.subs) == 0 {
+		return
+	}
+
+	ev.At = time.Now()
+	for sub := range b.subs {
+		if _, found := sub.types[ev.Type]; !found && len(sub.types) > 0 {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+		}
+	}
+}
+
+// Publishes the event for the response received from the server, when the response
+// provided answers or indicated that the name does not exist.
+func (b *eventBus) publishResponse(server string, msg *dns.Msg) {
+	if len(msg.Question) == 0 {
+		return
+	}
+
+	ev := &Event{
+		Name:   strings.ToLower(RemoveLastDot(msg.Question[0].Name)),
+		Server: server,
+		Msg:    msg,
+	}
+	switch {
+	case msg.Rcode == dns.RcodeSuccess && len(msg.Answer) > 0:
+		ev.Type = EventResolved
+	case msg.Rcode == dns.RcodeNameError:
+		ev.Type = EventNXDomain
+	default:
+		return
+	}
+	b.publish(ev)
+}
+
+// Publishes the removal of the resolver from the pool for the provided reason.
+func (b *eventBus) publishRemoval(res *resolver, reason string) {
+	b.publish(&Event{
+		Type:   EventServerRemoved,
+		Server: res.address.String(),
+		Reason: reason,
+	})
+}
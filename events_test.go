@@ -0,0 +1,156 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func nextEvent(t *testing.T, ch <-chan *Event) *Event {
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatalf("the expected event was not received")
+	}
+	return nil
+}
+
+func TestSubscribeResponses(t *testing.T) {
+	mux := dns.NewServeMux()
+	mux.HandleFunc("events.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		if req.Question[0].Name != "www.events.net." {
+			m := new(dns.Msg)
+			m.SetRcode(req, dns.RcodeNameError)
+			_ = w.WriteMsg(m)
+			return
+		}
+		typeAHandler(w, req)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	defer r.Stop()
+	_ = r.AddResolvers(10, addrstr)
+
+	ch, cancel := r.Subscribe(EventResolved, EventNXDomain)
+	for _, name := range []string{"www.events.net", "missing.events.net"} {
+		if _, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA)); err != nil {
+			t.Fatalf("the query for %s failed: %v", name, err)
+		}
+	}
+
+	for _, expected := range []struct {
+		Type EventType
+		Name string
+	}{
+		{EventResolved, "www.events.net"},
+		{EventNXDomain, "missing.events.net"},
+	} {
+		ev := nextEvent(t, ch)
+		if ev.Type != expected.Type || ev.Name != expected.Name || ev.Server != addrstr || ev.Msg == nil {
+			t.Errorf("Got: %s event %+v; Expected: %s for %s", ev.Type, ev, expected.Type, expected.Name)
+		}
+	}
+
+	// Events of types not subscribed are not received
+	_ = r.RemoveResolvers(addrstr)
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Errorf("an event was received for a type not subscribed")
+	}
+	// Ending the subscription again has no effect
+	cancel()
+}
+
+func TestSubscribeServerRemoved(t *testing.T) {
+	r := NewResolvers()
+	_ = r.AddResolvers(10, "127.0.0.1:5301")
+
+	ch, cancel := r.Subscribe()
+	defer cancel()
+
+	_ = r.RemoveResolvers("127.0.0.1:5301")
+	if ev := nextEvent(t, ch); ev.Type != EventServerRemoved || ev.Server != "127.0.0.1:5301" || ev.Reason != RemovedByCaller {
+		t.Errorf("Got: %s event %+v; Expected: %s", ev.Type, ev, EventServerRemoved)
+	}
+
+	r.Stop()
+	if _, ok := <-ch; ok {
+		t.Errorf("the channel was not closed when the pool was stopped")
+	}
+	if late, _ := r.Subscribe(); late != nil {
+		if _, ok := <-late; ok {
+			t.Errorf("the subscription made after the pool was stopped is open")
+		}
+	}
+}
+
+func TestSubscribeWildcardFiltered(t *testing.T) {
+	name := "domain.com."
+	dns.HandleFunc(name, wildcardHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	ch, cancel := r.Subscribe(EventWildcardFiltered)
+	defer cancel()
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("jeff_foley.wildcard.domain.com", dns.TypeA))
+	if err != nil {
+		t.Fatalf("the query failed: %v", err)
+	}
+	if !r.WildcardDetected(context.Background(), resp, "domain.com") {
+		t.Fatalf("the DNS wildcard was not detected")
+	}
+	if ev := nextEvent(t, ch); ev.Type != EventWildcardFiltered || ev.Name != "jeff_foley.wildcard.domain.com" {
+		t.Errorf("Got: %s event %+v; Expected: %s", ev.Type, ev, EventWildcardFiltered)
+	}
+}
+
+func TestEventBusDropsEvents(t *testing.T) {
+	b := newEventBus()
+	r := &Resolvers{done: make(chan struct{}), events: b}
+
+	ch, cancel := r.Subscribe()
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < eventBufferSize+10; i++ {
+			b.publish(&Event{Type: EventResolved})
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("publishing blocked on the subscriber that fell behind")
+	}
+	if l := len(ch); l != eventBufferSize {
+		t.Errorf("Got: %d events buffered; Expected: %d", l, eventBufferSize)
+	}
+	if EventServerRemoved.String() != "server-removed" || EventType(0).String() != "unknown" {
+		t.Errorf("the event types were not named as expected")
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Exchanger matches the exchange methods of the dns.Client type, so libraries expecting that
+// shape can be pointed at the pool. Both *dns.Client and *Resolvers implement the interface.
+type Exchanger interface {
+	Exchange(m *dns.Msg, address string) (*dns.Msg, time.Duration, error)
+	ExchangeContext(ctx context.Context, m *dns.Msg, address string) (*dns.Msg, time.Duration, error)
+}
+
+var (
+	_ Exchanger = (*dns.Client)(nil)
+	_ Exchanger = (*Resolvers)(nil)
+)
+
+// ErrNoResponse is returned by the exchange methods when the query did not receive a response.
+var ErrNoResponse = errors.New("the query did not receive a response")
+
+// Exchange sends the message through the pool and returns the response along with the time it
+// took to be received. The address is ignored, since the pool selects the resolver.
+func (r *Resolvers) Exchange(m *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	return r.ExchangeContext(context.Background(), m, address)
+}
+
+// ExchangeContext performs the same operation as Exchange while honoring the provided context.
+func (r *Resolvers) ExchangeContext(ctx context.Context, m *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	start := time.Now()
+	resp, err := r.exchange(ctx, m)
+	return resp, time.Since(start), err
+}
+
+// Sends the message through the pool and returns a copy of the response suitable for the wire,
+// with the message ID of the query and the ResponseSource removed.
+func (r *Resolvers) exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	if err := ValidateMsg(m); err != nil {
+		return nil, err
+	}
+
+	resp, err := r.QueryBlocking(ctx, m.Copy())
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode == RcodeNoResponse {
+		return nil, ErrNoResponse
+	}
+
+	resp = resp.Copy()
+	resp.Id = m.Id
+	return resp, nil
+}
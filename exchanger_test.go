@@ -0,0 +1,56 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestExchanger(t *testing.T) {
+	mux := dns.NewServeMux()
+	mux.HandleFunc("xchg.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		if req.Question[0].Name == "silent.xchg.net." {
+			return
+		}
+		typeAHandler(w, req)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	r.SetTimeout(100 * time.Millisecond)
+	defer r.Stop()
+
+	// The pool and the dns.Client are interchangeable behind the interface
+	for _, x := range []Exchanger{r, new(dns.Client)} {
+		msg := QueryMsg("www.xchg.net", dns.TypeA)
+		msg.Id = 1234
+
+		resp, rtt, err := x.Exchange(msg, addrstr)
+		if err != nil || resp.Id != 1234 || len(resp.Answer) != 1 || rtt <= 0 {
+			t.Errorf("%T: the exchange failed: %v %v", x, resp, err)
+			continue
+		}
+		if SourceOf(resp) != nil {
+			t.Errorf("%T: the response source was not removed", x)
+		}
+	}
+
+	if _, _, err := r.ExchangeContext(context.Background(), QueryMsg("silent.xchg.net", dns.TypeA), ""); err != ErrNoResponse {
+		t.Errorf("the query without a response was not reported: %v", err)
+	}
+	if _, _, err := r.Exchange(new(dns.Msg), ""); err == nil {
+		t.Errorf("a message without a question was accepted")
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import "time"
+
+// ExpiryHook is called with the details of each request that expired without a response, or
+// was removed when its resolver was stopped. The server is the IP:port address of the resolver.
+type ExpiryHook func(name string, qtype uint16, server string, sent time.Time)
+
+// SetExpiryHook assigns the hook called for each request removed without a response, helping
+// to diagnose which servers or subtrees are timing out. A nil hook disables the calls.
+func (r *Resolvers) SetExpiryHook(hook ExpiryHook) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.expiry = hook
+}
+
+func (r *Resolvers) expired(res *resolver, req *request) {
+	r.Lock()
+	hook := r.expiry
+	r.Unlock()
+
+	if hook != nil && req.Msg != nil && len(req.Msg.Question) > 0 {
+		q := req.Msg.Question[0]
+		hook(RemoveLastDot(q.Name), q.Qtype, res.address.String(), req.Timestamp)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestExpiryHook(t *testing.T) {
+	// The server never responds, so the request expires
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to create the UDP listener: %v", err)
+	}
+	defer conn.Close()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, conn.LocalAddr().String())
+	defer r.Stop()
+	r.SetTimeout(100 * time.Millisecond)
+
+	type expiry struct {
+		name   string
+		qtype  uint16
+		server string
+		sent   time.Time
+	}
+	ch := make(chan *expiry, 1)
+	r.SetExpiryHook(func(name string, qtype uint16, server string, sent time.Time) {
+		ch <- &expiry{name: name, qtype: qtype, server: server, sent: sent}
+	})
+
+	start := time.Now()
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("expired.caffix.net", dns.TypeAAAA))
+	if err != nil || resp.Rcode != RcodeNoResponse {
+		t.Fatalf("the query did not expire as expected")
+	}
+
+	select {
+	case e := <-ch:
+		if e.name != "expired.caffix.net" || e.qtype != dns.TypeAAAA ||
+			e.server != conn.LocalAddr().String() || e.sent.Before(start) {
+			t.Errorf("the hook was called with the wrong details: %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("the hook was not called for the expired request")
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// fallbackTier is the second tier of resolvers that receives the requests not answered
+// by the primary tier within the SLA.
+type fallbackTier struct {
+	sync.Mutex
+	pool  *Resolvers
+	sla   time.Duration
+	count int
+}
+
+// AddFallbackResolvers configures a fallback tier of resolvers for the pool. Requests that are
+// not answered by the primary resolvers within the sla, or that receive no response, are sent
+// again using the fallback resolvers. The primary tier provides speed and the fallback tier completeness.
+func (r *Resolvers) AddFallbackResolvers(qps int, sla time.Duration, addrs ...string) error {
+	if sla <= 0 {
+		return errors.New("AddFallbackResolvers: failed to provide an SLA greater than zero")
+	}
+
+	r.Lock()
+	f := r.fallback
+	timeout := r.timeout
+	r.Unlock()
+
+	if f == nil {
+		f = &fallbackTier{pool: NewResolvers()}
+		f.pool.SetTimeout(timeout)
+		if bootstrap := r.bootstrapServer(); bootstrap != "" {
+			f.pool.SetBootstrapServer(bootstrap)
+		}
+	}
+	if err := f.pool.AddResolvers(qps, addrs...); err != nil {
+		if r.fallback == nil {
+			f.pool.Stop()
+		}
+		return err
+	}
+
+	f.Lock()
+	f.sla = sla
+	f.Unlock()
+
+	r.Lock()
+	r.fallback = f
+	r.Unlock()
+	return nil
+}
+
+// FallbackQueries returns the number of requests that were sent to the fallback tier.
+func (r *Resolvers) FallbackQueries() int {
+	if f := r.fallbackTier(); f != nil {
+		f.Lock()
+		defer f.Unlock()
+
+		return f.count
+	}
+	return 0
+}
+
+func (r *Resolvers) fallbackTier() *fallbackTier {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.fallback
+}
+
+func (f *fallbackTier) query(ctx context.Context, r *Resolvers, msg *dns.Msg, ch chan *dns.Msg) {
+	f.Lock()
+	sla := f.sla
+	f.Unlock()
+
+	primary := make(chan *dns.Msg, 1)
+	r.enqueue(ctx, msg.Copy(), primary)
+
+	t := time.NewTimer(sla)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		msg.Rcode = RcodeNoResponse
+		ch <- msg
+		return
+	case resp := <-primary:
+		if resp != nil && resp.Rcode != RcodeNoResponse {
+			ch <- resp
+			return
+		}
+	case <-t.C:
+	}
+
+	f.Lock()
+	f.count++
+	f.Unlock()
+	f.pool.Query(ctx, msg, ch)
+}
@@ -0,0 +1,106 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestFallbackResolvers(t *testing.T) {
+	// The primary resolver is slow to answer queries for names within slow.net
+	primaryMux := dns.NewServeMux()
+	primaryMux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		if dns.IsSubDomain("slow.net.", req.Question[0].Name) {
+			time.Sleep(300 * time.Millisecond)
+		}
+
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Answer = append(m.Answer, testARecord(req.Question[0].Name, "10.0.0.1"))
+		_ = w.WriteMsg(m)
+	})
+
+	ps, primaryaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = primaryMux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = ps.Shutdown() }()
+
+	fallbackMux := dns.NewServeMux()
+	fallbackMux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Answer = append(m.Answer, testARecord(req.Question[0].Name, "192.168.1.1"))
+		_ = w.WriteMsg(m)
+	})
+
+	fs, fallbackaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = fallbackMux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = fs.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, primaryaddr)
+	defer r.Stop()
+
+	if err := r.AddFallbackResolvers(100, 0, fallbackaddr); err == nil {
+		t.Errorf("the fallback resolvers were added without an SLA")
+	}
+	if err := r.AddFallbackResolvers(100, 100*time.Millisecond, fallbackaddr); err != nil {
+		t.Fatalf("failed to add the fallback resolvers: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		want string
+	}{
+		{name: "www.fast.net", want: "10.0.0.1"},
+		{name: "www.slow.net", want: "192.168.1.1"},
+	}
+
+	for _, c := range cases {
+		resp, err := r.QueryBlocking(context.Background(), QueryMsg(c.name, dns.TypeA))
+		if err != nil {
+			t.Errorf("the query for %s failed: %v", c.name, err)
+			continue
+		}
+		if ans := ExtractAnswers(resp); len(ans) != 1 || ans[0].Data != c.want {
+			t.Errorf("%s was not answered by the expected tier", c.name)
+		}
+	}
+
+	if n := r.FallbackQueries(); n != 1 {
+		t.Errorf("expected 1 request sent to the fallback tier, got %d", n)
+	}
+}
+
+func TestFallbackBootstrap(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	// The fallback tier is not given a bootstrap server when the pool does not have one
+	if err := r.AddFallbackResolvers(10, time.Second, "192.0.2.1"); err != nil {
+		t.Fatalf("failed to add the fallback resolvers: %v", err)
+	}
+	if bootstrap := r.fallbackTier().pool.bootstrapServer(); bootstrap != "" {
+		t.Errorf("the fallback tier was given the bootstrap server %s", bootstrap)
+	}
+
+	p := NewResolvers()
+	defer p.Stop()
+
+	p.SetBootstrapServer("192.0.2.53")
+	if err := p.AddFallbackResolvers(10, time.Second, "192.0.2.1"); err != nil {
+		t.Fatalf("failed to add the fallback resolvers: %v", err)
+	}
+	if bootstrap := p.fallbackTier().pool.bootstrapServer(); bootstrap != "192.0.2.53:53" {
+		t.Errorf("Got: %s; Expected: 192.0.2.53:53", bootstrap)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// The thresholds used by CheckFastFlux to label a name as likely fast-flux.
+const (
+	fluxMinDistinctIPs      int    = 5
+	fluxMinDistinctNetworks int    = 3
+	fluxMaxTTL              uint32 = 300
+	fluxMinDistinctASNs     int    = 2
+)
+
+// FastFluxReport contains the heuristics computed from repeated resolutions of a name.
+type FastFluxReport struct {
+	Name             string
+	Samples          int
+	DistinctIPs      int
+	DistinctNetworks int
+	DistinctASNs     int
+	MinTTL           uint32
+	AvgTTL           uint32
+	Likely           bool
+}
+
+// CheckFastFlux resolves the A and AAAA records of the name the provided number of times, spread
+// evenly across the window, and computes the number of distinct addresses, the number of distinct
+// networks (/16 for IPv4 and /32 for IPv6) and the TTLs observed. The name is labeled as likely
+// fast-flux when many addresses across diverse networks are served with short TTLs. When an
+// enricher has been assigned, the number of distinct autonomous systems is also considered.
+func (r *Resolvers) CheckFastFlux(ctx context.Context, name string, window time.Duration, samples int) (*FastFluxReport, error) {
+	if samples <= 0 {
+		return nil, errors.New("CheckFastFlux: the number of samples must be greater than zero")
+	}
+
+	report := &FastFluxReport{Name: strings.ToLower(RemoveLastDot(name))}
+	ips := make(map[string]struct{})
+	networks := make(map[string]struct{})
+	asns := make(map[int]struct{})
+	enricher := r.getEnricher()
+
+	var total, count uint64
+	var interval time.Duration
+	if samples > 1 {
+		interval = window / time.Duration(samples-1)
+	}
+	for i := 0; i < samples; i++ {
+		if i > 0 {
+			t := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return report, errors.New("CheckFastFlux: the context expired")
+			case <-t.C:
+			}
+		}
+
+		var sampled bool
+		observe := func(ip net.IP, ttl uint32) {
+			sampled = true
+			if count == 0 || ttl < report.MinTTL {
+				report.MinTTL = ttl
+			}
+			total += uint64(ttl)
+			count++
+
+			if _, found := ips[ip.String()]; found {
+				return
+			}
+			ips[ip.String()] = struct{}{}
+			networks[fluxNetwork(ip)] = struct{}{}
+			if enricher != nil {
+				if info, err := enricher.Enrich(ctx, ip); err == nil && info != nil && info.ASN != 0 {
+					asns[info.ASN] = struct{}{}
+				}
+			}
+		}
+
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			resp, err := r.QueryBlocking(withoutCache(ctx), QueryMsg(report.Name, qtype))
+			if err != nil || resp.Rcode != dns.RcodeSuccess {
+				continue
+			}
+
+			for _, a := range Answers[*dns.A](resp) {
+				observe(a.A, a.Hdr.Ttl)
+			}
+			for _, aaaa := range Answers[*dns.AAAA](resp) {
+				observe(aaaa.AAAA, aaaa.Hdr.Ttl)
+			}
+		}
+		if sampled {
+			report.Samples++
+		}
+	}
+
+	if report.Samples == 0 {
+		return report, errors.New("CheckFastFlux: no addresses were obtained for the name")
+	}
+	report.AvgTTL = uint32(total / count)
+	report.DistinctIPs = len(ips)
+	report.DistinctNetworks = len(networks)
+	report.DistinctASNs = len(asns)
+	report.Likely = report.DistinctIPs >= fluxMinDistinctIPs &&
+		report.DistinctNetworks >= fluxMinDistinctNetworks && report.MinTTL <= fluxMaxTTL
+	if enricher != nil && report.DistinctASNs < fluxMinDistinctASNs {
+		report.Likely = false
+	}
+	return report, nil
+}
+
+// Returns the network containing the address that is used to measure the diversity of the answers.
+func fluxNetwork(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(16, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(32, 128)).String()
+}
@@ -0,0 +1,100 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestCheckFastFlux(t *testing.T) {
+	var counter int32
+	mux := dns.NewServeMux()
+	mux.HandleFunc("flux.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		if req.Question[0].Qtype == dns.TypeA {
+			name := req.Question[0].Name
+			if name == "flux.net." {
+				// Each response returns two addresses from a different /16 network
+				n := atomic.AddInt32(&counter, 1)
+				for i := 1; i <= 2; i++ {
+					rr := testARecord(name, fmt.Sprintf("198.%d.0.%d", n, i))
+					rr.Hdr.Ttl = 60
+					m.Answer = append(m.Answer, rr)
+				}
+			} else {
+				rr := testARecord(name, "192.0.2.1")
+				rr.Hdr.Ttl = 3600
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	report, err := r.CheckFastFlux(context.Background(), "flux.net", 200*time.Millisecond, 4)
+	if err != nil {
+		t.Fatalf("CheckFastFlux failed: %v", err)
+	}
+	if report.Samples != 4 || report.DistinctIPs != 8 || report.DistinctNetworks != 4 {
+		t.Errorf("unexpected heuristics: %+v", report)
+	}
+	if report.MinTTL != 60 || report.AvgTTL != 60 || !report.Likely {
+		t.Errorf("the rotating name was not labeled as likely fast-flux: %+v", report)
+	}
+
+	report, err = r.CheckFastFlux(context.Background(), "www.flux.net", 0, 3)
+	if err != nil {
+		t.Fatalf("CheckFastFlux failed: %v", err)
+	}
+	if report.DistinctIPs != 1 || report.DistinctNetworks != 1 || report.MinTTL != 3600 || report.Likely {
+		t.Errorf("the stable name was labeled as likely fast-flux: %+v", report)
+	}
+
+	// The addresses are all announced by a single autonomous system
+	r.SetEnricher(new(testEnricher))
+	if report, err := r.CheckFastFlux(context.Background(), "www.flux.net", 0, 1); err != nil || report.DistinctASNs != 1 || report.Likely {
+		t.Errorf("the ASN diversity was not considered: %+v", report)
+	}
+	r.SetEnricher(nil)
+
+	if _, err := r.CheckFastFlux(context.Background(), "flux.net", time.Second, 0); err == nil {
+		t.Errorf("CheckFastFlux accepted zero samples")
+	}
+}
+
+func TestFluxNetwork(t *testing.T) {
+	cases := []struct {
+		addr     string
+		expected string
+	}{
+		{addr: "192.0.2.1", expected: "192.0.0.0"},
+		{addr: "192.0.200.1", expected: "192.0.0.0"},
+		{addr: "2001:db8:1:2::1", expected: "2001:db8::"},
+	}
+
+	for _, c := range cases {
+		if got := fluxNetwork(net.ParseIP(c.addr)); got != c.expected {
+			t.Errorf("Got: %s; Expected: %s", got, c.expected)
+		}
+	}
+}
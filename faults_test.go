@@ -0,0 +1,230 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// faultConfig describes the faults injected into the packets written by a test server. The
+// Loss, Duplicate and Corrupt fields are the probabilities of each fault for a packet.
+type faultConfig struct {
+	Loss      float64
+	Duplicate float64
+	Corrupt   float64
+	Latency   time.Duration
+	Jitter    time.Duration
+	Seed      int64
+}
+
+// faultyPacketConn wraps the net.PacketConn of a test server and applies the configured faults
+// to the packets written, allowing the resilience of the pool and retry logic to be tested.
+type faultyPacketConn struct {
+	net.PacketConn
+	sync.Mutex
+	cfg        faultConfig
+	rng        *rand.Rand
+	wg         sync.WaitGroup
+	dropped    int32
+	duplicated int32
+	corrupted  int32
+}
+
+func newFaultyPacketConn(pc net.PacketConn, cfg faultConfig) *faultyPacketConn {
+	return &faultyPacketConn{
+		PacketConn: pc,
+		cfg:        cfg,
+		rng:        rand.New(rand.NewSource(cfg.Seed)),
+	}
+}
+
+func (c *faultyPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.Lock()
+	drop := c.rng.Float64() < c.cfg.Loss
+	copies := 1
+	if c.rng.Float64() < c.cfg.Duplicate {
+		copies++
+	}
+	corrupt := c.rng.Float64() < c.cfg.Corrupt
+	pos, mask := c.rng.Intn(len(b)), byte(1+c.rng.Intn(255))
+	delay := c.cfg.Latency
+	if c.cfg.Jitter > 0 {
+		delay += time.Duration(c.rng.Int63n(int64(c.cfg.Jitter)))
+	}
+	c.Unlock()
+
+	if drop {
+		atomic.AddInt32(&c.dropped, 1)
+		return len(b), nil
+	}
+	if copies > 1 {
+		atomic.AddInt32(&c.duplicated, 1)
+	}
+
+	packet := append([]byte(nil), b...)
+	if corrupt {
+		atomic.AddInt32(&c.corrupted, 1)
+		packet[pos] ^= mask
+	}
+
+	write := func() {
+		for i := 0; i < copies; i++ {
+			_, _ = c.PacketConn.WriteTo(packet, addr)
+		}
+	}
+	if delay <= 0 {
+		write()
+		return len(b), nil
+	}
+
+	c.wg.Add(1)
+	time.AfterFunc(delay, func() {
+		defer c.wg.Done()
+		write()
+	})
+	return len(b), nil
+}
+
+func (c *faultyPacketConn) Close() error {
+	c.wg.Wait()
+	return c.PacketConn.Close()
+}
+
+func RunFaultyUDPServer(laddr string, cfg faultConfig, opts ...func(*dns.Server)) (*dns.Server, string, *faultyPacketConn, error) {
+	pc, err := net.ListenPacket("udp", laddr)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	fpc := newFaultyPacketConn(pc, cfg)
+	s, addr, _, err := RunLocalServer(fpc, nil, opts...)
+	return s, addr, fpc, err
+}
+
+func TestFaultyPacketConn(t *testing.T) {
+	recv, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to create the receiving socket: %v", err)
+	}
+	defer recv.Close()
+
+	packet := []byte{0x01, 0x02, 0x03, 0x04}
+	read := func() ([]byte, bool) {
+		buf := make([]byte, 16)
+		_ = recv.SetReadDeadline(time.Now().Add(250 * time.Millisecond))
+		n, _, err := recv.ReadFrom(buf)
+		return buf[:n], err == nil
+	}
+
+	cases := []struct {
+		label string
+		cfg   faultConfig
+		check func(c *faultyPacketConn)
+	}{
+		{"loss", faultConfig{Loss: 1}, func(c *faultyPacketConn) {
+			if _, ok := read(); ok || atomic.LoadInt32(&c.dropped) != 1 {
+				t.Errorf("the packet was not dropped")
+			}
+		}},
+		{"duplicate", faultConfig{Duplicate: 1}, func(c *faultyPacketConn) {
+			first, ok1 := read()
+			second, ok2 := read()
+			if !ok1 || !ok2 || string(first) != string(second) || atomic.LoadInt32(&c.duplicated) != 1 {
+				t.Errorf("the packet was not duplicated")
+			}
+		}},
+		{"corrupt", faultConfig{Corrupt: 1}, func(c *faultyPacketConn) {
+			if b, ok := read(); !ok || string(b) == string(packet) || atomic.LoadInt32(&c.corrupted) != 1 {
+				t.Errorf("the packet was not corrupted")
+			}
+		}},
+		{"latency", faultConfig{Latency: 100 * time.Millisecond, Jitter: 50 * time.Millisecond}, func(c *faultyPacketConn) {
+			if _, ok := read(); !ok {
+				t.Errorf("the delayed packet was not received")
+			}
+		}},
+	}
+
+	for _, c := range cases {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("unable to create the sending socket: %v", err)
+		}
+
+		fpc := newFaultyPacketConn(pc, c.cfg)
+		start := time.Now()
+		if n, err := fpc.WriteTo(packet, recv.LocalAddr()); err != nil || n != len(packet) {
+			t.Errorf("%s: the write was not reported as successful", c.label)
+		}
+		c.check(fpc)
+		if elapsed := time.Since(start); c.cfg.Latency > 0 && elapsed < c.cfg.Latency {
+			t.Errorf("%s: the packet was received after %s", c.label, elapsed)
+		}
+		_ = fpc.Close()
+	}
+}
+
+func TestQueryBlockingWithFaults(t *testing.T) {
+	mux := dns.NewServeMux()
+	mux.HandleFunc("faults.net.", typeAHandler)
+
+	cases := []struct {
+		label string
+		cfg   faultConfig
+		min   float64
+	}{
+		{"loss", faultConfig{Loss: 0.4, Seed: 1}, 1},
+		{"jitter and duplicates", faultConfig{Duplicate: 0.5, Jitter: 150 * time.Millisecond, Seed: 2}, 1},
+		{"all faults", faultConfig{Loss: 0.2, Duplicate: 0.2, Corrupt: 0.1, Latency: 10 * time.Millisecond, Jitter: 50 * time.Millisecond, Seed: 3}, 0.8},
+	}
+
+	for _, c := range cases {
+		s, addrstr, fpc, err := RunFaultyUDPServer("127.0.0.1:0", c.cfg, func(s *dns.Server) { s.Handler = mux })
+		if err != nil {
+			t.Fatalf("unable to run test server: %v", err)
+		}
+
+		r := NewResolvers()
+		_ = r.AddResolvers(1000, addrstr)
+		r.SetTimeout(250 * time.Millisecond)
+		r.SetRetries(10)
+
+		num := 50
+		var success int32
+		var wg sync.WaitGroup
+		for i := 0; i < num; i++ {
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+
+				resp, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA))
+				if err == nil && resp.Rcode == dns.RcodeSuccess {
+					if ans := ExtractAnswers(resp); len(ans) > 0 && ans[0].Data == "192.168.1.1" {
+						atomic.AddInt32(&success, 1)
+					}
+				}
+			}(fmt.Sprintf("host%d.faults.net", i))
+		}
+		wg.Wait()
+
+		if rate := float64(success) / float64(num); rate < c.min {
+			t.Errorf("%s: only %.2f of the queries were successful", c.label, rate)
+		}
+		if c.cfg.Loss > 0 && atomic.LoadInt32(&fpc.dropped) == 0 {
+			t.Errorf("%s: no responses were dropped", c.label)
+		}
+		r.Stop()
+		_ = s.Shutdown()
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultCanaries are legitimate names from categories commonly blocked by DNS firewalls and
+// response policy zones, which are expected to resolve through resolvers that do not filter.
+var DefaultCanaries = []string{
+	"www.torproject.org",
+	"www.pokerstars.com",
+	"www.bittorrent.com",
+	"www.reddit.com",
+	"www.nordvpn.com",
+}
+
+// The number of resolvers probed concurrently by DetectFiltering.
+const numOfFilteringProbes int = 50
+
+// FilteringReport lists the canary names blocked by a resolver in the pool.
+type FilteringReport struct {
+	Address string
+	Blocked []string
+}
+
+// DetectFiltering queries each resolver in the pool for the canary names, or DefaultCanaries
+// when none are provided, and reports the resolvers returning NXDOMAIN, REFUSED or sinkhole
+// addresses for any of them. When exclude is true, the filtering resolvers are removed from
+// the pool. Otherwise, their NXDOMAIN and sinkhole answers are annotated with the Filtered
+// extended DNS error (RFC 8914), which can be checked using PotentiallyFiltered.
+func (r *Resolvers) DetectFiltering(ctx context.Context, exclude bool, canaries ...string) []*FilteringReport {
+	if len(canaries) == 0 {
+		canaries = DefaultCanaries
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var reports []*FilteringReport
+	sem := make(chan struct{}, numOfFilteringProbes)
+	for _, res := range r.pool.AllResolvers() {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(res *resolver) {
+			defer func() { <-sem; wg.Done() }()
+
+			var blocked []string
+			for _, name := range canaries {
+				resp, err := r.authExchange(ctx, res, QueryMsg(name, dns.TypeA))
+				if err == nil && (resp.Rcode == dns.RcodeRefused || sinkholed(resp)) {
+					blocked = append(blocked, name)
+				}
+			}
+			if len(blocked) == 0 {
+				return
+			}
+
+			if exclude {
+				r.disqualify(res, RemovedFiltering)
+			} else {
+				res.filters.Store(true)
+			}
+
+			mu.Lock()
+			reports = append(reports, &FilteringReport{Address: res.address.String(), Blocked: blocked})
+			mu.Unlock()
+		}(res)
+	}
+	wg.Wait()
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Address < reports[j].Address })
+	return reports
+}
+
+// PotentiallyFiltered returns true when the response carries an extended DNS error indicating
+// that the answer was blocked, censored or filtered by the resolver.
+func PotentiallyFiltered(msg *dns.Msg) bool {
+	if msg == nil {
+		return false
+	}
+
+	if opt := msg.IsEdns0(); opt != nil {
+		for _, o := range opt.Option {
+			if ede, ok := o.(*dns.EDNS0_EDE); ok {
+				switch ede.InfoCode {
+				case dns.ExtendedErrorCodeBlocked, dns.ExtendedErrorCodeCensored, dns.ExtendedErrorCodeFiltered:
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Adds the Filtered extended DNS error to the NXDOMAIN and sinkhole responses from resolvers
+// that were detected filtering the canary names.
+func (r *resolver) annotateFiltered(resp *dns.Msg) {
+	if !r.filters.Load() || !sinkholed(resp) || PotentiallyFiltered(resp) {
+		return
+	}
+
+	opt := resp.IsEdns0()
+	if opt == nil {
+		resp.SetEdns0(dns.DefaultMsgSize, false)
+		opt = resp.IsEdns0()
+	}
+	opt.Option = append(opt.Option, &dns.EDNS0_EDE{
+		InfoCode:  dns.ExtendedErrorCodeFiltered,
+		ExtraText: "the resolver was detected filtering names",
+	})
+}
+
+// Returns true when the response is NXDOMAIN or only contains unspecified or loopback addresses.
+func sinkholed(resp *dns.Msg) bool {
+	if resp.Rcode == dns.RcodeNameError {
+		return true
+	}
+
+	ips := IPs(resp)
+	for _, ip := range ips {
+		if !ip.IsUnspecified() && !ip.IsLoopback() {
+			return false
+		}
+	}
+	return len(ips) > 0
+}
@@ -0,0 +1,105 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestDetectFiltering(t *testing.T) {
+	canaries := []string{"good.canary.net", "gambling.canary.net", "vpn.canary.net"}
+
+	clean, cleanaddr := runCanaryServer(t, "")
+	defer func() { _ = clean.Shutdown() }()
+	nx, nxaddr := runCanaryServer(t, "nxdomain")
+	defer func() { _ = nx.Shutdown() }()
+	sink, sinkaddr := runCanaryServer(t, "sinkhole")
+	defer func() { _ = sink.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, cleanaddr, nxaddr, sinkaddr)
+	defer r.Stop()
+
+	reports := r.DetectFiltering(context.Background(), false, canaries...)
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 filtering resolvers, got %d", len(reports))
+	}
+	for _, rep := range reports {
+		if rep.Address == cleanaddr || len(rep.Blocked) != 2 || rep.Blocked[0] != "gambling.canary.net" {
+			t.Errorf("unexpected filtering report: %+v", rep)
+		}
+	}
+	if r.Len() != 3 {
+		t.Errorf("the filtering resolvers were removed from the pool")
+	}
+
+	// The filtered answers are annotated
+	var annotated int
+	for i := 0; i < 30; i++ {
+		resp, err := r.QueryBlocking(context.Background(), QueryMsg("gambling.canary.net", dns.TypeA))
+		if err != nil {
+			continue
+		}
+		if PotentiallyFiltered(resp) {
+			annotated++
+		} else if len(IPs(resp)) != 1 || IPs(resp)[0].String() != "192.0.2.1" {
+			t.Errorf("a filtered answer was not annotated: %v", resp)
+		}
+	}
+	if annotated == 0 {
+		t.Errorf("none of the filtered answers were annotated")
+	}
+
+	if reports := r.DetectFiltering(context.Background(), true, canaries...); len(reports) != 2 || r.Len() != 1 {
+		t.Errorf("the filtering resolvers were not excluded from the pool")
+	}
+}
+
+func TestPotentiallyFiltered(t *testing.T) {
+	msg := QueryMsg("caffix.net", dns.TypeA)
+	if PotentiallyFiltered(msg) || PotentiallyFiltered(nil) {
+		t.Errorf("a message without an extended DNS error was reported as filtered")
+	}
+
+	msg.IsEdns0().Option = append(msg.IsEdns0().Option, &dns.EDNS0_EDE{InfoCode: dns.ExtendedErrorCodeStaleAnswer})
+	if PotentiallyFiltered(msg) {
+		t.Errorf("a stale answer was reported as filtered")
+	}
+
+	msg.IsEdns0().Option = append(msg.IsEdns0().Option, &dns.EDNS0_EDE{InfoCode: dns.ExtendedErrorCodeBlocked})
+	if !PotentiallyFiltered(msg) {
+		t.Errorf("a blocked answer was not reported as filtered")
+	}
+}
+
+// Runs a nameserver that blocks all canaries except good.canary.net using the provided method.
+func runCanaryServer(t *testing.T, method string) (*dns.Server, string) {
+	mux := dns.NewServeMux()
+	mux.HandleFunc("canary.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		name := req.Question[0].Name
+		switch {
+		case req.Question[0].Qtype != dns.TypeA:
+		case name == "good.canary.net." || method == "":
+			m.Answer = append(m.Answer, testARecord(name, "192.0.2.1"))
+		case method == "nxdomain":
+			m.Rcode = dns.RcodeNameError
+		default:
+			m.Answer = append(m.Answer, testARecord(name, "0.0.0.0"))
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	return s, addr
+}
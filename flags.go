@@ -0,0 +1,54 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+
+	"github.com/miekg/dns"
+)
+
+// HeaderFlags selects the header bits set on the queries sent through the pool.
+type HeaderFlags struct {
+	RecursionDesired  bool
+	CheckingDisabled  bool
+	AuthenticatedData bool
+}
+
+type headerFlagsKey struct{}
+
+// SetHeaderFlags causes the provided header bits to be set on every query sent through the pool,
+// replacing the bits set on the messages. Queries for names within stub zones never set RD.
+func (r *Resolvers) SetHeaderFlags(flags HeaderFlags) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.flags = &flags
+}
+
+// WithHeaderFlags returns a context that causes the provided header bits to be set on the queries
+// sent using the context, taking precedence over the header flags of the pool.
+func WithHeaderFlags(ctx context.Context, flags HeaderFlags) context.Context {
+	return context.WithValue(ctx, headerFlagsKey{}, flags)
+}
+
+// Returns the header flags for queries sent using the context, or nil when the bits of the
+// messages should not be changed.
+func (r *Resolvers) headerFlags(ctx context.Context) *HeaderFlags {
+	if flags, ok := ctx.Value(headerFlagsKey{}).(HeaderFlags); ok {
+		return &flags
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	return r.flags
+}
+
+func (f *HeaderFlags) apply(msg *dns.Msg) {
+	msg.RecursionDesired = f.RecursionDesired
+	msg.CheckingDisabled = f.CheckingDisabled
+	msg.AuthenticatedData = f.AuthenticatedData
+}
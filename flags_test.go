@@ -0,0 +1,79 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestHeaderFlags(t *testing.T) {
+	received := make(chan dns.MsgHdr, 1)
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		received <- req.MsgHdr
+
+		m := new(dns.Msg)
+		m.SetReply(req)
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	cases := []struct {
+		label string
+		ctx   context.Context
+		pool  *HeaderFlags
+		want  HeaderFlags
+	}{
+		{
+			label: "Message bits",
+			ctx:   context.Background(),
+			want:  HeaderFlags{RecursionDesired: true},
+		}, {
+			label: "Pool flags",
+			ctx:   context.Background(),
+			pool:  &HeaderFlags{RecursionDesired: true, CheckingDisabled: true},
+			want:  HeaderFlags{RecursionDesired: true, CheckingDisabled: true},
+		}, {
+			label: "Query flags",
+			ctx:   WithHeaderFlags(context.Background(), HeaderFlags{AuthenticatedData: true}),
+			pool:  &HeaderFlags{RecursionDesired: true, CheckingDisabled: true},
+			want:  HeaderFlags{AuthenticatedData: true},
+		},
+	}
+
+	for _, c := range cases {
+		f := func(t *testing.T) {
+			if c.pool != nil {
+				r.SetHeaderFlags(*c.pool)
+			}
+			if _, err := r.QueryBlocking(c.ctx, QueryMsg("caffix.net", dns.TypeA)); err != nil {
+				t.Fatalf("the query failed: %v", err)
+			}
+
+			hdr := <-received
+			got := HeaderFlags{
+				RecursionDesired:  hdr.RecursionDesired,
+				CheckingDisabled:  hdr.CheckingDisabled,
+				AuthenticatedData: hdr.AuthenticatedData,
+			}
+			if got != c.want {
+				t.Errorf("Got: %+v; Expected: %+v", got, c.want)
+			}
+		}
+		t.Run(c.label, f)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// GlueMismatch is a finding reporting that the glue addresses provided by the parent zone for a
+// nameserver differ from the addresses published by the child zone, an indicator of lame delegation.
+type GlueMismatch struct {
+	Zone       string
+	Parent     string
+	Nameserver string
+	Qtype      uint16
+	Glue       []string
+	Child      []string
+}
+
+// String returns a human readable description of the finding.
+func (g *GlueMismatch) String() string {
+	return fmt.Sprintf("%s: %s %s glue from %s %v does not match the child zone %v", g.Zone,
+		g.Nameserver, dns.TypeToString[g.Qtype], g.Parent, g.Glue, g.Child)
+}
+
+// CheckGlue compares the glue addresses returned by the nameservers of the parent zone in the
+// delegation of the provided zone with the A/AAAA records the child zone publishes for the same
+// nameservers. The parent zone is the closest enclosing name with NS records at its apex, since
+// the names between the zone and its parent are not required to be zones. A finding is returned
+// for each nameserver and record type that does not match.
+func (r *Resolvers) CheckGlue(ctx context.Context, zone string) ([]*GlueMismatch, error) {
+	zone = strings.ToLower(RemoveLastDot(zone))
+
+	parent, servers, err := r.parentZone(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	glue, err := r.delegationGlue(ctx, zone, servers)
+	if err != nil {
+		return nil, err
+	}
+
+	var children []*resolver
+	for _, addrs := range glue {
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			for _, ip := range addrs[qtype] {
+				if res := r.authResolver(ip); res != nil {
+					children = append(children, res)
+				}
+			}
+		}
+	}
+	if len(children) == 0 {
+		return nil, fmt.Errorf("CheckGlue: %s was delegated without glue", zone)
+	}
+
+	var nameservers []string
+	for ns := range glue {
+		nameservers = append(nameservers, ns)
+	}
+	sort.Strings(nameservers)
+
+	var findings []*GlueMismatch
+	for _, ns := range nameservers {
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			addrs := glue[ns][qtype]
+			if len(addrs) == 0 {
+				continue
+			}
+
+			child := r.childAddrs(ctx, children, ns, qtype)
+			if !sameAddrs(addrs, child) {
+				findings = append(findings, &GlueMismatch{
+					Zone:       zone,
+					Parent:     parent,
+					Nameserver: ns,
+					Qtype:      qtype,
+					Glue:       addrs,
+					Child:      child,
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+// Returns the closest zone enclosing the provided zone, along with the addresses of its nameservers.
+func (r *Resolvers) parentZone(ctx context.Context, zone string) (string, []string, error) {
+	labels := strings.SplitN(zone, ".", 2)
+	if len(labels) != 2 || labels[1] == "" {
+		return "", nil, fmt.Errorf("CheckGlue: %s does not have a parent zone", zone)
+	}
+
+	for parent := labels[1]; parent != ""; {
+		if servers, found := r.zoneServers(ctx, parent); found {
+			if len(servers) == 0 {
+				return "", nil, fmt.Errorf("CheckGlue: failed to obtain the nameservers for %s", parent)
+			}
+			return parent, servers, nil
+		}
+
+		_, next, _ := strings.Cut(parent, ".")
+		parent = next
+	}
+	return "", nil, fmt.Errorf("CheckGlue: failed to find the parent zone of %s", zone)
+}
+
+// Returns the glue addresses, keyed by nameserver and record type, from the referral for the zone.
+func (r *Resolvers) delegationGlue(ctx context.Context, zone string, servers []string) (map[string]map[uint16][]string, error) {
+	for _, ip := range servers {
+		res := r.authResolver(ip)
+		if res == nil {
+			continue
+		}
+
+		resp, err := r.authExchange(ctx, res, authMsg(zone, dns.TypeNS))
+		if err != nil || resp.Rcode != dns.RcodeSuccess {
+			continue
+		}
+
+		glue := make(map[string]map[uint16][]string)
+		for _, ns := range Records[*dns.NS](resp.Ns) {
+			if strings.EqualFold(RemoveLastDot(ns.Hdr.Name), zone) {
+				glue[strings.ToLower(RemoveLastDot(ns.Ns))] = make(map[uint16][]string)
+			}
+		}
+		if len(glue) == 0 {
+			continue
+		}
+
+		for _, a := range Records[*dns.A](resp.Extra) {
+			if addrs, found := glue[strings.ToLower(RemoveLastDot(a.Hdr.Name))]; found {
+				addrs[dns.TypeA] = append(addrs[dns.TypeA], a.A.String())
+			}
+		}
+		for _, aaaa := range Records[*dns.AAAA](resp.Extra) {
+			if addrs, found := glue[strings.ToLower(RemoveLastDot(aaaa.Hdr.Name))]; found {
+				addrs[dns.TypeAAAA] = append(addrs[dns.TypeAAAA], aaaa.AAAA.String())
+			}
+		}
+		return glue, nil
+	}
+	return nil, errors.New("CheckGlue: failed to obtain the delegation from the parent zone")
+}
+
+// Returns the addresses published by the child zone for the nameserver.
+func (r *Resolvers) childAddrs(ctx context.Context, children []*resolver, ns string, qtype uint16) []string {
+	for _, res := range children {
+		resp, err := r.authExchange(ctx, res, authMsg(ns, qtype))
+		if err != nil || (resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError) {
+			continue
+		}
+
+		var addrs []string
+		for _, a := range AnswersByType(ExtractAnswers(resp), qtype) {
+			addrs = append(addrs, a.Data)
+		}
+		return addrs
+	}
+	return nil
+}
+
+func sameAddrs(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+
+	a := append([]string(nil), x...)
+	b := append([]string(nil), y...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
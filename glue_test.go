@@ -0,0 +1,219 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestCheckGlue(t *testing.T) {
+	// The parent nameserver delegates child.glue.net with glue for both nameservers
+	parentMux := dns.NewServeMux()
+	parentMux.HandleFunc("glue.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		if req.Question[0].Name == "child.glue.net." && req.Question[0].Qtype == dns.TypeNS {
+			for _, ns := range []string{"ns1.child.glue.net.", "ns2.child.glue.net."} {
+				m.Ns = append(m.Ns, &dns.NS{
+					Hdr: dns.RR_Header{Name: "child.glue.net.", Rrtype: dns.TypeNS, Class: dns.ClassINET},
+					Ns:  ns,
+				})
+				m.Extra = append(m.Extra, testARecord(ns, "127.0.0.2"))
+			}
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	ps, parentaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = parentMux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = ps.Shutdown() }()
+	_, authport, _ := net.SplitHostPort(parentaddr)
+
+	// The child zone publishes a different address for the second nameserver
+	childMux := dns.NewServeMux()
+	childMux.HandleFunc("child.glue.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Authoritative = true
+
+		if req.Question[0].Qtype == dns.TypeA {
+			switch name := req.Question[0].Name; name {
+			case "ns1.child.glue.net.":
+				m.Answer = append(m.Answer, testARecord(name, "127.0.0.2"))
+			case "ns2.child.glue.net.":
+				m.Answer = append(m.Answer, testARecord(name, "127.0.0.3"))
+			}
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	cs, _, _, err := RunLocalUDPServer(net.JoinHostPort("127.0.0.2", authport), func(s *dns.Server) { s.Handler = childMux })
+	if err != nil {
+		t.Skipf("unable to run test server on a second loopback address: %v", err)
+	}
+	defer func() { _ = cs.Shutdown() }()
+
+	recMux := dns.NewServeMux()
+	recMux.HandleFunc("glue.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		switch name := req.Question[0].Name; {
+		case name == "glue.net." && req.Question[0].Qtype == dns.TypeNS:
+			m.Answer = append(m.Answer, &dns.NS{
+				Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeNS, Class: dns.ClassINET},
+				Ns:  "ns.glue.net.",
+			})
+		case name == "ns.glue.net." && req.Question[0].Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, testARecord(name, "127.0.0.1"))
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	rs, recaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = recMux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = rs.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, recaddr)
+	defer r.Stop()
+	r.auth.port = authport
+
+	findings, err := r.CheckGlue(context.Background(), "child.glue.net")
+	if err != nil {
+		t.Fatalf("CheckGlue failed: %v", err)
+	}
+	if len(findings) != 1 {
+		t.Fatalf("expected one glue mismatch, got %d", len(findings))
+	}
+
+	f := findings[0]
+	if f.Nameserver != "ns2.child.glue.net" || f.Qtype != dns.TypeA ||
+		len(f.Glue) != 1 || f.Glue[0] != "127.0.0.2" || len(f.Child) != 1 || f.Child[0] != "127.0.0.3" {
+		t.Errorf("the glue mismatch was not reported correctly: %s", f)
+	}
+
+	if _, err := r.CheckGlue(context.Background(), "net"); err == nil {
+		t.Errorf("CheckGlue did not fail for a zone without a parent")
+	}
+}
+
+func TestCheckGlueAAAA(t *testing.T) {
+	// The parent nameserver delegates child.sub.glue6.net with only IPv6 glue
+	parentMux := dns.NewServeMux()
+	parentMux.HandleFunc("glue6.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		if req.Question[0].Name == "child.sub.glue6.net." && req.Question[0].Qtype == dns.TypeNS {
+			m.Ns = append(m.Ns, &dns.NS{
+				Hdr: dns.RR_Header{Name: "child.sub.glue6.net.", Rrtype: dns.TypeNS, Class: dns.ClassINET},
+				Ns:  "ns1.child.sub.glue6.net.",
+			})
+			m.Extra = append(m.Extra, &dns.AAAA{
+				Hdr:  dns.RR_Header{Name: "ns1.child.sub.glue6.net.", Rrtype: dns.TypeAAAA, Class: dns.ClassINET},
+				AAAA: net.ParseIP("::1"),
+			})
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	ps, parentaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = parentMux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = ps.Shutdown() }()
+	_, authport, _ := net.SplitHostPort(parentaddr)
+
+	// The child zone publishes a different IPv6 address for the nameserver
+	childMux := dns.NewServeMux()
+	childMux.HandleFunc("child.sub.glue6.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Authoritative = true
+
+		if name := req.Question[0].Name; name == "ns1.child.sub.glue6.net." && req.Question[0].Qtype == dns.TypeAAAA {
+			m.Answer = append(m.Answer, &dns.AAAA{
+				Hdr:  dns.RR_Header{Name: name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET},
+				AAAA: net.ParseIP("2001:db8::1"),
+			})
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	cs, _, _, err := RunLocalUDPServer(net.JoinHostPort("::1", authport), func(s *dns.Server) { s.Handler = childMux })
+	if err != nil {
+		t.Skipf("unable to run test server on the IPv6 loopback address: %v", err)
+	}
+	defer func() { _ = cs.Shutdown() }()
+
+	// The sub.glue6.net name is not a zone, so glue6.net is the parent of the delegation
+	recMux := dns.NewServeMux()
+	recMux.HandleFunc("glue6.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		switch name := req.Question[0].Name; {
+		case name == "glue6.net." && req.Question[0].Qtype == dns.TypeNS:
+			m.Answer = append(m.Answer, &dns.NS{
+				Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeNS, Class: dns.ClassINET},
+				Ns:  "ns.glue6.net.",
+			})
+		case name == "ns.glue6.net." && req.Question[0].Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, testARecord(name, "127.0.0.1"))
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	rs, recaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = recMux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = rs.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, recaddr)
+	defer r.Stop()
+	r.auth.port = authport
+
+	findings, err := r.CheckGlue(context.Background(), "child.sub.glue6.net")
+	if err != nil {
+		t.Fatalf("CheckGlue failed: %v", err)
+	}
+	if len(findings) != 1 {
+		t.Fatalf("expected one glue mismatch, got %d", len(findings))
+	}
+
+	f := findings[0]
+	if f.Parent != "glue6.net" || f.Qtype != dns.TypeAAAA || len(f.Child) != 1 || f.Child[0] != "2001:db8::1" {
+		t.Errorf("the glue mismatch was not reported correctly: %s", f)
+	}
+}
+
+func TestSameAddrs(t *testing.T) {
+	cases := []struct {
+		x, y []string
+		want bool
+	}{
+		{x: []string{"192.168.1.1", "192.168.1.2"}, y: []string{"192.168.1.2", "192.168.1.1"}, want: true},
+		{x: []string{"192.168.1.1"}, y: []string{"192.168.1.2"}, want: false},
+		{x: []string{"192.168.1.1"}, y: nil, want: false},
+	}
+
+	for _, c := range cases {
+		if got := sameAddrs(c.x, c.y); got != c.want {
+			t.Errorf("sameAddrs(%v, %v) returned %t instead of %t", c.x, c.y, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultHijackProbes is the number of nonexistent names queried by DetectHijacking.
+const DefaultHijackProbes int = 3
+
+// The top-level domains of the nonexistent names queried by DetectHijacking.
+var hijackProbeTLDs = []string{"com", "net", "org"}
+
+// HijackReport lists the addresses returned by a resolver for every nonexistent name.
+type HijackReport struct {
+	Address string
+	Answers []string
+}
+
+// DetectHijacking queries each resolver in the pool for the number of random nonexistent
+// names, or DefaultHijackProbes when fewer than two are requested, and removes the resolvers
+// answering all of them with identical A records, such as captive portals and resolvers
+// redirecting NXDOMAIN responses to advertising. The removed resolvers are reported.
+func (r *Resolvers) DetectHijacking(ctx context.Context, probes int) []*HijackReport {
+	if probes <= 1 {
+		probes = DefaultHijackProbes
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var reports []*HijackReport
+	sem := make(chan struct{}, numOfFilteringProbes)
+	for _, res := range r.pool.AllResolvers() {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(res *resolver) {
+			defer func() { <-sem; wg.Done() }()
+
+			answers := r.hijackedAnswers(ctx, res, probes)
+			if len(answers) == 0 {
+				return
+			}
+
+			r.disqualify(res, RemovedHijacking)
+			mu.Lock()
+			reports = append(reports, &HijackReport{Address: res.address.String(), Answers: answers})
+			mu.Unlock()
+		}(res)
+	}
+	wg.Wait()
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Address < reports[j].Address })
+	return reports
+}
+
+// Returns the addresses provided by the resolver for every one of the nonexistent names,
+// or nil when any of the names was not answered with the same A records.
+func (r *Resolvers) hijackedAnswers(ctx context.Context, res *resolver, probes int) []string {
+	var first []string
+
+	for i := 0; i < probes; i++ {
+		name := UnlikelyName(hijackProbeTLDs[i%len(hijackProbeTLDs)])
+		if name == "" {
+			return nil
+		}
+
+		resp, err := r.authExchange(ctx, res, QueryMsg(name, dns.TypeA))
+		if err != nil || resp.Rcode != dns.RcodeSuccess {
+			return nil
+		}
+
+		var addrs []string
+		for _, ans := range ExtractAnswers(resp) {
+			if ans.Type == dns.TypeA {
+				addrs = append(addrs, ans.Data)
+			}
+		}
+		if len(addrs) == 0 {
+			return nil
+		}
+		sort.Strings(addrs)
+
+		if i == 0 {
+			first = addrs
+		} else if strings.Join(addrs, ",") != strings.Join(first, ",") {
+			return nil
+		}
+	}
+	return first
+}
@@ -0,0 +1,66 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestDetectHijacking(t *testing.T) {
+	portal, portaladdr := runHijackServer(t, "portal")
+	defer func() { _ = portal.Shutdown() }()
+	random, randomaddr := runHijackServer(t, "random")
+	defer func() { _ = random.Shutdown() }()
+	clean, cleanaddr := runHijackServer(t, "")
+	defer func() { _ = clean.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, portaladdr, randomaddr, cleanaddr)
+	defer r.Stop()
+
+	reports := r.DetectHijacking(context.Background(), 0)
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 hijacking resolver, got %d", len(reports))
+	}
+	if rep := reports[0]; rep.Address != portaladdr || len(rep.Answers) != 2 || rep.Answers[0] != "192.0.2.1" {
+		t.Errorf("unexpected hijacking report: %+v", rep)
+	}
+	if r.Len() != 2 {
+		t.Errorf("the hijacking resolver was not removed from the pool")
+	}
+}
+
+// Runs a nameserver answering every A query with the same addresses as a captive portal,
+// with a different address each time, or with NXDOMAIN, as selected by the method.
+func runHijackServer(t *testing.T, method string) (*dns.Server, string) {
+	var count int
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		name := req.Question[0].Name
+		switch method {
+		case "portal":
+			m.Answer = append(m.Answer, testARecord(name, "192.0.2.2"), testARecord(name, "192.0.2.1"))
+		case "random":
+			count++
+			m.Answer = append(m.Answer, testARecord(name, "192.0.2."+string(rune('0'+count%10))))
+		default:
+			m.Rcode = dns.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	return s, addr
+}
@@ -0,0 +1,107 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// The TTL used for the records generated from the hosts overrides.
+const overrideTTL uint32 = 3600
+
+// hostsOverrides contains the pinned addresses for names that are answered without queries being sent.
+type hostsOverrides struct {
+	sync.Mutex
+	names map[string][]net.IP
+}
+
+func newHostsOverrides() *hostsOverrides {
+	return &hostsOverrides{names: make(map[string][]net.IP)}
+}
+
+// AddHostsOverride pins the provided IP addresses to the name, so A and AAAA queries for the
+// name are answered by the pool without being sent to a resolver.
+func (r *Resolvers) AddHostsOverride(name string, addrs ...string) error {
+	var ips []net.IP
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return fmt.Errorf("AddHostsOverride: %s is not a valid IP address", addr)
+		}
+		ips = append(ips, ip)
+	}
+
+	r.hosts.Lock()
+	defer r.hosts.Unlock()
+
+	key := strings.ToLower(dns.Fqdn(name))
+	r.hosts.names[key] = append(r.hosts.names[key], ips...)
+	return nil
+}
+
+// LoadHostsFile adds the overrides found in the hosts-style file at the provided path.
+// Each line contains an IP address followed by one or more names.
+func (r *Resolvers) LoadHostsFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, name := range fields[1:] {
+			if err := r.AddHostsOverride(name, fields[0]); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// Returns the response generated from the overrides for the query, or nil when not pinned.
+func (h *hostsOverrides) answer(msg *dns.Msg) *dns.Msg {
+	q := msg.Question[0]
+	if q.Qtype != dns.TypeA && q.Qtype != dns.TypeAAAA {
+		return nil
+	}
+
+	h.Lock()
+	ips, found := h.names[strings.ToLower(q.Name)]
+	h.Unlock()
+
+	if !found {
+		return nil
+	}
+
+	resp := new(dns.Msg).SetReply(msg)
+	resp.RecursionAvailable = true
+
+	hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET, Ttl: overrideTTL}
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil && q.Qtype == dns.TypeA {
+			resp.Answer = append(resp.Answer, &dns.A{Hdr: hdr, A: ip4})
+		} else if ip4 == nil && q.Qtype == dns.TypeAAAA {
+			resp.Answer = append(resp.Answer, &dns.AAAA{Hdr: hdr, AAAA: ip})
+		}
+	}
+	return resp
+}
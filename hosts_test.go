@@ -0,0 +1,63 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestHostsOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	hosts := "# lab environment\n192.168.1.1 www.lab.test web.lab.test\n2001:db8::1 www.lab.test # ipv6\n\n"
+	if err := os.WriteFile(path, []byte(hosts), 0600); err != nil {
+		t.Fatalf("failed to write the hosts file: %v", err)
+	}
+
+	// The pool does not have any resolvers, so only overrides can be answered
+	r := NewResolvers()
+	defer r.Stop()
+
+	if err := r.LoadHostsFile(path); err != nil {
+		t.Fatalf("failed to load the hosts file: %v", err)
+	}
+	if err := r.AddHostsOverride("db.lab.test", "not an address"); err == nil {
+		t.Errorf("an invalid IP address was accepted as an override")
+	}
+
+	cases := []struct {
+		name  string
+		qtype uint16
+		want  string
+	}{
+		{name: "www.lab.test", qtype: dns.TypeA, want: "192.168.1.1"},
+		{name: "WEB.lab.test", qtype: dns.TypeA, want: "192.168.1.1"},
+		{name: "www.lab.test", qtype: dns.TypeAAAA, want: "2001:db8::1"},
+		{name: "web.lab.test", qtype: dns.TypeAAAA, want: ""},
+	}
+
+	for _, c := range cases {
+		resp, err := r.QueryBlocking(context.Background(), QueryMsg(c.name, c.qtype))
+		if err != nil || resp.Rcode != dns.RcodeSuccess {
+			t.Errorf("%s: the override was not answered", c.name)
+			continue
+		}
+
+		ans := ExtractAnswers(resp)
+		if c.want == "" && len(ans) > 0 {
+			t.Errorf("%s: Got: %v; Expected no answers", c.name, ans[0].Data)
+		} else if c.want != "" && (len(ans) != 1 || ans[0].Data != c.want) {
+			t.Errorf("%s: the override answer %s was not returned", c.name, c.want)
+		}
+	}
+
+	if _, err := r.QueryBlocking(context.Background(), QueryMsg("www.lab.test", dns.TypeTXT)); err != nil {
+		t.Errorf("a query type without overrides failed: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// zoneJitter spaces the queries for names within the same registered domain by a random delay.
+type zoneJitter struct {
+	sync.Mutex
+	min  time.Duration
+	max  time.Duration
+	next map[string]time.Time
+}
+
+func newZoneJitter() *zoneJitter {
+	return &zoneJitter{next: make(map[string]time.Time)}
+}
+
+// SetZoneJitter causes the pool to wait a random duration in the range [min,max) between sending
+// queries for names within the same registered domain, independent of the QPS limits, so queries
+// for a zone do not arrive at a steady rate. A max of zero removes the delay.
+func (r *Resolvers) SetZoneJitter(min, max time.Duration) {
+	r.jitter.Lock()
+	defer r.jitter.Unlock()
+
+	if max < min {
+		max = min
+	}
+	r.jitter.min = min
+	r.jitter.max = max
+	r.jitter.next = make(map[string]time.Time)
+}
+
+// delay calls fn once the delay selected for the zone of the name has elapsed, or immediately
+// when no delay is required, without blocking the caller. The error of the context is provided
+// to fn when the context expires first.
+func (j *zoneJitter) delay(ctx context.Context, name string, fn func(err error)) {
+	d := j.reserve(name, time.Now())
+	if d <= 0 {
+		fn(nil)
+		return
+	}
+
+	var once sync.Once
+	var mu sync.Mutex
+	var t *time.Timer
+	// The lock prevents the context from being handled before the timer has been assigned
+	mu.Lock()
+	defer mu.Unlock()
+
+	stop := context.AfterFunc(ctx, func() {
+		once.Do(func() {
+			mu.Lock()
+			t.Stop()
+			mu.Unlock()
+			fn(ctx.Err())
+		})
+	})
+	t = time.AfterFunc(d, func() {
+		once.Do(func() {
+			stop()
+			fn(nil)
+		})
+	})
+}
+
+// reserve returns the time remaining before a query for the name can be sent, and
+// schedules the earliest time for the following query sent to the same zone.
+func (j *zoneJitter) reserve(name string, now time.Time) time.Duration {
+	j.Lock()
+	defer j.Unlock()
+
+	if j.max <= 0 {
+		return 0
+	}
+
+	zone := selectorZone(name)
+	at := now
+	if next, found := j.next[zone]; found && next.After(now) {
+		at = next
+	}
+
+	delay := j.min
+	if j.max > j.min {
+		delay = BackoffJitter(j.min, j.max)
+	}
+	j.next[zone] = at.Add(delay)
+	return at.Sub(now)
+}
@@ -0,0 +1,125 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestZoneJitterReserve(t *testing.T) {
+	j := newZoneJitter()
+	now := time.Now()
+
+	if d := j.reserve("www.owasp.org", now); d != 0 {
+		t.Errorf("a delay of %v was returned without the jitter being set", d)
+	}
+
+	j.min, j.max = 100*time.Millisecond, 200*time.Millisecond
+	if d := j.reserve("www.owasp.org", now); d != 0 {
+		t.Errorf("the first query for the zone was delayed by %v", d)
+	}
+
+	d := j.reserve("mail.OWASP.org.", now)
+	if d < j.min || d >= j.max {
+		t.Errorf("the second query for the zone was delayed by %v; expected [%v,%v)", d, j.min, j.max)
+	}
+	if next := j.reserve("ftp.owasp.org", now); next < d+j.min || next >= d+j.max {
+		t.Errorf("the third query for the zone was delayed by %v after the second at %v", next, d)
+	}
+	if d := j.reserve("www.caffix.net", now); d != 0 {
+		t.Errorf("the query for another zone was delayed by %v", d)
+	}
+	if d := j.reserve("www.owasp.org", now.Add(time.Second)); d != 0 {
+		t.Errorf("the query sent after the scheduled time was delayed by %v", d)
+	}
+}
+
+// Returns the time taken for the delay of the name to elapse, and the error provided.
+func jitterDelay(ctx context.Context, j *zoneJitter, name string) (time.Duration, error) {
+	start := time.Now()
+	ch := make(chan error, 1)
+	j.delay(ctx, name, func(err error) { ch <- err })
+	err := <-ch
+	return time.Since(start), err
+}
+
+func TestSetZoneJitter(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	r.SetZoneJitter(50*time.Millisecond, 10*time.Millisecond)
+	if r.jitter.min != 50*time.Millisecond || r.jitter.max != 50*time.Millisecond {
+		t.Errorf("the max was not raised to the min: [%v,%v)", r.jitter.min, r.jitter.max)
+	}
+
+	first, _ := jitterDelay(context.Background(), r.jitter, "www.owasp.org")
+	second, _ := jitterDelay(context.Background(), r.jitter, "mail.owasp.org")
+	if elapsed := first + second; elapsed < 50*time.Millisecond {
+		t.Errorf("the queries for the zone were only %v apart", elapsed)
+	}
+
+	r.SetZoneJitter(0, 0)
+	if elapsed, _ := jitterDelay(context.Background(), r.jitter, "www.owasp.org"); elapsed > 25*time.Millisecond {
+		t.Errorf("the jitter was not removed, the query waited %v", elapsed)
+	}
+}
+
+func TestZoneJitterContext(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	r.SetZoneJitter(time.Second, time.Second)
+	_, _ = jitterDelay(context.Background(), r.jitter, "www.owasp.org")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 25*time.Millisecond)
+	defer cancel()
+
+	elapsed, err := jitterDelay(ctx, r.jitter, "mail.owasp.org")
+	if err == nil {
+		t.Errorf("the delay did not provide the error of the expired context")
+	}
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("the delay ignored the expired context for %v", elapsed)
+	}
+}
+
+func TestZoneJitterQuery(t *testing.T) {
+	dns.HandleFunc("jitter.net.", typeAHandler)
+	defer dns.HandleRemove("jitter.net.")
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+	r.SetZoneJitter(200*time.Millisecond, 200*time.Millisecond)
+
+	// The queries for the zone are delayed without blocking the caller
+	ch := make(chan *dns.Msg, 3)
+	start := time.Now()
+	for _, name := range []string{"www.jitter.net", "mail.jitter.net", "ftp.jitter.net"} {
+		r.Query(context.Background(), QueryMsg(name, dns.TypeA), ch)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("the caller was blocked for %v by the jitter", elapsed)
+	}
+
+	for i := 0; i < 3; i++ {
+		if resp := <-ch; resp.Rcode != dns.RcodeSuccess {
+			t.Errorf("the delayed query did not receive a response")
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("the queries for the zone were only %v apart", elapsed)
+	}
+}
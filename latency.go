@@ -0,0 +1,195 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxLatencySamples is the number of the most recent traces kept for the latency report.
+const maxLatencySamples = 10000
+
+// LatencyStage identifies a portion of the path taken by a request through the pool.
+type LatencyStage int
+
+// The stages of the latency breakdown, in the order visited by a request.
+const (
+	// StageSelection is the time from the query being queued until a resolver is selected,
+	// including the wait for the pool QPS limit.
+	StageSelection LatencyStage = iota
+	// StageDispatch is the time from the selection until the query is written to the socket,
+	// including the wait for the in-flight limit and the rate limit of the resolver.
+	StageDispatch
+	// StageNetwork is the time from the query being written until the response is matched.
+	StageNetwork
+	// StageDelivery is the time from the response being matched until the caller receives it.
+	StageDelivery
+	numLatencyStages
+)
+
+// String returns the name of the latency stage.
+func (s LatencyStage) String() string {
+	switch s {
+	case StageSelection:
+		return "selection"
+	case StageDispatch:
+		return "dispatch"
+	case StageNetwork:
+		return "network"
+	case StageDelivery:
+		return "delivery"
+	}
+	return "unknown"
+}
+
+// LatencyStats summarizes the durations observed for a stage.
+type LatencyStats struct {
+	Stage LatencyStage
+	Mean  time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	Max   time.Duration
+}
+
+// LatencyReport is the breakdown of the latency across the stages for the sampled requests.
+type LatencyReport struct {
+	Samples int
+	Stages  []LatencyStats
+}
+
+// requestTrace records the time a sampled request reached each point in the pool.
+type requestTrace struct {
+	tracer    *latencyTracer
+	enqueued  time.Time
+	picked    time.Time
+	sent      time.Time
+	received  time.Time
+	delivered time.Time
+}
+
+type latencyTracer struct {
+	sync.Mutex
+	fraction float64
+	samples  [][numLatencyStages]time.Duration
+	next     int
+}
+
+func newLatencyTracer() *latencyTracer {
+	return new(latencyTracer)
+}
+
+// SetLatencyTracing sets the fraction of requests, between zero and one, that have the time spent
+// in each stage recorded for the latency report. Tracing is disabled by a fraction of zero.
+func (r *Resolvers) SetLatencyTracing(fraction float64) {
+	if fraction < 0 {
+		fraction = 0
+	} else if fraction > 1 {
+		fraction = 1
+	}
+
+	r.latency.Lock()
+	defer r.latency.Unlock()
+
+	r.latency.fraction = fraction
+}
+
+// LatencyReport returns the latency breakdown for the most recent requests sampled by the pool,
+// so the time spent selecting resolvers, on the network and delivering responses can be compared.
+func (r *Resolvers) LatencyReport() *LatencyReport {
+	return r.latency.report()
+}
+
+// Returns a trace for the request when it has been sampled, otherwise nil.
+func (t *latencyTracer) start() *requestTrace {
+	t.Lock()
+	fraction := t.fraction
+	t.Unlock()
+
+	if fraction <= 0 || (fraction < 1 && float64(prng.Intn(1000000)) >= fraction*1000000) {
+		return nil
+	}
+	return &requestTrace{
+		tracer:   t,
+		enqueued: time.Now(),
+	}
+}
+
+func (t *latencyTracer) add(sample [numLatencyStages]time.Duration) {
+	t.Lock()
+	defer t.Unlock()
+
+	if len(t.samples) < maxLatencySamples {
+		t.samples = append(t.samples, sample)
+		return
+	}
+	t.samples[t.next] = sample
+	t.next = (t.next + 1) % maxLatencySamples
+}
+
+func (t *latencyTracer) report() *LatencyReport {
+	t.Lock()
+	samples := append([][numLatencyStages]time.Duration(nil), t.samples...)
+	t.Unlock()
+
+	rep := &LatencyReport{Samples: len(samples)}
+	if len(samples) == 0 {
+		return rep
+	}
+
+	for stage := LatencyStage(0); stage < numLatencyStages; stage++ {
+		var total time.Duration
+		durs := make([]time.Duration, 0, len(samples))
+
+		for _, s := range samples {
+			durs = append(durs, s[stage])
+			total += s[stage]
+		}
+		slices.Sort(durs)
+
+		rep.Stages = append(rep.Stages, LatencyStats{
+			Stage: stage,
+			Mean:  total / time.Duration(len(durs)),
+			P50:   durs[(len(durs)-1)*50/100],
+			P95:   durs[(len(durs)-1)*95/100],
+			Max:   durs[len(durs)-1],
+		})
+	}
+	return rep
+}
+
+func (rt *requestTrace) pick() {
+	if rt != nil {
+		rt.picked = time.Now()
+	}
+}
+
+func (rt *requestTrace) send() {
+	if rt != nil {
+		rt.sent = time.Now()
+	}
+}
+
+func (rt *requestTrace) receive() {
+	if rt != nil {
+		rt.received = time.Now()
+	}
+}
+
+// Completes the trace once the response has been delivered and adds it to the samples.
+func (rt *requestTrace) deliver() {
+	if rt == nil || rt.sent.IsZero() || rt.received.IsZero() {
+		return
+	}
+
+	rt.delivered = time.Now()
+	rt.tracer.add([numLatencyStages]time.Duration{
+		StageSelection: rt.picked.Sub(rt.enqueued),
+		StageDispatch:  rt.sent.Sub(rt.picked),
+		StageNetwork:   rt.received.Sub(rt.sent),
+		StageDelivery:  rt.delivered.Sub(rt.received),
+	})
+}
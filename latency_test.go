@@ -0,0 +1,81 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestLatencyTracing(t *testing.T) {
+	dns.HandleFunc("latency.net.", typeAHandler)
+	defer dns.HandleRemove("latency.net.")
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	if rep := r.LatencyReport(); rep.Samples != 0 || len(rep.Stages) != 0 {
+		t.Errorf("Expected an empty report before tracing was enabled")
+	}
+
+	num := 20
+	r.SetLatencyTracing(1)
+	for i := 0; i < num; i++ {
+		if _, err := r.QueryBlocking(context.Background(), QueryMsg("latency.net", dns.TypeA)); err != nil {
+			t.Fatalf("The query failed: %v", err)
+		}
+	}
+
+	// The trace is completed after the response has been delivered
+	var rep *LatencyReport
+	for i := 0; i < 10; i++ {
+		if rep = r.LatencyReport(); rep.Samples == num {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if rep.Samples != num || len(rep.Stages) != int(numLatencyStages) {
+		t.Fatalf("Expected %d samples across the stages, got %d", num, rep.Samples)
+	}
+	for _, st := range rep.Stages {
+		if st.Mean < 0 || st.P50 > st.P95 || st.P95 > st.Max {
+			t.Errorf("Unexpected statistics for the %s stage: %+v", st.Stage, st)
+		}
+	}
+	if rep.Stages[StageNetwork].Max <= 0 {
+		t.Errorf("The network stage was not recorded")
+	}
+
+	r.SetLatencyTracing(0)
+	_, _ = r.QueryBlocking(context.Background(), QueryMsg("latency.net", dns.TypeA))
+	time.Sleep(10 * time.Millisecond)
+	if rep := r.LatencyReport(); rep.Samples != num {
+		t.Errorf("A request was traced after tracing was disabled")
+	}
+}
+
+func TestLatencyTracerSamples(t *testing.T) {
+	tracer := newLatencyTracer()
+
+	for i := 0; i < maxLatencySamples+10; i++ {
+		tracer.add([numLatencyStages]time.Duration{time.Duration(i)})
+	}
+	if rep := tracer.report(); rep.Samples != maxLatencySamples || rep.Stages[StageSelection].Max != time.Duration(maxLatencySamples+9) {
+		t.Errorf("The oldest samples were not replaced")
+	}
+	if s := StageDelivery.String(); s != "delivery" {
+		t.Errorf("Got: %s; Expected: delivery", s)
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build linux
+
+package resolve
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+type mmsghdr struct {
+	hdr unix.Msghdr
+	len uint32
+}
+
+// mmsgScratch holds the headers passed to the kernel for a batch of datagrams.
+type mmsgScratch struct {
+	hdrs  [batchSize]mmsghdr
+	iovs  [batchSize]unix.Iovec
+	names [batchSize]unix.RawSockaddrInet6
+}
+
+// batchConn sends and receives datagrams in batches using the sendmmsg and recvmmsg system
+// calls, and falls back to a datagram per call when the kernel does not provide them.
+type batchConn struct {
+	conn     net.PacketConn
+	raw      syscall.RawConn
+	family   int
+	fallback atomic.Bool
+	reads    mmsgScratch
+	writes   mmsgScratch
+}
+
+func newBatchConn(conn net.PacketConn) *batchConn {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil
+	}
+
+	family := unix.AF_INET6
+	var serr error
+	if err := raw.Control(func(fd uintptr) {
+		var sa unix.Sockaddr
+
+		sa, serr = unix.Getsockname(int(fd))
+		if _, ok := sa.(*unix.SockaddrInet4); ok {
+			family = unix.AF_INET
+		}
+	}); err != nil || serr != nil {
+		return nil
+	}
+	return &batchConn{
+		conn:   conn,
+		raw:    raw,
+		family: family,
+	}
+}
+
+// writeBatch sends the datagrams and returns the number sent before an error was encountered.
+// It is not safe for concurrent use with other calls to writeBatch.
+func (b *batchConn) writeBatch(dgrams []*datagram) (int, error) {
+	if b.fallback.Load() {
+		return b.writeOne(dgrams[0])
+	}
+
+	var n int
+	for ; n < len(dgrams) && n < batchSize; n++ {
+		d := dgrams[n]
+
+		namelen := b.putSockaddr(&b.writes.names[n], d.addr)
+		if namelen == 0 {
+			break
+		}
+		b.writes.iovs[n] = unix.Iovec{Base: &d.b[0]}
+		b.writes.iovs[n].SetLen(len(d.b))
+		b.writes.hdrs[n] = mmsghdr{hdr: unix.Msghdr{
+			Name:    (*byte)(unsafe.Pointer(&b.writes.names[n])),
+			Namelen: namelen,
+			Iov:     &b.writes.iovs[n],
+		}}
+		b.writes.hdrs[n].hdr.SetIovlen(1)
+	}
+	if n == 0 {
+		return 0, errors.New("the address is not supported by the connection")
+	}
+
+	var sent int
+	var errno error
+	err := b.raw.Write(func(fd uintptr) bool {
+		r, _, e := unix.Syscall6(unix.SYS_SENDMMSG, fd, uintptr(unsafe.Pointer(&b.writes.hdrs[0])), uintptr(n), 0, 0, 0)
+		if e == unix.EAGAIN {
+			return false
+		}
+		if e != 0 {
+			errno = e
+		}
+		sent = int(r)
+		return true
+	})
+	if err == nil {
+		err = errno
+	}
+	if errors.Is(err, unix.ENOSYS) {
+		b.fallback.Store(true)
+		return b.writeOne(dgrams[0])
+	}
+	if err != nil {
+		sent = 0
+	}
+	return sent, err
+}
+
+func (b *batchConn) writeOne(d *datagram) (int, error) {
+	if _, err := b.conn.WriteTo(d.b, d.addr); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// readBatch blocks until datagrams are received and returns the number of datagrams filled.
+// It is not safe for concurrent use with other calls to readBatch.
+func (b *batchConn) readBatch(dgrams []*datagram) (int, error) {
+	if b.fallback.Load() {
+		return b.readOne(dgrams[0])
+	}
+
+	n := len(dgrams)
+	if n > batchSize {
+		n = batchSize
+	}
+	for i := 0; i < n; i++ {
+		d := dgrams[i]
+
+		b.reads.iovs[i] = unix.Iovec{Base: &d.b[0]}
+		b.reads.iovs[i].SetLen(len(d.b))
+		b.reads.hdrs[i] = mmsghdr{hdr: unix.Msghdr{
+			Name:    (*byte)(unsafe.Pointer(&b.reads.names[i])),
+			Namelen: unix.SizeofSockaddrInet6,
+			Iov:     &b.reads.iovs[i],
+		}}
+		b.reads.hdrs[i].hdr.SetIovlen(1)
+	}
+
+	var received int
+	var errno error
+	err := b.raw.Read(func(fd uintptr) bool {
+		r, _, e := unix.Syscall6(unix.SYS_RECVMMSG, fd, uintptr(unsafe.Pointer(&b.reads.hdrs[0])), uintptr(n), 0, 0, 0)
+		if e == unix.EAGAIN {
+			return false
+		}
+		if e != 0 {
+			errno = e
+		}
+		received = int(r)
+		return true
+	})
+	if err == nil {
+		err = errno
+	}
+	if errors.Is(err, unix.ENOSYS) {
+		b.fallback.Store(true)
+		return b.readOne(dgrams[0])
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < received; i++ {
+		dgrams[i].n = int(b.reads.hdrs[i].len)
+		dgrams[i].addr = parseSockaddr(&b.reads.names[i])
+	}
+	return received, nil
+}
+
+func (b *batchConn) readOne(d *datagram) (int, error) {
+	n, addr, err := b.conn.ReadFrom(d.b)
+	if err != nil {
+		return 0, err
+	}
+
+	d.n = n
+	d.addr = addr
+	return 1, nil
+}
+
+// Writes the address in the socket address format of the connection and returns the length,
+// or zero when the address cannot be reached from the connection.
+func (b *batchConn) putSockaddr(buf *unix.RawSockaddrInet6, addr net.Addr) uint32 {
+	uaddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return 0
+	}
+
+	if b.family == unix.AF_INET {
+		ip4 := uaddr.IP.To4()
+		if ip4 == nil {
+			return 0
+		}
+
+		sa := (*unix.RawSockaddrInet4)(unsafe.Pointer(buf))
+		*sa = unix.RawSockaddrInet4{Family: unix.AF_INET}
+		putPort(&sa.Port, uaddr.Port)
+		copy(sa.Addr[:], ip4)
+		return unix.SizeofSockaddrInet4
+	}
+
+	// IPv4 addresses are mapped into the IPv6 address space of the dual-stack socket
+	ip16 := uaddr.IP.To16()
+	if ip16 == nil {
+		return 0
+	}
+
+	*buf = unix.RawSockaddrInet6{Family: unix.AF_INET6}
+	putPort(&buf.Port, uaddr.Port)
+	copy(buf.Addr[:], ip16)
+	if uaddr.Zone != "" {
+		if ifi, err := net.InterfaceByName(uaddr.Zone); err == nil {
+			buf.Scope_id = uint32(ifi.Index)
+		} else if idx, err := strconv.Atoi(uaddr.Zone); err == nil {
+			buf.Scope_id = uint32(idx)
+		}
+	}
+	return unix.SizeofSockaddrInet6
+}
+
+// Returns the UDP address in the socket address, with IPv4-mapped addresses in the IPv4 form.
+func parseSockaddr(buf *unix.RawSockaddrInet6) net.Addr {
+	switch buf.Family {
+	case unix.AF_INET:
+		sa := (*unix.RawSockaddrInet4)(unsafe.Pointer(buf))
+
+		return &net.UDPAddr{
+			IP:   net.IP(append([]byte(nil), sa.Addr[:]...)),
+			Port: getPort(&sa.Port),
+		}
+	case unix.AF_INET6:
+		ip := net.IP(append([]byte(nil), buf.Addr[:]...))
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+
+		var zone string
+		if buf.Scope_id != 0 {
+			zone = strconv.Itoa(int(buf.Scope_id))
+		}
+		return &net.UDPAddr{
+			IP:   ip,
+			Port: getPort(&buf.Port),
+			Zone: zone,
+		}
+	}
+	return nil
+}
+
+// The port of a socket address is stored in network byte order.
+func putPort(p *uint16, port int) {
+	b := (*[2]byte)(unsafe.Pointer(p))
+	b[0] = byte(port >> 8)
+	b[1] = byte(port)
+}
+
+func getPort(p *uint16) int {
+	b := (*[2]byte)(unsafe.Pointer(p))
+	return int(b[0])<<8 | int(b[1])
+}
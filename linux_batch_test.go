@@ -0,0 +1,125 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build linux
+
+package resolve
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestBatchConn(t *testing.T) {
+	conn, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		t.Fatalf("unable to create the batching socket: %v", err)
+	}
+	defer conn.Close()
+
+	b := newBatchConn(conn)
+	if b == nil {
+		t.Fatalf("failed to create the batch connection")
+	}
+
+	peer, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to create the peer socket: %v", err)
+	}
+	defer peer.Close()
+
+	var dgrams []*datagram
+	for i := 0; i < batchSize+2; i++ {
+		dgrams = append(dgrams, &datagram{
+			b:    []byte(fmt.Sprintf("datagram %d", i)),
+			addr: peer.LocalAddr(),
+		})
+	}
+	for pending := dgrams; len(pending) > 0; {
+		n, err := b.writeBatch(pending)
+		if err != nil {
+			t.Fatalf("failed to write the batch: %v", err)
+		}
+		pending = pending[n:]
+	}
+
+	buf := make([]byte, 512)
+	_ = peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for i := range dgrams {
+		n, _, err := peer.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("the peer only received %d datagrams: %v", i, err)
+		}
+		if got := string(buf[:n]); got != string(dgrams[i].b) {
+			t.Errorf("Got: %s; Expected: %s", got, string(dgrams[i].b))
+		}
+	}
+
+	_, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+	dst, _ := net.ResolveUDPAddr("udp", "127.0.0.1:"+port)
+	for i := 0; i < 3; i++ {
+		if _, err := peer.WriteTo([]byte(fmt.Sprintf("response %d", i)), dst); err != nil {
+			t.Fatalf("the peer failed to write: %v", err)
+		}
+	}
+
+	recv := make([]*datagram, batchSize)
+	for i := range recv {
+		recv[i] = &datagram{b: make([]byte, 512)}
+	}
+
+	var got []string
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for len(got) < 3 {
+		n, err := b.readBatch(recv)
+		if err != nil {
+			t.Fatalf("failed to read the batch after %d datagrams: %v", len(got), err)
+		}
+		for _, d := range recv[:n] {
+			if d.addr.String() != peer.LocalAddr().String() {
+				t.Errorf("Got the address %s; Expected: %s", d.addr, peer.LocalAddr())
+			}
+			got = append(got, string(d.b[:d.n]))
+		}
+	}
+	for i, s := range got {
+		if expected := fmt.Sprintf("response %d", i); s != expected {
+			t.Errorf("Got: %s; Expected: %s", s, expected)
+		}
+	}
+}
+
+func TestBatchSockaddr(t *testing.T) {
+	cases := []struct {
+		family int
+		addr   string
+		want   uint32
+	}{
+		{unix.AF_INET6, "192.168.1.1:53", unix.SizeofSockaddrInet6},
+		{unix.AF_INET6, "[2001:db8::1]:5353", unix.SizeofSockaddrInet6},
+		{unix.AF_INET, "192.168.1.1:53", unix.SizeofSockaddrInet4},
+		{unix.AF_INET, "[2001:db8::1]:53", 0},
+	}
+
+	for _, c := range cases {
+		b := &batchConn{family: c.family}
+		addr, _ := net.ResolveUDPAddr("udp", c.addr)
+
+		var buf unix.RawSockaddrInet6
+		if got := b.putSockaddr(&buf, addr); got != c.want {
+			t.Errorf("%s: Got the length %d; Expected: %d", c.addr, got, c.want)
+			continue
+		}
+		if c.want == 0 {
+			continue
+		}
+		if got := parseSockaddr(&buf); got.String() != addr.String() {
+			t.Errorf("Got: %s; Expected: %s", got, addr)
+		}
+	}
+}
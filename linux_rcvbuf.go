@@ -0,0 +1,75 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build linux
+
+package resolve
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Returns the inode identifying the socket in the proc filesystem, or zero when not available.
+func socketInode(conn net.PacketConn) uint64 {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return 0
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0
+	}
+
+	var st unix.Stat_t
+	var serr error
+	if err := raw.Control(func(fd uintptr) {
+		serr = unix.Fstat(int(fd), &st)
+	}); err != nil || serr != nil {
+		return 0
+	}
+	return uint64(st.Ino)
+}
+
+// Returns the drop counters of the UDP sockets on the host keyed by inode.
+func udpSocketDrops() (map[uint64]uint64, error) {
+	counts := make(map[uint64]uint64)
+
+	for _, path := range []string{"/proc/net/udp", "/proc/net/udp6"} {
+		f, err := os.Open(path)
+		if err != nil {
+			if path == "/proc/net/udp6" && os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(f)
+		// Skip the header
+		scanner.Scan()
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 13 {
+				continue
+			}
+
+			inode, err := strconv.ParseUint(fields[9], 10, 64)
+			if err != nil {
+				continue
+			}
+			if drops, err := strconv.ParseUint(fields[12], 10, 64); err == nil {
+				counts[inode] = drops
+			}
+		}
+		f.Close()
+	}
+	return counts, nil
+}
@@ -0,0 +1,52 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build linux
+
+package resolve
+
+import (
+	"net"
+	"testing"
+)
+
+func TestReceiveDrops(t *testing.T) {
+	sock, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to create the socket: %v", err)
+	}
+	defer sock.Close()
+
+	conns := &connections{drops: newDropCounter()}
+	setReadBuffer(sock, 1024)
+	conns.trackDrops(sock)
+	if len(conns.drops.inodes) != 1 {
+		t.Fatalf("The socket inode was not obtained")
+	}
+	if _, err := udpSocketDrops(); err != nil {
+		t.Skipf("the UDP socket table is not available: %v", err)
+	}
+
+	sender, err := net.Dial("udp4", sock.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("unable to create the sending socket: %v", err)
+	}
+	defer sender.Close()
+
+	// Overflow the receive buffer of the socket that is never read
+	payload := make([]byte, 512)
+	for i := 0; i < 1000; i++ {
+		_, _ = sender.Write(payload)
+	}
+
+	if drops := conns.receiveDrops(); drops == 0 {
+		t.Errorf("Expected the kernel to report dropped datagrams")
+	}
+
+	sock.Close()
+	conns.pollDrops()
+	if len(conns.drops.inodes) != 0 {
+		t.Errorf("The closed socket was still tracked")
+	}
+}
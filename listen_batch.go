@@ -0,0 +1,27 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !linux
+
+package resolve
+
+import (
+	"errors"
+	"net"
+)
+
+// batchConn is only provided on Linux, so the connections fall back to a datagram per call.
+type batchConn struct{}
+
+func newBatchConn(conn net.PacketConn) *batchConn {
+	return nil
+}
+
+func (b *batchConn) writeBatch(dgrams []*datagram) (int, error) {
+	return 0, errors.ErrUnsupported
+}
+
+func (b *batchConn) readBatch(dgrams []*datagram) (int, error) {
+	return 0, errors.ErrUnsupported
+}
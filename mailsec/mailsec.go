@@ -0,0 +1,224 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package mailsec resolves and parses the SPF, DKIM and DMARC records that make up the email
+// security policy of a domain.
+package mailsec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+)
+
+// The SPF terms that cause DNS lookups, which are limited to ten by RFC 7208.
+var spfLookupTerms = []string{"include", "a", "mx", "ptr", "exists", "redirect"}
+
+// SPFPolicy is the structured form of the SPF record published for a domain. All contains the
+// qualifier of the "all" mechanism, and Lookups the number of terms requiring DNS queries.
+type SPFPolicy struct {
+	Record   string
+	Terms    []*resolve.SPFTerm
+	All      string
+	Includes []string
+	Redirect string
+	Lookups  int
+}
+
+// DMARCPolicy is the structured form of the DMARC record published for a domain (RFC 7489).
+// The defaults defined by the RFC are applied to the optional tags.
+type DMARCPolicy struct {
+	Record          string
+	Policy          string
+	SubdomainPolicy string
+	Percent         int
+	ADKIM           string
+	ASPF            string
+	RUA             []string
+	RUF             []string
+}
+
+// DKIMKey is the structured form of the DKIM key record published for a selector (RFC 6376).
+type DKIMKey struct {
+	Selector string
+	Record   string
+	Version  string
+	KeyType  string
+	Key      []byte
+	Revoked  bool
+	Testing  bool
+}
+
+// Policy contains the email security records found for a domain. The fields are nil, or
+// empty, when the corresponding records are not published.
+type Policy struct {
+	Domain string
+	SPF    *SPFPolicy
+	DMARC  *DMARCPolicy
+	DKIM   []*DKIMKey
+}
+
+// Lookup uses the resolver pool to obtain and parse the SPF and DMARC records for the domain,
+// along with the DKIM key records for each of the provided selectors.
+func Lookup(ctx context.Context, r *resolve.Resolvers, domain string, selectors ...string) *Policy {
+	domain = strings.ToLower(resolve.RemoveLastDot(domain))
+	policy := &Policy{Domain: domain}
+
+	for _, txt := range txtLookup(ctx, r, domain) {
+		if spf, err := ParseSPFPolicy(txt); err == nil {
+			policy.SPF = spf
+			break
+		}
+	}
+	for _, txt := range txtLookup(ctx, r, "_dmarc."+domain) {
+		if dmarc, err := ParseDMARC(txt); err == nil {
+			policy.DMARC = dmarc
+			break
+		}
+	}
+	for _, sel := range selectors {
+		for _, txt := range txtLookup(ctx, r, sel+"._domainkey."+domain) {
+			if key, err := ParseDKIMKey(txt); err == nil {
+				key.Selector = sel
+				policy.DKIM = append(policy.DKIM, key)
+				break
+			}
+		}
+	}
+	return policy
+}
+
+// ParseSPFPolicy returns the structured form of the SPF record in the provided text.
+func ParseSPFPolicy(txt string) (*SPFPolicy, error) {
+	terms, err := resolve.ParseSPF(txt)
+	if err != nil {
+		return nil, err
+	}
+
+	spf := &SPFPolicy{
+		Record: txt,
+		Terms:  terms,
+	}
+	for _, term := range terms {
+		switch term.Name {
+		case "all":
+			spf.All = term.Qualifier
+		case "include":
+			spf.Includes = append(spf.Includes, strings.ToLower(term.Value))
+		case "redirect":
+			spf.Redirect = strings.ToLower(term.Value)
+		}
+		if slices.Contains(spfLookupTerms, term.Name) {
+			spf.Lookups++
+		}
+	}
+	return spf, nil
+}
+
+// ParseDMARC returns the structured form of the DMARC record in the provided text.
+func ParseDMARC(txt string) (*DMARCPolicy, error) {
+	tags := resolve.TagValues(txt)
+	if !strings.EqualFold(tags["v"], "DMARC1") {
+		return nil, errors.New("ParseDMARC: the text is not a DMARC record")
+	}
+
+	policy, found := tags["p"]
+	if !found {
+		return nil, errors.New("ParseDMARC: the record does not have a policy tag")
+	}
+
+	dmarc := &DMARCPolicy{
+		Record:          txt,
+		Policy:          strings.ToLower(policy),
+		SubdomainPolicy: strings.ToLower(policy),
+		Percent:         100,
+		ADKIM:           "r",
+		ASPF:            "r",
+		RUA:             dmarcURIs(tags["rua"]),
+		RUF:             dmarcURIs(tags["ruf"]),
+	}
+	if sp, found := tags["sp"]; found {
+		dmarc.SubdomainPolicy = strings.ToLower(sp)
+	}
+	if pct, found := tags["pct"]; found {
+		n, err := strconv.Atoi(pct)
+		if err != nil || n < 0 || n > 100 {
+			return nil, fmt.Errorf("ParseDMARC: %s is not a valid percentage", pct)
+		}
+		dmarc.Percent = n
+	}
+	if adkim, found := tags["adkim"]; found {
+		dmarc.ADKIM = strings.ToLower(adkim)
+	}
+	if aspf, found := tags["aspf"]; found {
+		dmarc.ASPF = strings.ToLower(aspf)
+	}
+	return dmarc, nil
+}
+
+// ParseDKIMKey returns the structured form of the DKIM key record in the provided text.
+func ParseDKIMKey(txt string) (*DKIMKey, error) {
+	tags := resolve.TagValues(txt)
+	if _, found := tags["p"]; !found {
+		return nil, errors.New("ParseDKIMKey: the text is not a DKIM key record")
+	}
+
+	key := &DKIMKey{
+		Record:  txt,
+		Version: "DKIM1",
+		KeyType: "rsa",
+	}
+	if v, found := tags["v"]; found {
+		if v != "DKIM1" {
+			return nil, fmt.Errorf("ParseDKIMKey: %s is not a supported version", v)
+		}
+		key.Version = v
+	}
+	if k, found := tags["k"]; found {
+		key.KeyType = strings.ToLower(k)
+	}
+	for _, flag := range strings.Split(tags["t"], ":") {
+		if strings.EqualFold(flag, "y") {
+			key.Testing = true
+		}
+	}
+
+	if tags["p"] == "" {
+		key.Revoked = true
+		return key, nil
+	}
+
+	data, err := resolve.DecodeDKIMKey(txt)
+	if err != nil {
+		return nil, err
+	}
+	key.Key = data
+	return key, nil
+}
+
+// Returns the URIs in the comma-separated list of a DMARC reporting tag.
+func dmarcURIs(value string) []string {
+	var uris []string
+
+	for _, uri := range strings.Split(value, ",") {
+		if uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
+
+// Returns the reassembled TXT records for the name, as obtained through the resolver pool.
+func txtLookup(ctx context.Context, r *resolve.Resolvers, name string) []string {
+	if resp, err := r.QueryWithRetries(ctx, resolve.QueryMsg(name, dns.TypeTXT)); err == nil && resp.Rcode == dns.RcodeSuccess {
+		return resolve.TXTRecords(resp)
+	}
+	return nil
+}
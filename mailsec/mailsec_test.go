@@ -0,0 +1,156 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package mailsec
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+)
+
+func TestLookup(t *testing.T) {
+	records := map[string][]string{
+		"mail.net.": {
+			`mail.net. 300 IN TXT "google-site-verification=abcdef"`,
+			`mail.net. 300 IN TXT "v=spf1 include:_spf.mail.net " "mx -all"`,
+		},
+		"_dmarc.mail.net.":               {`_dmarc.mail.net. 300 IN TXT "v=DMARC1; p=quarantine; rua=mailto:dmarc@mail.net"`},
+		"s1._domainkey.mail.net.":        {`s1._domainkey.mail.net. 300 IN TXT "v=DKIM1; k=rsa; p=aGVsbG8gd29ybGQ="`},
+		"revoked._domainkey.mail.net.":   {`revoked._domainkey.mail.net. 300 IN TXT "v=DKIM1; p="`},
+		"unrelated._domainkey.mail.net.": {`unrelated._domainkey.mail.net. 300 IN TXT "some text"`},
+	}
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc("mail.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		if list, found := records[req.Question[0].Name]; found && req.Question[0].Qtype == dns.TypeTXT {
+			for _, s := range list {
+				if rr, err := dns.NewRR(s); err == nil {
+					m.Answer = append(m.Answer, rr)
+				}
+			}
+		} else if !found {
+			m.Rcode = dns.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, err := runLocalUDPServer(mux)
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := resolve.NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	policy := Lookup(context.Background(), r, "Mail.net.", "s1", "revoked", "unrelated", "missing")
+	if policy.Domain != "mail.net" {
+		t.Errorf("unexpected domain: %s", policy.Domain)
+	}
+	if spf := policy.SPF; spf == nil || spf.All != "-" || spf.Lookups != 2 || !reflect.DeepEqual(spf.Includes, []string{"_spf.mail.net"}) {
+		t.Errorf("unexpected SPF policy: %+v", spf)
+	}
+	if dmarc := policy.DMARC; dmarc == nil || dmarc.Policy != "quarantine" || dmarc.SubdomainPolicy != "quarantine" || len(dmarc.RUA) != 1 {
+		t.Errorf("unexpected DMARC policy: %+v", dmarc)
+	}
+	if len(policy.DKIM) != 2 {
+		t.Fatalf("expected 2 DKIM keys, got %d", len(policy.DKIM))
+	}
+	if key := policy.DKIM[0]; key.Selector != "s1" || string(key.Key) != "hello world" || key.Revoked {
+		t.Errorf("unexpected DKIM key: %+v", key)
+	}
+	if key := policy.DKIM[1]; key.Selector != "revoked" || !key.Revoked {
+		t.Errorf("the revoked DKIM key was not reported: %+v", key)
+	}
+
+	if policy := Lookup(context.Background(), r, "www.mail.net"); policy.SPF != nil || policy.DMARC != nil {
+		t.Errorf("policies were returned for a name without records: %+v", policy)
+	}
+}
+
+func TestParseDMARC(t *testing.T) {
+	dmarc, err := ParseDMARC("v=DMARC1; p=Reject; sp=none; pct=50; adkim=s; aspf=S; rua=mailto:a@caffix.net,mailto:b@caffix.net; ruf=mailto:c@caffix.net")
+	if err != nil {
+		t.Fatalf("failed to parse the DMARC record: %v", err)
+	}
+
+	expected := &DMARCPolicy{
+		Policy:          "reject",
+		SubdomainPolicy: "none",
+		Percent:         50,
+		ADKIM:           "s",
+		ASPF:            "s",
+		RUA:             []string{"mailto:a@caffix.net", "mailto:b@caffix.net"},
+		RUF:             []string{"mailto:c@caffix.net"},
+	}
+	expected.Record = dmarc.Record
+	if !reflect.DeepEqual(dmarc, expected) {
+		t.Errorf("Got: %+v; Expected: %+v", dmarc, expected)
+	}
+
+	for _, txt := range []string{"v=spf1 -all", "v=DMARC1; rua=mailto:a@caffix.net", "v=DMARC1; p=none; pct=150"} {
+		if _, err := ParseDMARC(txt); err == nil {
+			t.Errorf("%q was parsed as a DMARC record", txt)
+		}
+	}
+}
+
+func TestParseDKIMKey(t *testing.T) {
+	key, err := ParseDKIMKey("k=ed25519; t=y:s; p=aGVsbG8gd29ybGQ=")
+	if err != nil {
+		t.Fatalf("failed to parse the DKIM key record: %v", err)
+	}
+	if key.Version != "DKIM1" || key.KeyType != "ed25519" || !key.Testing || string(key.Key) != "hello world" {
+		t.Errorf("unexpected DKIM key: %+v", key)
+	}
+
+	for _, txt := range []string{"v=spf1 -all", "v=DKIM2; p=aGVsbG8gd29ybGQ=", "v=DKIM1; p=!invalid!"} {
+		if _, err := ParseDKIMKey(txt); err == nil {
+			t.Errorf("%q was parsed as a DKIM key record", txt)
+		}
+	}
+}
+
+func TestParseSPFPolicy(t *testing.T) {
+	spf, err := ParseSPFPolicy("v=spf1 a mx include:_spf.caffix.net include:_Spf.Owasp.org ip4:192.168.1.0/24 redirect=_spf.caffix.net")
+	if err != nil {
+		t.Fatalf("failed to parse the SPF record: %v", err)
+	}
+	if spf.All != "" || spf.Redirect != "_spf.caffix.net" || spf.Lookups != 5 || len(spf.Terms) != 6 {
+		t.Errorf("unexpected SPF policy: %+v", spf)
+	}
+	if !reflect.DeepEqual(spf.Includes, []string{"_spf.caffix.net", "_spf.owasp.org"}) {
+		t.Errorf("unexpected includes: %v", spf.Includes)
+	}
+	if _, err := ParseSPFPolicy("v=DMARC1; p=none"); err == nil {
+		t.Errorf("a DMARC record was parsed as an SPF record")
+	}
+}
+
+func runLocalUDPServer(handler dns.Handler) (*dns.Server, string, error) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, "", err
+	}
+
+	started := make(chan struct{})
+	server := &dns.Server{
+		PacketConn:        pc,
+		Handler:           handler,
+		NotifyStartedFunc: func() { close(started) },
+	}
+	go func() { _ = server.ActivateAndServe() }()
+
+	<-started
+	return server, pc.LocalAddr().String(), nil
+}
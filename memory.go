@@ -0,0 +1,155 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+const (
+	// The approximate number of bytes held for each request queued or in flight.
+	approxRequestSize uint64 = 1024
+	// The interval between the checks of the heap performed by the memory watchdog.
+	memoryCheckInterval = 100 * time.Millisecond
+	// The runtime metric providing the bytes occupied by live and unswept heap objects.
+	heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+)
+
+// MemoryUsage is an approximate accounting of the memory used by the resolver pool.
+type MemoryUsage struct {
+	// Queued is the number of requests waiting to be sent.
+	Queued int
+	// InFlight is the number of requests sent that have not been answered or expired.
+	InFlight int
+	// Estimated is the approximate number of bytes held for the queued and in-flight requests.
+	Estimated uint64
+	// Heap is the number of bytes occupied by heap objects in the process.
+	Heap uint64
+	// Budget is the memory budget of the pool, or zero when no budget has been set.
+	Budget uint64
+}
+
+// memoryWatchdog compares the heap size against the budget, and provides the channel
+// used by callers to wait until the usage is back under the budget.
+type memoryWatchdog struct {
+	sync.Mutex
+	budget  uint64
+	over    bool
+	under   chan struct{}
+	running bool
+}
+
+func newMemoryWatchdog() *memoryWatchdog {
+	under := make(chan struct{})
+	close(under)
+	return &memoryWatchdog{under: under}
+}
+
+// SetMemoryBudget sets the number of bytes of heap the process should stay beneath. Once the
+// budget is exceeded, OverMemoryBudget returns true and WaitForMemory blocks, so callers can apply
+// backpressure by not submitting new queries. A value of zero removes the budget.
+func (r *Resolvers) SetMemoryBudget(budget uint64) {
+	r.mem.Lock()
+	defer r.mem.Unlock()
+
+	r.mem.budget = budget
+	if budget == 0 {
+		r.mem.setOver(false)
+		return
+	}
+	if !r.mem.running {
+		r.mem.running = true
+		go r.memoryWatchdog()
+	}
+}
+
+// OverMemoryBudget returns true when the memory used by the process exceeds the budget.
+func (r *Resolvers) OverMemoryBudget() bool {
+	r.mem.Lock()
+	defer r.mem.Unlock()
+
+	return r.mem.over
+}
+
+// WaitForMemory blocks until the memory used by the process is within the budget.
+func (r *Resolvers) WaitForMemory(ctx context.Context) error {
+	r.mem.Lock()
+	under := r.mem.under
+	r.mem.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return errors.New("the context expired")
+	case <-r.done:
+		return errors.New("the resolver pool has been stopped")
+	case <-under:
+	}
+	return nil
+}
+
+// MemoryUsage returns the approximate accounting of the memory used by the resolver pool.
+func (r *Resolvers) MemoryUsage() *MemoryUsage {
+	usage := &MemoryUsage{
+		Queued: r.queue.Len(),
+		Heap:   heapBytes(),
+	}
+
+	for _, res := range r.allResolvers(r.getDetectionResolvers()...) {
+		usage.Queued += res.queue.Len()
+		usage.InFlight += res.xchgs.len()
+	}
+	usage.Estimated = uint64(usage.Queued+usage.InFlight) * approxRequestSize
+
+	r.mem.Lock()
+	usage.Budget = r.mem.budget
+	r.mem.Unlock()
+	return usage
+}
+
+func (r *Resolvers) memoryWatchdog() {
+	t := time.NewTicker(memoryCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-t.C:
+		}
+
+		heap := heapBytes()
+		r.mem.Lock()
+		r.mem.setOver(r.mem.budget > 0 && heap > r.mem.budget)
+		r.mem.Unlock()
+	}
+}
+
+// Updates the state of the watchdog. Must be called holding the lock.
+func (m *memoryWatchdog) setOver(over bool) {
+	if over == m.over {
+		return
+	}
+
+	m.over = over
+	if over {
+		m.under = make(chan struct{})
+	} else {
+		close(m.under)
+	}
+}
+
+func heapBytes() uint64 {
+	sample := []metrics.Sample{{Name: heapObjectsMetric}}
+
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
@@ -0,0 +1,68 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	if r.OverMemoryBudget() {
+		t.Errorf("the pool was over the memory budget before one was set")
+	}
+
+	r.SetMemoryBudget(1)
+	time.Sleep(3 * memoryCheckInterval)
+	if !r.OverMemoryBudget() {
+		t.Fatalf("the pool was not over the memory budget of one byte")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := r.WaitForMemory(ctx); err == nil {
+		t.Errorf("WaitForMemory returned while the pool was over the budget")
+	}
+
+	r.SetMemoryBudget(0)
+	if r.OverMemoryBudget() || r.WaitForMemory(context.Background()) != nil {
+		t.Errorf("the pool remained over the budget after it was removed")
+	}
+}
+
+func TestMemoryUsage(t *testing.T) {
+	// The server never responds, so the requests remain in flight
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to create the UDP listener: %v", err)
+	}
+	defer conn.Close()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, conn.LocalAddr().String())
+	defer r.Stop()
+
+	num := 5
+	ch := make(chan *dns.Msg, num)
+	for i := 0; i < num; i++ {
+		r.Query(context.Background(), QueryMsg("caffix.net", dns.TypeA), ch)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	usage := r.MemoryUsage()
+	if usage.Queued+usage.InFlight != num {
+		t.Errorf("expected %d requests to be accounted for, got %d", num, usage.Queued+usage.InFlight)
+	}
+	if usage.Estimated != uint64(num)*approxRequestSize || usage.Heap == 0 {
+		t.Errorf("the memory usage was not estimated: %+v", usage)
+	}
+}
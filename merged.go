@@ -0,0 +1,91 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// MergedRecord is a resource record observed during repeated queries for the same name,
+// along with the number of responses that contained the record.
+type MergedRecord struct {
+	RR    dns.RR
+	Count int
+}
+
+// MergedResult contains the union of the answers collected across repeated queries.
+type MergedResult struct {
+	Name      string
+	Qtype     uint16
+	Responses int
+	Records   []*MergedRecord
+}
+
+// QueryMerged sends the query for the provided name and type n times, allowing the pool to
+// select a different resolver for each attempt, and merges the union of the answers into a
+// single result. This is helpful when load balancers return a subset of the addresses per query.
+// The queries bypass the cache of the pool, since a cached response would only repeat the subset
+// obtained by an earlier query, but the responses received are still added to the cache.
+func (r *Resolvers) QueryMerged(ctx context.Context, name string, qtype uint16, n int) (*MergedResult, error) {
+	if n <= 0 {
+		return nil, errors.New("the number of queries must be greater than zero")
+	}
+
+	ctx = withoutCache(ctx)
+	ch := make(chan *dns.Msg, n)
+	for i := 0; i < n; i++ {
+		r.Query(ctx, QueryMsg(name, qtype), ch)
+	}
+
+	result := &MergedResult{
+		Name:  strings.ToLower(RemoveLastDot(name)),
+		Qtype: qtype,
+	}
+	index := make(map[string]*MergedRecord)
+	for i := 0; i < n; i++ {
+		resp := <-ch
+		if resp == nil || resp.Rcode != dns.RcodeSuccess {
+			continue
+		}
+
+		result.Responses++
+		seen := make(map[string]struct{})
+		for _, rr := range resp.Answer {
+			if rr.Header().Rrtype != qtype {
+				continue
+			}
+
+			key := mergeKey(rr)
+			if _, found := seen[key]; found {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			if rec, found := index[key]; found {
+				rec.Count++
+				continue
+			}
+			rec := &MergedRecord{RR: rr, Count: 1}
+			index[key] = rec
+			result.Records = append(result.Records, rec)
+		}
+	}
+
+	if result.Responses == 0 {
+		return result, errors.New("no successful responses were received")
+	}
+	return result, nil
+}
+
+// The key ignores the TTL, since it can differ between resolvers for the same record.
+func mergeKey(rr dns.RR) string {
+	c := dns.Copy(rr)
+	c.Header().Ttl = 0
+	return c.String()
+}
@@ -0,0 +1,130 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestQueryMerged(t *testing.T) {
+	var counter uint32
+	name := "balanced.net."
+	dns.HandleFunc(name, func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		n := atomic.AddUint32(&counter, 1)
+		for _, last := range []uint32{n % 4, (n + 1) % 4} {
+			m.Answer = append(m.Answer, &dns.A{
+				Hdr: dns.RR_Header{
+					Name:   m.Question[0].Name,
+					Rrtype: dns.TypeA,
+					Class:  dns.ClassINET,
+					Ttl:    n,
+				},
+				A: net.ParseIP(fmt.Sprintf("192.168.1.%d", last+1)),
+			})
+		}
+		_ = w.WriteMsg(m)
+	})
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	if _, err := r.QueryMerged(context.Background(), name, dns.TypeA, 0); err == nil {
+		t.Errorf("failed to return an error when requesting zero queries")
+	}
+
+	result, err := r.QueryMerged(context.Background(), name, dns.TypeA, 8)
+	if err != nil {
+		t.Fatalf("the merged query failed: %v", err)
+	}
+	if result.Name != "balanced.net" || result.Qtype != dns.TypeA {
+		t.Errorf("the merged result did not identify the query: %s %d", result.Name, result.Qtype)
+	}
+	if len(result.Records) != 4 {
+		t.Errorf("expected the union of 4 records, got %d", len(result.Records))
+	}
+
+	var total int
+	for _, rec := range result.Records {
+		total += rec.Count
+	}
+	if total != 2*result.Responses {
+		t.Errorf("the occurrence counts totaled %d for %d responses", total, result.Responses)
+	}
+}
+
+func TestQueryMergedCache(t *testing.T) {
+	var counter uint32
+	name := "cached.balanced.net."
+	dns.HandleFunc(name, func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		n := atomic.AddUint32(&counter, 1)
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{
+				Name:   m.Question[0].Name,
+				Rrtype: dns.TypeA,
+				Class:  dns.ClassINET,
+				Ttl:    300,
+			},
+			A: net.ParseIP(fmt.Sprintf("192.168.1.%d", n%4+1)),
+		})
+		_ = w.WriteMsg(m)
+	})
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	r.SetCache(NewCache())
+	defer r.Stop()
+
+	// The response to the first query is added to the cache
+	if resp, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA)); err != nil || len(resp.Answer) != 1 {
+		t.Fatalf("the query failed: %v", err)
+	}
+
+	result, err := r.QueryMerged(context.Background(), name, dns.TypeA, 8)
+	if err != nil {
+		t.Fatalf("the merged query failed: %v", err)
+	}
+	if n := atomic.LoadUint32(&counter); n != 9 {
+		t.Errorf("expected the merged queries to bypass the cache, but the server received %d queries", n)
+	}
+	if len(result.Records) != 4 {
+		t.Errorf("expected the union of 4 records, got %d", len(result.Records))
+	}
+
+	// The other queries are still answered from the cache
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA))
+	if err != nil {
+		t.Fatalf("the query failed: %v", err)
+	}
+	if src := SourceOf(resp); src == nil || src.Transport != TransportCache {
+		t.Errorf("the query was not answered from the cache: %+v", src)
+	}
+}
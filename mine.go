@@ -0,0 +1,75 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// The SPF mechanisms and modifiers that reference a domain name.
+var spfNameTerms = []string{"include", "a", "mx", "ptr", "exists", "redirect", "exp"}
+
+// MineNames returns the unique hostnames referenced by the records in the answer section of
+// the provided message, including SPF includes in TXT records and the targets of MX, SRV and
+// CNAME records. The names can be resolved to expand coverage without additional inputs.
+func MineNames(msg *dns.Msg) []string {
+	var names []string
+
+	for _, txt := range TXTRecords(msg) {
+		names = append(names, spfNames(txt)...)
+	}
+	for _, mx := range Answers[*dns.MX](msg) {
+		names = append(names, mx.Mx)
+	}
+	for _, srv := range Answers[*dns.SRV](msg) {
+		names = append(names, srv.Target)
+	}
+	names = append(names, CNAMETargets(msg)...)
+
+	var unique []string
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		name = strings.ToLower(RemoveLastDot(strings.TrimSpace(name)))
+		if name == "" {
+			continue
+		}
+		if _, ok := dns.IsDomainName(name); !ok {
+			continue
+		}
+		if _, found := set[name]; !found {
+			set[name] = struct{}{}
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
+
+// Returns the domain names referenced by the mechanisms and modifiers of an SPF record.
+func spfNames(txt string) []string {
+	terms, err := ParseSPF(txt)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, term := range terms {
+		if !slices.Contains(spfNameTerms, term.Name) {
+			continue
+		}
+
+		name := strings.ToLower(term.Value)
+		// Remove the CIDR lengths and skip names built using macros
+		if i := strings.Index(name, "/"); i >= 0 {
+			name = name[:i]
+		}
+		if name != "" && !strings.Contains(name, "%") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,65 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestMineNames(t *testing.T) {
+	msg := QueryMsg("caffix.net", dns.TypeANY)
+	for _, s := range []string{
+		`caffix.net. 300 IN TXT "v=spf1 include:_spf.caffix.net ~include:Mail.Caffix.net a:web.caffix.net/24 exists:%{i}.spf.caffix.net -all"`,
+		`caffix.net. 300 IN TXT "google-site-verification=abcdef"`,
+		`caffix.net. 300 IN MX 10 mx1.caffix.net.`,
+		`_sip._tcp.caffix.net. 300 IN SRV 10 5 5060 sip.caffix.net.`,
+		`www.caffix.net. 300 IN CNAME mx1.caffix.net.`,
+	} {
+		if rr, err := dns.NewRR(s); err == nil {
+			msg.Answer = append(msg.Answer, rr)
+		}
+	}
+
+	expected := []string{"_spf.caffix.net", "mail.caffix.net", "web.caffix.net", "mx1.caffix.net", "sip.caffix.net"}
+	if got := MineNames(msg); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Got: %v; Expected: %v", got, expected)
+	}
+	if got := MineNames(nil); len(got) != 0 {
+		t.Errorf("names were mined from a nil message")
+	}
+}
+
+func TestSPFNames(t *testing.T) {
+	cases := []struct {
+		label    string
+		txt      string
+		expected []string
+	}{
+		{
+			label: "Not an SPF record",
+			txt:   "include:caffix.net",
+		}, {
+			label:    "Redirect modifier",
+			txt:      "v=spf1 redirect=_spf.caffix.net",
+			expected: []string{"_spf.caffix.net"},
+		}, {
+			label:    "Mechanisms without domain names",
+			txt:      "V=SPF1 a mx ip4:192.168.1.0/24 mx:mail.caffix.net/24//64 ?all",
+			expected: []string{"mail.caffix.net"},
+		},
+	}
+
+	for _, c := range cases {
+		f := func(t *testing.T) {
+			if got := spfNames(c.txt); !reflect.DeepEqual(got, c.expected) {
+				t.Errorf("Got: %v; Expected: %v", got, c.expected)
+			}
+		}
+		t.Run(c.label, f)
+	}
+}
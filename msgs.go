@@ -5,6 +5,7 @@
 package resolve
 
 import (
+	"errors"
 	"net"
 	"strings"
 
@@ -20,6 +21,28 @@ func RemoveLastDot(name string) string {
 	return name
 }
 
+// Errors returned by ValidateMsg for messages that cannot be safely processed.
+var (
+	ErrNilMsg            = errors.New("the message is nil")
+	ErrNoQuestion        = errors.New("the message does not have a question")
+	ErrMultipleQuestions = errors.New("the message has multiple questions")
+)
+
+// ValidateMsg returns an error when the provided message does not contain exactly one question.
+func ValidateMsg(msg *dns.Msg) error {
+	if msg == nil {
+		return ErrNilMsg
+	}
+
+	switch len(msg.Question) {
+	case 0:
+		return ErrNoQuestion
+	case 1:
+		return nil
+	}
+	return ErrMultipleQuestions
+}
+
 // QueryMsg generates a message used for a forward DNS query.
 func QueryMsg(name string, qtype uint16) *dns.Msg {
 	m := new(dns.Msg)
@@ -68,6 +91,7 @@ func SetupOptions() *dns.OPT {
 type ExtractedAnswer struct {
 	Name string
 	Type uint16
+	TTL  uint32
 	Data string
 }
 
@@ -87,6 +111,25 @@ func AnswersByType(answers []*ExtractedAnswer, qtype uint16) []*ExtractedAnswer
 	return subset
 }
 
+// MinimumTTL returns the smallest TTL value found in the DNS Answer section of the provided Msg.
+// The second return value is false when the Answer section is empty.
+func MinimumTTL(msg *dns.Msg) (uint32, bool) {
+	var found bool
+	var ttl uint32
+
+	if msg == nil {
+		return ttl, found
+	}
+
+	for _, a := range msg.Answer {
+		if t := a.Header().Ttl; !found || t < ttl {
+			ttl = t
+			found = true
+		}
+	}
+	return ttl, found
+}
+
 // ExtractAnswers returns information from the DNS Answer section of the provided Msg in ExtractedAnswer type.
 func ExtractAnswers(msg *dns.Msg) []*ExtractedAnswer {
 	var data []*ExtractedAnswer
@@ -117,11 +160,12 @@ func ExtractAnswers(msg *dns.Msg) []*ExtractedAnswer {
 		case dns.TypeSRV:
 			value = parseSRVType(a)
 		}
-		if value != "" {
+		if value = strings.TrimSpace(value); value != "" {
 			data = append(data, &ExtractedAnswer{
 				Name: strings.ToLower(RemoveLastDot(a.Header().Name)),
 				Type: a.Header().Rrtype,
-				Data: strings.TrimSpace(value),
+				TTL:  a.Header().Ttl,
+				Data: value,
 			})
 		}
 	}
@@ -149,3 +149,81 @@ func TestExtractAnswers(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateMsg(t *testing.T) {
+	multiple := QueryMsg("caffix.net", dns.TypeA)
+	multiple.Question = append(multiple.Question, dns.Question{Name: "owasp.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET})
+
+	cases := []struct {
+		label string
+		msg   *dns.Msg
+		want  error
+	}{
+		{label: "nil message", msg: nil, want: ErrNilMsg},
+		{label: "no question", msg: new(dns.Msg), want: ErrNoQuestion},
+		{label: "one question", msg: QueryMsg("caffix.net", dns.TypeA), want: nil},
+		{label: "multiple questions", msg: multiple, want: ErrMultipleQuestions},
+	}
+
+	for _, c := range cases {
+		if err := ValidateMsg(c.msg); err != c.want {
+			t.Errorf("%s: got %v; expected %v", c.label, err, c.want)
+		}
+	}
+}
+
+func TestMinimumTTL(t *testing.T) {
+	if _, ok := MinimumTTL(nil); ok {
+		t.Errorf("a TTL was returned for a nil message")
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("caffix.net.", dns.TypeA)
+	for _, ttl := range []uint32{300, 60, 3600} {
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: "caffix.net.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl},
+			A:   net.ParseIP("192.168.1.1"),
+		})
+	}
+	if ttl, ok := MinimumTTL(m); !ok || ttl != 60 {
+		t.Errorf("MinimumTTL returned %d instead of the expected %d", ttl, 60)
+	}
+}
+
+func FuzzExtractAnswers(f *testing.F) {
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeCNAME, dns.TypeTXT, dns.TypeSOA} {
+		msg := QueryMsg("caffix.net", qtype)
+		msg.Response = true
+		if b, err := msg.Pack(); err == nil {
+			f.Add(b)
+		}
+	}
+	// A TXT record containing only whitespace
+	msg := QueryMsg("caffix.net", dns.TypeTXT)
+	msg.Response = true
+	msg.Answer = append(msg.Answer, &dns.TXT{
+		Hdr: dns.RR_Header{Name: "caffix.net.", Rrtype: dns.TypeTXT, Class: dns.ClassINET},
+		Txt: []string{" ", "  "},
+	})
+	if b, err := msg.Pack(); err == nil {
+		f.Add(b)
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		msg := new(dns.Msg)
+		if err := msg.Unpack(b); err != nil {
+			return
+		}
+
+		for _, a := range ExtractAnswers(msg) {
+			if a.Data == "" {
+				t.Errorf("ExtractAnswers returned an answer without data")
+			}
+		}
+		_, _ = MinimumTTL(msg)
+		_ = ExtractDenialProof(msg)
+		_ = IPs(msg)
+		_ = CNAMETargets(msg)
+		_ = TXTRecords(msg)
+	})
+}
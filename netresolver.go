@@ -0,0 +1,155 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// NetResolver returns a net.Resolver using the pure Go resolver, with the lookups sent through
+// the pool. This allows existing code performing lookups with the net package to benefit from
+// the resolver pool without changes beyond using the returned resolver.
+func (r *Resolvers) NetResolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial:     r.DialContext,
+	}
+}
+
+// DialContext implements the Dial hook of the net.Resolver type. The returned connection sends
+// the DNS messages written to it through the pool, and the network and address are ignored.
+func (r *Resolvers) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	select {
+	case <-r.done:
+		return nil, errors.New("DialContext: the resolver pool has been stopped")
+	default:
+	}
+
+	return &poolConn{
+		ctx:   ctx,
+		pool:  r,
+		resps: make(chan []byte, 2),
+		done:  make(chan struct{}),
+	}, nil
+}
+
+// The address reported for both ends of the connections returned by DialContext.
+var poolConnAddr = &net.UDPAddr{IP: net.IPv4zero}
+
+// poolConn implements net.Conn and net.PacketConn, so the Go resolver exchanges the DNS
+// messages without the length prefix used for streams.
+type poolConn struct {
+	sync.Mutex
+	ctx      context.Context
+	pool     *Resolvers
+	resps    chan []byte
+	done     chan struct{}
+	once     sync.Once
+	deadline time.Time
+}
+
+func (c *poolConn) Write(b []byte) (int, error) {
+	msg := new(dns.Msg)
+	if err := msg.Unpack(b); err != nil {
+		return 0, err
+	}
+	if err := ValidateMsg(msg); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	if d := c.getDeadline(); !d.IsZero() {
+		ctx, cancel = context.WithDeadline(c.ctx, d)
+	}
+
+	go func() {
+		defer cancel()
+
+		resp, err := c.pool.exchange(ctx, msg)
+		if err != nil {
+			return
+		}
+
+		if data, err := resp.Pack(); err == nil {
+			select {
+			case c.resps <- data:
+			case <-c.done:
+			}
+		}
+	}()
+	return len(b), nil
+}
+
+func (c *poolConn) Read(b []byte) (int, error) {
+	var expired <-chan time.Time
+	if d := c.getDeadline(); !d.IsZero() {
+		t := time.NewTimer(time.Until(d))
+		defer t.Stop()
+		expired = t.C
+	}
+
+	select {
+	case data := <-c.resps:
+		return copy(b, data), nil
+	case <-expired:
+		return 0, os.ErrDeadlineExceeded
+	case <-c.done:
+		return 0, net.ErrClosed
+	case <-c.ctx.Done():
+		return 0, c.ctx.Err()
+	}
+}
+
+func (c *poolConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, err := c.Read(b)
+	return n, poolConnAddr, err
+}
+
+func (c *poolConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.Write(b)
+}
+
+func (c *poolConn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return nil
+}
+
+func (c *poolConn) LocalAddr() net.Addr {
+	return poolConnAddr
+}
+
+func (c *poolConn) RemoteAddr() net.Addr {
+	return poolConnAddr
+}
+
+func (c *poolConn) SetDeadline(t time.Time) error {
+	c.Lock()
+	defer c.Unlock()
+
+	c.deadline = t
+	return nil
+}
+
+func (c *poolConn) SetReadDeadline(t time.Time) error {
+	return c.SetDeadline(t)
+}
+
+func (c *poolConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *poolConn) getDeadline() time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.deadline
+}
@@ -0,0 +1,94 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestNetResolver(t *testing.T) {
+	mux := dns.NewServeMux()
+	mux.HandleFunc("netres.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		q := req.Question[0]
+		switch {
+		case q.Name == "missing.netres.net.":
+			m.Rcode = dns.RcodeNameError
+		case q.Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, testARecord(q.Name, "192.0.2.1"))
+		case q.Qtype == dns.TypeAAAA:
+			m.Answer = append(m.Answer, &dns.AAAA{
+				Hdr:  dns.RR_Header{Name: q.Name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET},
+				AAAA: net.ParseIP("2001:db8::1"),
+			})
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	nr := r.NetResolver()
+	addrs, err := nr.LookupHost(ctx, "www.netres.net.")
+	sort.Strings(addrs)
+	if err != nil || len(addrs) != 2 || addrs[0] != "192.0.2.1" || addrs[1] != "2001:db8::1" {
+		t.Errorf("the lookup through the pool failed: %v %v", addrs, err)
+	}
+
+	var dnsErr *net.DNSError
+	if _, err := nr.LookupHost(ctx, "missing.netres.net."); !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("the nonexistent name was not reported: %v", err)
+	}
+}
+
+func TestPoolConnDeadline(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	conn, err := r.DialContext(context.Background(), "udp", "127.0.0.1:53")
+	if err != nil {
+		t.Fatalf("failed to dial the pool: %v", err)
+	}
+
+	if _, err := conn.Write([]byte{0x00, 0x01}); err == nil {
+		t.Errorf("a malformed message was accepted")
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 512)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("the read deadline was not enforced: %v", err)
+	}
+
+	_ = conn.Close()
+	_ = conn.SetDeadline(time.Time{})
+	if _, err := conn.Read(make([]byte, 512)); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("the read on the closed connection did not fail: %v", err)
+	}
+
+	r.Stop()
+	if _, err := r.DialContext(context.Background(), "udp", "127.0.0.1:53"); err == nil {
+		t.Errorf("a connection was returned by the stopped pool")
+	}
+}
@@ -0,0 +1,18 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build notls
+
+package resolve
+
+import (
+	"crypto/tls"
+	"errors"
+)
+
+// AddTLSResolvers returns an error, since the DNS-over-TLS transport was left out of the build
+// by the notls build tag.
+func (r *Resolvers) AddTLSResolvers(qps int, config *tls.Config, addrs ...string) error {
+	return errors.New("the DNS-over-TLS transport is not available in builds with the notls tag")
+}
@@ -0,0 +1,97 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"sort"
+	"strings"
+)
+
+// NameOrder determines the order in which a list of names is resolved.
+type NameOrder int
+
+// The name orders supported by OrderNames.
+const (
+	// InputOrder keeps the names in the order provided.
+	InputOrder NameOrder = iota
+	// ShuffleOrder places the names in a random order.
+	ShuffleOrder
+	// InterleaveOrder alternates between the registered domains, so consecutive names
+	// belong to different zones whenever possible.
+	InterleaveOrder
+	// DepthFirstOrder places each name before the names beneath it, keeping the
+	// names of a subtree together.
+	DepthFirstOrder
+	// BreadthFirstOrder places the names with fewer labels first, so each level of
+	// the namespace is resolved before the level beneath it.
+	BreadthFirstOrder
+)
+
+// OrderNames returns a copy of the names placed in the requested order.
+func OrderNames(names []string, order NameOrder) []string {
+	ordered := make([]string, len(names))
+	copy(ordered, names)
+
+	switch order {
+	case ShuffleOrder:
+		prng.Shuffle(len(ordered), func(i, j int) {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		})
+	case InterleaveOrder:
+		ordered = interleaveNames(ordered)
+	case DepthFirstOrder:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return reversedLabels(ordered[i]) < reversedLabels(ordered[j])
+		})
+	case BreadthFirstOrder:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return labelCount(ordered[i]) < labelCount(ordered[j])
+		})
+	}
+	return ordered
+}
+
+// Returns the names taken from each registered domain in turn, keeping the order in which
+// the domains and the names within them appeared.
+func interleaveNames(names []string) []string {
+	var zones []string
+	groups := make(map[string][]string)
+	for _, name := range names {
+		zone := selectorZone(name)
+		if _, found := groups[zone]; !found {
+			zones = append(zones, zone)
+		}
+		groups[zone] = append(groups[zone], name)
+	}
+
+	ordered := make([]string, 0, len(names))
+	for len(ordered) < len(names) {
+		for _, zone := range zones {
+			if group := groups[zone]; len(group) > 0 {
+				ordered = append(ordered, group[0])
+				groups[zone] = group[1:]
+			}
+		}
+	}
+	return ordered
+}
+
+// Returns the labels of the name from the root, separated by a character sorting before
+// the others allowed, so parents sort before their children.
+func reversedLabels(name string) string {
+	labels := strings.Split(strings.ToLower(RemoveLastDot(name)), ".")
+
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return strings.Join(labels, "\x00")
+}
+
+func labelCount(name string) int {
+	if name = RemoveLastDot(name); name == "" {
+		return 0
+	}
+	return strings.Count(name, ".") + 1
+}
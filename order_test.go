@@ -0,0 +1,64 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+var orderTestNames = []string{
+	"www.owasp.org",
+	"a.b.owasp.org",
+	"owasp.org",
+	"www.caffix.net",
+	"b.owasp.org",
+	"mail.caffix.net",
+	"ftp.owasp.org",
+	"example.com",
+}
+
+func TestOrderNames(t *testing.T) {
+	for _, test := range []struct {
+		order    NameOrder
+		expected []string
+	}{
+		{InputOrder, orderTestNames},
+		{InterleaveOrder, []string{
+			"www.owasp.org", "www.caffix.net", "example.com", "a.b.owasp.org",
+			"mail.caffix.net", "owasp.org", "b.owasp.org", "ftp.owasp.org",
+		}},
+		{DepthFirstOrder, []string{
+			"example.com", "mail.caffix.net", "www.caffix.net", "owasp.org",
+			"b.owasp.org", "a.b.owasp.org", "ftp.owasp.org", "www.owasp.org",
+		}},
+		{BreadthFirstOrder, []string{
+			"owasp.org", "example.com", "www.owasp.org", "www.caffix.net",
+			"b.owasp.org", "mail.caffix.net", "ftp.owasp.org", "a.b.owasp.org",
+		}},
+	} {
+		if got := OrderNames(orderTestNames, test.order); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("order %d returned %v; expected %v", test.order, got, test.expected)
+		}
+	}
+}
+
+func TestOrderNamesShuffle(t *testing.T) {
+	got := OrderNames(orderTestNames, ShuffleOrder)
+	if len(got) != len(orderTestNames) {
+		t.Fatalf("the shuffle returned %d names; expected %d", len(got), len(orderTestNames))
+	}
+
+	sorted := append([]string(nil), orderTestNames...)
+	sort.Strings(sorted)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, sorted) {
+		t.Errorf("the shuffle did not return the names provided: %v", got)
+	}
+	if orderTestNames[0] != "www.owasp.org" {
+		t.Errorf("the names provided were modified by the shuffle")
+	}
+}
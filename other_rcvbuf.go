@@ -0,0 +1,20 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !linux
+
+package resolve
+
+import (
+	"errors"
+	"net"
+)
+
+func socketInode(conn net.PacketConn) uint64 {
+	return 0
+}
+
+func udpSocketDrops() (map[uint64]uint64, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,73 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"encoding/hex"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+type packetFilter struct {
+	names   *regexp.Regexp
+	hexdump bool
+}
+
+// SetPacketLogging writes the messages sent and received for the names matched by the filter to
+// the logger of the pool, as hex dumps of the wire format when requested, or in the presentation
+// format otherwise. The names are matched without the trailing dot, and a nil filter disables it.
+func (r *Resolvers) SetPacketLogging(filter *regexp.Regexp, hexdump bool) {
+	if filter == nil {
+		r.packets.Store(nil)
+		return
+	}
+	r.packets.Store(&packetFilter{names: filter, hexdump: hexdump})
+}
+
+// GlobFilter returns a regular expression matching the names described by the glob pattern,
+// where "*" matches any sequence of characters and "?" matches a single character.
+func GlobFilter(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+
+	b.WriteString("(?i)^")
+	for _, c := range strings.ToLower(RemoveLastDot(glob)) {
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// Writes the message to the pool logger when the name in the question is matched by the filter.
+func (r *Resolvers) logPacket(direction string, addr net.Addr, msg *dns.Msg) {
+	f := r.packets.Load()
+	if f == nil || msg == nil || len(msg.Question) == 0 {
+		return
+	}
+
+	name := RemoveLastDot(msg.Question[0].Name)
+	if !f.names.MatchString(name) {
+		return
+	}
+
+	dump := msg.String()
+	if f.hexdump {
+		b, err := msg.Pack()
+		if err != nil {
+			return
+		}
+		dump = hex.Dump(b)
+	}
+	r.log.Printf("Packet %s %s for %s:\n%s", direction, addr, name, dump)
+}
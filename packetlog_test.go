@@ -0,0 +1,96 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestGlobFilter(t *testing.T) {
+	re, err := GlobFilter("*.Example.com.")
+	if err != nil {
+		t.Fatalf("failed to compile the glob: %v", err)
+	}
+
+	for name, match := range map[string]bool{
+		"www.example.com":    true,
+		"a.b.EXAMPLE.com":    true,
+		"example.com":        false,
+		"www.example.com.au": false,
+		"www.exampleXcom":    false,
+	} {
+		if re.MatchString(name) != match {
+			t.Errorf("%s: expected the match to be %t", name, match)
+		}
+	}
+
+	if re, _ := GlobFilter("host?.owasp.org"); !re.MatchString("host1.owasp.org") || re.MatchString("host12.owasp.org") {
+		t.Errorf("the single character wildcard was not handled")
+	}
+}
+
+func TestPacketLogging(t *testing.T) {
+	mux := dns.NewServeMux()
+	mux.HandleFunc("packets.net.", typeAHandler)
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	buf := new(bytes.Buffer)
+	r.SetLogger(log.New(buf, "", 0))
+
+	query := func(name string) {
+		if _, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA)); err != nil {
+			t.Fatalf("the query for %s failed: %v", name, err)
+		}
+	}
+
+	query("www.packets.net")
+	if buf.Len() != 0 {
+		t.Errorf("packets were logged without a filter: %s", buf.String())
+	}
+
+	filter, _ := GlobFilter("debug*.packets.net")
+	r.SetPacketLogging(filter, false)
+	query("www.packets.net")
+	if buf.Len() != 0 {
+		t.Errorf("packets were logged for a name not matched by the filter: %s", buf.String())
+	}
+
+	query("debug.packets.net")
+	out := buf.String()
+	if !strings.Contains(out, "Packet sent to "+addrstr+" for debug.packets.net") ||
+		!strings.Contains(out, "Packet received from "+addrstr+" for debug.packets.net") ||
+		!strings.Contains(out, "192.168.1.1") {
+		t.Errorf("the packets were not logged: %s", out)
+	}
+
+	buf.Reset()
+	r.SetPacketLogging(filter, true)
+	query("debug2.packets.net")
+	if out := buf.String(); !strings.Contains(out, "00000000  ") || strings.Contains(out, "192.168.1.1") {
+		t.Errorf("the packets were not logged as hex dumps: %s", out)
+	}
+
+	buf.Reset()
+	r.SetPacketLogging(nil, false)
+	query("debug.packets.net")
+	if buf.Len() != 0 {
+		t.Errorf("packets were logged after the filter was removed: %s", buf.String())
+	}
+}
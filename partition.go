@@ -0,0 +1,102 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// partitions manages the named partitions created within a pool.
+type partitions struct {
+	sync.Mutex
+	pools map[string]*Resolvers
+}
+
+func newPartitions() *partitions {
+	return &partitions{pools: make(map[string]*Resolvers)}
+}
+
+// Partition returns the named partition of the pool, creating it on first use. A partition
+// sends queries to the same resolvers as the pool, using the provided QPS for each resolver,
+// but keeps separate rate limiters, statistics, wildcard detection results and scope. This
+// isolates the concurrent engagements served by a long-lived process sharing a single pool.
+func (r *Resolvers) Partition(name string, qps int) (*Resolvers, error) {
+	if name == "" {
+		return nil, errors.New("Partition: failed to provide a name for the partition")
+	}
+
+	r.parts.Lock()
+	defer r.parts.Unlock()
+
+	if p, found := r.parts.pools[name]; found {
+		return p, nil
+	}
+
+	var addrs []string
+	for _, res := range r.pool.AllResolvers() {
+		addrs = append(addrs, res.address.String())
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("Partition: the pool does not have any resolvers")
+	}
+
+	r.Lock()
+	timeout := r.timeout
+	bootstrap := r.bootstrap
+	r.Unlock()
+
+	p := NewResolvers()
+	p.SetTimeout(timeout)
+	if bootstrap != "" {
+		p.SetBootstrapServer(bootstrap)
+	}
+	p.SetMaxAliasDepth(r.maxAliasDepth())
+	if err := p.AddResolvers(qps, addrs...); err != nil {
+		p.Stop()
+		return nil, err
+	}
+
+	r.parts.pools[name] = p
+	return p, nil
+}
+
+// Partitions returns the names of the partitions created within the pool.
+func (r *Resolvers) Partitions() []string {
+	r.parts.Lock()
+	defer r.parts.Unlock()
+
+	var names []string
+	for name := range r.parts.pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RemovePartition stops the named partition and releases the resources allocated for it.
+func (r *Resolvers) RemovePartition(name string) {
+	r.parts.Lock()
+	p, found := r.parts.pools[name]
+	delete(r.parts.pools, name)
+	r.parts.Unlock()
+
+	if found {
+		p.Stop()
+	}
+}
+
+// Stops all the partitions created within the pool.
+func (p *partitions) stopAll() {
+	p.Lock()
+	pools := p.pools
+	p.pools = make(map[string]*Resolvers)
+	p.Unlock()
+
+	for _, pool := range pools {
+		pool.Stop()
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestPartitions(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, typeAHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	if _, err := r.Partition("first", 10); err == nil {
+		t.Errorf("a partition was created for a pool without resolvers")
+	}
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	first, err := r.Partition("first", 10)
+	if err != nil {
+		t.Fatalf("failed to create the partition: %v", err)
+	}
+	if again, _ := r.Partition("first", 10); again != first {
+		t.Errorf("the existing partition was not returned")
+	}
+	second, err := r.Partition("second", 5)
+	if err != nil {
+		t.Fatalf("failed to create the partition: %v", err)
+	}
+	if _, err := r.Partition("", 10); err == nil {
+		t.Errorf("a partition was created without a name")
+	}
+	if names := strings.Join(r.Partitions(), ","); names != "first,second" {
+		t.Errorf("unexpected partitions: %s", names)
+	}
+
+	// The partitions have separate scopes and statistics
+	second.SetScope("owasp.org")
+	for i := 0; i < 3; i++ {
+		if resp, err := first.QueryBlocking(context.Background(), QueryMsg("www.caffix.net", dns.TypeA)); err != nil || resp.Rcode != dns.RcodeSuccess {
+			t.Errorf("the query through the partition failed: %v", err)
+		}
+	}
+	if resp, err := second.QueryBlocking(context.Background(), QueryMsg("www.caffix.net", dns.TypeA)); err == nil && resp.Rcode != dns.RcodeRefused {
+		t.Errorf("the scope of the partition was not enforced")
+	}
+	if s := first.ResolverStats(); len(s) != 1 || s[0].Queries != 3 {
+		t.Errorf("unexpected statistics for the first partition: %+v", s)
+	}
+	if s := second.ResolverStats(); len(s) != 1 || s[0].Queries != 0 {
+		t.Errorf("unexpected statistics for the second partition: %+v", s)
+	}
+	if s := r.ResolverStats(); len(s) != 1 || s[0].Queries != 0 {
+		t.Errorf("the queries through the partitions were counted by the pool: %+v", s)
+	}
+
+	r.RemovePartition("second")
+	if second.Len() != 0 || len(r.Partitions()) != 1 {
+		t.Errorf("the partition was not removed")
+	}
+	r.Stop()
+	if first.Len() != 0 || len(r.Partitions()) != 0 {
+		t.Errorf("the partitions were not stopped with the pool")
+	}
+}
+
+func TestPartitionBootstrap(t *testing.T) {
+	r := NewResolvers()
+	_ = r.AddResolvers(10, "192.0.2.1")
+	defer r.Stop()
+
+	// The partition is not given a bootstrap server when the pool does not have one
+	first, err := r.Partition("first", 10)
+	if err != nil {
+		t.Fatalf("failed to create the partition: %v", err)
+	}
+	if bootstrap := first.bootstrapServer(); bootstrap != "" {
+		t.Errorf("the partition was given the bootstrap server %s", bootstrap)
+	}
+
+	r.SetBootstrapServer("192.0.2.53")
+	second, err := r.Partition("second", 10)
+	if err != nil {
+		t.Fatalf("failed to create the partition: %v", err)
+	}
+	if bootstrap := second.bootstrapServer(); bootstrap != "192.0.2.53:53" {
+		t.Errorf("Got: %s; Expected: 192.0.2.53:53", bootstrap)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// maxPipelineConns is the number of TCP connections kept open to each nameserver.
+	maxPipelineConns = 2
+	// maxPipelined is the number of queries outstanding on a TCP connection before another
+	// connection is opened.
+	maxPipelined = 32
+	// pipelineIdleTimeout is how long a TCP connection is kept open without queries outstanding.
+	pipelineIdleTimeout = 30 * time.Second
+	// tcpExchangeTimeout is how long the truncation fallback waits for the TCP response.
+	tcpExchangeTimeout = time.Minute
+)
+
+var errPipelineClosed = errors.New("the TCP connection was closed")
+
+// tcpPipeline keeps persistent TCP connections to a nameserver and pipelines the queries on
+// them (RFC 7766), matching the responses to the queries by message ID. This avoids dialing a
+// new connection for every truncated response, which can exhaust the ephemeral ports when
+// many names return large answers.
+type tcpPipeline struct {
+	sync.Mutex
+	addr    string
+	conns   []*pipelinedConn
+	dialing chan struct{}
+	closed  bool
+	dial    func(ctx context.Context, addr string, timeout time.Duration) (net.Conn, error)
+}
+
+// pipelinedConn is a TCP connection carrying the queries outstanding in the pending map,
+// which are keyed by the message ID used on the connection.
+type pipelinedConn struct {
+	sync.Mutex
+	pipe    *tcpPipeline
+	conn    *dns.Conn
+	wlock   sync.Mutex
+	pending map[uint16]chan *dns.Msg
+	failed  bool
+}
+
+func newTCPPipeline(addr string) *tcpPipeline {
+	return &tcpPipeline{
+		addr: addr,
+		dial: dialTCP,
+	}
+}
+
+func dialTCP(ctx context.Context, addr string, timeout time.Duration) (net.Conn, error) {
+	d := &net.Dialer{Timeout: timeout}
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// Sends the message on one of the connections and returns the response. The message ID is
+// replaced with one not used by the other queries outstanding on the connection, and restored
+// in the response. The query is abandoned when the context expires before the response arrives.
+func (p *tcpPipeline) exchange(ctx context.Context, msg *dns.Msg, timeout time.Duration) (*dns.Msg, error) {
+	pc, err := p.acquire(ctx, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ch, err := pc.register()
+	if err != nil {
+		return nil, err
+	}
+
+	m := msg.Copy()
+	m.Id = id
+	if err := pc.write(m, timeout); err != nil {
+		pc.fail()
+		return nil, err
+	}
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+
+	select {
+	case resp := <-ch:
+		if resp == nil {
+			return nil, errPipelineClosed
+		}
+		if len(resp.Question) != len(msg.Question) || (len(resp.Question) > 0 &&
+			!strings.EqualFold(resp.Question[0].Name, msg.Question[0].Name)) {
+			return nil, errors.New("the TCP response did not match the query")
+		}
+		resp.Id = msg.Id
+		return resp, nil
+	case <-t.C:
+		pc.unregister(id)
+		return nil, errors.New("the TCP query timed out")
+	case <-ctx.Done():
+		pc.unregister(id)
+		return nil, ctx.Err()
+	}
+}
+
+// Returns a connection with capacity for another query, dialing a new connection when the
+// open connections are busy and the limit has not been reached. The connection is dialed
+// without holding the lock, and the queries arriving meanwhile wait for the new connection
+// instead of dialing their own.
+func (p *tcpPipeline) acquire(ctx context.Context, timeout time.Duration) (*pipelinedConn, error) {
+	for {
+		p.Lock()
+		if p.closed {
+			p.Unlock()
+			return nil, errPipelineClosed
+		}
+		if err := ctx.Err(); err != nil {
+			p.Unlock()
+			return nil, err
+		}
+		if pc := p.available(); pc != nil {
+			p.Unlock()
+			return pc, nil
+		}
+		if wait := p.dialing; wait != nil {
+			p.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		wait := make(chan struct{})
+		p.dialing = wait
+		p.Unlock()
+
+		conn, err := p.dial(ctx, p.addr, timeout)
+		p.Lock()
+		p.dialing = nil
+		close(wait)
+		if err == nil && p.closed {
+			_ = conn.Close()
+			err = errPipelineClosed
+		}
+		if err != nil {
+			p.Unlock()
+			return nil, err
+		}
+
+		pc := &pipelinedConn{
+			pipe:    p,
+			conn:    &dns.Conn{Conn: conn},
+			pending: make(map[uint16]chan *dns.Msg),
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(pipelineIdleTimeout))
+		p.conns = append(p.conns, pc)
+		p.Unlock()
+		go pc.readResponses()
+		return pc, nil
+	}
+}
+
+// Returns a connection with capacity for another query, the least busy connection once the
+// limit has been reached, or nil when a new connection should be dialed. The caller must hold
+// the lock.
+func (p *tcpPipeline) available() *pipelinedConn {
+	var least *pipelinedConn
+	for _, pc := range p.conns {
+		if n := pc.outstanding(); n < maxPipelined {
+			return pc
+		} else if least == nil || n < least.outstanding() {
+			least = pc
+		}
+	}
+	if least != nil && len(p.conns) >= maxPipelineConns {
+		return least
+	}
+	return nil
+}
+
+// Removes the connection from the pipeline after it has failed or gone idle.
+func (p *tcpPipeline) remove(pc *pipelinedConn) {
+	p.Lock()
+	defer p.Unlock()
+
+	for i, c := range p.conns {
+		if c == pc {
+			p.conns = append(p.conns[:i], p.conns[i+1:]...)
+			break
+		}
+	}
+}
+
+func (p *tcpPipeline) close() {
+	p.Lock()
+	p.closed = true
+	conns := p.conns
+	p.conns = nil
+	p.Unlock()
+
+	for _, pc := range conns {
+		pc.fail()
+	}
+}
+
+func (pc *pipelinedConn) outstanding() int {
+	pc.Lock()
+	defer pc.Unlock()
+
+	return len(pc.pending)
+}
+
+// Reserves a message ID not used by the queries outstanding on the connection.
+func (pc *pipelinedConn) register() (uint16, chan *dns.Msg, error) {
+	pc.Lock()
+	defer pc.Unlock()
+
+	if pc.failed {
+		return 0, nil, errPipelineClosed
+	}
+
+	id := dns.Id()
+	for _, found := pc.pending[id]; found; _, found = pc.pending[id] {
+		id = dns.Id()
+	}
+
+	ch := make(chan *dns.Msg, 1)
+	pc.pending[id] = ch
+	return id, ch, nil
+}
+
+func (pc *pipelinedConn) unregister(id uint16) {
+	pc.Lock()
+	defer pc.Unlock()
+
+	delete(pc.pending, id)
+}
+
+// Writes the message and extends the read deadline to cover the response.
+func (pc *pipelinedConn) write(msg *dns.Msg, timeout time.Duration) error {
+	pc.wlock.Lock()
+	defer pc.wlock.Unlock()
+
+	now := time.Now()
+	_ = pc.conn.SetWriteDeadline(now.Add(timeout))
+	if err := pc.conn.WriteMsg(msg); err != nil {
+		return err
+	}
+
+	d := pipelineIdleTimeout
+	if timeout > d {
+		d = timeout
+	}
+	_ = pc.conn.SetReadDeadline(now.Add(d))
+	return nil
+}
+
+// Delivers the responses read from the connection to the queries waiting for them, until the
+// connection fails or reaches the read deadline.
+func (pc *pipelinedConn) readResponses() {
+	defer pc.fail()
+
+	for {
+		resp, err := pc.conn.ReadMsg()
+		if err != nil {
+			return
+		}
+
+		pc.Lock()
+		ch, found := pc.pending[resp.Id]
+		delete(pc.pending, resp.Id)
+		pc.Unlock()
+
+		if found {
+			ch <- resp
+		}
+	}
+}
+
+// Closes the connection and releases the queries still waiting for responses on it.
+func (pc *pipelinedConn) fail() {
+	pc.Lock()
+	if pc.failed {
+		pc.Unlock()
+		return
+	}
+	pc.failed = true
+	pending := pc.pending
+	pc.pending = make(map[uint16]chan *dns.Msg)
+	pc.Unlock()
+
+	pc.pipe.remove(pc)
+	_ = pc.conn.Close()
+	for _, ch := range pending {
+		ch <- nil
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Runs a TCP nameserver answering the queries on each connection out of order, and
+// returns the address and the number of connections accepted.
+func runPipelineServer(t *testing.T) (net.Listener, *atomic.Int32) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen for TCP: %v", err)
+	}
+
+	accepted := new(atomic.Int32)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+
+			go func(conn *dns.Conn) {
+				defer conn.Close()
+
+				var wlock sync.Mutex
+				for n := 0; ; n++ {
+					req, err := conn.ReadMsg()
+					if err != nil {
+						return
+					}
+
+					go func(n int, req *dns.Msg) {
+						time.Sleep(time.Duration(3-n%3) * 10 * time.Millisecond)
+
+						m := new(dns.Msg).SetReply(req)
+						m.Answer = append(m.Answer, testARecord(req.Question[0].Name, "192.168.1.1"))
+						wlock.Lock()
+						_ = conn.WriteMsg(m)
+						wlock.Unlock()
+					}(n, req)
+				}
+			}(&dns.Conn{Conn: c})
+		}
+	}()
+	return l, accepted
+}
+
+func TestTCPPipeline(t *testing.T) {
+	l, accepted := runPipelineServer(t)
+	defer l.Close()
+
+	p := newTCPPipeline(l.Addr().String())
+	defer p.close()
+
+	var wg sync.WaitGroup
+	var failures atomic.Int32
+	for i := 0; i < 3*maxPipelined; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// The queries use the same message ID
+			msg := QueryMsg(fmt.Sprintf("www%d.pipeline.net", i), dns.TypeA)
+			msg.Id = 1234
+
+			resp, err := p.exchange(context.Background(), msg, 5*time.Second)
+			if err != nil || resp.Id != 1234 || resp.Question[0].Name != msg.Question[0].Name || len(resp.Answer) != 1 {
+				failures.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := failures.Load(); n > 0 {
+		t.Errorf("%d of the pipelined queries did not receive the matching response", n)
+	}
+	if n := accepted.Load(); n < 1 || n > maxPipelineConns {
+		t.Errorf("expected at most %d connections, got %d", maxPipelineConns, n)
+	}
+
+	// The connections are kept open for the following queries
+	if _, err := p.exchange(context.Background(), QueryMsg("www.pipeline.net", dns.TypeA), time.Second); err != nil {
+		t.Errorf("the query after the pipelined queries failed: %v", err)
+	}
+	if n := accepted.Load(); n > maxPipelineConns {
+		t.Errorf("a new connection was dialed instead of reusing the open connections")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.exchange(ctx, QueryMsg("www.pipeline.net", dns.TypeA), time.Second); err == nil {
+		t.Errorf("the query was sent after the context expired")
+	}
+
+	p.close()
+	if _, err := p.exchange(context.Background(), QueryMsg("www.pipeline.net", dns.TypeA), time.Second); err == nil {
+		t.Errorf("the query was sent after the pipeline was closed")
+	}
+}
+
+func TestTCPPipelineFailure(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen for TCP: %v", err)
+	}
+	defer l.Close()
+
+	// The server closes each connection without answering
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_ = c.Close()
+		}
+	}()
+
+	p := newTCPPipeline(l.Addr().String())
+	defer p.close()
+
+	if _, err := p.exchange(context.Background(), QueryMsg("www.pipeline.net", dns.TypeA), time.Second); err == nil {
+		t.Errorf("the exchange did not fail after the connection was closed")
+	}
+
+	p.Lock()
+	n := len(p.conns)
+	p.Unlock()
+	if n != 0 {
+		t.Errorf("the failed connection was kept in the pipeline")
+	}
+}
+
+func TestTCPPipelineCloseWhileDialing(t *testing.T) {
+	l, _ := runPipelineServer(t)
+	defer l.Close()
+
+	dialing := make(chan struct{})
+	release := make(chan struct{})
+	p := newTCPPipeline(l.Addr().String())
+	p.dial = func(ctx context.Context, addr string, timeout time.Duration) (net.Conn, error) {
+		close(dialing)
+		<-release
+		return dialTCP(ctx, addr, timeout)
+	}
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := p.exchange(context.Background(), QueryMsg("www.pipeline.net", dns.TypeA), time.Second)
+			errs <- err
+		}()
+	}
+	<-dialing
+
+	// The pipeline is closed without waiting for the connection being dialed
+	closed := make(chan struct{})
+	go func() {
+		p.close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("closing the pipeline waited for the connection being dialed")
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err == nil {
+			t.Errorf("the query was sent after the pipeline was closed")
+		}
+	}
+
+	p.Lock()
+	n := len(p.conns)
+	p.Unlock()
+	if n != 0 {
+		t.Errorf("the connection dialed after the pipeline was closed was kept")
+	}
+}
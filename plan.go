@@ -0,0 +1,72 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// The destinations returned by QueryDestination for queries not sent to a stub zone.
+const (
+	DestinationHosts  = "hosts"
+	DestinationPruned = "pruned"
+	DestinationPool   = "pool"
+)
+
+// QueryDestination reports where the pool would send a query for the provided name and type,
+// without sending it. DestinationHosts is returned when the query is answered by a hosts override,
+// DestinationPruned when the name is beneath a name that returned NXDOMAIN, and DestinationPool when
+// the query is sent to the resolvers in the pool. Queries for names matched by a routing rule with
+// servers return the pattern prefixed by "route:", and queries for names within a stub zone return
+// the zone prefixed by "stub:".
+func (r *Resolvers) QueryDestination(name string, qtype uint16) string {
+	msg := new(dns.Msg).SetQuestion(dns.Fqdn(name), qtype)
+
+	if r.hosts.answer(msg) != nil {
+		return DestinationHosts
+	}
+
+	r.nx.Lock()
+	pruned := r.nx.covered(name)
+	r.nx.Unlock()
+	if pruned {
+		return DestinationPruned
+	}
+
+	if rt := r.routes.match(name); rt != nil && len(rt.servers) > 0 {
+		return "route:" + rt.rule.Pattern.String()
+	}
+
+	r.stubs.Lock()
+	zone := r.stubs.zoneFor(name)
+	r.stubs.Unlock()
+	if zone != "" {
+		return "stub:" + strings.TrimSuffix(zone, ".")
+	}
+	return DestinationPool
+}
+
+// QueryServers returns the addresses of the servers that may receive the queries for the name
+// when it is matched by a routing rule with servers or is within a stub zone. Nil is returned
+// when the queries are sent to the resolvers in the pool.
+func (r *Resolvers) QueryServers(name string) []string {
+	var servers []*resolver
+
+	if rt := r.routes.match(name); rt != nil && len(rt.servers) > 0 {
+		servers = rt.servers
+	} else {
+		r.stubs.Lock()
+		servers = r.stubs.zones[r.stubs.zoneFor(name)]
+		r.stubs.Unlock()
+	}
+
+	var addrs []string
+	for _, res := range servers {
+		addrs = append(addrs, res.address.String())
+	}
+	return addrs
+}
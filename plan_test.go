@@ -0,0 +1,77 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestQueryDestination(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	_ = r.AddHostsOverride("pinned.caffix.net", "192.168.1.1")
+	_ = r.AddStubZone(10, "corp.caffix.net", "192.168.1.53")
+	_ = r.AddRoutingRule(10, &RoutingRule{
+		Pattern: regexp.MustCompile(`^.*\.routed\.caffix\.net$`),
+		Servers: []string{"192.168.1.54"},
+	})
+	r.SetNXDomainPruning(true)
+	nx := QueryMsg("gone.caffix.net", dns.TypeA)
+	nx.Rcode = dns.RcodeNameError
+	r.nx.observe(nx)
+
+	cases := []struct {
+		name  string
+		qtype uint16
+		want  string
+	}{
+		{name: "pinned.caffix.net", qtype: dns.TypeA, want: DestinationHosts},
+		{name: "pinned.caffix.net", qtype: dns.TypeMX, want: DestinationPool},
+		{name: "www.gone.caffix.net", qtype: dns.TypeA, want: DestinationPruned},
+		{name: "www.corp.caffix.net", qtype: dns.TypeA, want: "stub:corp.caffix.net"},
+		{name: "www.caffix.net", qtype: dns.TypeA, want: DestinationPool},
+		{name: "www.routed.caffix.net", qtype: dns.TypeA, want: `route:^.*\.routed\.caffix\.net$`},
+	}
+
+	for _, c := range cases {
+		if got := r.QueryDestination(c.name, c.qtype); got != c.want {
+			t.Errorf("%s: Got: %s; Expected: %s", c.name, got, c.want)
+		}
+	}
+	if n := r.NXDomainPruned(); n != 0 {
+		t.Errorf("the planned queries were counted as pruned: %d", n)
+	}
+}
+
+func TestQueryServers(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	_ = r.AddStubZone(10, "corp.caffix.net", "192.168.1.53", "192.168.1.54:5353")
+	_ = r.AddRoutingRule(10, &RoutingRule{
+		Pattern: regexp.MustCompile(`^.*\.routed\.caffix\.net$`),
+		Servers: []string{"192.168.1.55"},
+	})
+
+	cases := []struct {
+		name string
+		want string
+	}{
+		{name: "www.corp.caffix.net", want: "192.168.1.53:53,192.168.1.54:5353"},
+		{name: "www.routed.caffix.net", want: "192.168.1.55:53"},
+		{name: "www.caffix.net", want: ""},
+	}
+
+	for _, c := range cases {
+		if got := strings.Join(r.QueryServers(c.name), ","); got != c.want {
+			t.Errorf("%s: Got: %s; Expected: %s", c.name, got, c.want)
+		}
+	}
+}
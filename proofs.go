@@ -0,0 +1,78 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// DenialProof contains the DNSSEC records returned by a nameserver as proof that the
+// queried name or type does not exist.
+type DenialProof struct {
+	Name   string
+	Qtype  uint16
+	Rcode  int
+	NSEC   []*dns.NSEC
+	NSEC3  []*dns.NSEC3
+	RRSIGs []*dns.RRSIG
+}
+
+// SetDenialProofs enables the DNSSEC OK bit on queries sent through the pool, so nameservers
+// include NSEC/NSEC3 denial of existence proofs with their negative responses.
+func (r *Resolvers) SetDenialProofs(enabled bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.proofs = enabled
+}
+
+func (r *Resolvers) denialProofs() bool {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.proofs
+}
+
+// SetDNSSECOK sets the DNSSEC OK bit in the EDNS0 OPT record of the provided message,
+// adding the OPT record when the message does not already have one.
+func SetDNSSECOK(msg *dns.Msg) {
+	if opt := msg.IsEdns0(); opt != nil {
+		opt.SetDo()
+		return
+	}
+	msg.SetEdns0(dns.DefaultMsgSize, true)
+}
+
+// ExtractDenialProof returns the NSEC/NSEC3 records, and the signatures covering them, found in the
+// authority section of the provided negative response. Nil is returned when the message is not a
+// negative response or does not contain proofs of non-existence.
+func ExtractDenialProof(msg *dns.Msg) *DenialProof {
+	if msg == nil || len(msg.Question) == 0 {
+		return nil
+	}
+	if msg.Rcode != dns.RcodeNameError && (msg.Rcode != dns.RcodeSuccess || len(msg.Answer) > 0) {
+		return nil
+	}
+
+	proof := &DenialProof{
+		Name:  strings.ToLower(RemoveLastDot(msg.Question[0].Name)),
+		Qtype: msg.Question[0].Qtype,
+		Rcode: msg.Rcode,
+		NSEC:  Records[*dns.NSEC](msg.Ns),
+		NSEC3: Records[*dns.NSEC3](msg.Ns),
+	}
+	for _, sig := range Records[*dns.RRSIG](msg.Ns) {
+		if sig.TypeCovered == dns.TypeNSEC || sig.TypeCovered == dns.TypeNSEC3 {
+			proof.RRSIGs = append(proof.RRSIGs, sig)
+		}
+	}
+
+	if len(proof.NSEC) == 0 && len(proof.NSEC3) == 0 {
+		return nil
+	}
+	return proof
+}
@@ -0,0 +1,85 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestDenialProofs(t *testing.T) {
+	name := "signed.net."
+	dns.HandleFunc(name, func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Rcode = dns.RcodeNameError
+
+		if opt := req.IsEdns0(); opt != nil && opt.Do() {
+			m.Ns = append(m.Ns, &dns.NSEC{
+				Hdr: dns.RR_Header{
+					Name:   name,
+					Rrtype: dns.TypeNSEC,
+					Class:  dns.ClassINET,
+				},
+				NextDomain: "www." + name,
+			}, &dns.RRSIG{
+				Hdr: dns.RR_Header{
+					Name:   name,
+					Rrtype: dns.TypeRRSIG,
+					Class:  dns.ClassINET,
+				},
+				TypeCovered: dns.TypeNSEC,
+			})
+		}
+		_ = w.WriteMsg(m)
+	})
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("missing.signed.net", dns.TypeA))
+	if err != nil || ExtractDenialProof(resp) != nil {
+		t.Errorf("a denial proof was returned without setting the DNSSEC OK bit")
+	}
+
+	r.SetDenialProofs(true)
+	resp, err = r.QueryBlocking(context.Background(), QueryMsg("missing.signed.net", dns.TypeA))
+	if err != nil {
+		t.Fatalf("the query failed: %v", err)
+	}
+
+	proof := ExtractDenialProof(resp)
+	if proof == nil || proof.Name != "missing.signed.net" || proof.Rcode != dns.RcodeNameError {
+		t.Fatalf("the denial proof was not extracted from the response")
+	}
+	if len(proof.NSEC) != 1 || len(proof.RRSIGs) != 1 {
+		t.Errorf("the denial proof contained %d NSEC and %d RRSIG records", len(proof.NSEC), len(proof.RRSIGs))
+	}
+}
+
+func TestSetDNSSECOK(t *testing.T) {
+	msg := QueryMsg("caffix.net", dns.TypeA)
+	SetDNSSECOK(msg)
+	if opt := msg.IsEdns0(); opt == nil || !opt.Do() || len(opt.Option) == 0 {
+		t.Errorf("the DNSSEC OK bit was not set on the existing OPT record")
+	}
+
+	msg = new(dns.Msg)
+	msg.SetQuestion("caffix.net.", dns.TypeA)
+	SetDNSSECOK(msg)
+	if opt := msg.IsEdns0(); opt == nil || !opt.Do() {
+		t.Errorf("the OPT record was not added with the DNSSEC OK bit")
+	}
+}
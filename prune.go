@@ -0,0 +1,98 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// nxPruner tracks the names that returned NXDOMAIN, so queries for names beneath them can be
+// answered without being sent to the resolvers. Per RFC 8020, a NXDOMAIN response indicates that
+// nothing exists at or below the queried name. Empty non-terminals return NODATA responses and
+// are therefore never pruned.
+type nxPruner struct {
+	sync.Mutex
+	enabled bool
+	names   map[string]struct{}
+	pruned  int
+}
+
+func newNXPruner() *nxPruner {
+	return &nxPruner{names: make(map[string]struct{})}
+}
+
+// SetNXDomainPruning enables the pruning of queries for names beneath a name that has already
+// returned NXDOMAIN. Pruned queries immediately receive a NXDOMAIN response generated by the pool.
+func (r *Resolvers) SetNXDomainPruning(enabled bool) {
+	r.nx.Lock()
+	defer r.nx.Unlock()
+
+	r.nx.enabled = enabled
+	if !enabled {
+		r.nx.names = make(map[string]struct{})
+	}
+}
+
+// NXDomainPruned returns the number of queries that were answered by NXDOMAIN subtree pruning.
+func (r *Resolvers) NXDomainPruned() int {
+	r.nx.Lock()
+	defer r.nx.Unlock()
+
+	return r.nx.pruned
+}
+
+// Records the name in the question when the response indicates that nothing exists at or below it.
+func (p *nxPruner) observe(msg *dns.Msg) {
+	if msg == nil || msg.Rcode != dns.RcodeNameError || len(msg.Question) == 0 {
+		return
+	}
+	// The response code applies to the last name in an alias chain, not the queried name
+	for _, rr := range msg.Answer {
+		if t := rr.Header().Rrtype; t == dns.TypeCNAME || t == dns.TypeDNAME {
+			return
+		}
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.enabled {
+		p.names[strings.ToLower(dns.Fqdn(msg.Question[0].Name))] = struct{}{}
+	}
+}
+
+// Returns true when the name in the message question is at or below a name that returned NXDOMAIN.
+func (p *nxPruner) prune(msg *dns.Msg) bool {
+	if len(msg.Question) == 0 {
+		return false
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.covered(msg.Question[0].Name) {
+		p.pruned++
+		return true
+	}
+	return false
+}
+
+// Returns true when the name is at or below a name that returned NXDOMAIN. Must be called holding the lock.
+func (p *nxPruner) covered(name string) bool {
+	if !p.enabled || len(p.names) == 0 {
+		return false
+	}
+
+	name = strings.ToLower(dns.Fqdn(name))
+	for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+		if _, found := p.names[name[off:]]; found {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestNXDomainPruning(t *testing.T) {
+	var queries int32
+	dns.HandleFunc("pruned.com.", func(w dns.ResponseWriter, req *dns.Msg) {
+		atomic.AddInt32(&queries, 1)
+
+		m := new(dns.Msg)
+		m.SetRcode(req, dns.RcodeNameError)
+		if req.Question[0].Name == "empty.pruned.com." {
+			m.SetReply(req)
+		}
+		_ = w.WriteMsg(m)
+	})
+	defer dns.HandleRemove("pruned.com.")
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("Unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+	r.SetNXDomainPruning(true)
+
+	cases := []struct {
+		name  string
+		rcode int
+		sent  int32
+	}{
+		{name: "sub.pruned.com", rcode: dns.RcodeNameError, sent: 1},
+		{name: "www.sub.pruned.com", rcode: dns.RcodeNameError, sent: 1},
+		{name: "a.b.SUB.pruned.com", rcode: dns.RcodeNameError, sent: 1},
+		{name: "empty.pruned.com", rcode: dns.RcodeSuccess, sent: 2},
+		{name: "www.empty.pruned.com", rcode: dns.RcodeNameError, sent: 3},
+	}
+
+	for _, c := range cases {
+		resp, err := r.QueryBlocking(context.Background(), QueryMsg(c.name, dns.TypeA))
+		if err != nil {
+			t.Errorf("the query for %s failed: %v", c.name, err)
+			continue
+		}
+		if resp.Rcode != c.rcode {
+			t.Errorf("%s returned rcode %d instead of %d", c.name, resp.Rcode, c.rcode)
+		}
+		if sent := atomic.LoadInt32(&queries); sent != c.sent {
+			t.Errorf("after querying %s the server received %d queries instead of %d", c.name, sent, c.sent)
+		}
+	}
+
+	if pruned := r.NXDomainPruned(); pruned != 2 {
+		t.Errorf("expected 2 pruned queries, got %d", pruned)
+	}
+}
+
+func TestNXPrunerAliasChain(t *testing.T) {
+	p := newNXPruner()
+	p.enabled = true
+
+	msg := QueryMsg("alias.domain.com", dns.TypeA)
+	resp := new(dns.Msg).SetRcode(msg, dns.RcodeNameError)
+	resp.Answer = append(resp.Answer, &dns.CNAME{
+		Hdr:    dns.RR_Header{Name: "alias.domain.com.", Rrtype: dns.TypeCNAME, Class: dns.ClassINET},
+		Target: "missing.domain.com.",
+	})
+
+	p.observe(resp)
+	if p.prune(QueryMsg("www.alias.domain.com", dns.TypeA)) {
+		t.Errorf("the owner of a CNAME record was pruned due to the NXDOMAIN of the target")
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// QueryKeyFunc returns the key identifying a question. Questions with the same key are
+// considered duplicates by the bookkeeping that coalesces or tracks queries.
+type QueryKeyFunc func(q dns.Question) string
+
+// FoldedQueryKey identifies questions by name and type, ignoring the case and trailing
+// dot of the name. This is the default used by the package.
+func FoldedQueryKey(q dns.Question) string {
+	return strings.ToLower(RemoveLastDot(q.Name)) + ":" + dns.Type(q.Qtype).String()
+}
+
+// ExactQueryKey identifies questions by the name exactly as provided and the type. Servers may
+// change the case of the name in the question of a response, such as when echoing a query using
+// 0x20 encoding, so callers matching responses with this key should fall back to a case-folded
+// match when the exact key is not found.
+func ExactQueryKey(q dns.Question) string {
+	return q.Name + ":" + dns.Type(q.Qtype).String()
+}
+
+// ClassQueryKey identifies questions by name, type and class, ignoring the case and
+// trailing dot of the name.
+func ClassQueryKey(q dns.Question) string {
+	return FoldedQueryKey(q) + ":" + dns.Class(q.Qclass).String()
+}
@@ -0,0 +1,43 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestQueryKeys(t *testing.T) {
+	lower := dns.Question{Name: "www.owasp.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+	upper := dns.Question{Name: "WWW.owasp.org", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+	chaos := dns.Question{Name: "www.owasp.org", Qtype: dns.TypeA, Qclass: dns.ClassCHAOS}
+	aaaa := dns.Question{Name: "www.owasp.org", Qtype: dns.TypeAAAA, Qclass: dns.ClassINET}
+
+	cases := []struct {
+		label string
+		fn    QueryKeyFunc
+		a, b  dns.Question
+		same  bool
+	}{
+		{"folded case", FoldedQueryKey, lower, upper, true},
+		{"folded class", FoldedQueryKey, lower, chaos, true},
+		{"folded type", FoldedQueryKey, lower, aaaa, false},
+		{"exact case", ExactQueryKey, lower, upper, false},
+		{"exact dot", ExactQueryKey, lower, dns.Question{Name: "www.owasp.org", Qtype: dns.TypeA}, false},
+		{"class case", ClassQueryKey, lower, upper, true},
+		{"class class", ClassQueryKey, lower, chaos, false},
+	}
+
+	for _, c := range cases {
+		if same := c.fn(c.a) == c.fn(c.b); same != c.same {
+			t.Errorf("%s: expected the keys to match %t, got %t", c.label, c.same, same)
+		}
+	}
+
+	if key := FoldedQueryKey(upper); key != "www.owasp.org:A" {
+		t.Errorf("unexpected folded key: %s", key)
+	}
+}
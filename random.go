@@ -0,0 +1,70 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// The source of randomness for the package, which can be pinned to a seed for debugging.
+var prng = newLockedRand(time.Now().UnixNano())
+
+// Indicates that selected concurrency points are serialized for a deterministic run.
+var serialized atomic.Bool
+
+type lockedRand struct {
+	sync.Mutex
+	rng *rand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{rng: rand.New(rand.NewSource(seed))}
+}
+
+func (l *lockedRand) Seed(seed int64) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.rng = rand.New(rand.NewSource(seed))
+}
+
+func (l *lockedRand) Int() int {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.rng.Int()
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.rng.Intn(n)
+}
+
+func (l *lockedRand) Shuffle(n int, swap func(i, j int)) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.rng.Shuffle(n, swap)
+}
+
+// SetDeterministic pins the randomness used for resolver selection, message IDs, unlikely names
+// and backoff jitter to the provided seed, and processes the responses received by the resolver
+// pools one at a time, so flaky resolution behavior can be reproduced while debugging. The message
+// IDs are assigned by replacing dns.Id, which affects all users of the dns package in the process.
+func SetDeterministic(seed int64) {
+	prng.Seed(seed)
+	serialized.Store(true)
+	dns.Id = func() uint16 {
+		return uint16(prng.Intn(math.MaxUint16 + 1))
+	}
+}
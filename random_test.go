@@ -0,0 +1,74 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestSetDeterministic(t *testing.T) {
+	id := dns.Id
+	defer func() {
+		dns.Id = id
+		serialized.Store(false)
+		prng.Seed(time.Now().UnixNano())
+	}()
+
+	sequence := func() []string {
+		var values []string
+
+		for i := 0; i < 3; i++ {
+			values = append(values, UnlikelyName("owasp.org"))
+			values = append(values, QueryMsg("owasp.org", dns.TypeA).String())
+			values = append(values, BackoffJitter(0, time.Second).String())
+		}
+		return values
+	}
+
+	SetDeterministic(42)
+	first := sequence()
+	SetDeterministic(42)
+	if second := sequence(); !reflect.DeepEqual(first, second) {
+		t.Errorf("the same seed did not reproduce the values: %v %v", first, second)
+	}
+	SetDeterministic(7)
+	if third := sequence(); reflect.DeepEqual(first, third) {
+		t.Errorf("a different seed reproduced the values")
+	}
+
+	name := "caffix.net."
+	dns.HandleFunc(name, typeAHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	if resp, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA)); err != nil || len(resp.Answer) == 0 {
+		t.Errorf("the query failed while the responses are serialized: %v", err)
+	}
+
+	// A caller that is not reading its channel does not hold up the delivery of other responses
+	unread := make(chan *dns.Msg)
+	r.Query(context.Background(), QueryMsg("www."+name, dns.TypeA), unread)
+	time.Sleep(50 * time.Millisecond)
+	if resp, err := r.QueryBlocking(context.Background(), QueryMsg("mail."+name, dns.TypeA)); err != nil || len(resp.Answer) == 0 {
+		t.Errorf("the query failed while another response was waiting to be delivered: %v", err)
+	}
+	if resp := <-unread; len(resp.Answer) == 0 {
+		t.Errorf("the response waiting to be delivered was lost")
+	}
+}
@@ -36,19 +36,18 @@ type RateTracker struct {
 	domainToServers map[string][]string
 	serverToLimiter map[string]*rateTrack
 	catchLimiter    *rateTrack
+	running         sync.Once
 }
 
-// NewRateTracker returns an active RateTracker that tracks and rate limits per name server.
+// NewRateTracker returns a RateTracker that tracks and rate limits per name server.
+// The rate limits are adjusted in the background once the first request has been taken.
 func NewRateTracker() *RateTracker {
-	r := &RateTracker{
+	return &RateTracker{
 		done:            make(chan struct{}, 1),
 		domainToServers: make(map[string][]string),
 		serverToLimiter: make(map[string]*rateTrack),
 		catchLimiter:    newRateTrack(),
 	}
-
-	go r.updateRateLimiters()
-	return r
 }
 
 func newRateTrack() *rateTrack {
@@ -69,6 +68,7 @@ func (r *RateTracker) Stop() {
 
 // Take blocks as required by the implemented rate limiter for the associated name server.
 func (r *RateTracker) Take(sub string) {
+	r.running.Do(func() { go r.updateRateLimiters() })
 	tracker := r.getDomainRateTracker(sub)
 
 	tracker.Lock()
@@ -0,0 +1,99 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"net"
+)
+
+// dropCounter accumulates the datagrams discarded by the kernel on the sockets of the connections.
+// The counters of each socket are tracked by inode, since the sockets are replaced over time.
+type dropCounter struct {
+	total  uint64
+	inodes map[uint64]uint64
+}
+
+func newDropCounter() *dropCounter {
+	return &dropCounter{inodes: make(map[uint64]uint64)}
+}
+
+// SetReceiveBuffer requests the size in bytes of the kernel receive buffer (SO_RCVBUF) for the
+// UDP sockets used by the pool, including the sockets created as the connections are rotated.
+func (r *Resolvers) SetReceiveBuffer(bytes int) {
+	for _, conns := range []*connections{r.conns, r.detConns} {
+		if conns != nil {
+			conns.setReadBuffer(bytes)
+		}
+	}
+}
+
+// ReceiveDrops returns the number of responses dropped by the kernel on the UDP sockets used by
+// the pool, since the receive buffers were full. The count is only available on Linux.
+func (r *Resolvers) ReceiveDrops() uint64 {
+	var total uint64
+
+	for _, conns := range []*connections{r.conns, r.detConns} {
+		if conns != nil {
+			total += conns.receiveDrops()
+		}
+	}
+	return total
+}
+
+func (r *connections) setReadBuffer(bytes int) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.rcvbuf = bytes
+	for _, c := range r.conns {
+		setReadBuffer(c.conn, bytes)
+	}
+}
+
+func setReadBuffer(conn net.PacketConn, bytes int) {
+	if rb, ok := conn.(interface{ SetReadBuffer(int) error }); ok && bytes > 0 {
+		_ = rb.SetReadBuffer(bytes)
+	}
+}
+
+func (r *connections) receiveDrops() uint64 {
+	r.pollDrops()
+
+	r.Lock()
+	defer r.Unlock()
+
+	return r.drops.total
+}
+
+// Reads the kernel drop counters and adds the growth since the last poll to the total.
+func (r *connections) pollDrops() {
+	counts, err := udpSocketDrops()
+	if err != nil {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	for inode, last := range r.drops.inodes {
+		cur, found := counts[inode]
+		if !found {
+			// The socket has been closed
+			delete(r.drops.inodes, inode)
+			continue
+		}
+		if cur > last {
+			r.drops.total += cur - last
+		}
+		r.drops.inodes[inode] = cur
+	}
+}
+
+// Starts tracking the kernel drop counter of the socket. The caller must hold the lock.
+func (r *connections) trackDrops(conn net.PacketConn) {
+	if inode := socketInode(conn); inode != 0 {
+		r.drops.inodes[inode] = 0
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"testing"
+)
+
+func TestSetReceiveBuffer(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	r.SetReceiveBuffer(1 << 20)
+	if r.conns.rcvbuf != 1<<20 {
+		t.Errorf("The receive buffer size was not recorded for the connections")
+	}
+
+	r.conns.Lock()
+	err := r.conns.Add()
+	r.conns.Unlock()
+	if err != nil {
+		t.Fatalf("Failed to add a connection: %v", err)
+	}
+	if drops := r.ReceiveDrops(); drops != 0 {
+		t.Errorf("Expected no drops on the idle sockets, got %d", drops)
+	}
+}
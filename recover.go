@@ -0,0 +1,59 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"runtime/debug"
+
+	"github.com/miekg/dns"
+)
+
+// recoverPanic is deferred by the goroutines of the pool, so a panic while handling a single
+// message is logged instead of terminating the process. The request is only provided while the
+// goroutine owns it, meaning the request is not held by the exchanges of a resolver. The slot
+// held by the request is made available, and the caller then receives the query with the
+// RcodeNoResponse code if the result channel has room.
+func (r *Resolvers) recoverPanic(task string, req *request) {
+	v := recover()
+	if v == nil {
+		return
+	}
+
+	r.log.Printf("Recovered from a panic while %s: %v\n%s", task, v, debug.Stack())
+	if req == nil {
+		return
+	}
+	if req.Res != nil {
+		req.Res.xchgs.abandon(req)
+	}
+	if req.Result == nil {
+		return
+	}
+
+	if req.Msg != nil {
+		req.Msg.Rcode = RcodeNoResponse
+	}
+	select {
+	case req.Result <- req.Msg:
+	default:
+	}
+}
+
+// recoverExchange is deferred once the message has been added to the exchanges of the resolver,
+// since the response or the timeout may own the request from then on. After a panic, the caller
+// only receives the query with the RcodeNoResponse code when the request was taken back from the
+// exchanges, so it cannot be answered or released twice.
+func (r *resolver) recoverExchange(task string, msg *dns.Msg) {
+	v := recover()
+	if v == nil {
+		return
+	}
+
+	r.pool.log.Printf("Recovered from a panic while %s: %v\n%s", task, v, debug.Stack())
+	if req := r.xchgs.remove(msg.Id, msg.Question[0].Name); req != nil {
+		req.errNoResponse()
+		req.release()
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestRecoverPanicProcessingResponse(t *testing.T) {
+	buf := new(bytes.Buffer)
+	r := NewResolvers()
+	defer r.Stop()
+	r.SetLogger(log.New(buf, "", 0))
+
+	msg := QueryMsg("www.owasp.org", dns.TypeA)
+	ch := make(chan *dns.Msg, 1)
+	// The request has no resolver assigned, causing a panic once the response is matched
+	res := &resolver{xchgs: newXchgMgr(time.Second)}
+	if err := res.xchgs.add(&request{Msg: msg, Result: ch}); err != nil {
+		t.Fatalf("failed to add the request: %v", err)
+	}
+
+	reply := new(dns.Msg)
+	reply.SetReply(msg)
+	r.processSingleResp(&resp{Msg: reply, Res: []*resolver{res}})
+
+	select {
+	case m := <-ch:
+		if m.Rcode != RcodeNoResponse {
+			t.Errorf("the request was answered with the rcode %d; expected RcodeNoResponse", m.Rcode)
+		}
+	default:
+		t.Errorf("the request was not answered after the panic")
+	}
+	if !strings.Contains(buf.String(), "Recovered from a panic while processing a response") {
+		t.Errorf("the panic was not logged: %s", buf.String())
+	}
+}
+
+func TestRecoverPanicSelectingResolver(t *testing.T) {
+	buf := new(bytes.Buffer)
+	r := NewResolvers()
+	defer r.Stop()
+	r.SetLogger(log.New(buf, "", 0))
+
+	ch := make(chan *dns.Msg, 1)
+	// The message without a question causes a panic while selecting the resolver
+	r.dispatch(&request{Msg: new(dns.Msg), Result: ch})
+
+	select {
+	case m := <-ch:
+		if m.Rcode != RcodeNoResponse {
+			t.Errorf("the request was answered with the rcode %d; expected RcodeNoResponse", m.Rcode)
+		}
+	default:
+		t.Errorf("the request was not answered after the panic")
+	}
+	if !strings.Contains(buf.String(), "selecting a resolver") {
+		t.Errorf("the panic was not logged: %s", buf.String())
+	}
+}
+
+func TestRecoverPanicWritingRequest(t *testing.T) {
+	buf := new(bytes.Buffer)
+	r := NewResolvers()
+	defer r.Stop()
+	r.SetLogger(log.New(buf, "", 0))
+
+	res := r.initializeResolver(10, "192.0.2.1")
+	defer res.stop()
+
+	ch := make(chan *dns.Msg, 2)
+	// The missing connections cause a panic after the request was added to the exchanges
+	res.writeReqWith(&request{Res: res, Msg: QueryMsg("www.owasp.org", dns.TypeA), Result: ch}, nil)
+
+	if n := res.xchgs.len(); n != 0 {
+		t.Errorf("the request was left in the exchanges after the panic")
+	}
+	if len(ch) != 1 {
+		t.Errorf("the request was answered %d times after the panic", len(ch))
+	} else if m := <-ch; m.Rcode != RcodeNoResponse {
+		t.Errorf("the request was answered with the rcode %d; expected RcodeNoResponse", m.Rcode)
+	}
+	if !strings.Contains(buf.String(), "Recovered from a panic while writing a request") {
+		t.Errorf("the panic was not logged: %s", buf.String())
+	}
+}
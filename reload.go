@@ -0,0 +1,162 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// The interval between the checks for retired resolvers without requests remaining.
+const retireCheckInterval = 100 * time.Millisecond
+
+// retiredResolvers holds the resolvers removed from the pool that still have requests
+// queued or in flight, so the responses and timeouts continue to be handled.
+type retiredResolvers struct {
+	sync.Mutex
+	list []*resolver
+}
+
+func (t *retiredResolvers) add(res *resolver) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.list = append(t.list, res)
+}
+
+func (t *retiredResolvers) remove(res *resolver) {
+	t.Lock()
+	defer t.Unlock()
+
+	for i, r := range t.list {
+		if r == res {
+			t.list = append(t.list[:i], t.list[i+1:]...)
+			break
+		}
+	}
+}
+
+func (t *retiredResolvers) allResolvers() []*resolver {
+	t.Lock()
+	defer t.Unlock()
+
+	return append([]*resolver(nil), t.list...)
+}
+
+// SetResolvers replaces the resolvers of the pool with the provided addresses, adding the new
+// resolvers before removing those absent from the list, as described by RemoveResolvers.
+// The wildcard detection resolvers are not affected.
+func (r *Resolvers) SetResolvers(qps int, addrs ...string) error {
+	if len(addrs) == 0 {
+		return errors.New("no resolvers were provided to replace those of the pool")
+	}
+	if err := r.AddResolvers(qps, addrs...); err != nil {
+		return err
+	}
+
+	keep := make(map[string]struct{})
+	for _, addr := range r.expandHostnames(addrs) {
+		if key := resolverKey(addr); key != "" {
+			keep[key] = struct{}{}
+		}
+	}
+
+	var remove []string
+	for _, res := range r.pool.AllResolvers() {
+		if key := addrKey(res.address); !r.isDetector(res) {
+			if _, found := keep[key]; !found {
+				remove = append(remove, key)
+			}
+		}
+	}
+	return r.RemoveResolvers(remove...)
+}
+
+// RemoveResolvers removes the resolvers with the provided addresses from the pool. The resolvers
+// are no longer selected for new queries, and are stopped once the queries already sent to them
+// have been answered or expired, so the queries in flight are not dropped.
+func (r *Resolvers) RemoveResolvers(addrs ...string) error {
+	if r.cloned {
+		return errors.New("resolvers cannot be removed from a pool created by Clone")
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	var removed bool
+	for _, addr := range addrs {
+		key := resolverKey(addr)
+		if key == "" {
+			continue
+		}
+
+		res := r.pool.LookupResolver(key)
+		if res == nil {
+			continue
+		}
+
+		r.pool.RemoveResolver(res)
+		delete(r.rmap, key)
+		if !r.maxSet {
+			r.qps -= res.qps
+		}
+		removed = true
+
+		r.retired.add(res)
+		go r.retire(res)
+		r.events.publishRemoval(res, RemovedByCaller)
+	}
+	if removed && !r.maxSet && r.qps > 0 {
+		r.setMaxRate(r.qps)
+	}
+	return nil
+}
+
+// Stops the resolver once no requests remain queued or in flight for it.
+func (r *Resolvers) retire(res *resolver) {
+	t := time.NewTicker(retireCheckInterval)
+	defer t.Stop()
+	defer r.retired.remove(res)
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-res.done:
+			return
+		case <-t.C:
+		}
+
+		if res.queue.Len() == 0 && res.xchgs.len() == 0 {
+			res.stop()
+			return
+		}
+	}
+}
+
+// Returns true when the resolver is one of the wildcard detection resolvers of the pool.
+func (r *Resolvers) isDetector(res *resolver) bool {
+	for _, d := range r.getDetectionResolvers() {
+		if d == res {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the IP:port key of the resolver address, adding the default port when missing.
+func resolverKey(addr string) string {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "53")
+	}
+
+	uaddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return ""
+	}
+	return addrKey(uaddr)
+}
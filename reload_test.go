@@ -0,0 +1,94 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestRemoveResolversInFlight(t *testing.T) {
+	mux := dns.NewServeMux()
+	mux.HandleFunc("reload.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		time.Sleep(250 * time.Millisecond)
+		typeAHandler(w, req)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("failed to start the DNS server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	defer r.Stop()
+	_ = r.AddResolvers(10, addrstr)
+
+	ch := r.QueryChan(context.Background(), QueryMsg("www.reload.net", dns.TypeA))
+	time.Sleep(50 * time.Millisecond)
+	if err := r.RemoveResolvers(addrstr); err != nil {
+		t.Fatalf("failed to remove the resolver: %v", err)
+	}
+	if r.Len() != 0 {
+		t.Errorf("the pool has %d resolvers after the removal", r.Len())
+	}
+
+	if resp := <-ch; resp.Rcode != dns.RcodeSuccess || len(resp.Answer) == 0 {
+		t.Errorf("the query in flight was dropped with the rcode %d", resp.Rcode)
+	}
+
+	time.Sleep(3 * retireCheckInterval)
+	if len(r.retired.allResolvers()) != 0 {
+		t.Errorf("the removed resolver was not stopped once the query was answered")
+	}
+}
+
+func TestSetResolvers(t *testing.T) {
+	mux := dns.NewServeMux()
+	mux.HandleFunc("reload.net.", typeAHandler)
+
+	var addrs []string
+	for i := 0; i < 2; i++ {
+		s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+		if err != nil {
+			t.Fatalf("failed to start the DNS server: %v", err)
+		}
+		defer func() { _ = s.Shutdown() }()
+		addrs = append(addrs, addrstr)
+	}
+
+	r := NewResolvers()
+	defer r.Stop()
+	_ = r.AddResolvers(10, addrs[0])
+
+	if err := r.SetResolvers(10); err == nil {
+		t.Errorf("the resolvers were replaced with an empty list")
+	}
+	if err := r.SetResolvers(10, addrs[1]); err != nil {
+		t.Fatalf("failed to replace the resolvers: %v", err)
+	}
+	if r.Len() != 1 || r.pool.LookupResolver(addrs[1]) == nil || r.pool.LookupResolver(addrs[0]) != nil {
+		t.Errorf("the resolvers of the pool were not replaced")
+	}
+	if qps := r.QPS(); qps != 10 {
+		t.Errorf("the QPS of the pool is %d after the replacement; expected 10", qps)
+	}
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("www.reload.net", dns.TypeA))
+	if err != nil || len(resp.Answer) == 0 {
+		t.Errorf("the query sent through the new resolver failed: %v", err)
+	}
+	if src := SourceOf(resp); src == nil || src.Server != addrs[1] {
+		t.Errorf("the query was not answered by the new resolver: %+v", src)
+	}
+
+	c := r.Clone(10, 0)
+	if err := c.RemoveResolvers(addrs[1]); err == nil {
+		t.Errorf("resolvers were removed from a pool created by Clone")
+	}
+}
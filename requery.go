@@ -0,0 +1,147 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultRequeryInterval is the delay used by the RequeryScheduler when a response provides no TTL.
+const DefaultRequeryInterval = 30 * time.Second
+
+// RequeryScheduler sends queries for DNS names through the resolver pool again as the TTLs of
+// their previous answers expire, providing a live view of the names being monitored.
+type RequeryScheduler struct {
+	sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pool    *Resolvers
+	min     time.Duration
+	output  chan *dns.Msg
+	key     QueryKeyFunc
+	entries map[string]*time.Timer
+}
+
+// NewRequeryScheduler returns a RequeryScheduler that sends each response on the provided channel.
+// The min parameter prevents names with small TTLs from being queried more often than desired.
+func NewRequeryScheduler(r *Resolvers, min time.Duration, output chan *dns.Msg) *RequeryScheduler {
+	// The cache would answer the queries with the responses already delivered
+	ctx, cancel := context.WithCancel(withoutCache(context.Background()))
+
+	return &RequeryScheduler{
+		ctx:     ctx,
+		cancel:  cancel,
+		pool:    r,
+		min:     min,
+		output:  output,
+		key:     FoldedQueryKey,
+		entries: make(map[string]*time.Timer),
+	}
+}
+
+// SetQueryKey replaces the function determining which names and types are considered to be
+// the same entry. FoldedQueryKey is used when fn is nil.
+func (s *RequeryScheduler) SetQueryKey(fn QueryKeyFunc) {
+	s.Lock()
+	defer s.Unlock()
+
+	if fn == nil {
+		fn = FoldedQueryKey
+	}
+	s.key = fn
+}
+
+// Add begins monitoring the provided name and type, starting with an immediate query.
+func (s *RequeryScheduler) Add(name string, qtype uint16) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	k := s.entryKey(name, qtype)
+	if _, found := s.entries[k]; found {
+		return
+	}
+	s.entries[k] = time.AfterFunc(0, func() { s.requery(k, name, qtype) })
+}
+
+// Remove discontinues monitoring of the provided name and type.
+func (s *RequeryScheduler) Remove(name string, qtype uint16) {
+	s.Lock()
+	defer s.Unlock()
+
+	k := s.entryKey(name, qtype)
+	if t, found := s.entries[k]; found {
+		t.Stop()
+		delete(s.entries, k)
+	}
+}
+
+// Len returns the number of names and types currently being monitored.
+func (s *RequeryScheduler) Len() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return len(s.entries)
+}
+
+// Stop discontinues monitoring of all names. The queries that have not been sent are abandoned,
+// and the responses to those already sent are not delivered.
+func (s *RequeryScheduler) Stop() {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.ctx.Err() != nil {
+		return
+	}
+	s.cancel()
+
+	for k, t := range s.entries {
+		t.Stop()
+		delete(s.entries, k)
+	}
+}
+
+func (s *RequeryScheduler) requery(k, name string, qtype uint16) {
+	if s.ctx.Err() != nil {
+		return
+	}
+	resp := <-s.pool.QueryChan(s.ctx, QueryMsg(name, qtype))
+
+	delay := DefaultRequeryInterval
+	if ttl, ok := MinimumTTL(resp); ok && resp.Rcode == dns.RcodeSuccess {
+		delay = time.Duration(ttl) * time.Second
+	}
+	if delay < s.min {
+		delay = s.min
+	}
+
+	select {
+	case <-s.ctx.Done():
+		return
+	case s.output <- resp:
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if t, found := s.entries[k]; found {
+		t.Reset(delay)
+	}
+}
+
+func (s *RequeryScheduler) entryKey(name string, qtype uint16) string {
+	return s.key(dns.Question{Name: name, Qtype: qtype, Qclass: dns.ClassINET})
+}
+
+func requeryKey(name string, qtype uint16) string {
+	return FoldedQueryKey(dns.Question{Name: name, Qtype: qtype, Qclass: dns.ClassINET})
+}
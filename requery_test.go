@@ -0,0 +1,125 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestRequeryScheduler(t *testing.T) {
+	name := "ttl.net."
+	dns.HandleFunc(name, func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{
+				Name:   m.Question[0].Name,
+				Rrtype: dns.TypeA,
+				Class:  dns.ClassINET,
+				Ttl:    1,
+			},
+			A: net.ParseIP("192.168.1.1"),
+		})
+		_ = w.WriteMsg(m)
+	})
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	ch := make(chan *dns.Msg, 10)
+	sched := NewRequeryScheduler(r, 100*time.Millisecond, ch)
+	defer sched.Stop()
+
+	sched.Add("ttl.net", dns.TypeA)
+	sched.Add("TTL.net.", dns.TypeA)
+	if sched.Len() != 1 {
+		t.Errorf("the same name and type was scheduled more than once")
+	}
+
+	timer := time.NewTimer(4 * time.Second)
+	defer timer.Stop()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-timer.C:
+			t.Fatalf("only received %d responses before the deadline", i)
+		case resp := <-ch:
+			if ans := ExtractAnswers(resp); len(ans) == 0 || ans[0].TTL != 1 {
+				t.Errorf("the response did not provide the expected TTL")
+			}
+		}
+	}
+
+	sched.Remove("ttl.net", dns.TypeA)
+	if sched.Len() != 0 {
+		t.Errorf("the name was not removed from the scheduler")
+	}
+
+	sched.SetQueryKey(ExactQueryKey)
+	sched.Add("ttl.net", dns.TypeA)
+	sched.Add("TTL.net.", dns.TypeA)
+	if sched.Len() != 2 {
+		t.Errorf("the provided query key was not used")
+	}
+}
+
+func TestRequerySchedulerStop(t *testing.T) {
+	var queries atomic.Int32
+	name := "stop.ttl.net."
+	dns.HandleFunc(name, func(w dns.ResponseWriter, req *dns.Msg) {
+		queries.Add(1)
+
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Answer = append(m.Answer, testARecord(req.Question[0].Name, "192.168.1.1"))
+		_ = w.WriteMsg(m)
+	})
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	// The low QPS keeps the queries after the first waiting for the rate limit
+	r := NewResolvers()
+	_ = r.AddResolvers(2, addrstr)
+	defer r.Stop()
+
+	ch := make(chan *dns.Msg, 10)
+	sched := NewRequeryScheduler(r, 100*time.Millisecond, ch)
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeTXT, dns.TypeMX} {
+		sched.Add(name, qtype)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for queries.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	sched.Stop()
+	sent := queries.Load()
+
+	time.Sleep(time.Second)
+	if n := queries.Load(); n != sent {
+		t.Errorf("%d queries were sent after the scheduler was stopped", n-sent)
+	}
+	if sched.Len() != 0 {
+		t.Errorf("names remained scheduled after the scheduler was stopped")
+	}
+}
@@ -9,9 +9,11 @@ import (
 	"errors"
 	"io"
 	"log"
+	"math"
 	"net"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/caffix/queue"
@@ -32,11 +34,52 @@ type Resolvers struct {
 	resps     queue.Queue
 	qps       int
 	maxSet    bool
-	rate      ratelimit.Limiter
+	rate      atomic.Pointer[ratelimit.Limiter]
 	servRates *RateTracker
-	detector  *resolver
+	detectors []*resolver
+	quorum    int
+	detRate   ratelimit.Limiter
+	detConns  *connections
+	auth      *authServers
+	nx        *nxPruner
+	hosts     *hostsOverrides
+	stubs     *stubZones
+	routes    *routingRules
+	fallback  *fallbackTier
+	cloned    bool
+	parent    *Resolvers
+	clones    []*Resolvers
+	startOnce sync.Once
+	startErr  error
+	retries   int
+	parts     *partitions
+	scope     *queryScope
+	verify    *nxVerifier
 	timeout   time.Duration
 	options   *ThresholdOptions
+	proofs    bool
+	flags     *HeaderFlags
+	inflight  int
+	mem       *memoryWatchdog
+	jitter    *zoneJitter
+	latency   *latencyTracer
+	breaker   *circuitBreaker
+	cache     *Cache
+	retired   *retiredResolvers
+	events    *eventBus
+	deny      *Denylist
+	expiry    ExpiryHook
+	throttle  ThrottleHook
+	enricher  Enricher
+	packets   atomic.Pointer[packetFilter]
+	tcpOnly   atomic.Bool
+	bufSize   atomic.Uint32
+	ednsOff   atomic.Bool
+	cookies   atomic.Bool
+	maxDepth  int
+	bootstrap string
+	search    []string
+	ndots     int
 }
 
 type resolver struct {
@@ -46,8 +89,15 @@ type resolver struct {
 	xchgs   *xchgMgr
 	address *net.UDPAddr
 	qps     int
-	rate    ratelimit.Limiter
+	rate    atomic.Pointer[ratelimit.Limiter]
 	stats   *stats
+	noEDNS  atomic.Bool
+	filters atomic.Bool
+	proto   string
+	stream  streamer
+	tcp     *tcpPipeline
+	cookies *cookieJar
+	running sync.Once
 }
 
 func (r *Resolvers) initializeResolver(qps int, addr string) *resolver {
@@ -65,14 +115,35 @@ func (r *Resolvers) initializeResolver(qps int, addr string) *resolver {
 			xchgs:   newXchgMgr(r.timeout),
 			address: uaddr,
 			qps:     qps,
-			rate:    ratelimit.New(qps),
 			stats:   new(stats),
+			tcp:     newTCPPipeline(addrKey(uaddr)),
+			cookies: newCookieJar(),
+		}
+		res.setQPS(qps)
+		res.xchgs.setMaxInFlight(r.inflight)
+		if r.tcpOnly.Load() {
+			res.useTCP()
 		}
-		go res.processRequests()
 	}
 	return res
 }
 
+// Replaces the rate limiter of the resolver, so the requests not yet sent are limited to the
+// QPS provided. Must be called holding the lock of the pool once the resolver has been added.
+func (r *resolver) setQPS(qps int) {
+	rate := ratelimit.New(qps)
+
+	r.qps = qps
+	r.rate.Store(&rate)
+}
+
+// Queues the request on the resolver, starting the goroutine that sends the requests the
+// first time the resolver is used.
+func (r *resolver) enqueue(req *request) {
+	r.running.Do(func() { go r.processRequests() })
+	r.queue.Append(req)
+}
+
 func (r *resolver) stop() {
 	select {
 	case <-r.done:
@@ -81,14 +152,23 @@ func (r *resolver) stop() {
 	}
 	// Send the signal to shutdown and close the connection
 	close(r.done)
+	r.pool.unregister(r)
+	if r.stream != nil {
+		r.stream.close()
+	}
+	if r.tcp != nil {
+		r.tcp.close()
+	}
 	// Drain the xchgs of all messages and allow callers to return
 	for _, req := range r.xchgs.removeAll() {
+		r.pool.expired(r, req)
 		req.errNoResponse()
 		req.release()
 	}
 }
 
-// NewResolvers initializes a Resolvers.
+// NewResolvers initializes a Resolvers. No sockets are opened and no goroutines are started
+// until the pool is started by Start or by sending the first query.
 func NewResolvers() *Resolvers {
 	responses := queue.NewQueue()
 	r := &Resolvers{
@@ -98,17 +178,59 @@ func NewResolvers() *Resolvers {
 		pool:      newRandomSelector(),
 		rmap:      make(map[string]struct{}),
 		wildcards: make(map[string]*wildcard),
+		auth:      newAuthServers(),
+		nx:        newNXPruner(),
+		parts:     newPartitions(),
+		scope:     new(queryScope),
+		verify:    new(nxVerifier),
+		hosts:     newHostsOverrides(),
+		stubs:     newStubZones(),
+		routes:    newRoutingRules(),
+		mem:       newMemoryWatchdog(),
+		jitter:    newZoneJitter(),
+		latency:   newLatencyTracer(),
+		breaker:   newCircuitBreaker(),
+		retired:   new(retiredResolvers),
+		events:    newEventBus(),
 		queue:     queue.NewQueue(),
 		resps:     responses,
 		timeout:   DefaultTimeout,
 		options:   new(ThresholdOptions),
+		maxDepth:  DefaultMaxAliasDepth,
 	}
+	return r
+}
 
+// Start opens the sockets and starts the goroutines of the resolver pool, allowing applications
+// that embed the pool to control when the background work begins. Otherwise, the pool is started
+// when the first query is sent. The pool created by Clone also starts the pool it was cloned from.
+// An error is returned when the pool was stopped or the sockets could not be opened.
+func (r *Resolvers) Start() error {
+	r.startOnce.Do(func() { r.startErr = r.start() })
+	return r.startErr
+}
+
+func (r *Resolvers) start() error {
+	select {
+	case <-r.done:
+		return errors.New("the resolver pool has been stopped")
+	default:
+	}
+
+	var err error
+	if r.parent != nil {
+		err = r.parent.Start()
+	} else {
+		err = r.conns.Start()
+	}
+	// The goroutines are started regardless, so the queries fail instead of waiting in the queue
 	go r.timeouts()
 	go r.enforceMaxQPS()
-	go r.thresholdChecks()
-	go r.processResponses()
-	return r
+	if !r.cloned {
+		go r.thresholdChecks()
+		go r.processResponses()
+	}
+	return err
 }
 
 // Len returns the number of resolvers that have been added to the pool.
@@ -132,15 +254,13 @@ func (r *Resolvers) SetTimeout(d time.Duration) {
 
 	r.timeout = d
 	r.updateResolverTimeouts()
+	if r.fallback != nil {
+		r.fallback.pool.SetTimeout(d)
+	}
 }
 
 func (r *Resolvers) updateResolverTimeouts() {
-	all := r.pool.AllResolvers()
-	if r.detector != nil {
-		all = append(all, r.detector)
-	}
-
-	for _, res := range all {
+	for _, res := range r.allResolvers(r.detectors...) {
 		select {
 		case <-res.done:
 		default:
@@ -149,6 +269,19 @@ func (r *Resolvers) updateResolverTimeouts() {
 	}
 }
 
+// SetMaxInFlight limits the number of outstanding requests for each nameserver used by the pool.
+// Requests beyond the limit remain queued until a request in flight is answered or expires.
+// A value of zero removes the limit.
+func (r *Resolvers) SetMaxInFlight(max int) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.inflight = max
+	for _, res := range r.allResolvers(r.detectors...) {
+		res.xchgs.setMaxInFlight(max)
+	}
+}
+
 // QPS returns the maximum queries per second provided by the resolver pool.
 func (r *Resolvers) QPS() int {
 	r.Lock()
@@ -158,25 +291,70 @@ func (r *Resolvers) QPS() int {
 }
 
 // SetMaxQPS allows a preferred maximum number of queries per second to be specified for the pool.
+// It can be called while queries are being sent, but the pool cannot exceed the combined QPS of its resolvers.
 func (r *Resolvers) SetMaxQPS(qps int) {
+	r.Lock()
+	defer r.Unlock()
+
 	r.qps = qps
-	if qps > 0 {
-		r.maxSet = true
-		r.rate = ratelimit.New(qps)
+	r.maxSet = qps > 0
+	r.setMaxRate(qps)
+}
+
+// ScaleResolverQPS multiplies the QPS of each resolver in the pool by the factor provided, with
+// each resolver keeping at least one query per second. When a maximum has not been set using
+// SetMaxQPS, the QPS of the pool becomes the combined QPS of the resolvers. A pool created by
+// Clone shares the resolvers, so the QPS of the resolvers also changes for the parent pool.
+func (r *Resolvers) ScaleResolverQPS(factor float64) {
+	r.Lock()
+	defer r.Unlock()
+
+	var total int
+	for _, res := range r.pool.AllResolvers() {
+		qps := int(math.Round(float64(res.qps) * factor))
+		if qps < 1 {
+			qps = 1
+		}
+
+		res.setQPS(qps)
+		total += qps
+	}
+	if !r.maxSet {
+		r.qps = total
+		r.setMaxRate(total)
+	}
+}
+
+// Replaces the rate limiter shared by the requests sent from the pool, which is removed when
+// the QPS provided is not positive. Must be called holding the lock.
+func (r *Resolvers) setMaxRate(qps int) {
+	if qps <= 0 {
+		r.rate.Store(nil)
 		return
 	}
-	r.maxSet = false
-	r.rate = nil
+
+	rate := ratelimit.New(qps)
+	r.rate.Store(&rate)
 }
 
 // AddResolvers initializes and adds new resolvers to the pool of resolvers.
+// Resolvers specified by hostname are added for each IP address bootstrapped for the hostname.
 func (r *Resolvers) AddResolvers(qps int, addrs ...string) error {
-	r.Lock()
-	defer r.Unlock()
-
 	if qps == 0 {
 		return errors.New("failed to provide a maximum number of queries per second greater than zero")
 	}
+	if r.cloned {
+		return errors.New("resolvers cannot be added to a pool created by Clone")
+	}
+
+	r.addResolvers(qps, nil, r.expandHostnames(addrs)...)
+	return nil
+}
+
+// Adds the resolvers not already in the pool, with setup applied to each before it is added.
+func (r *Resolvers) addResolvers(qps int, setup func(res *resolver), addrs ...string) {
+	r.Lock()
+	defer r.Unlock()
 
 	for _, addr := range addrs {
 		if _, _, err := net.SplitHostPort(addr); err != nil {
@@ -184,10 +362,16 @@ func (r *Resolvers) AddResolvers(qps int, addrs ...string) error {
 			addr = net.JoinHostPort(addr, "53")
 		}
 		// check that this address will not create a duplicate resolver
-		if host, _, err := net.SplitHostPort(addr); err == nil {
-			if _, found := r.rmap[host]; !found {
+		if uaddr, err := net.ResolveUDPAddr("udp", addr); err == nil {
+			if r.deny != nil && r.deny.Contains(addrKey(uaddr)) {
+				continue
+			}
+			if _, found := r.rmap[addrKey(uaddr)]; !found {
 				if res := r.initializeResolver(qps, addr); res != nil {
-					r.rmap[res.address.IP.String()] = struct{}{}
+					if setup != nil {
+						setup(res)
+					}
+					r.rmap[addrKey(res.address)] = struct{}{}
 					r.pool.AddResolver(res)
 					if !r.maxSet {
 						r.qps += qps
@@ -197,10 +381,9 @@ func (r *Resolvers) AddResolvers(qps int, addrs ...string) error {
 		}
 	}
 	// create the new rate limiter for the updated QPS
-	if !r.maxSet {
-		r.rate = ratelimit.New(r.qps)
+	if !r.maxSet && r.qps > 0 {
+		r.setMaxRate(r.qps)
 	}
-	return nil
 }
 
 // Stop will release resources for the resolver pool and all add resolvers.
@@ -211,44 +394,97 @@ func (r *Resolvers) Stop() {
 	default:
 	}
 	close(r.done)
+	r.stopClones()
+	if r.parent != nil {
+		r.parent.removeClone(r)
+	}
 	if r.servRates != nil {
 		r.servRates.Stop()
 	}
-	r.conns.Close()
-
-	all := r.pool.AllResolvers()
-	if d := r.getDetectionResolver(); d != nil {
-		all = append(all, d)
+	if !r.cloned && r.conns != nil {
+		r.conns.Close()
+	}
+	if f := r.fallbackTier(); f != nil {
+		f.pool.Stop()
+	}
+	r.parts.stopAll()
+	if dc := r.detectionConns(); dc != nil {
+		dc.Close()
 	}
 
-	for _, res := range all {
+	for _, res := range r.pool.AllResolvers() {
 		if !r.maxSet {
 			r.qps -= res.qps
 		}
-		res.stop()
 	}
-	r.pool.Close()
+	// The resolvers of the parent pool are not stopped by a pool created by Clone
+	shared := make(map[*resolver]struct{})
+	if r.cloned {
+		for _, res := range r.pool.AllResolvers() {
+			shared[res] = struct{}{}
+		}
+	}
+	for _, res := range r.allResolvers(r.getDetectionResolvers()...) {
+		if _, found := shared[res]; !found {
+			res.stop()
+		}
+	}
+	if !r.cloned {
+		r.pool.Close()
+	}
+	r.events.close()
 }
 
 // Query queues the provided DNS message and returns the response on the provided channel.
+// Messages that do not contain exactly one question are returned with the FORMERR response code.
 func (r *Resolvers) Query(ctx context.Context, msg *dns.Msg, ch chan *dns.Msg) {
 	if msg == nil {
 		ch <- msg
 		return
 	}
+	if ValidateMsg(msg) != nil {
+		msg.Rcode = dns.RcodeFormatError
+		ch <- msg
+		return
+	}
+	_ = r.Start()
+	if !r.scope.contains(msg.Question[0].Name) {
+		ch <- new(dns.Msg).SetRcode(msg, dns.RcodeRefused)
+		return
+	}
 
 	select {
 	case <-ctx.Done():
 	case <-r.done:
 	default:
-		req := reqPool.Get().(*request)
+		if resp := r.hosts.answer(msg); resp != nil {
+			setSource(resp, "", TransportHosts)
+			ch <- resp
+			return
+		}
+		if r.nx.prune(msg) {
+			ch <- new(dns.Msg).SetRcode(msg, dns.RcodeNameError)
+			return
+		}
 
-		req.Msg = msg
-		req.Result = ch
-		if r.servRates != nil {
-			r.servRates.Take(msg.Question[0].Name)
+		if r.denialProofs() {
+			SetDNSSECOK(msg)
+		}
+		if flags := r.headerFlags(ctx); flags != nil {
+			flags.apply(msg)
 		}
-		r.queue.Append(req)
+		// The lookup follows the changes to the DO and CD bits that select the cache entry
+		if resp := r.cachedResponse(ctx, msg); resp != nil {
+			setSource(resp, "", TransportCache)
+			ch <- resp
+			return
+		}
+		if r.verifyNXDomains(ctx) {
+			go r.verifiedQuery(ctx, msg, ch)
+			return
+		}
+
+		r.send(ctx, msg, ch)
 		return
 	}
 
@@ -256,6 +492,63 @@ func (r *Resolvers) Query(ctx context.Context, msg *dns.Msg, ch chan *dns.Msg) {
 	ch <- msg
 }
 
+// Sends the message to the fallback tier when configured, or queues it on the resolver pool.
+func (r *Resolvers) send(ctx context.Context, msg *dns.Msg, ch chan *dns.Msg) {
+	if f := r.fallbackTier(); f != nil {
+		go f.query(ctx, r, msg, ch)
+		return
+	}
+	r.enqueue(ctx, msg, ch)
+}
+
+// Queues the request for the message once the delay for the zone of the name has elapsed.
+func (r *Resolvers) enqueue(ctx context.Context, msg *dns.Msg, ch chan *dns.Msg) {
+	req := reqPool.Get().(*request)
+
+	req.Msg = msg
+	req.Result = ch
+	req.ctx = ctx
+	req.trace = r.latency.start()
+	if r.servRates != nil {
+		r.servRates.Take(msg.Question[0].Name)
+	}
+	// The delay for the zone is applied by a timer, so the caller of Query is not blocked
+	r.jitter.delay(ctx, msg.Question[0].Name, func(err error) {
+		select {
+		case <-r.done:
+			err = errors.New("the resolver pool has been stopped")
+		default:
+		}
+		if err != nil {
+			req.errNoResponse()
+			req.release()
+			return
+		}
+		r.route(req)
+	})
+}
+
+// Places the request on the server selected by a routing rule, the stub zone nameserver
+// or the queue of the pool.
+func (r *Resolvers) route(req *request) {
+	msg := req.Msg
+	if res, recursion := r.routes.resolverFor(msg.Question[0].Name); res != nil {
+		msg.RecursionDesired = recursion
+		req.Res = res
+		req.trace.pick()
+		res.enqueue(req)
+		return
+	}
+	if res := r.stubs.resolverFor(msg.Question[0].Name); res != nil {
+		msg.RecursionDesired = false
+		req.Res = res
+		req.trace.pick()
+		res.enqueue(req)
+		return
+	}
+	r.queue.Append(req)
+}
+
 // Query queues the provided DNS message and sends the response on the returned channel.
 func (r *Resolvers) QueryChan(ctx context.Context, msg *dns.Msg) chan *dns.Msg {
 	ch := make(chan *dns.Msg, 1)
@@ -272,9 +565,45 @@ func (r *Resolvers) QueryBlocking(ctx context.Context, msg *dns.Msg) (*dns.Msg,
 	}
 
 	var err error
-	resp := <-r.QueryChan(ctx, msg)
+	var resp *dns.Msg
+	var attempts int
+	retries := r.getRetries()
+	for attempts < retries+1 {
+		if attempts > 0 {
+			if retryBackoff.Sleep(ctx, attempts-1) != nil {
+				break
+			}
+			msg = msg.Copy()
+			msg.Rcode = dns.RcodeSuccess
+		}
+
+		attempts++
+		if resp = <-r.QueryChan(ctx, msg); resp == nil || resp.Rcode != RcodeNoResponse {
+			break
+		}
+	}
 	if resp == nil {
 		err = errors.New("query failed")
+	} else if attempts > 1 {
+		updateSource(resp, func(s *ResponseSource) { s.Attempts = attempts })
+	}
+	return resp, err
+}
+
+// QueryWithRetries sends a copy of the message using QueryBlocking until a NOERROR or NXDOMAIN
+// response is received, making up to maxQueryAttempts. The last response is returned, which has
+// another rcode when the attempts were exhausted, and an error when a query failed or the context
+// expired before a response was received.
+func (r *Resolvers) QueryWithRetries(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	var err error
+	var resp *dns.Msg
+
+	retryAttempts(ctx, func() bool {
+		resp, err = r.QueryBlocking(ctx, msg.Copy())
+		return err != nil || resp.Rcode == dns.RcodeSuccess || resp.Rcode == dns.RcodeNameError
+	})
+	if err == nil && resp == nil {
+		err = ctx.Err()
 	}
 	return resp, err
 }
@@ -291,18 +620,19 @@ loop:
 				continue loop
 			}
 
-			if r.rate != nil {
-				_ = r.rate.Take()
+			req, ok := element.(*request)
+			if ok && !r.breaker.wait(req, r.done) {
+				req.errNoResponse()
+				req.release()
+				continue loop
 			}
 
-			if req, ok := element.(*request); ok {
-				if res := r.pool.GetResolver(); res != nil {
-					req.Res = res
-					res.queue.Append(req)
-				} else {
-					req.errNoResponse()
-					req.release()
-				}
+			if rate := r.maxRate(); rate != nil {
+				_ = rate.Take()
+			}
+
+			if ok {
+				r.dispatch(req)
 			}
 		}
 	}
@@ -315,6 +645,28 @@ loop:
 	})
 }
 
+// Places the request on the queue of the resolver selected for the name.
+func (r *Resolvers) dispatch(req *request) {
+	defer r.recoverPanic("selecting a resolver", req)
+
+	if res := r.pool.GetResolverFor(req.context(), req.Msg.Question[0].Name); res != nil {
+		req.Res = res
+		req.trace.pick()
+		res.enqueue(req)
+	} else {
+		req.errNoResponse()
+		req.release()
+	}
+}
+
+// Returns the rate limiter shared by the requests sent from the pool, or nil when there is none.
+func (r *Resolvers) maxRate() ratelimit.Limiter {
+	if rate := r.rate.Load(); rate != nil {
+		return *rate
+	}
+	return nil
+}
+
 func (r *Resolvers) processResponses() {
 	for {
 		select {
@@ -324,7 +676,14 @@ func (r *Resolvers) processResponses() {
 		}
 
 		r.resps.Process(func(element interface{}) {
-			if response, ok := element.(*resp); ok && response != nil {
+			response, ok := element.(*resp)
+			if !ok || response == nil {
+				return
+			}
+			// Responses are handled in the order received during a deterministic run
+			if serialized.Load() {
+				r.processSingleResp(response)
+			} else {
 				go r.processSingleResp(response)
 			}
 		})
@@ -332,37 +691,71 @@ func (r *Resolvers) processResponses() {
 }
 
 func (r *Resolvers) processSingleResp(response *resp) {
-	var res *resolver
-	addr, _, _ := net.SplitHostPort(response.Addr.String())
+	defer r.recoverPanic("processing a response", nil)
 
-	if res = r.pool.LookupResolver(addr); res == nil {
-		if detector := r.getDetectionResolver(); detector != nil {
-			if detector.address.IP.String() == addr {
-				res = detector
-			}
-		}
-	}
-	if res == nil {
+	msg := response.Msg
+	if ValidateMsg(msg) != nil {
 		return
 	}
-
-	msg := response.Msg
 	name := msg.Question[0].Name
-	if req := res.xchgs.remove(msg.Id, name); req != nil {
+	r.logPacket("received from", response.Addr, msg)
+
+	var req *request
+	for _, res := range response.Res {
+		if req = res.xchgs.remove(msg.Id, name); req != nil {
+			break
+		}
+	}
+
+	if req != nil {
+		defer r.recoverPanic("processing a response", req)
+
 		req.Resp = msg
-		if req.Resp.Truncated {
+		req.trace.receive()
+		req.Res.collectRTT(time.Since(req.Timestamp))
+		req.Res.collectSize(response.Size, msg)
+		req.Res.cookies.observe(msg)
+		if req.Res.ednsDowngrade(req) || req.Res.cookieRetry(req) {
+			go req.Res.writeReq(req)
+		} else if req.Resp.Truncated && req.Res.stream == nil {
 			go req.Res.tcpExchange(req)
 		} else {
-			req.Result <- req.Resp
-			req.Res.collectStats(req.Resp)
-			if r.servRates != nil {
-				r.servRates.Success(name)
+			r.nx.observe(req.Resp)
+			if req.Resp.Rcode == dns.RcodeRefused {
+				// Avoid sending queries for this zone to the nameserver that refused to answer
+				r.pool.Demote(req.Res, name)
+			}
+			req.Res.annotateFiltered(req.Resp)
+			transport := TransportUDP
+			if response.Transport != "" {
+				transport = response.Transport
+			}
+			setSource(req.Resp, req.Res.address.String(), transport)
+			r.events.publishResponse(req.Res.address.String(), req.Resp)
+			r.getCache().Add(req.Resp)
+			r.breaker.success()
+			// The responses processed in order during a deterministic run are still delivered
+			// asynchronously, so a caller not reading its channel cannot hold up the others
+			if serialized.Load() {
+				go r.deliver(req, name)
+			} else {
+				r.deliver(req, name)
 			}
-			req.release()
 		}
 	}
 }
 
+// Sends the response to the caller and releases the request.
+func (r *Resolvers) deliver(req *request, name string) {
+	req.Result <- req.Resp
+	req.trace.deliver()
+	req.Res.collectStats(req.Resp)
+	if r.servRates != nil {
+		r.servRates.Success(name)
+	}
+	req.release()
+}
+
 func (r *Resolvers) timeouts() {
 	r.Lock()
 	d := r.timeout / 2
@@ -378,17 +771,14 @@ func (r *Resolvers) timeouts() {
 		default:
 		}
 
-		all := r.pool.AllResolvers()
-		if d := r.getDetectionResolver(); d != nil {
-			all = append(all, d)
-		}
-
-		for _, res := range all {
+		for _, res := range r.allResolvers(r.getDetectionResolvers()...) {
 			select {
 			case <-r.done:
 				return
 			default:
 				for _, req := range res.xchgs.removeExpired() {
+					r.expired(res, req)
+					r.breaker.failure(req)
 					req.errNoResponse()
 					res.collectStats(req.Msg)
 					if r.servRates != nil {
@@ -401,6 +791,35 @@ func (r *Resolvers) timeouts() {
 	}
 }
 
+// Removes the resolver from the address registries of the connections used by the pool.
+func (r *Resolvers) unregister(res *resolver) {
+	for _, conns := range []*connections{r.conns, r.detectionConns()} {
+		if conns != nil {
+			conns.registry.unregister(res)
+		}
+	}
+}
+
+// Returns the resolvers in the pool, along with the detectors and authoritative resolvers.
+func (r *Resolvers) allResolvers(detectors ...*resolver) []*resolver {
+	all := r.pool.AllResolvers()
+	all = append(all, detectors...)
+	all = append(all, r.allAuthResolvers()...)
+	all = append(all, r.stubs.allResolvers()...)
+	all = append(all, r.routes.allResolvers()...)
+	all = append(all, r.retired.allResolvers()...)
+
+	var unique []*resolver
+	set := make(map[*resolver]struct{}, len(all))
+	for _, res := range all {
+		if _, found := set[res]; !found {
+			set[res] = struct{}{}
+			unique = append(unique, res)
+		}
+	}
+	return unique
+}
+
 func (r *resolver) processRequests() {
 	for {
 		select {
@@ -411,32 +830,103 @@ func (r *resolver) processRequests() {
 
 		r.queue.Process(func(element interface{}) {
 			if req, ok := element.(*request); ok && req != nil {
-				_ = r.rate.Take()
-				go r.writeReq(req)
+				r.send(req)
 			}
 		})
 	}
 }
 
+// Waits for a slot and the rate limit of the resolver before writing the request.
+func (r *resolver) send(req *request) {
+	defer r.pool.recoverPanic("sending a request", req)
+
+	if !r.xchgs.acquire(req.context(), req, r.done) {
+		req.errNoResponse()
+		req.release()
+		return
+	}
+	_ = (*r.rate.Load()).Take()
+	go r.writeReq(req)
+}
+
 func (r *resolver) writeReq(req *request) {
+	defer r.pool.recoverPanic("writing a request", req)
+
+	r.writeReqWith(req, r.pool.conns)
+}
+
+func (r *resolver) writeReqWith(req *request, conns *connections) {
+	// The requests sent directly to a resolver, such as for wildcard detection, start the pool
+	_ = r.pool.Start()
+	// The retries and the requests sent directly to a resolver did not pass through send,
+	// and must also hold a slot so the maximum number of requests in flight is enforced
+	if !r.xchgs.acquire(req.context(), req, r.done) {
+		req.errNoResponse()
+		req.release()
+		return
+	}
+	// The requests abandoned while waiting for the rate limit are not written
+	if req.context().Err() != nil {
+		r.xchgs.abandon(req)
+		req.errNoResponse()
+		req.release()
+		return
+	}
+	r.pool.applyEDNS(req.Msg)
+	if r.noEDNS.Load() {
+		removeEDNS(req.Msg)
+	}
+	r.addCookie(req.Msg)
+	if r.stream != nil {
+		r.streamExchange(req)
+		return
+	}
+
+	// The request can be released by the response or the timeout as soon as it has been added
+	// to the exchanges, so the fields used for writing the message are read beforehand
 	msg := req.Msg.Copy()
+	ctx := req.context()
 	req.Timestamp = time.Now()
+	req.trace.send()
 
-	if r.xchgs.add(req) == nil {
-		if err := r.pool.conns.WriteMsg(msg, r.address); err != nil {
-			_ = r.xchgs.remove(msg.Id, msg.Question[0].Name)
+	if conns != nil {
+		conns.registry.register(r)
+	}
+	if r.xchgs.add(req) != nil {
+		req.errNoResponse()
+		req.release()
+		return
+	}
+	r.writeMsg(ctx, msg, conns)
+}
+
+// Writes the message of the request added to the exchanges, which no longer owns the request.
+func (r *resolver) writeMsg(ctx context.Context, msg *dns.Msg, conns *connections) {
+	defer r.recoverExchange("writing a request", msg)
+
+	r.collectQuery()
+	if err := conns.WriteMsg(ctx, msg, r.address); err != nil {
+		if req := r.xchgs.remove(msg.Id, msg.Question[0].Name); req != nil {
 			req.errNoResponse()
 			req.release()
 		}
+	} else {
+		r.pool.logPacket("sent to", r.address, msg)
 	}
 }
 
 func (r *resolver) tcpExchange(req *request) {
-	client := dns.Client{
-		Net:     "tcp",
-		Timeout: time.Minute,
-	}
-	if m, _, err := client.Exchange(req.Msg, r.address.String()); err == nil {
+	defer r.pool.recoverPanic("exchanging over TCP", req)
+
+	r.pool.logPacket("sent over TCP to", r.address, req.Msg)
+	if m, err := r.tcp.exchange(req.context(), req.Msg, tcpExchangeTimeout); err == nil {
+		r.pool.logPacket("received over TCP from", r.address, m)
+		r.cookies.observe(m)
+		r.pool.nx.observe(m)
+		r.annotateFiltered(m)
+		setSource(m, r.address.String(), TransportTCP)
+		r.pool.events.publishResponse(r.address.String(), m)
+		r.pool.getCache().Add(m)
 		req.Result <- m
 		r.collectStats(m)
 	} else {
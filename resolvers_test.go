@@ -6,9 +6,12 @@ package resolve
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"net"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -70,6 +73,118 @@ func TestPoolQuery(t *testing.T) {
 	}
 }
 
+func TestSetMaxInFlight(t *testing.T) {
+	var outstanding, peak int32
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		cur := atomic.AddInt32(&outstanding, 1)
+		for {
+			if p := atomic.LoadInt32(&peak); cur <= p || atomic.CompareAndSwapInt32(&peak, p, cur) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&outstanding, -1)
+
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Answer = append(m.Answer, testARecord(req.Question[0].Name, "192.168.1.1"))
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+	r.SetMaxInFlight(2)
+
+	num := 8
+	ch := make(chan *dns.Msg, num)
+	for i := 0; i < num; i++ {
+		r.Query(context.Background(), QueryMsg("caffix.net", dns.TypeA), ch)
+	}
+	for i := 0; i < num; i++ {
+		if resp := <-ch; resp.Rcode != dns.RcodeSuccess {
+			t.Errorf("the queued request did not receive a response")
+		}
+	}
+
+	if p := atomic.LoadInt32(&peak); p > 2 {
+		t.Errorf("expected at most 2 requests in flight, got %d", p)
+	}
+}
+
+func TestSetMaxInFlightRetries(t *testing.T) {
+	var outstanding, peak, received int32
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		atomic.AddInt32(&received, 1)
+		cur := atomic.AddInt32(&outstanding, 1)
+		for {
+			if p := atomic.LoadInt32(&peak); cur <= p || atomic.CompareAndSwapInt32(&peak, p, cur) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&outstanding, -1)
+
+		// Each query is rejected with a new server cookie, so every request is sent twice
+		cookie := extractCookie(req)
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.SetEdns0(dns.DefaultMsgSize, false)
+		m.Rcode = dns.RcodeBadCookie
+		if len(cookie) >= 2*clientCookieSize {
+			m.IsEdns0().Option = append(m.IsEdns0().Option, &dns.EDNS0_COOKIE{
+				Code:   dns.EDNS0COOKIE,
+				Cookie: cookie[:2*clientCookieSize] + fmt.Sprintf("%016x", atomic.LoadInt32(&received)),
+			})
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+	r.SetCookies(true)
+	r.SetMaxInFlight(2)
+
+	num := 8
+	ch := make(chan *dns.Msg, num)
+	for i := 0; i < num; i++ {
+		r.Query(context.Background(), QueryMsg(fmt.Sprintf("www%d.caffix.net", i), dns.TypeA), ch)
+	}
+	for i := 0; i < num; i++ {
+		<-ch
+	}
+
+	if n := atomic.LoadInt32(&received); n != int32(2*num) {
+		t.Errorf("expected %d queries including the retries, got %d", 2*num, n)
+	}
+	if p := atomic.LoadInt32(&peak); p > 2 {
+		t.Errorf("expected at most 2 requests in flight with the retries, got %d", p)
+	}
+	// The slots of the finished requests were all made available again
+	res := r.pool.GetResolver()
+	res.xchgs.Lock()
+	held := res.xchgs.inflight
+	res.xchgs.Unlock()
+	if held != 0 {
+		t.Errorf("expected no slots held after the requests finished, got %d", held)
+	}
+}
+
 func TestLen(t *testing.T) {
 	r := NewResolvers()
 	defer r.Stop()
@@ -109,16 +224,76 @@ func TestQPS(t *testing.T) {
 		t.Errorf("the value returned by QPS did not equal the qps provided to AddMaxQPS")
 	}
 	// A rate limiter should now be setup, since a QPS greater than zero has been provided
-	if !r.maxSet || r.rate == nil {
+	if !r.maxSet || r.maxRate() == nil {
 		t.Errorf("the rate limiter was not setup after providing a qps greater than zero")
 	}
 	// The rate limiter should be removed once we zero out the qps
 	r.SetMaxQPS(0)
-	if r.maxSet || r.rate != nil {
+	if r.maxSet || r.maxRate() != nil {
 		t.Errorf("the rate limiter was not removed after providing a qps of zero")
 	}
 }
 
+func TestSetMaxQPSWhileRunning(t *testing.T) {
+	dns.HandleFunc("qps.net.", typeAHandler)
+	defer dns.HandleRemove("qps.net.")
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(1000, addrstr)
+	defer r.Stop()
+
+	num := 100
+	ch := make(chan *dns.Msg, num)
+	for i := 0; i < num; i++ {
+		if i%10 == 0 {
+			r.SetMaxQPS(500 + i)
+		}
+		r.Query(context.Background(), QueryMsg("qps.net", dns.TypeA), ch)
+	}
+	for i := 0; i < num; i++ {
+		<-ch
+	}
+
+	if qps := r.QPS(); qps != 590 {
+		t.Errorf("Got: %d; Expected: %d", qps, 590)
+	}
+}
+
+func TestScaleResolverQPS(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+	_ = r.AddResolvers(10, "192.168.1.1", "192.168.1.2")
+	_ = r.AddResolvers(1, "192.168.1.3")
+
+	r.ScaleResolverQPS(1.25)
+	if qps := r.QPS(); qps != 27 {
+		t.Errorf("the QPS of the pool was not the combined QPS: %d", qps)
+	}
+	want := map[string]int{"192.168.1.1:53": 13, "192.168.1.2:53": 13, "192.168.1.3:53": 1}
+	for _, res := range r.pool.AllResolvers() {
+		if qps := want[res.address.String()]; res.qps != qps {
+			t.Errorf("%s: Got: %d; Expected: %d", res.address, res.qps, qps)
+		}
+	}
+
+	r.SetMaxQPS(5)
+	r.ScaleResolverQPS(0.1)
+	if qps := r.QPS(); qps != 5 {
+		t.Errorf("the maximum QPS of the pool was changed to %d", qps)
+	}
+	for _, res := range r.pool.AllResolvers() {
+		if res.qps < 1 {
+			t.Errorf("%s: the QPS was lowered to %d", res.address, res.qps)
+		}
+	}
+}
+
 func TestAddResolvers(t *testing.T) {
 	r := NewResolvers()
 	defer r.Stop()
@@ -132,6 +307,58 @@ func TestAddResolvers(t *testing.T) {
 	}
 }
 
+func TestResolversOnDifferentPorts(t *testing.T) {
+	var servers []*dns.Server
+	var addrs []string
+	for _, ip := range []string{"192.168.1.1", "192.168.1.2"} {
+		addr := ip
+		mux := dns.NewServeMux()
+		mux.HandleFunc("caffix.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(req)
+			m.Answer = append(m.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET},
+				A:   net.ParseIP(addr),
+			})
+			_ = w.WriteMsg(m)
+		})
+
+		s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+		if err != nil {
+			t.Fatalf("unable to run test server: %v", err)
+		}
+		servers = append(servers, s)
+		addrs = append(addrs, addrstr)
+	}
+	defer func() {
+		for _, s := range servers {
+			_ = s.Shutdown()
+		}
+	}()
+
+	r := NewResolvers()
+	defer r.Stop()
+
+	_ = r.AddResolvers(100, append(addrs, addrs[0])...)
+	if l := r.Len(); l != 2 {
+		t.Fatalf("expected 2 resolvers on different ports of the same address, got %d", l)
+	}
+
+	answers := make(map[string]struct{})
+	for i := 0; i < 50; i++ {
+		resp, err := r.QueryBlocking(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+		if err != nil || resp.Rcode != dns.RcodeSuccess {
+			t.Fatalf("the query failed")
+		}
+		for _, a := range ExtractAnswers(resp) {
+			answers[a.Data] = struct{}{}
+		}
+	}
+	if len(answers) != 2 {
+		t.Errorf("the responses were not received from both resolvers: %v", answers)
+	}
+}
+
 func TestStopped(t *testing.T) {
 	r := NewResolvers()
 	_ = r.AddResolvers(10, "8.8.8.8")
@@ -154,6 +381,50 @@ func TestStopped(t *testing.T) {
 	r.Stop()
 }
 
+func TestLazyStart(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, "8.8.8.8")
+	defer r.Stop()
+
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines were started by constructing the resolver pool", n-before)
+	}
+	r.conns.Lock()
+	started, sockets := r.conns.started, len(r.conns.conns)
+	r.conns.Unlock()
+	if started || sockets > 0 {
+		t.Errorf("the sockets were opened before the resolver pool was started")
+	}
+
+	if err := r.Start(); err != nil {
+		t.Fatalf("failed to start the resolver pool: %v", err)
+	}
+	r.conns.Lock()
+	started, sockets = r.conns.started, len(r.conns.conns)
+	r.conns.Unlock()
+	if !started || sockets == 0 {
+		t.Errorf("the sockets were not opened when the resolver pool was started")
+	}
+	if n := runtime.NumGoroutine(); n <= before {
+		t.Errorf("no goroutines were started with the resolver pool")
+	}
+	// It should be safe to start the resolver pool more than once
+	if err := r.Start(); err != nil {
+		t.Errorf("starting the resolver pool again failed: %v", err)
+	}
+}
+
+func TestStartAfterStop(t *testing.T) {
+	r := NewResolvers()
+	r.Stop()
+
+	if err := r.Start(); err == nil {
+		t.Errorf("the stopped resolver pool was started")
+	}
+}
+
 func TestQuery(t *testing.T) {
 	dns.HandleFunc("caffix.net.", typeAHandler)
 	defer dns.HandleRemove("caffix.net.")
@@ -282,6 +553,43 @@ func TestQueryTimeout(t *testing.T) {
 	}
 }
 
+func TestConcurrentQueriesWithContext(t *testing.T) {
+	dns.HandleFunc("concurrent.net.", typeAHandler)
+	defer dns.HandleRemove("concurrent.net.")
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(1000, addrstr)
+	defer r.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// The responses race the writes of the requests, which are released once answered
+	var wg sync.WaitGroup
+	var failures atomic.Int32
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp, err := r.QueryBlocking(ctx, QueryMsg("concurrent.net", dns.TypeA))
+			if err != nil || resp.Rcode != dns.RcodeSuccess {
+				failures.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := failures.Load(); n > 0 {
+		t.Errorf("%d of the concurrent queries failed", n)
+	}
+}
+
 func TestEdgeCases(t *testing.T) {
 	dns.HandleFunc("google.com.", typeAHandler)
 	defer dns.HandleRemove("google.com.")
@@ -307,6 +615,57 @@ func TestEdgeCases(t *testing.T) {
 	}
 }
 
+func TestResponseRoutingWithoutSelector(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, typeAHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	defer r.Stop()
+	// The resolver is never added to the selector
+	res := r.initializeResolver(10, addrstr)
+	defer res.stop()
+
+	ch := make(chan *dns.Msg, 1)
+	res.writeReq(&request{Res: res, Msg: QueryMsg(name, dns.TypeA), Result: ch})
+	if resp := <-ch; resp.Rcode != dns.RcodeSuccess || len(resp.Answer) == 0 {
+		t.Errorf("the response was not routed to the resolver")
+	}
+
+	res.stop()
+	if len(r.conns.registry.lookup(res.address)) != 0 {
+		t.Errorf("the stopped resolver remained in the address registry")
+	}
+}
+
+func TestQueryInvalidQuestions(t *testing.T) {
+	r := NewResolvers()
+	_ = r.AddResolvers(10, "8.8.8.8")
+	defer r.Stop()
+
+	multiple := QueryMsg("caffix.net", dns.TypeA)
+	multiple.Question = append(multiple.Question, multiple.Question[0])
+
+	for _, msg := range []*dns.Msg{new(dns.Msg), multiple} {
+		resp, err := r.QueryBlocking(context.Background(), msg)
+		if err != nil || resp.Rcode != dns.RcodeFormatError {
+			t.Errorf("a message with %d questions was not rejected", len(msg.Question))
+		}
+		if r.WildcardDetected(context.Background(), resp, "caffix.net") {
+			t.Errorf("a message with %d questions was checked for a wildcard", len(msg.Question))
+		}
+	}
+
+	// Responses without a single question must not reach the resolvers
+	r.processSingleResp(&resp{Msg: new(dns.Msg), Addr: &net.UDPAddr{IP: net.ParseIP("8.8.8.8"), Port: 53}})
+}
+
 func TestBadWriteNextMsg(t *testing.T) {
 	name := "caffix.net."
 	dns.HandleFunc(name, typeAHandler)
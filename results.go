@@ -0,0 +1,81 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build go1.23
+
+package resolve
+
+import (
+	"context"
+	"iter"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// The number of requests kept in flight by Results when the pool has no QPS.
+const defaultResultsWindow int = 100
+
+// Results returns an iterator over the responses for the names produced by seq, with a query
+// sent for each of the provided types (default A). The names are pulled from seq as responses are
+// received, so the number of outstanding requests is bounded by the QPS of the pool. The name
+// yielded with each response is the lowercase name from the question without the trailing dot.
+// Breaking out of the loop stops the consumption of seq and discards the responses still in flight.
+func (r *Resolvers) Results(ctx context.Context, seq iter.Seq[string], qtypes ...uint16) iter.Seq2[string, *dns.Msg] {
+	if len(qtypes) == 0 {
+		qtypes = []uint16{dns.TypeA}
+	}
+
+	return func(yield func(string, *dns.Msg) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		window := r.QPS()
+		if window <= 0 {
+			window = defaultResultsWindow
+		}
+		// The buffer allows the requests in flight to complete after the loop has been exited
+		ch := make(chan *dns.Msg, window)
+		sem := make(chan struct{}, window)
+		total := make(chan int, 1)
+
+		go func() {
+			var sent int
+			defer func() { total <- sent }()
+
+			seq(func(name string) bool {
+				for _, qtype := range qtypes {
+					select {
+					case <-ctx.Done():
+						return false
+					case sem <- struct{}{}:
+					}
+
+					r.Query(ctx, QueryMsg(name, qtype), ch)
+					sent++
+				}
+				return true
+			})
+		}()
+
+		expected := -1
+		for received := 0; expected < 0 || received < expected; {
+			select {
+			case n := <-total:
+				expected = n
+			case resp := <-ch:
+				received++
+				<-sem
+
+				var name string
+				if resp != nil && len(resp.Question) > 0 {
+					name = strings.ToLower(RemoveLastDot(resp.Question[0].Name))
+				}
+				if !yield(name, resp) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build go1.23
+
+package resolve
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestResults(t *testing.T) {
+	dns.HandleFunc("results.net.", typeAHandler)
+	defer dns.HandleRemove("results.net.")
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	input := []string{"www.results.net", "mail.results.net", "ftp.results.net", "vpn.results.net"}
+	types := map[string]int{}
+	for name, resp := range r.Results(context.Background(), slices.Values(input), dns.TypeA, dns.TypeAAAA) {
+		if resp == nil || resp.Question[0].Name != name+"." {
+			t.Errorf("the response did not match the name %s", name)
+		}
+		types[name]++
+	}
+	for _, name := range input {
+		if types[name] != 2 {
+			t.Errorf("expected 2 responses for %s, got %d", name, types[name])
+		}
+	}
+
+	var count int
+	for range r.Results(context.Background(), slices.Values(input)) {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("the loop was not exited after the first response")
+	}
+}
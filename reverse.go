@@ -0,0 +1,153 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// The number of concurrent reverse lookups performed by ReverseSweep.
+const numOfSweepWorkers int = 100
+
+// ReversePTR is the result of a reverse DNS lookup for an IP address. When the reverse name
+// is delegated using CNAME records (RFC 2317), the aliases followed are listed in order and
+// the PTR names are those found at the end of the chain.
+type ReversePTR struct {
+	Addr    string
+	Name    string
+	Aliases []string
+	PTR     []string
+}
+
+// ReverseLookup obtains the PTR names for the provided IP address, following CNAME records
+// into classless in-addr.arpa delegations so the final PTR is attributed to the address.
+func (r *Resolvers) ReverseLookup(ctx context.Context, addr string) (*ReversePTR, error) {
+	msg := ReverseMsg(addr)
+	if msg == nil {
+		return nil, fmt.Errorf("ReverseLookup: %s is not a valid IP address", addr)
+	}
+
+	result := &ReversePTR{
+		Addr: net.ParseIP(addr).String(),
+		Name: strings.ToLower(RemoveLastDot(msg.Question[0].Name)),
+	}
+
+	name := result.Name
+	depth := r.maxAliasDepth()
+	for len(result.Aliases) <= depth {
+		resp, err := r.reverseQuery(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		next := name
+		// Follow the CNAME chain provided within the response
+		for len(result.Aliases) <= depth {
+			target := cnameTarget(resp, next)
+			if target == "" {
+				break
+			}
+			result.Aliases = append(result.Aliases, target)
+			next = target
+		}
+
+		for _, ptr := range Answers[*dns.PTR](resp) {
+			if strings.EqualFold(RemoveLastDot(ptr.Hdr.Name), next) {
+				result.PTR = append(result.PTR, strings.ToLower(RemoveLastDot(ptr.Ptr)))
+			}
+		}
+		if len(result.PTR) > 0 || next == name {
+			break
+		}
+		// Query the target of the classless delegation
+		name = next
+	}
+
+	if len(result.PTR) == 0 {
+		return result, fmt.Errorf("ReverseLookup: %s PTR record not found", result.Addr)
+	}
+	return result, nil
+}
+
+// ReverseSweep performs a reverse DNS lookup for each address within the provided CIDR block and
+// sends the results with PTR names on the returned channel. The channel is closed once the sweep
+// has completed or the context expires.
+func (r *Resolvers) ReverseSweep(ctx context.Context, cidr string) (<-chan *ReversePTR, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("ReverseSweep: %v", err)
+	}
+
+	addrs := make(chan netip.Addr, numOfSweepWorkers)
+	go func() {
+		defer close(addrs)
+
+		for addr := prefix.Masked().Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+			select {
+			case <-ctx.Done():
+				return
+			case addrs <- addr:
+			}
+		}
+	}()
+	return r.reverseLookups(ctx, addrs), nil
+}
+
+// Performs the reverse lookups for the addresses received until the channel is closed, and sends
+// the results with PTR names on the returned channel.
+func (r *Resolvers) reverseLookups(ctx context.Context, addrs <-chan netip.Addr) <-chan *ReversePTR {
+	ch := make(chan *ReversePTR, numOfSweepWorkers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < numOfSweepWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for addr := range addrs {
+				if result, err := r.ReverseLookup(ctx, addr.String()); err == nil {
+					select {
+					case <-ctx.Done():
+					case ch <- result:
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+	return ch
+}
+
+func (r *Resolvers) reverseQuery(ctx context.Context, name string) (*dns.Msg, error) {
+	resp, err := r.QueryWithRetries(ctx, QueryMsg(name, dns.TypePTR))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode == dns.RcodeSuccess {
+		return resp, nil
+	}
+	return nil, fmt.Errorf("ReverseLookup: %s PTR record not found", name)
+}
+
+// Returns the target of the CNAME record in the response owned by the provided name.
+func cnameTarget(resp *dns.Msg, name string) string {
+	for _, cname := range Answers[*dns.CNAME](resp) {
+		if strings.EqualFold(RemoveLastDot(cname.Hdr.Name), name) {
+			return strings.ToLower(RemoveLastDot(cname.Target))
+		}
+	}
+	return ""
+}
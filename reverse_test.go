@@ -0,0 +1,128 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func reverseHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	cname := func(name, target string) dns.RR {
+		return &dns.CNAME{
+			Hdr:    dns.RR_Header{Name: name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET},
+			Target: target,
+		}
+	}
+	ptr := func(name, target string) dns.RR {
+		return &dns.PTR{
+			Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypePTR, Class: dns.ClassINET},
+			Ptr: target,
+		}
+	}
+
+	switch name := req.Question[0].Name; name {
+	case "5.2.0.192.in-addr.arpa.":
+		// The alias into the classless delegation is not followed by the server
+		m.Answer = append(m.Answer, cname(name, "5.0-25.2.0.192.in-addr.arpa."))
+	case "5.0-25.2.0.192.in-addr.arpa.":
+		m.Answer = append(m.Answer, ptr(name, "host5.caffix.net."))
+	case "6.2.0.192.in-addr.arpa.":
+		m.Answer = append(m.Answer, cname(name, "6.0-25.2.0.192.in-addr.arpa."))
+		m.Answer = append(m.Answer, ptr("6.0-25.2.0.192.in-addr.arpa.", "host6.caffix.net."))
+	case "7.2.0.192.in-addr.arpa.":
+		m.Answer = append(m.Answer, ptr(name, "host7.caffix.net."))
+	default:
+		m.Rcode = dns.RcodeNameError
+	}
+	_ = w.WriteMsg(m)
+}
+
+func TestReverseLookup(t *testing.T) {
+	dns.HandleFunc("2.0.192.in-addr.arpa.", reverseHandler)
+	defer dns.HandleRemove("2.0.192.in-addr.arpa.")
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	cases := []struct {
+		addr    string
+		aliases []string
+		ptr     []string
+	}{
+		{addr: "192.0.2.5", aliases: []string{"5.0-25.2.0.192.in-addr.arpa"}, ptr: []string{"host5.caffix.net"}},
+		{addr: "192.0.2.6", aliases: []string{"6.0-25.2.0.192.in-addr.arpa"}, ptr: []string{"host6.caffix.net"}},
+		{addr: "192.0.2.7", ptr: []string{"host7.caffix.net"}},
+	}
+
+	for _, c := range cases {
+		result, err := r.ReverseLookup(context.Background(), c.addr)
+		if err != nil {
+			t.Errorf("the reverse lookup for %s failed: %v", c.addr, err)
+			continue
+		}
+		if result.Addr != c.addr || !reflect.DeepEqual(result.Aliases, c.aliases) || !reflect.DeepEqual(result.PTR, c.ptr) {
+			t.Errorf("Got: %+v; Expected aliases %v and PTR %v", result, c.aliases, c.ptr)
+		}
+	}
+
+	if _, err := r.ReverseLookup(context.Background(), "192.0.2.8"); err == nil {
+		t.Errorf("a PTR record was returned for an address without one")
+	}
+	if _, err := r.ReverseLookup(context.Background(), "not an address"); err == nil {
+		t.Errorf("an invalid address was accepted")
+	}
+}
+
+func TestReverseSweep(t *testing.T) {
+	dns.HandleFunc("2.0.192.in-addr.arpa.", reverseHandler)
+	defer dns.HandleRemove("2.0.192.in-addr.arpa.")
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	if _, err := r.ReverseSweep(context.Background(), "192.0.2.300/30"); err == nil {
+		t.Errorf("an invalid CIDR block was accepted")
+	}
+
+	ch, err := r.ReverseSweep(context.Background(), "192.0.2.4/30")
+	if err != nil {
+		t.Fatalf("failed to start the reverse sweep: %v", err)
+	}
+
+	found := make(map[string]string)
+	for result := range ch {
+		found[result.Addr] = result.PTR[0]
+	}
+
+	expected := map[string]string{
+		"192.0.2.5": "host5.caffix.net",
+		"192.0.2.6": "host6.caffix.net",
+		"192.0.2.7": "host7.caffix.net",
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Got: %v; Expected: %v", found, expected)
+	}
+}
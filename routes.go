@@ -0,0 +1,162 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// RoutingRule selects how the queries for the names matched by the Pattern are handled. The
+// Pattern is applied to the lowercase names without the trailing dot, and can be obtained from
+// a glob using GlobFilter. When Servers are provided, the queries are sent to those servers
+// instead of the pool, with recursion desired unless NoRecursion is set. SkipWildcards causes
+// WildcardDetected to report false for the names without sending any queries.
+type RoutingRule struct {
+	Pattern       *regexp.Regexp
+	Servers       []string
+	NoRecursion   bool
+	SkipWildcards bool
+}
+
+type route struct {
+	rule    *RoutingRule
+	servers []*resolver
+}
+
+// routingRules holds the rules added to the pool, evaluated in the order they were added.
+type routingRules struct {
+	sync.Mutex
+	routes []*route
+}
+
+func newRoutingRules() *routingRules {
+	return new(routingRules)
+}
+
+// AddRoutingRule appends the rule to those evaluated for each query. The first rule matching
+// a name determines how the query is handled, and the servers are added using the provided QPS.
+func (r *Resolvers) AddRoutingRule(qps int, rule *RoutingRule) error {
+	rt, err := r.newRoute(qps, rule)
+	if err != nil {
+		return fmt.Errorf("AddRoutingRule: %v", err)
+	}
+
+	r.routes.Lock()
+	defer r.routes.Unlock()
+
+	r.routes.routes = append(r.routes.routes, rt)
+	return nil
+}
+
+// SetRoutingRules replaces the rules evaluated for each query with those provided, in the same
+// order. The servers of the replaced rules are no longer selected for new queries, and are
+// stopped once the queries already sent to them have been answered or expired. The rules are
+// left unchanged when any of them cannot be added.
+func (r *Resolvers) SetRoutingRules(qps int, rules ...*RoutingRule) error {
+	routes := make([]*route, 0, len(rules))
+	for _, rule := range rules {
+		rt, err := r.newRoute(qps, rule)
+		if err != nil {
+			for _, added := range routes {
+				for _, res := range added.servers {
+					res.stop()
+				}
+			}
+			return fmt.Errorf("SetRoutingRules: %v", err)
+		}
+		routes = append(routes, rt)
+	}
+
+	r.routes.Lock()
+	old := r.routes.routes
+	r.routes.routes = routes
+	r.routes.Unlock()
+
+	for _, rt := range old {
+		for _, res := range rt.servers {
+			r.retired.add(res)
+			go r.retire(res)
+		}
+	}
+	return nil
+}
+
+// Returns the route for the rule, with the servers added using the provided QPS.
+func (r *Resolvers) newRoute(qps int, rule *RoutingRule) (*route, error) {
+	if rule == nil || rule.Pattern == nil {
+		return nil, errors.New("failed to provide a pattern for the rule")
+	}
+
+	var servers []*resolver
+	if len(rule.Servers) > 0 {
+		if qps <= 0 {
+			return nil, errors.New("failed to provide a QPS greater than zero")
+		}
+
+		for _, addr := range r.expandHostnames(rule.Servers) {
+			r.Lock()
+			res := r.initializeResolver(qps, addr)
+			r.Unlock()
+
+			if res != nil {
+				servers = append(servers, res)
+			}
+		}
+		if len(servers) == 0 {
+			return nil, errors.New("failed to add the servers for the rule")
+		}
+	}
+	return &route{rule: rule, servers: servers}, nil
+}
+
+// Returns the first route with a rule matching the name, or nil when none match.
+func (t *routingRules) match(name string) *route {
+	t.Lock()
+	defer t.Unlock()
+
+	if len(t.routes) == 0 {
+		return nil
+	}
+
+	name = strings.ToLower(RemoveLastDot(name))
+	for _, rt := range t.routes {
+		if rt.rule.Pattern.MatchString(name) {
+			return rt
+		}
+	}
+	return nil
+}
+
+// Returns a server selected by the rule matching the name, or nil when the name should
+// be resolved by the pool. The second return value indicates that recursion is desired.
+func (t *routingRules) resolverFor(name string) (*resolver, bool) {
+	if rt := t.match(name); rt != nil && len(rt.servers) > 0 {
+		return rt.servers[prng.Intn(len(rt.servers))], !rt.rule.NoRecursion
+	}
+	return nil, false
+}
+
+// Returns true when the rule matching the name disables wildcard detection.
+func (t *routingRules) skipWildcards(name string) bool {
+	if rt := t.match(name); rt != nil {
+		return rt.rule.SkipWildcards
+	}
+	return false
+}
+
+func (t *routingRules) allResolvers() []*resolver {
+	t.Lock()
+	defer t.Unlock()
+
+	var all []*resolver
+	for _, rt := range t.routes {
+		all = append(all, rt.servers...)
+	}
+	return all
+}
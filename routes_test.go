@@ -0,0 +1,164 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestRoutingRules(t *testing.T) {
+	answer := func(addr string) dns.HandlerFunc {
+		return func(w dns.ResponseWriter, req *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(req)
+			if req.RecursionDesired {
+				m.Answer = append(m.Answer, testARecord(req.Question[0].Name, addr))
+			}
+			_ = w.WriteMsg(m)
+		}
+	}
+
+	pmux := dns.NewServeMux()
+	pmux.HandleFunc(".", answer("192.0.2.1"))
+	ps, paddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = pmux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = ps.Shutdown() }()
+
+	imux := dns.NewServeMux()
+	imux.HandleFunc(".", answer("10.0.0.1"))
+	is, iaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = imux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = is.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, paddr)
+	defer r.Stop()
+
+	internal, _ := GlobFilter("*.internal.example.com")
+	if err := r.AddRoutingRule(100, &RoutingRule{Pattern: internal, Servers: []string{iaddr}, SkipWildcards: true}); err != nil {
+		t.Fatalf("failed to add the routing rule: %v", err)
+	}
+	norec := regexp.MustCompile(`^norec\.`)
+	if err := r.AddRoutingRule(100, &RoutingRule{Pattern: norec, Servers: []string{iaddr}, NoRecursion: true}); err != nil {
+		t.Fatalf("failed to add the routing rule: %v", err)
+	}
+	if err := r.AddRoutingRule(100, &RoutingRule{Servers: []string{iaddr}}); err == nil {
+		t.Errorf("a rule without a pattern was accepted")
+	}
+	if err := r.AddRoutingRule(0, &RoutingRule{Pattern: norec, Servers: []string{iaddr}}); err == nil {
+		t.Errorf("a rule with servers and a QPS of zero was accepted")
+	}
+
+	for name, expected := range map[string]string{
+		"www.internal.example.com": "10.0.0.1",
+		"www.example.com":          "192.0.2.1",
+		"norec.example.com":        "",
+	} {
+		resp, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA))
+		if err != nil {
+			t.Errorf("%s: the query failed: %v", name, err)
+			continue
+		}
+
+		var addr string
+		if ips := IPs(resp); len(ips) > 0 {
+			addr = ips[0].String()
+		}
+		if addr != expected {
+			t.Errorf("%s: expected the answer %q, got %q", name, expected, addr)
+		}
+	}
+
+	if !r.routes.skipWildcards("WWW.internal.example.com.") || r.routes.skipWildcards("www.example.com") {
+		t.Errorf("the wildcard detection policy was not applied by the rules")
+	}
+	if len(r.allResolvers()) != 3 {
+		t.Errorf("the servers of the rules were not included with the resolvers")
+	}
+}
+
+func TestSetRoutingRules(t *testing.T) {
+	answer := func(addr string) dns.HandlerFunc {
+		return func(w dns.ResponseWriter, req *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(req)
+			m.Answer = append(m.Answer, testARecord(req.Question[0].Name, addr))
+			_ = w.WriteMsg(m)
+		}
+	}
+
+	pmux := dns.NewServeMux()
+	pmux.HandleFunc(".", answer("192.0.2.1"))
+	ps, paddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = pmux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = ps.Shutdown() }()
+
+	imux := dns.NewServeMux()
+	imux.HandleFunc(".", answer("10.0.0.1"))
+	is, iaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = imux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = is.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, paddr)
+	defer r.Stop()
+
+	internal, _ := GlobFilter("*.internal.example.com")
+	if err := r.AddRoutingRule(100, &RoutingRule{Pattern: internal, Servers: []string{iaddr}}); err != nil {
+		t.Fatalf("failed to add the routing rule: %v", err)
+	}
+	replaced := r.routes.allResolvers()
+
+	dev, _ := GlobFilter("*.dev.example.com")
+	if err := r.SetRoutingRules(100, &RoutingRule{Pattern: dev, Servers: []string{iaddr}}, &RoutingRule{}); err == nil {
+		t.Errorf("the rules were replaced using a rule without a pattern")
+	}
+	if r.routes.match("www.internal.example.com") == nil {
+		t.Errorf("the rules were changed by the failed replacement")
+	}
+
+	if err := r.SetRoutingRules(100, &RoutingRule{Pattern: dev, Servers: []string{iaddr}}); err != nil {
+		t.Fatalf("failed to replace the routing rules: %v", err)
+	}
+	for name, expected := range map[string]string{
+		"www.internal.example.com": "192.0.2.1",
+		"www.dev.example.com":      "10.0.0.1",
+	} {
+		resp, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA))
+		if err != nil {
+			t.Errorf("%s: the query failed: %v", name, err)
+			continue
+		}
+		if ips := IPs(resp); len(ips) != 1 || ips[0].String() != expected {
+			t.Errorf("%s: expected the answer %q, got %v", name, expected, ips)
+		}
+	}
+
+	// The servers of the replaced rules are stopped without queries remaining
+	deadline := time.Now().Add(time.Second)
+	for len(r.retired.allResolvers()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, res := range replaced {
+		select {
+		case <-res.done:
+		default:
+			t.Errorf("the server of a replaced rule was not stopped")
+		}
+	}
+}
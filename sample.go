@@ -0,0 +1,108 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net/netip"
+)
+
+const (
+	// DefaultIPv6LowHosts is the number of sequential interface identifiers, from ::1, sampled
+	// in each /64 network observed.
+	DefaultIPv6LowHosts = 256
+	// DefaultIPv6Neighbors is the number of interface identifiers sampled on each side of an
+	// observed address.
+	DefaultIPv6Neighbors = 16
+)
+
+// Interface identifiers commonly assigned by hand, such as service ports written in hex
+// and words spelled with the hex digits.
+var ipv6WordyIIDs = []uint64{
+	0x25, 0x53, 0x80, 0x443, 0x1000, 0x1001, 0x10001, 0x10002,
+	0xbeef, 0xcafe, 0xface, 0xdeadbeef, 0xfaceb00c,
+}
+
+// SampleIPv6 returns the candidate addresses for reverse lookups within the /64 networks of the
+// observed IPv6 addresses, since full sweeps of IPv6 networks are infeasible. Within each network,
+// the first lowHosts interface identifiers from ::1, the identifiers commonly assigned by hand,
+// and the neighbors on each side of the observed addresses, such as the SLAAC addresses of hosts
+// with nearby MAC addresses, are sampled. The observed addresses are not included in the results.
+func SampleIPv6(observed []string, lowHosts, neighbors int) []netip.Addr {
+	seen := make(map[netip.Addr]struct{})
+	for _, str := range observed {
+		if addr, err := netip.ParseAddr(str); err == nil {
+			seen[addr.Unmap()] = struct{}{}
+		}
+	}
+
+	var results []netip.Addr
+	add := func(network [8]byte, iid uint64) {
+		var b [16]byte
+
+		copy(b[:8], network[:])
+		binary.BigEndian.PutUint64(b[8:], iid)
+		addr := netip.AddrFrom16(b)
+		if _, found := seen[addr]; !found {
+			seen[addr] = struct{}{}
+			results = append(results, addr)
+		}
+	}
+
+	for _, str := range observed {
+		addr, err := netip.ParseAddr(str)
+		if err != nil || !addr.Is6() || addr.Is4In6() {
+			continue
+		}
+
+		b := addr.As16()
+		var network [8]byte
+		copy(network[:], b[:8])
+		iid := binary.BigEndian.Uint64(b[8:])
+
+		for i := 1; i <= lowHosts; i++ {
+			add(network, uint64(i))
+		}
+		for _, w := range ipv6WordyIIDs {
+			add(network, w)
+		}
+		for i := 1; i <= neighbors; i++ {
+			if n := uint64(i); iid > n {
+				add(network, iid-n)
+			}
+			if n := uint64(i); iid+n > iid {
+				add(network, iid+n)
+			}
+		}
+	}
+	return results
+}
+
+// ReverseSample performs reverse DNS lookups for the addresses sampled by SampleIPv6 from the
+// observed IPv6 addresses, using the DefaultIPv6LowHosts and DefaultIPv6Neighbors, and sends the
+// results with PTR names on the returned channel. The channel is closed once the lookups have
+// completed or the context expires.
+func (r *Resolvers) ReverseSample(ctx context.Context, observed ...string) (<-chan *ReversePTR, error) {
+	candidates := SampleIPv6(observed, DefaultIPv6LowHosts, DefaultIPv6Neighbors)
+	if len(candidates) == 0 {
+		return nil, errors.New("ReverseSample: no IPv6 addresses were provided")
+	}
+
+	addrs := make(chan netip.Addr, numOfSweepWorkers)
+	go func() {
+		defer close(addrs)
+
+		for _, addr := range candidates {
+			select {
+			case <-ctx.Done():
+				return
+			case addrs <- addr:
+			}
+		}
+	}()
+	return r.reverseLookups(ctx, addrs), nil
+}
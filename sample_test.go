@@ -0,0 +1,103 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestSampleIPv6(t *testing.T) {
+	if got := SampleIPv6([]string{"192.0.2.1", "bad"}, 10, 2); len(got) != 0 {
+		t.Errorf("addresses were sampled without IPv6 addresses observed: %v", got)
+	}
+
+	got := SampleIPv6([]string{"2001:db8::200", "2001:db8::5"}, 10, 2)
+	set := make(map[netip.Addr]struct{})
+	for _, addr := range got {
+		set[addr] = struct{}{}
+	}
+	if len(set) != len(got) {
+		t.Errorf("the samples contain duplicate addresses: %v", got)
+	}
+
+	for _, str := range []string{"2001:db8::1", "2001:db8::a", "2001:db8::53", "2001:db8::dead:beef",
+		"2001:db8::1fe", "2001:db8::1ff", "2001:db8::201", "2001:db8::202", "2001:db8::3", "2001:db8::7"} {
+		if _, found := set[netip.MustParseAddr(str)]; !found {
+			t.Errorf("%s was not sampled", str)
+		}
+	}
+	for _, str := range []string{"2001:db8::200", "2001:db8::5", "2001:db8::b", "2001:db8::203"} {
+		if _, found := set[netip.MustParseAddr(str)]; found {
+			t.Errorf("%s was sampled", str)
+		}
+	}
+	// The observed ::5 is within the low hosts, and its neighbors are all low hosts
+	if expected := 10 - 1 + len(ipv6WordyIIDs) + 4; len(got) != expected {
+		t.Errorf("Got: %d samples; Expected: %d", len(got), expected)
+	}
+}
+
+func TestReverseSample(t *testing.T) {
+	ptrs := map[string]string{
+		"2001:db8::1":   "gw.caffix.net.",
+		"2001:db8::53":  "ns.caffix.net.",
+		"2001:db8::201": "host.caffix.net.",
+	}
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc("ip6.arpa.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Rcode = dns.RcodeNameError
+		for addr, ptr := range ptrs {
+			if name, _ := dns.ReverseAddr(addr); name == req.Question[0].Name {
+				m.Rcode = dns.RcodeSuccess
+				m.Answer = append(m.Answer, &dns.PTR{
+					Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypePTR, Class: dns.ClassINET},
+					Ptr: ptr,
+				})
+			}
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(1000, addrstr)
+	defer r.Stop()
+
+	if _, err := r.ReverseSample(context.Background(), "192.0.2.1"); err == nil {
+		t.Errorf("the sample was started without IPv6 addresses")
+	}
+
+	ch, err := r.ReverseSample(context.Background(), "2001:db8::200")
+	if err != nil {
+		t.Fatalf("failed to start the reverse sample: %v", err)
+	}
+
+	found := make(map[string]string)
+	for result := range ch {
+		found[result.Addr] = result.PTR[0]
+	}
+
+	expected := map[string]string{
+		"2001:db8::1":   "gw.caffix.net",
+		"2001:db8::53":  "ns.caffix.net",
+		"2001:db8::201": "host.caffix.net",
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Got: %v; Expected: %v", found, expected)
+	}
+}
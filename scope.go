@@ -0,0 +1,51 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"strings"
+	"sync"
+)
+
+// queryScope restricts the names that can be queried through the pool.
+type queryScope struct {
+	sync.Mutex
+	domains []string
+}
+
+// SetScope restricts the queries sent through the pool to names within the provided domains.
+// Queries for names outside of the scope are returned with the REFUSED response code without
+// being sent. Providing no domains removes the restriction.
+func (r *Resolvers) SetScope(domains ...string) {
+	var scope []string
+	for _, d := range domains {
+		if d = strings.ToLower(strings.Trim(strings.TrimSpace(d), ".")); d != "" {
+			scope = append(scope, d)
+		}
+	}
+
+	r.scope.Lock()
+	defer r.scope.Unlock()
+
+	r.scope.domains = scope
+}
+
+// Returns true when the name is within one of the domains of the scope, or no scope was set.
+func (s *queryScope) contains(name string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.domains) == 0 {
+		return true
+	}
+
+	name = strings.ToLower(RemoveLastDot(name))
+	for _, d := range s.domains {
+		if name == d || strings.HasSuffix(name, "."+d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestSetScope(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, typeAHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	r.SetScope(" Caffix.NET. ", "")
+	for _, name := range []string{"caffix.net", "www.caffix.net"} {
+		if resp, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA)); err != nil || resp.Rcode != dns.RcodeSuccess {
+			t.Errorf("the query for %s within the scope failed: %v", name, err)
+		}
+	}
+	for _, name := range []string{"owasp.org", "notcaffix.net"} {
+		if resp, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA)); err == nil && resp.Rcode != dns.RcodeRefused {
+			t.Errorf("the query for %s outside of the scope was not refused", name)
+		}
+	}
+
+	r.SetScope()
+	if !r.scope.contains("owasp.org") {
+		t.Errorf("the scope was not removed")
+	}
+}
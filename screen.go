@@ -0,0 +1,114 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	// The maximum number of characters in a DNS label.
+	maxLabelLength = 63
+	// The maximum number of characters in a DNS name, without the trailing dot.
+	maxNameLength = 253
+)
+
+// NameProfile describes the candidate names worth sending queries for. The fields
+// left as zero do not restrict the names beyond the DNS rules.
+type NameProfile struct {
+	// MaxLabelLength is the maximum number of characters in each label.
+	MaxLabelLength int
+	// MaxNameLength is the maximum number of characters in the name.
+	MaxNameLength int
+	// MaxLabels is the maximum number of labels in the name.
+	MaxLabels int
+	// MaxEntropy is the maximum Shannon entropy, in bits per character, of each label.
+	MaxEntropy float64
+	// AllowUnderscore permits the labels starting with an underscore, such as "_dmarc".
+	AllowUnderscore bool
+}
+
+// Permits returns true when the name follows the DNS rules for hostnames and fits the profile.
+func (p *NameProfile) Permits(name string) bool {
+	name = RemoveLastDot(name)
+	if name == "" || len(name) > maxNameLength || (p.MaxNameLength > 0 && len(name) > p.MaxNameLength) {
+		return false
+	}
+
+	labels := strings.Split(name, ".")
+	if p.MaxLabels > 0 && len(labels) > p.MaxLabels {
+		return false
+	}
+
+	for _, label := range labels {
+		if !p.validLabel(label) {
+			return false
+		}
+		if p.MaxLabelLength > 0 && len(label) > p.MaxLabelLength {
+			return false
+		}
+		if p.MaxEntropy > 0 && LabelEntropy(label) > p.MaxEntropy {
+			return false
+		}
+	}
+	return true
+}
+
+// Screen returns the names permitted by the profile, keeping the order provided.
+func (p *NameProfile) Screen(names []string) []string {
+	var permitted []string
+
+	for _, name := range names {
+		if p.Permits(name) {
+			permitted = append(permitted, name)
+		}
+	}
+	return permitted
+}
+
+// Returns true when the label contains letters, digits and hyphens that are not at the ends.
+func (p *NameProfile) validLabel(label string) bool {
+	if label == "" || len(label) > maxLabelLength {
+		return false
+	}
+	if p.AllowUnderscore && label[0] == '_' {
+		label = label[1:]
+		if label == "" {
+			return false
+		}
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+
+	for _, c := range label {
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// LabelEntropy returns the Shannon entropy of the characters in the label, in bits per character,
+// ignoring case. Generated labels with high entropy are unlikely to have been assigned by hand.
+func LabelEntropy(label string) float64 {
+	if label == "" {
+		return 0
+	}
+
+	counts := make(map[rune]int)
+	for _, c := range strings.ToLower(label) {
+		counts[c]++
+	}
+
+	var entropy float64
+	n := float64(len(label))
+	for _, count := range counts {
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
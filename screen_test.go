@@ -0,0 +1,81 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLabelEntropy(t *testing.T) {
+	for label, expected := range map[string]float64{
+		"":         0,
+		"aaaa":     0,
+		"ab":       1,
+		"AbBa":     1,
+		"abcd":     2,
+		"abcdefgh": 3,
+	} {
+		if got := LabelEntropy(label); math.Abs(got-expected) > 1e-9 {
+			t.Errorf("LabelEntropy(%s) returned %f; expected %f", label, got, expected)
+		}
+	}
+}
+
+func TestNameProfilePermits(t *testing.T) {
+	rules := new(NameProfile)
+	for name, expected := range map[string]bool{
+		"www.owasp.org":                   true,
+		"www.owasp.org.":                  true,
+		"dev-01.owasp.org":                true,
+		"-dev.owasp.org":                  false,
+		"dev-.owasp.org":                  false,
+		"dev..owasp.org":                  false,
+		"dev_01.owasp.org":                false,
+		"_dmarc.owasp.org":                false,
+		"dév.owasp.org":                   false,
+		"":                                false,
+		strings.Repeat("a", 64) + ".org":  false,
+		strings.Repeat("a.", 127) + "org": false,
+	} {
+		if got := rules.Permits(name); got != expected {
+			t.Errorf("Permits(%s) returned %t; expected %t", name, got, expected)
+		}
+	}
+
+	profile := &NameProfile{
+		MaxLabelLength:  10,
+		MaxNameLength:   30,
+		MaxLabels:       4,
+		MaxEntropy:      3,
+		AllowUnderscore: true,
+	}
+	for name, expected := range map[string]bool{
+		"_dmarc.owasp.org":              true,
+		"_.owasp.org":                   false,
+		"staging.owasp.org":             true,
+		"abcdefghij.owasp.org":          false,
+		"abcdefghijk.owasp.org":         false,
+		"a.b.c.owasp.org":               false,
+		"mail.corp.caffix.net":          true,
+		"mail.corp.example.caffix.net.": false,
+		"mail.corporation.example.net":  false,
+	} {
+		if got := profile.Permits(name); got != expected {
+			t.Errorf("Permits(%s) returned %t; expected %t", name, got, expected)
+		}
+	}
+}
+
+func TestNameProfileScreen(t *testing.T) {
+	profile := &NameProfile{MaxEntropy: 3}
+
+	names := []string{"www.owasp.org", "x7q9kzt2mw.owasp.org", "bad_name.owasp.org", "mail.owasp.org"}
+	if got := profile.Screen(names); !reflect.DeepEqual(got, []string{"www.owasp.org", "mail.owasp.org"}) {
+		t.Errorf("Got: %v; Expected: [www.owasp.org mail.owasp.org]", got)
+	}
+}
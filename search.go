@@ -0,0 +1,76 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// SetSearchDomains causes relative names to be expanded using the provided search domains,
+// following the ndots semantics of resolv.conf. Names with fewer dots than ndots are tried with
+// each search domain before being tried as provided. An ndots value less than one defaults to one.
+func (r *Resolvers) SetSearchDomains(ndots int, domains ...string) {
+	if ndots < 1 {
+		ndots = 1
+	}
+
+	var search []string
+	for _, d := range domains {
+		if d = strings.ToLower(strings.Trim(d, ".")); d != "" {
+			search = append(search, d)
+		}
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.ndots = ndots
+	r.search = search
+}
+
+// SearchNames returns the names that should be queried for the provided name, in the order they
+// should be attempted. Names ending with a dot are absolute and never expanded.
+func (r *Resolvers) SearchNames(name string) []string {
+	r.Lock()
+	ndots := r.ndots
+	search := r.search
+	r.Unlock()
+
+	if len(search) == 0 || dns.IsFqdn(name) {
+		return []string{RemoveLastDot(name)}
+	}
+
+	var names []string
+	for _, d := range search {
+		names = append(names, name+"."+d)
+	}
+
+	if strings.Count(name, ".") >= ndots {
+		return append([]string{name}, names...)
+	}
+	return append(names, name)
+}
+
+// QuerySearch queries the names returned by SearchNames for the provided name, in order, and
+// returns the first response containing answers. The last response is returned when none of
+// the names resolve.
+func (r *Resolvers) QuerySearch(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	var last *dns.Msg
+
+	for _, n := range r.SearchNames(name) {
+		resp, err := r.QueryBlocking(ctx, QueryMsg(n, qtype))
+		if err != nil {
+			return resp, err
+		}
+		if resp.Rcode == dns.RcodeSuccess && len(resp.Answer) > 0 {
+			return resp, nil
+		}
+		last = resp
+	}
+	return last, nil
+}
@@ -0,0 +1,75 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestSearchNames(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	if names := r.SearchNames("www"); len(names) != 1 || names[0] != "www" {
+		t.Errorf("the name was expanded without search domains: %v", names)
+	}
+
+	r.SetSearchDomains(2, "corp.example.com.", "example.com")
+	cases := []struct {
+		name string
+		want []string
+	}{
+		{name: "www", want: []string{"www.corp.example.com", "www.example.com", "www"}},
+		{name: "www.dev", want: []string{"www.dev.corp.example.com", "www.dev.example.com", "www.dev"}},
+		{name: "www.dev.net", want: []string{"www.dev.net", "www.dev.net.corp.example.com", "www.dev.net.example.com"}},
+		{name: "www.owasp.org.", want: []string{"www.owasp.org"}},
+	}
+
+	for _, c := range cases {
+		if got := r.SearchNames(c.name); strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Errorf("%s: Got: %v; Expected: %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestQuerySearch(t *testing.T) {
+	dns.HandleFunc("search.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		if name := req.Question[0].Name; name == "intranet.corp.search.net." {
+			m.Answer = append(m.Answer, testARecord(name, "192.168.1.1"))
+		} else {
+			m.Rcode = dns.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})
+	defer dns.HandleRemove("search.net.")
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+	r.SetSearchDomains(1, "search.net", "corp.search.net")
+
+	resp, err := r.QuerySearch(context.Background(), "intranet", dns.TypeA)
+	if err != nil || len(resp.Answer) == 0 || resp.Question[0].Name != "intranet.corp.search.net." {
+		t.Errorf("the name was not resolved using the search domains")
+	}
+
+	resp, err = r.QuerySearch(context.Background(), "missing", dns.TypeA)
+	if err != nil || len(resp.Answer) > 0 || resp.Question[0].Name != "missing." {
+		t.Errorf("the last response was not returned when none of the names resolved")
+	}
+}
@@ -5,20 +5,34 @@
 package resolve
 
 import (
-	"math/rand"
+	"context"
+	"strings"
 	"sync"
+
+	"golang.org/x/net/publicsuffix"
 )
 
 type selector interface {
 	// GetResolver returns a resolver managed by the selector.
 	GetResolver() *resolver
 
-	// LookupResolver returns the resolver with the matching address.
+	// GetResolverFor returns a resolver managed by the selector that has not been demoted
+	// for the zone of the provided name, when such a resolver is available. No resolver is
+	// returned once the context has expired.
+	GetResolverFor(ctx context.Context, name string) *resolver
+
+	// Demote marks the resolver as unable to answer queries within the zone of the provided name.
+	Demote(res *resolver, name string)
+
+	// LookupResolver returns the resolver with the matching IP:port address.
 	LookupResolver(addr string) *resolver
 
 	// AddResolver adds a resolver to the selector pool.
 	AddResolver(res *resolver)
 
+	// RemoveResolver removes the resolver from the selector pool.
+	RemoveResolver(res *resolver)
+
 	// AllResolvers returns all the resolver objects currently managed by the selector.
 	AllResolvers() []*resolver
 
@@ -31,12 +45,16 @@ type selector interface {
 
 type randomSelector struct {
 	sync.Mutex
-	list   []*resolver
-	lookup map[string]*resolver
+	list    []*resolver
+	lookup  map[string]*resolver
+	demoted map[string]map[*resolver]struct{}
 }
 
 func newRandomSelector() *randomSelector {
-	return &randomSelector{lookup: make(map[string]*resolver)}
+	return &randomSelector{
+		lookup:  make(map[string]*resolver),
+		demoted: make(map[string]map[*resolver]struct{}),
+	}
 }
 
 // GetResolver performs random selection on the pool of resolvers.
@@ -51,7 +69,7 @@ func (r *randomSelector) GetResolver() *resolver {
 	}
 
 	var chosen *resolver
-	sel := rand.Intn(len(r.list))
+	sel := prng.Intn(len(r.list))
 loop:
 	for _, res := range r.list[sel:] {
 		select {
@@ -66,6 +84,69 @@ loop:
 	return chosen
 }
 
+// GetResolverFor performs random selection on the pool of resolvers, skipping the resolvers
+// demoted for the zone of the provided name. Any resolver is selected when all have been demoted.
+func (r *randomSelector) GetResolverFor(ctx context.Context, name string) *resolver {
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	r.Lock()
+	var chosen *resolver
+	if len(r.demoted) > 0 && len(r.list) > 0 {
+		if avoid, found := r.demoted[selectorZone(name)]; found {
+			sel := prng.Intn(len(r.list))
+
+			for i := 0; i < len(r.list); i++ {
+				res := r.list[(sel+i)%len(r.list)]
+				if _, demoted := avoid[res]; demoted {
+					continue
+				}
+
+				select {
+				case <-res.done:
+					continue
+				default:
+				}
+
+				chosen = res
+				break
+			}
+		}
+	}
+	r.Unlock()
+
+	if chosen == nil {
+		chosen = r.GetResolver()
+	}
+	return chosen
+}
+
+func (r *randomSelector) Demote(res *resolver, name string) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.demoted == nil {
+		return
+	}
+
+	zone := selectorZone(name)
+	if _, found := r.demoted[zone]; !found {
+		r.demoted[zone] = make(map[*resolver]struct{})
+	}
+	r.demoted[zone][res] = struct{}{}
+}
+
+// Returns the registered domain name for the provided name, or the name when it cannot be determined.
+func selectorZone(name string) string {
+	name = strings.ToLower(RemoveLastDot(name))
+
+	if zone, err := publicsuffix.EffectiveTLDPlusOne(name); err == nil {
+		return zone
+	}
+	return name
+}
+
 func (r *randomSelector) LookupResolver(addr string) *resolver {
 	r.Lock()
 	defer r.Unlock()
@@ -77,9 +158,27 @@ func (r *randomSelector) AddResolver(res *resolver) {
 	r.Lock()
 	defer r.Unlock()
 
-	if _, found := r.lookup[res.address.IP.String()]; !found {
+	if key := addrKey(res.address); r.lookup[key] == nil {
 		r.list = append(r.list, res)
-		r.lookup[res.address.IP.String()] = res
+		r.lookup[key] = res
+	}
+}
+
+func (r *randomSelector) RemoveResolver(res *resolver) {
+	r.Lock()
+	defer r.Unlock()
+
+	for i, cur := range r.list {
+		if cur == res {
+			r.list = append(r.list[:i:i], r.list[i+1:]...)
+			break
+		}
+	}
+	if key := addrKey(res.address); r.lookup[key] == res {
+		delete(r.lookup, key)
+	}
+	for _, avoid := range r.demoted {
+		delete(avoid, res)
 	}
 }
 
@@ -119,6 +218,7 @@ func (r *randomSelector) Close() {
 
 	r.list = nil
 	r.lookup = nil
+	r.demoted = nil
 }
 
 func min(x, y int) int {
@@ -5,6 +5,8 @@
 package resolve
 
 import (
+	"context"
+	"net"
 	"testing"
 )
 
@@ -47,3 +49,41 @@ func TestMin(t *testing.T) {
 		}
 	}
 }
+
+func TestSelectorDemote(t *testing.T) {
+	sel := newRandomSelector()
+	defer sel.Close()
+
+	first := &resolver{done: make(chan struct{}), address: &net.UDPAddr{IP: net.ParseIP("192.168.1.1"), Port: 53}}
+	second := &resolver{done: make(chan struct{}), address: &net.UDPAddr{IP: net.ParseIP("192.168.1.2"), Port: 53}}
+	sel.AddResolver(first)
+	sel.AddResolver(second)
+
+	sel.Demote(first, "www.refused.com")
+	for i := 0; i < 50; i++ {
+		if res := sel.GetResolverFor(context.Background(), "mail.refused.com"); res != second {
+			t.Fatalf("the selector returned a resolver demoted for the zone")
+		}
+	}
+
+	var others int
+	for i := 0; i < 100; i++ {
+		if res := sel.GetResolverFor(context.Background(), "www.owasp.org"); res == first {
+			others++
+		}
+	}
+	if others == 0 {
+		t.Errorf("the demoted resolver was not selected for a different zone")
+	}
+
+	sel.Demote(second, "refused.com")
+	if res := sel.GetResolverFor(context.Background(), "refused.com"); res == nil {
+		t.Errorf("the selector failed to return a resolver once all were demoted for the zone")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if res := sel.GetResolverFor(ctx, "www.owasp.org"); res != nil {
+		t.Errorf("the selector returned a resolver after the context expired")
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const numOfSnapshotQueries = 50
+
+// Snapshot contains the responses obtained for a set of names, along with the zone containing
+// each name and the SOA serial of each zone at the time the names were resolved.
+type Snapshot struct {
+	Time      time.Time
+	Zones     map[string]string
+	Serials   map[string]uint32
+	Responses map[string]*dns.Msg
+}
+
+// NewSnapshot returns an empty Snapshot.
+func NewSnapshot() *Snapshot {
+	return &Snapshot{
+		Time:      time.Now(),
+		Zones:     make(map[string]string),
+		Serials:   make(map[string]uint32),
+		Responses: make(map[string]*dns.Msg),
+	}
+}
+
+// Response returns the response recorded for the name and type, or nil when not in the snapshot.
+func (s *Snapshot) Response(name string, qtype uint16) *dns.Msg {
+	return s.Responses[requeryKey(strings.ToLower(RemoveLastDot(name)), qtype)]
+}
+
+// ResolveIfChanged resolves the names for the provided type and returns the results in a new
+// Snapshot, along with the names that were sent to the resolver pool. Responses in the prior
+// snapshot are reused for names whose zone has the same SOA serial, and a name is always resolved
+// when the serial cannot be obtained. The zone of a name is found by the SOA record returned for
+// the name, so delegated subdomains are tracked by their own serials. The SOA queries and the
+// names resolved bypass the cache, and the cached responses within a zone are removed once its
+// serial has changed.
+func (r *Resolvers) ResolveIfChanged(ctx context.Context, prior *Snapshot, qtype uint16, names ...string) (*Snapshot, []string) {
+	if prior == nil {
+		prior = NewSnapshot()
+	}
+
+	snap := NewSnapshot()
+	// The serials of the zones found by the prior snapshot are obtained once for each zone
+	for _, n := range names {
+		zone, found := prior.Zones[strings.ToLower(RemoveLastDot(n))]
+		if _, checked := snap.Serials[zone]; !found || checked {
+			continue
+		}
+		if soa, err := r.SOARecord(ctx, zone); err == nil {
+			snap.Serials[zone] = soa.Serial
+			r.invalidateZone(prior, zone, soa.Serial)
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var resolved []string
+	seen := make(map[string]struct{})
+	sem := make(chan struct{}, numOfSnapshotQueries)
+loop:
+	for _, n := range names {
+		name := strings.ToLower(RemoveLastDot(n))
+		key := requeryKey(name, qtype)
+		if _, found := seen[key]; found {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		zone, known := prior.Zones[name]
+		serial, current := snap.Serials[zone]
+		if old, found := prior.Serials[zone]; known && current && found && old == serial {
+			if resp := prior.Responses[key]; resp != nil {
+				mu.Lock()
+				snap.Zones[name] = zone
+				snap.Responses[key] = resp
+				mu.Unlock()
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			break loop
+		case sem <- struct{}{}:
+		}
+
+		resolved = append(resolved, name)
+		wg.Add(1)
+		go func(name, key string) {
+			defer func() { <-sem; wg.Done() }()
+
+			// The zone is found again, since the serial change may come from a new delegation
+			if soa, err := r.enclosingSOA(ctx, name); err == nil {
+				zone := strings.ToLower(RemoveLastDot(soa.Hdr.Name))
+
+				mu.Lock()
+				if _, found := snap.Serials[zone]; !found {
+					r.invalidateZone(prior, zone, soa.Serial)
+				}
+				snap.Zones[name] = zone
+				snap.Serials[zone] = soa.Serial
+				mu.Unlock()
+			}
+			if resp, err := r.QueryBlocking(withoutCache(ctx), QueryMsg(name, qtype)); err == nil {
+				mu.Lock()
+				snap.Responses[key] = resp
+				mu.Unlock()
+			}
+		}(name, key)
+	}
+	wg.Wait()
+	return snap, resolved
+}
+
+// Removes the cached responses within the zone when the serial differs from the prior snapshot.
+func (r *Resolvers) invalidateZone(prior *Snapshot, zone string, serial uint32) {
+	if old, found := prior.Serials[zone]; found && old != serial {
+		r.getCache().RemoveZone(zone)
+	}
+}
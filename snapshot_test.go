@@ -0,0 +1,104 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestResolveIfChanged(t *testing.T) {
+	var serial, deptSerial, queries uint32 = 1, 1, 0
+
+	mux := dns.NewServeMux()
+	handler := func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		switch q := req.Question[0]; q.Qtype {
+		case dns.TypeSOA:
+			// The dept.stable.net subdomain is delegated, and noserial.net provides no SOA record
+			serials := map[string]*uint32{"dept.stable.net.": &deptSerial, "changed.net.": &serial, "stable.net.": new(uint32)}
+			for _, zone := range []string{"dept.stable.net.", "changed.net.", "stable.net."} {
+				if !dns.IsSubDomain(zone, q.Name) {
+					continue
+				}
+
+				soa := testSOARecord(zone, 0, 0)
+				soa.Serial = atomic.LoadUint32(serials[zone])
+				if q.Name == zone {
+					m.Answer = append(m.Answer, soa)
+				} else {
+					m.Ns = append(m.Ns, soa)
+				}
+				break
+			}
+		case dns.TypeA:
+			atomic.AddUint32(&queries, 1)
+			m.Answer = append(m.Answer, testARecord(q.Name, "192.168.1.1"))
+		}
+		_ = w.WriteMsg(m)
+	}
+	for _, zone := range []string{"changed.net.", "stable.net.", "noserial.net."} {
+		mux.HandleFunc(zone, handler)
+	}
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+	cache := NewCache()
+	r.SetCache(cache)
+
+	names := []string{"www.changed.net", "www.stable.net", "mail.stable.net.", "www.noserial.net", "WWW.stable.net", "www.dept.stable.net"}
+	first, resolved := r.ResolveIfChanged(context.Background(), nil, dns.TypeA, names...)
+	if len(resolved) != 5 || atomic.LoadUint32(&queries) != 5 {
+		t.Fatalf("expected 5 names to be resolved, got %v", resolved)
+	}
+	if first.Serials["changed.net"] != 1 || len(first.Serials) != 3 || first.Response("www.stable.net.", dns.TypeA) == nil {
+		t.Errorf("the snapshot is incomplete: %v %v", first.Serials, first.Responses)
+	}
+	if zone := first.Zones["www.dept.stable.net"]; zone != "dept.stable.net" {
+		t.Errorf("the name was placed in the zone %s instead of the delegated subdomain", zone)
+	}
+
+	// The cached responses within the changed zones are removed
+	for _, name := range []string{"other.changed.net", "other.stable.net"} {
+		resp := new(dns.Msg).SetReply(QueryMsg(name, dns.TypeA))
+		rr := testARecord(dns.Fqdn(name), "192.168.1.1")
+		rr.Hdr.Ttl = 300
+		resp.Answer = append(resp.Answer, rr)
+		cache.Add(resp)
+	}
+
+	atomic.StoreUint32(&serial, 2)
+	atomic.StoreUint32(&deptSerial, 2)
+	second, resolved := r.ResolveIfChanged(context.Background(), first, dns.TypeA, names...)
+	sort.Strings(resolved)
+	if len(resolved) != 3 || resolved[0] != "www.changed.net" || resolved[1] != "www.dept.stable.net" || resolved[2] != "www.noserial.net" {
+		t.Errorf("unexpected names were resolved: %v", resolved)
+	}
+	if second.Serials["changed.net"] != 2 || second.Serials["dept.stable.net"] != 2 || len(second.Responses) != 5 {
+		t.Errorf("the new snapshot is incomplete: %v %v", second.Serials, second.Responses)
+	}
+	if second.Response("mail.stable.net", dns.TypeA) != first.Response("mail.stable.net", dns.TypeA) {
+		t.Errorf("the response for the unchanged zone was not reused")
+	}
+	if cache.Lookup(QueryMsg("other.changed.net", dns.TypeA)) != nil {
+		t.Errorf("the cached response within the changed zone was not removed")
+	}
+	if cache.Lookup(QueryMsg("other.stable.net", dns.TypeA)) == nil {
+		t.Errorf("the cached response within the unchanged zone was removed")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultSOAInterval is the time between the queries for the SOA records of the watched zones
+// when no interval greater than zero is provided.
+const DefaultSOAInterval = time.Minute
+
+// SOAChange is emitted when the serial number in the SOA record of a watched zone changes.
+type SOAChange struct {
+	Zone      string
+	OldSerial uint32
+	NewSerial uint32
+	SOA       *dns.SOA
+	Time      time.Time
+}
+
+// SOARecord returns the SOA record for the provided zone, as obtained through the resolver pool.
+// The query bypasses the cache, so the current serial number is returned.
+func (r *Resolvers) SOARecord(ctx context.Context, zone string) (*dns.SOA, error) {
+	resp, err := r.QueryWithRetries(withoutCache(ctx), QueryMsg(zone, dns.TypeSOA))
+	if err == nil && resp.Rcode == dns.RcodeSuccess {
+		if soas := Answers[*dns.SOA](resp); len(soas) > 0 {
+			return soas[0], nil
+		}
+	}
+	return nil, fmt.Errorf("SOARecord: %s SOA record not found", zone)
+}
+
+// Returns the SOA record of the zone containing the name, which is in the answer when the name is
+// the apex of the zone, and otherwise in the authority section of the NODATA or NXDOMAIN response.
+// The query bypasses the cache, so the current serial number is returned.
+func (r *Resolvers) enclosingSOA(ctx context.Context, name string) (*dns.SOA, error) {
+	name = strings.ToLower(dns.Fqdn(name))
+
+	resp, err := r.QueryWithRetries(withoutCache(ctx), QueryMsg(name, dns.TypeSOA))
+	if err == nil && (resp.Rcode == dns.RcodeSuccess || resp.Rcode == dns.RcodeNameError) {
+		for _, soa := range append(Answers[*dns.SOA](resp), Records[*dns.SOA](resp.Ns)...) {
+			if dns.IsSubDomain(soa.Hdr.Name, name) {
+				return soa, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("enclosingSOA: the SOA record of the zone containing %s was not found", name)
+}
+
+// WatchSOA queries the SOA record of each provided zone at the interval specified and sends a
+// SOAChange on the returned channel each time a serial number changes. The first successful query
+// for a zone establishes the baseline serial. The channel is closed once the context expires or
+// the resolver pool has been stopped. An interval that is not greater than zero is replaced by
+// the DefaultSOAInterval.
+func (r *Resolvers) WatchSOA(ctx context.Context, interval time.Duration, zones ...string) <-chan *SOAChange {
+	if interval <= 0 {
+		interval = DefaultSOAInterval
+	}
+	ch := make(chan *SOAChange, len(zones))
+
+	go func() {
+		defer close(ch)
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		serials := make(map[string]uint32, len(zones))
+		for {
+			for _, zone := range zones {
+				zone = strings.ToLower(RemoveLastDot(zone))
+
+				soa, err := r.SOARecord(ctx, zone)
+				if err != nil {
+					continue
+				}
+
+				old, found := serials[zone]
+				serials[zone] = soa.Serial
+				if !found || old == soa.Serial {
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-r.done:
+					return
+				case ch <- &SOAChange{
+					Zone:      zone,
+					OldSerial: old,
+					NewSerial: soa.Serial,
+					SOA:       soa,
+					Time:      time.Now(),
+				}:
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.done:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	return ch
+}
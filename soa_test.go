@@ -0,0 +1,84 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestWatchSOA(t *testing.T) {
+	var serial uint32 = 2024010100
+	name := "zone.net."
+	dns.HandleFunc(name, func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		m.Answer = append(m.Answer, &dns.SOA{
+			Hdr: dns.RR_Header{
+				Name:   name,
+				Rrtype: dns.TypeSOA,
+				Class:  dns.ClassINET,
+				Ttl:    0,
+			},
+			Ns:     "ns1.zone.net.",
+			Mbox:   "admin.zone.net.",
+			Serial: atomic.LoadUint32(&serial),
+		})
+		_ = w.WriteMsg(m)
+	})
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	if soa, err := r.SOARecord(context.Background(), "zone.net"); err != nil || soa.Serial != serial {
+		t.Fatalf("failed to obtain the SOA record for the zone: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ch := r.WatchSOA(ctx, 100*time.Millisecond, "zone.net")
+	time.Sleep(250 * time.Millisecond)
+	atomic.AddUint32(&serial, 1)
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("the watcher failed to report the serial number change")
+	case change := <-ch:
+		if change.Zone != "zone.net" || change.NewSerial != change.OldSerial+1 {
+			t.Errorf("the change was not reported correctly: %v", change)
+		}
+	}
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestWatchSOAInterval(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		// The watcher must not panic and close the channel once the context expires
+		for range r.WatchSOA(ctx, interval, "zone.net") {
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"reflect"
+	"runtime"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// The transports reported by the ResponseSource.
+const (
+	TransportUDP   = "udp"
+	TransportTCP   = "tcp"
+	TransportHosts = "hosts"
+	TransportTLS   = "tls"
+)
+
+// ResponseSource describes how a response was obtained through the pool: the nameserver that
+// answered, the transport used, or the hosts overrides, and the number of attempts made by
+// QueryBlocking. WildcardChecked indicates that the response was tested by WildcardDetected,
+// and Wildcard contains the result.
+type ResponseSource struct {
+	Server          string `json:"server,omitempty"`
+	Transport       string `json:"transport"`
+	Attempts        int    `json:"attempts"`
+	WildcardChecked bool   `json:"wildcard_checked,omitempty"`
+	Wildcard        bool   `json:"wildcard,omitempty"`
+}
+
+// sourceTable holds the ResponseSource of each response obtained through the pool, keyed by the
+// address of the message, so the source is reported without adding records to the message.
+// The entries are removed by the finalizer set on the message once it is no longer referenced.
+type sourceTable struct {
+	sync.Mutex
+	sources map[uintptr]ResponseSource
+}
+
+var sources = &sourceTable{sources: make(map[uintptr]ResponseSource)}
+
+func msgKey(msg *dns.Msg) uintptr {
+	return reflect.ValueOf(msg).Pointer()
+}
+
+// SourceOf returns a copy of the ResponseSource of a response obtained through the pool, or nil
+// when the message was not returned by the pool, such as a copy of the response.
+func SourceOf(msg *dns.Msg) *ResponseSource {
+	if msg == nil {
+		return nil
+	}
+
+	sources.Lock()
+	defer sources.Unlock()
+
+	if s, found := sources.sources[msgKey(msg)]; found {
+		return &s
+	}
+	return nil
+}
+
+// Records a new ResponseSource for a single attempt of the response, before it is delivered.
+func setSource(msg *dns.Msg, server, transport string) {
+	sources.Lock()
+	defer sources.Unlock()
+
+	key := msgKey(msg)
+	if _, found := sources.sources[key]; !found {
+		runtime.SetFinalizer(msg, func(m *dns.Msg) {
+			sources.Lock()
+			defer sources.Unlock()
+
+			delete(sources.sources, msgKey(m))
+		})
+	}
+	sources.sources[key] = ResponseSource{Server: server, Transport: transport, Attempts: 1}
+}
+
+// Applies the changes to the ResponseSource of the response, when present. The message itself
+// is not modified, so the response can be shared with the event subscribers.
+func updateSource(msg *dns.Msg, update func(s *ResponseSource)) {
+	sources.Lock()
+	defer sources.Unlock()
+
+	key := msgKey(msg)
+	if s, found := sources.sources[key]; found {
+		update(&s)
+		sources.sources[key] = s
+	}
+}
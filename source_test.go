@@ -0,0 +1,102 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestResponseSource(t *testing.T) {
+	msg := new(dns.Msg).SetQuestion("owasp.org.", dns.TypeA)
+	if SourceOf(msg) != nil || SourceOf(nil) != nil {
+		t.Errorf("a source was returned for a message without one")
+	}
+
+	updateSource(msg, func(s *ResponseSource) { s.Attempts = 5 })
+	if SourceOf(msg) != nil {
+		t.Errorf("a source was recorded by the update")
+	}
+
+	setSource(msg, "192.0.2.1:53", TransportUDP)
+	updateSource(msg, func(s *ResponseSource) { s.WildcardChecked = true })
+	if s := SourceOf(msg); s == nil || s.Server != "192.0.2.1:53" || s.Transport != TransportUDP ||
+		s.Attempts != 1 || !s.WildcardChecked || s.Wildcard {
+		t.Errorf("unexpected source: %+v", s)
+	}
+	// The source is not carried by the message
+	if msg.IsEdns0() != nil || SourceOf(msg.Copy()) != nil {
+		t.Errorf("the source was added to the message")
+	}
+}
+
+func TestResponseSourceRelease(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		setSource(new(dns.Msg).SetQuestion("owasp.org.", dns.TypeA), "192.0.2.1:53", TransportUDP)
+	}
+
+	// The sources are removed once the messages are no longer referenced
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		runtime.GC()
+		sources.Lock()
+		n := len(sources.sources)
+		sources.Unlock()
+		if n < 100 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("the sources of the unreferenced messages were kept")
+}
+
+func TestQuerySources(t *testing.T) {
+	var queries int32
+	mux := dns.NewServeMux()
+	mux.HandleFunc("source.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		// The first query for the retry name does not receive a response
+		if req.Question[0].Name == "retry.source.net." && atomic.AddInt32(&queries, 1) == 1 {
+			return
+		}
+		typeAHandler(w, req)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	r.SetTimeout(250 * time.Millisecond)
+	r.SetRetries(3)
+	defer r.Stop()
+	_ = r.AddHostsOverride("pinned.source.net", "10.0.0.1")
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("www.source.net", dns.TypeA))
+	if src := SourceOf(resp); err != nil || src == nil || src.Server != addrstr || src.Transport != TransportUDP || src.Attempts != 1 {
+		t.Errorf("unexpected source for the resolved name: %+v %v", src, err)
+	}
+	// The server does not use EDNS, so the response must not contain an OPT record
+	if resp.IsEdns0() != nil {
+		t.Errorf("an OPT record was added to the response")
+	}
+
+	resp, err = r.QueryBlocking(context.Background(), QueryMsg("retry.source.net", dns.TypeA))
+	if src := SourceOf(resp); err != nil || src == nil || src.Attempts != 2 {
+		t.Errorf("the attempts were not reported: %+v %v", src, err)
+	}
+
+	resp, err = r.QueryBlocking(context.Background(), QueryMsg("pinned.source.net", dns.TypeA))
+	if src := SourceOf(resp); err != nil || src == nil || src.Server != "" || src.Transport != TransportHosts {
+		t.Errorf("unexpected source for the pinned name: %+v %v", src, err)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+const numOfSRVQueries = 50
+
+// DefaultSRVServices is the list of well-known _service._proto labels queried by ExpandSRV.
+var DefaultSRVServices = []string{
+	"_ldap._tcp",
+	"_ldaps._tcp",
+	"_gc._tcp",
+	"_kerberos._tcp",
+	"_kerberos._udp",
+	"_kpasswd._tcp",
+	"_kpasswd._udp",
+	"_sip._tcp",
+	"_sip._udp",
+	"_sip._tls",
+	"_sips._tcp",
+	"_sipfederationtls._tcp",
+	"_autodiscover._tcp",
+	"_xmpp-client._tcp",
+	"_xmpp-server._tcp",
+	"_imap._tcp",
+	"_imaps._tcp",
+	"_pop3._tcp",
+	"_pop3s._tcp",
+	"_submission._tcp",
+	"_caldav._tcp",
+	"_caldavs._tcp",
+	"_carddav._tcp",
+	"_carddavs._tcp",
+	"_vlmcs._tcp",
+	"_h323cs._tcp",
+}
+
+// SRVTarget is a service endpoint discovered in the SRV records of a domain.
+type SRVTarget struct {
+	Domain   string
+	Service  string
+	Name     string
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   string
+}
+
+// ExpandSRV queries the _service._proto labels under each of the domains and returns the
+// targets of the SRV records found. DefaultSRVServices is used when no services are provided,
+// and records indicating that a service is not available are not included.
+func (r *Resolvers) ExpandSRV(ctx context.Context, domains []string, services ...string) []*SRVTarget {
+	if len(services) == 0 {
+		services = DefaultSRVServices
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var targets []*SRVTarget
+	sem := make(chan struct{}, numOfSRVQueries)
+loop:
+	for _, d := range domains {
+		domain := strings.ToLower(RemoveLastDot(d))
+
+		for _, svc := range services {
+			select {
+			case <-ctx.Done():
+				break loop
+			case sem <- struct{}{}:
+			}
+
+			wg.Add(1)
+			go func(domain, svc string) {
+				defer func() { <-sem; wg.Done() }()
+
+				if found := r.srvLookup(ctx, domain, svc); len(found) > 0 {
+					mu.Lock()
+					targets = append(targets, found...)
+					mu.Unlock()
+				}
+			}(domain, strings.ToLower(RemoveLastDot(svc)))
+		}
+	}
+	wg.Wait()
+
+	sort.Slice(targets, func(i, j int) bool {
+		a, b := targets[i], targets[j]
+
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		return a.Target < b.Target
+	})
+	return targets
+}
+
+func (r *Resolvers) srvLookup(ctx context.Context, domain, svc string) []*SRVTarget {
+	name := svc + "." + domain
+
+	resp, err := r.QueryWithRetries(ctx, QueryMsg(name, dns.TypeSRV))
+	if err != nil || resp.Rcode != dns.RcodeSuccess {
+		return nil
+	}
+
+	var targets []*SRVTarget
+	for _, srv := range Answers[*dns.SRV](resp) {
+		// A target of "." indicates the service is decidedly not available
+		if target := strings.ToLower(RemoveLastDot(srv.Target)); target != "" {
+			targets = append(targets, &SRVTarget{
+				Domain:   domain,
+				Service:  svc,
+				Name:     name,
+				Priority: srv.Priority,
+				Weight:   srv.Weight,
+				Port:     srv.Port,
+				Target:   target,
+			})
+		}
+	}
+	return targets
+}
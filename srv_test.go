@@ -0,0 +1,69 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestExpandSRV(t *testing.T) {
+	records := map[string][]string{
+		"_ldap._tcp.srv.net.": {
+			`_ldap._tcp.srv.net. 300 IN SRV 10 50 389 dc2.srv.net.`,
+			`_ldap._tcp.srv.net. 300 IN SRV 0 100 389 dc1.srv.net.`,
+		},
+		"_sip._tls.srv.net.":        {`_sip._tls.srv.net. 300 IN SRV 0 0 443 sip.srv.net.`},
+		"_kerberos._udp.srv.net.":   {`_kerberos._udp.srv.net. 300 IN SRV 0 0 0 .`},
+		"_ldap._tcp.other.srv.net.": {`_ldap._tcp.other.srv.net. 300 IN SRV 0 0 636 ldap.other.srv.net.`},
+	}
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc("srv.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		if rrs, found := records[req.Question[0].Name]; found {
+			for _, s := range rrs {
+				if rr, err := dns.NewRR(s); err == nil {
+					m.Answer = append(m.Answer, rr)
+				}
+			}
+		} else {
+			m.Rcode = dns.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	targets := r.ExpandSRV(context.Background(), []string{"srv.net", "other.srv.net."})
+	if len(targets) != 4 {
+		t.Fatalf("expected 4 SRV targets, got %d", len(targets))
+	}
+	if first := targets[0]; first.Name != "_ldap._tcp.other.srv.net" || first.Domain != "other.srv.net" || first.Port != 636 {
+		t.Errorf("unexpected first target: %+v", first)
+	}
+	if targets[1].Target != "dc1.srv.net" || targets[2].Target != "dc2.srv.net" || targets[1].Service != "_ldap._tcp" {
+		t.Errorf("the targets were not ordered by priority: %+v %+v", targets[1], targets[2])
+	}
+	if last := targets[3]; last.Target != "sip.srv.net" || last.Port != 443 {
+		t.Errorf("unexpected last target: %+v", last)
+	}
+
+	if targets := r.ExpandSRV(context.Background(), []string{"srv.net"}, "_sip._tls."); len(targets) != 1 {
+		t.Errorf("the provided services were not used: %v", targets)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"github.com/miekg/dns"
+)
+
+// ResponseStatus classifies the outcome of a query.
+type ResponseStatus string
+
+// The statuses returned by StatusOf.
+const (
+	// StatusAnswer indicates records of the type queried were returned.
+	StatusAnswer ResponseStatus = "ANSWER"
+	// StatusNoData indicates the name exists without records of the type queried (RFC 2308).
+	StatusNoData ResponseStatus = "NODATA"
+	// StatusNXDomain indicates the name does not exist.
+	StatusNXDomain ResponseStatus = "NXDOMAIN"
+	// StatusNoResponse indicates no response was received for the query.
+	StatusNoResponse ResponseStatus = "NORESPONSE"
+	// StatusError indicates the server returned another response code, such as SERVFAIL.
+	StatusError ResponseStatus = "ERROR"
+)
+
+// StatusOf returns the status of the response. A NOERROR response is NODATA when the answer
+// section is empty or only provides aliases, without records of the type queried.
+func StatusOf(msg *dns.Msg) ResponseStatus {
+	if msg == nil || msg.Rcode == RcodeNoResponse {
+		return StatusNoResponse
+	}
+
+	switch msg.Rcode {
+	case dns.RcodeNameError:
+		return StatusNXDomain
+	case dns.RcodeSuccess:
+	default:
+		return StatusError
+	}
+
+	if len(msg.Question) == 0 {
+		if len(msg.Answer) > 0 {
+			return StatusAnswer
+		}
+		return StatusNoData
+	}
+
+	qtype := msg.Question[0].Qtype
+	for _, rr := range msg.Answer {
+		if t := rr.Header().Rrtype; t == qtype || qtype == dns.TypeANY {
+			return StatusAnswer
+		}
+	}
+	return StatusNoData
+}
@@ -0,0 +1,44 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestStatusOf(t *testing.T) {
+	reply := func(qtype uint16, rcode int, answers ...dns.RR) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion("www.owasp.org.", qtype)
+		m.Rcode = rcode
+		m.Answer = answers
+		return m
+	}
+
+	noResponse := QueryMsg("www.owasp.org", dns.TypeA)
+	noResponse.Rcode = RcodeNoResponse
+
+	for _, c := range []struct {
+		label    string
+		msg      *dns.Msg
+		expected ResponseStatus
+	}{
+		{"nil message", nil, StatusNoResponse},
+		{"no response", noResponse, StatusNoResponse},
+		{"answer", reply(dns.TypeA, dns.RcodeSuccess, testARecord("www.owasp.org.", "192.168.1.1")), StatusAnswer},
+		{"empty answer", reply(dns.TypeAAAA, dns.RcodeSuccess), StatusNoData},
+		{"alias only", reply(dns.TypeAAAA, dns.RcodeSuccess, testCNAMERecord("www.owasp.org.", "owasp.org.")), StatusNoData},
+		{"alias queried", reply(dns.TypeCNAME, dns.RcodeSuccess, testCNAMERecord("www.owasp.org.", "owasp.org.")), StatusAnswer},
+		{"any type", reply(dns.TypeANY, dns.RcodeSuccess, testARecord("www.owasp.org.", "192.168.1.1")), StatusAnswer},
+		{"nxdomain", reply(dns.TypeA, dns.RcodeNameError), StatusNXDomain},
+		{"servfail", reply(dns.TypeA, dns.RcodeServerFailure), StatusError},
+	} {
+		if got := StatusOf(c.msg); got != c.expected {
+			t.Errorf("%s: Got: %s; Expected: %s", c.label, got, c.expected)
+		}
+	}
+}
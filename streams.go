@@ -0,0 +1,173 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// streamer sends queries to a nameserver on a transport other than the UDP sockets of the
+// pool, such as the persistent connections of a streamPool. The resolvers with a streamer
+// are not sent truncation fallbacks, since the transport is expected to carry full responses.
+type streamer interface {
+	exchange(ctx context.Context, msg *dns.Msg, timeout time.Duration) (*dns.Msg, error)
+	close()
+}
+
+// maxIdleStreams is the number of idle connections kept open to each nameserver.
+const maxIdleStreams = 4
+
+// streamPool keeps persistent connections to a nameserver over TCP or TLS, so each query
+// does not pay for the handshakes of a new connection.
+type streamPool struct {
+	sync.Mutex
+	client *dns.Client
+	addr   string
+	idle   []*dns.Conn
+	closed bool
+}
+
+func newStreamPool(network, addr string, config *tls.Config) *streamPool {
+	return &streamPool{
+		client: &dns.Client{
+			Net:       network,
+			TLSConfig: config,
+		},
+		addr: addr,
+	}
+}
+
+// Sends the message on an idle connection, or a new connection when none are available, and
+// returns the response. A failed idle connection, which the server may have closed, is replaced
+// by a new connection for a second attempt.
+func (s *streamPool) exchange(ctx context.Context, msg *dns.Msg, timeout time.Duration) (*dns.Msg, error) {
+	if conn := s.get(); conn != nil {
+		if resp, err := s.exchangeOn(ctx, conn, msg, timeout); err == nil {
+			return resp, nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	client := *s.client
+	client.Timeout = timeout
+	conn, err := client.DialContext(ctx, s.addr)
+	if err != nil {
+		return nil, err
+	}
+	return s.exchangeOn(ctx, conn, msg, timeout)
+}
+
+func (s *streamPool) exchangeOn(ctx context.Context, conn *dns.Conn, msg *dns.Msg, timeout time.Duration) (*dns.Msg, error) {
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	// Expiring the deadline releases the blocked write or read when the context is cancelled
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if err := conn.WriteMsg(msg); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	resp, err := conn.ReadMsg()
+	if err == nil && resp.Id != msg.Id {
+		err = errors.New("the response ID did not match the query")
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	s.put(conn)
+	return resp, nil
+}
+
+func (s *streamPool) get() *dns.Conn {
+	s.Lock()
+	defer s.Unlock()
+
+	if n := len(s.idle); n > 0 {
+		conn := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		return conn
+	}
+	return nil
+}
+
+func (s *streamPool) put(conn *dns.Conn) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.closed || len(s.idle) >= maxIdleStreams {
+		_ = conn.Close()
+		return
+	}
+	s.idle = append(s.idle, conn)
+}
+
+func (s *streamPool) close() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.closed = true
+	for _, conn := range s.idle {
+		_ = conn.Close()
+	}
+	s.idle = nil
+}
+
+// Sends the request over the persistent connections of the resolver. The response is handled
+// by the pool in the same way as the responses received on the UDP sockets.
+func (r *resolver) streamExchange(req *request) {
+	msg := req.Msg.Copy()
+	ctx := req.context()
+	req.Timestamp = time.Now()
+	req.trace.send()
+
+	if r.xchgs.add(req) != nil {
+		req.errNoResponse()
+		req.release()
+		return
+	}
+	r.streamMsg(ctx, msg)
+}
+
+// Exchanges the message of the request added to the exchanges, which no longer owns the request.
+func (r *resolver) streamMsg(ctx context.Context, msg *dns.Msg) {
+	defer r.recoverExchange("exchanging over "+r.proto, msg)
+
+	r.collectQuery()
+	r.pool.logPacket("sent over "+r.proto+" to", r.address, msg)
+
+	r.xchgs.Lock()
+	timeout := r.xchgs.timeout
+	r.xchgs.Unlock()
+
+	m, err := r.stream.exchange(ctx, msg, timeout)
+	if err != nil {
+		if req := r.xchgs.remove(msg.Id, msg.Question[0].Name); req != nil {
+			r.pool.expired(r, req)
+			r.pool.breaker.failure(req)
+			req.errNoResponse()
+			req.release()
+		}
+		return
+	}
+
+	r.pool.resps.Append(&resp{
+		Msg:       m,
+		Addr:      r.address,
+		Res:       []*resolver{r},
+		Size:      m.Len(),
+		Transport: r.proto,
+	})
+}
@@ -0,0 +1,93 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// testStreamer answers the queries for A records without using the network.
+type testStreamer struct {
+	sent   atomic.Int32
+	closed atomic.Bool
+}
+
+func (s *testStreamer) exchange(ctx context.Context, msg *dns.Msg, timeout time.Duration) (*dns.Msg, error) {
+	s.sent.Add(1)
+	if msg.Question[0].Qtype != dns.TypeA {
+		return nil, errors.New("no response")
+	}
+
+	resp := new(dns.Msg).SetReply(msg)
+	resp.Answer = append(resp.Answer, testARecord(msg.Question[0].Name, "192.168.1.1"))
+	return resp, nil
+}
+
+func (s *testStreamer) close() { s.closed.Store(true) }
+
+func TestStreamerResolver(t *testing.T) {
+	r := NewResolvers()
+	r.SetTimeout(100 * time.Millisecond)
+	defer r.Stop()
+
+	s := new(testStreamer)
+	r.addResolvers(10, func(res *resolver) {
+		res.proto = "test"
+		res.stream = s
+	}, "192.0.2.53")
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("stream.net", dns.TypeA))
+	if err != nil || len(IPs(resp)) != 1 {
+		t.Fatalf("the query was not answered by the streamer: %v", err)
+	}
+	if src := SourceOf(resp); src == nil || src.Transport != "test" {
+		t.Errorf("the response was not reported as received by the streamer: %+v", src)
+	}
+
+	if resp, err := r.QueryBlocking(context.Background(), QueryMsg("stream.net", dns.TypeAAAA)); err == nil && resp.Rcode != RcodeNoResponse {
+		t.Errorf("a failed exchange returned a response")
+	}
+	if s.sent.Load() < 2 {
+		t.Errorf("the queries were not sent through the streamer")
+	}
+
+	r.Stop()
+	if !s.closed.Load() {
+		t.Errorf("the streamer was not closed with the pool")
+	}
+}
+
+func TestStreamExchangeDuplicate(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	r.addResolvers(10, func(res *resolver) {
+		res.proto = "test"
+		res.stream = new(testStreamer)
+	}, "192.0.2.53")
+	res := r.pool.GetResolver()
+
+	msg := QueryMsg("stream.net", dns.TypeA)
+	if err := res.xchgs.add(&request{Msg: msg, Result: make(chan *dns.Msg, 1)}); err != nil {
+		t.Fatalf("failed to add the request: %v", err)
+	}
+
+	ch := make(chan *dns.Msg, 1)
+	res.streamExchange(&request{Res: res, Msg: msg.Copy(), Result: ch})
+	select {
+	case resp := <-ch:
+		if resp.Rcode != RcodeNoResponse {
+			t.Errorf("the request using a key already in use was answered")
+		}
+	default:
+		t.Errorf("the request using a key already in use did not receive a response")
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// stubZones routes the queries for names within the configured zones to dedicated nameservers.
+type stubZones struct {
+	sync.Mutex
+	zones map[string][]*resolver
+}
+
+func newStubZones() *stubZones {
+	return &stubZones{zones: make(map[string][]*resolver)}
+}
+
+// AddStubZone causes queries for names within the provided zone to be sent directly to the
+// provided nameservers with recursion desired cleared, instead of the resolvers in the pool.
+// Queries for names within nested stub zones are sent to the servers of the most specific zone.
+func (r *Resolvers) AddStubZone(qps int, zone string, addrs ...string) error {
+	if qps <= 0 {
+		return errors.New("AddStubZone: failed to provide a QPS greater than zero")
+	}
+
+	zone = strings.ToLower(dns.Fqdn(zone))
+	if _, ok := dns.IsDomainName(zone); !ok {
+		return fmt.Errorf("AddStubZone: %s is not a valid zone", zone)
+	}
+
+	var servers []*resolver
+	for _, addr := range r.expandHostnames(addrs) {
+		r.Lock()
+		res := r.initializeResolver(qps, addr)
+		r.Unlock()
+
+		if res != nil {
+			servers = append(servers, res)
+		}
+	}
+	if len(servers) == 0 {
+		return fmt.Errorf("AddStubZone: failed to add the servers for %s", zone)
+	}
+
+	r.stubs.Lock()
+	defer r.stubs.Unlock()
+
+	r.stubs.zones[zone] = append(r.stubs.zones[zone], servers...)
+	return nil
+}
+
+// Returns a nameserver for the most specific stub zone containing the name, or nil when
+// the name is not within a stub zone.
+func (s *stubZones) resolverFor(name string) *resolver {
+	s.Lock()
+	defer s.Unlock()
+
+	if servers := s.zones[s.zoneFor(name)]; len(servers) > 0 {
+		return servers[prng.Intn(len(servers))]
+	}
+	return nil
+}
+
+// Returns the most specific stub zone containing the name, or an empty string when the
+// name is not within a stub zone. Must be called holding the lock.
+func (s *stubZones) zoneFor(name string) string {
+	if len(s.zones) == 0 {
+		return ""
+	}
+
+	name = strings.ToLower(dns.Fqdn(name))
+	for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+		if servers, found := s.zones[name[off:]]; found && len(servers) > 0 {
+			return name[off:]
+		}
+	}
+	return ""
+}
+
+func (s *stubZones) allResolvers() []*resolver {
+	s.Lock()
+	defer s.Unlock()
+
+	var all []*resolver
+	for _, servers := range s.zones {
+		all = append(all, servers...)
+	}
+	return all
+}
@@ -0,0 +1,84 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestStubZones(t *testing.T) {
+	var recursive int32
+	stubMux := dns.NewServeMux()
+	stubMux.HandleFunc("corp.stub.", func(w dns.ResponseWriter, req *dns.Msg) {
+		if req.RecursionDesired {
+			atomic.AddInt32(&recursive, 1)
+		}
+
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Authoritative = true
+		m.Answer = append(m.Answer, testARecord(req.Question[0].Name, "10.0.0.1"))
+		_ = w.WriteMsg(m)
+	})
+
+	ss, stubaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = stubMux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = ss.Shutdown() }()
+
+	poolMux := dns.NewServeMux()
+	poolMux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Answer = append(m.Answer, testARecord(req.Question[0].Name, "192.168.1.1"))
+		_ = w.WriteMsg(m)
+	})
+
+	ps, pooladdr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = poolMux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = ps.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, pooladdr)
+	defer r.Stop()
+
+	if err := r.AddStubZone(0, "corp.stub", stubaddr); err == nil {
+		t.Errorf("the stub zone was added with a QPS of zero")
+	}
+	if err := r.AddStubZone(100, "corp.stub", stubaddr); err != nil {
+		t.Fatalf("failed to add the stub zone: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		want string
+	}{
+		{name: "www.corp.stub", want: "10.0.0.1"},
+		{name: "corp.stub", want: "10.0.0.1"},
+		{name: "www.other.stub", want: "192.168.1.1"},
+	}
+
+	for _, c := range cases {
+		resp, err := r.QueryBlocking(context.Background(), QueryMsg(c.name, dns.TypeA))
+		if err != nil {
+			t.Errorf("the query for %s failed: %v", c.name, err)
+			continue
+		}
+		if ans := ExtractAnswers(resp); len(ans) != 1 || ans[0].Data != c.want {
+			t.Errorf("%s was not answered by the expected server", c.name)
+		}
+	}
+
+	if atomic.LoadInt32(&recursive) > 0 {
+		t.Errorf("queries sent to the stub zone servers requested recursion")
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"slices"
+	"time"
+)
+
+// DefaultReadDeadline is the default maximum time a read on the UDP sockets blocks.
+const DefaultReadDeadline = 5 * time.Second
+
+const (
+	stuckCheckInterval = time.Second
+	// A read still blocked after this many deadlines indicates the socket has wedged
+	stuckReadFactor = 3
+	// Consecutive failed writes before the socket is considered stuck
+	maxWriteFailures = 10
+)
+
+// SetReadDeadline sets the maximum time each read on the UDP sockets of the pool blocks.
+// Sockets with a read blocked well beyond the deadline, or with writes that persistently
+// fail, are considered stuck and replaced with new sockets.
+func (r *Resolvers) SetReadDeadline(d time.Duration) {
+	if d <= 0 {
+		d = DefaultReadDeadline
+	}
+
+	for _, conns := range []*connections{r.conns, r.detConns} {
+		if conns != nil {
+			conns.deadline.Store(int64(d))
+		}
+	}
+}
+
+// ReplacedSockets returns the number of stuck UDP sockets that have been replaced by the pool.
+func (r *Resolvers) ReplacedSockets() uint64 {
+	var total uint64
+
+	for _, conns := range []*connections{r.conns, r.detConns} {
+		if conns != nil {
+			conns.Lock()
+			total += conns.replaced
+			conns.Unlock()
+		}
+	}
+	return total
+}
+
+func (r *connections) readDeadline() time.Duration {
+	return time.Duration(r.deadline.Load())
+}
+
+// Replaces the stuck connections with new sockets. As with rotate, the new sockets are opened
+// without holding the lock, and only swapped in for the stuck connections still in place.
+func (r *connections) replaceStuck() {
+	r.Lock()
+	now := time.Now()
+	deadline := r.readDeadline()
+	var stuck []*connection
+	for _, c := range r.conns {
+		if c.stuck(now, deadline) {
+			stuck = append(stuck, c)
+		}
+	}
+	rcvbuf := r.rcvbuf
+	r.Unlock()
+
+	var fresh []*connection
+	for range stuck {
+		if c, err := r.listen(rcvbuf); err == nil {
+			fresh = append(fresh, c)
+		}
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	for _, c := range stuck {
+		// The connection may have been removed by a rotation while the socket was opened
+		i := slices.Index(r.conns, c)
+		if r.closed || i < 0 || len(fresh) == 0 {
+			continue
+		}
+
+		nc := fresh[0]
+		fresh = fresh[1:]
+		r.serve(nc)
+		r.conns[i] = nc
+		r.replaced++
+
+		close(c.done)
+		// Closing the socket releases a reader that ignored the deadline
+		_ = c.conn.Close()
+	}
+	closeUnserved(fresh)
+}
+
+func (c *connection) beginRead(deadline time.Duration) {
+	now := time.Now()
+
+	c.reading.Store(now.UnixNano())
+	_ = c.conn.SetReadDeadline(now.Add(deadline))
+}
+
+func (c *connection) endRead() {
+	c.reading.Store(0)
+}
+
+func (c *connection) writeResult(err error) {
+	if err != nil {
+		c.failures.Add(1)
+	} else {
+		c.failures.Store(0)
+	}
+}
+
+// Returns true when the connection is blocked in a read or failing to write.
+func (c *connection) stuck(now time.Time, deadline time.Duration) bool {
+	if start := c.reading.Load(); start != 0 && now.Sub(time.Unix(0, start)) > stuckReadFactor*deadline {
+		return true
+	}
+	return c.failures.Load() >= maxWriteFailures
+}
@@ -0,0 +1,94 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/caffix/queue"
+)
+
+func TestSetReadDeadline(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	r.SetReadDeadline(time.Second)
+	if d := r.conns.readDeadline(); d != time.Second {
+		t.Errorf("Got the read deadline %v; Expected: %v", d, time.Second)
+	}
+
+	r.SetReadDeadline(0)
+	if d := r.conns.readDeadline(); d != DefaultReadDeadline {
+		t.Errorf("Got the read deadline %v; Expected the default %v", d, DefaultReadDeadline)
+	}
+	if n := r.ReplacedSockets(); n != 0 {
+		t.Errorf("Expected no sockets replaced, got %d", n)
+	}
+}
+
+func TestConnectionStuck(t *testing.T) {
+	now := time.Now()
+	c := new(connection)
+
+	if c.stuck(now, time.Second) {
+		t.Errorf("An idle connection was considered stuck")
+	}
+
+	c.reading.Store(now.Add(-2 * time.Second).UnixNano())
+	if c.stuck(now, time.Second) {
+		t.Errorf("A read within the deadlines was considered stuck")
+	}
+
+	c.reading.Store(now.Add(-time.Minute).UnixNano())
+	if !c.stuck(now, time.Second) {
+		t.Errorf("A read blocked beyond the deadlines was not considered stuck")
+	}
+
+	c.endRead()
+	for i := 0; i < maxWriteFailures; i++ {
+		c.writeResult(errors.New("write failed"))
+	}
+	if !c.stuck(now, time.Second) {
+		t.Errorf("Persistent write failures were not considered stuck")
+	}
+
+	c.writeResult(nil)
+	if c.stuck(now, time.Second) {
+		t.Errorf("A successful write did not reset the failures")
+	}
+}
+
+func TestReplaceStuck(t *testing.T) {
+	conns := newConnections(1, queue.NewQueue())
+	defer conns.Close()
+	if err := conns.Start(); err != nil {
+		t.Fatalf("unable to open the sockets: %v", err)
+	}
+
+	conns.Lock()
+	c := conns.conns[0]
+	conns.Unlock()
+
+	c.failures.Store(maxWriteFailures)
+	conns.replaceStuck()
+
+	conns.Lock()
+	replaced, cur := conns.replaced, conns.conns[0]
+	conns.Unlock()
+
+	if replaced != 1 || cur == c {
+		t.Errorf("The stuck connection was not replaced")
+	}
+	select {
+	case <-c.done:
+	default:
+		t.Errorf("The stuck connection was not closed")
+	}
+	if cur.stuck(time.Now(), conns.readDeadline()) {
+		t.Errorf("The new connection was considered stuck")
+	}
+}
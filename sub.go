@@ -17,24 +17,16 @@ func FirstProperSubdomain(ctx context.Context, r *Resolvers, name string) string
 	var domain string
 	// Obtain all parts of the subdomain name
 	labels := strings.Split(strings.TrimSpace(name), ".")
-loop:
-	for i := 0; i < len(labels)-1; i++ {
+	for i := 0; i < len(labels)-1 && ctx.Err() == nil; i++ {
 		sub := strings.Join(labels[i:], ".")
 
-		for i := 0; i < maxQueryAttempts; i++ {
-			resp, err := r.QueryBlocking(ctx, QueryMsg(sub, dns.TypeNS))
-			if err != nil || resp.Rcode == dns.RcodeNameError {
-				continue loop
-			}
-			if resp.Rcode == dns.RcodeSuccess {
-				if len(resp.Answer) == 0 {
-					continue loop
-				}
-				if d := AnswersByType(ExtractAnswers(resp), dns.TypeNS); len(d) > 0 {
-					domain = sub
-					break loop
-				}
-			}
+		resp, err := r.QueryWithRetries(ctx, QueryMsg(sub, dns.TypeNS))
+		if err != nil || resp.Rcode != dns.RcodeSuccess {
+			continue
+		}
+		if d := AnswersByType(ExtractAnswers(resp), dns.TypeNS); len(d) > 0 {
+			domain = sub
+			break
 		}
 	}
 	return domain
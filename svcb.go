@@ -0,0 +1,144 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ServiceEndpoint is the structured form of an SVCB or HTTPS record (RFC 9460). Target holds
+// the owner name when the TargetName of a ServiceMode record is ".", and Addresses is only
+// populated when the target has been resolved through the pool.
+type ServiceEndpoint struct {
+	Name      string
+	Priority  uint16
+	Target    string
+	Port      uint16
+	ALPN      []string
+	IPv4Hints []net.IP
+	IPv6Hints []net.IP
+	Addresses []net.IP
+}
+
+// AliasMode returns true when the record delegates the service to the Target name.
+func (e *ServiceEndpoint) AliasMode() bool {
+	return e.Priority == 0
+}
+
+// ServiceEndpoints parses the SVCB and HTTPS records in the answer section of the message,
+// ordered by priority.
+func ServiceEndpoints(msg *dns.Msg) []*ServiceEndpoint {
+	var endpoints []*ServiceEndpoint
+
+	for _, rr := range msg.Answer {
+		switch v := rr.(type) {
+		case *dns.SVCB:
+			endpoints = append(endpoints, newServiceEndpoint(v))
+		case *dns.HTTPS:
+			endpoints = append(endpoints, newServiceEndpoint(&v.SVCB))
+		}
+	}
+
+	slices.SortStableFunc(endpoints, func(a, b *ServiceEndpoint) int {
+		return int(a.Priority) - int(b.Priority)
+	})
+	return endpoints
+}
+
+func newServiceEndpoint(svcb *dns.SVCB) *ServiceEndpoint {
+	e := &ServiceEndpoint{
+		Name:     strings.ToLower(RemoveLastDot(svcb.Hdr.Name)),
+		Priority: svcb.Priority,
+		Target:   strings.ToLower(RemoveLastDot(svcb.Target)),
+	}
+	if e.Target == "" && !e.AliasMode() {
+		e.Target = e.Name
+	}
+
+	for _, kv := range svcb.Value {
+		switch v := kv.(type) {
+		case *dns.SVCBAlpn:
+			e.ALPN = append(e.ALPN, v.Alpn...)
+		case *dns.SVCBPort:
+			e.Port = v.Port
+		case *dns.SVCBIPv4Hint:
+			e.IPv4Hints = append(e.IPv4Hints, v.Hint...)
+		case *dns.SVCBIPv6Hint:
+			e.IPv6Hints = append(e.IPv6Hints, v.Hint...)
+		}
+	}
+	return e
+}
+
+// ResolveServiceEndpoints obtains the SVCB or HTTPS records for the name, following AliasMode
+// records to the ServiceMode records of the target. When resolve is true, the TargetName of
+// each endpoint is resolved through the pool to populate the Addresses.
+func (r *Resolvers) ResolveServiceEndpoints(ctx context.Context, name string, qtype uint16, resolve bool) ([]*ServiceEndpoint, error) {
+	if qtype != dns.TypeSVCB && qtype != dns.TypeHTTPS {
+		return nil, fmt.Errorf("ResolveServiceEndpoints: %s is not a service binding type", dns.TypeToString[qtype])
+	}
+
+	name = strings.ToLower(RemoveLastDot(name))
+	seen := map[string]struct{}{name: {}}
+	depth := r.maxAliasDepth()
+
+	var endpoints []*ServiceEndpoint
+	for {
+		_, resp, err := r.FollowAliases(ctx, name, qtype)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Rcode != dns.RcodeSuccess {
+			return nil, nil
+		}
+
+		all := ServiceEndpoints(resp)
+		// ServiceMode records take precedence over any AliasMode records in the set
+		endpoints = slices.DeleteFunc(slices.Clone(all), (*ServiceEndpoint).AliasMode)
+		if len(endpoints) > 0 || len(all) == 0 {
+			break
+		}
+		// An AliasMode record with the "." TargetName indicates the service is not available
+		if name = all[0].Target; name == "" {
+			return nil, nil
+		}
+		if _, found := seen[name]; found {
+			return nil, ErrAliasLoop
+		}
+		if seen[name] = struct{}{}; len(seen) > depth {
+			return nil, ErrAliasDepth
+		}
+	}
+
+	if resolve {
+		r.resolveServiceTargets(ctx, endpoints)
+	}
+	return endpoints, nil
+}
+
+// Populates the Addresses of the endpoints, querying each distinct target only once.
+func (r *Resolvers) resolveServiceTargets(ctx context.Context, endpoints []*ServiceEndpoint) {
+	addrs := make(map[string][]net.IP)
+
+	for _, e := range endpoints {
+		if _, found := addrs[e.Target]; !found {
+			var ips []net.IP
+
+			for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+				if _, resp, err := r.FollowAliases(ctx, e.Target, qtype); err == nil && resp.Rcode == dns.RcodeSuccess {
+					ips = append(ips, IPs(resp)...)
+				}
+			}
+			addrs[e.Target] = ips
+		}
+		e.Addresses = addrs[e.Target]
+	}
+}
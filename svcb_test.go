@@ -0,0 +1,98 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServiceEndpoints(t *testing.T) {
+	msg := QueryMsg("svc.net", dns.TypeHTTPS)
+	for _, s := range []string{
+		`svc.net. 300 IN HTTPS 2 alt.svc.net. alpn=h2 port=8443 ipv6hint=2001:db8::1`,
+		`svc.net. 300 IN HTTPS 1 . alpn=h3,h2 ipv4hint=192.0.2.1,192.0.2.2`,
+	} {
+		if rr, err := dns.NewRR(s); err == nil {
+			msg.Answer = append(msg.Answer, rr)
+		}
+	}
+
+	endpoints := ServiceEndpoints(msg)
+	if len(endpoints) != 2 {
+		t.Fatalf("expected 2 endpoints, got %d", len(endpoints))
+	}
+
+	first, second := endpoints[0], endpoints[1]
+	if first.Priority != 1 || first.Target != "svc.net" || first.AliasMode() ||
+		!reflect.DeepEqual(first.ALPN, []string{"h3", "h2"}) || len(first.IPv4Hints) != 2 {
+		t.Errorf("the first endpoint was not parsed correctly: %+v", first)
+	}
+	if second.Target != "alt.svc.net" || second.Port != 8443 ||
+		len(second.IPv6Hints) != 1 || second.IPv6Hints[0].String() != "2001:db8::1" {
+		t.Errorf("the second endpoint was not parsed correctly: %+v", second)
+	}
+}
+
+func TestResolveServiceEndpoints(t *testing.T) {
+	records := map[string][]string{
+		"svc.net./HTTPS":       {`svc.net. 300 IN HTTPS 0 pool.svc.net.`},
+		"pool.svc.net./HTTPS":  {`pool.svc.net. 300 IN HTTPS 1 edge.svc.net. alpn=h2`},
+		"edge.svc.net./A":      {`edge.svc.net. 300 IN A 192.0.2.10`},
+		"edge.svc.net./AAAA":   {`edge.svc.net. 300 IN AAAA 2001:db8::10`},
+		"loop.svc.net./HTTPS":  {`loop.svc.net. 300 IN HTTPS 0 loop2.svc.net.`},
+		"loop2.svc.net./HTTPS": {`loop2.svc.net. 300 IN HTTPS 0 loop.svc.net.`},
+		"none.svc.net./HTTPS":  {`none.svc.net. 300 IN HTTPS 0 .`},
+	}
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc("svc.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		q := req.Question[0]
+		for _, s := range records[q.Name+"/"+dns.TypeToString[q.Qtype]] {
+			if rr, err := dns.NewRR(s); err == nil {
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	endpoints, err := r.ResolveServiceEndpoints(context.Background(), "svc.net", dns.TypeHTTPS, true)
+	if err != nil || len(endpoints) != 1 {
+		t.Fatalf("failed to resolve the service endpoints: %v %v", endpoints, err)
+	}
+	if e := endpoints[0]; e.Name != "pool.svc.net" || e.Target != "edge.svc.net" || len(e.Addresses) != 2 {
+		t.Errorf("the AliasMode record or the target was not followed: %+v", e)
+	}
+
+	if endpoints, err := r.ResolveServiceEndpoints(context.Background(), "pool.svc.net", dns.TypeHTTPS, false); err != nil ||
+		len(endpoints) != 1 || endpoints[0].Addresses != nil {
+		t.Errorf("the target was resolved when not requested: %v %v", endpoints, err)
+	}
+	if _, err := r.ResolveServiceEndpoints(context.Background(), "loop.svc.net", dns.TypeHTTPS, false); err != ErrAliasLoop {
+		t.Errorf("the AliasMode loop was not detected: %v", err)
+	}
+	if endpoints, err := r.ResolveServiceEndpoints(context.Background(), "none.svc.net", dns.TypeHTTPS, false); err != nil || endpoints != nil {
+		t.Errorf("the unavailable service returned endpoints: %v %v", endpoints, err)
+	}
+	if _, err := r.ResolveServiceEndpoints(context.Background(), "svc.net", dns.TypeA, false); err == nil {
+		t.Errorf("a type other than SVCB or HTTPS was accepted")
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// SystemConfig contains the DNS resolver configuration of the host system.
+type SystemConfig struct {
+	Servers []string
+	Search  []string
+	Ndots   int
+}
+
+// SystemResolverConfig returns the DNS resolver configuration of the host system, obtained
+// from /etc/resolv.conf or the network adapters on Windows.
+func SystemResolverConfig() (*SystemConfig, error) {
+	return systemConfig()
+}
+
+// AddSystemResolvers adds the resolvers configured on the host system to the pool and
+// returns the configuration, so the search domains and ndots value can be applied.
+func (r *Resolvers) AddSystemResolvers(qps int) (*SystemConfig, error) {
+	cfg, err := SystemResolverConfig()
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Servers) == 0 {
+		return nil, errors.New("the host system does not have resolvers configured")
+	}
+
+	if err := r.AddResolvers(qps, cfg.Servers...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Returns the configuration from the resolv.conf file at the provided path.
+func parseResolvConf(path string) (*SystemConfig, error) {
+	conf, err := dns.ClientConfigFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &SystemConfig{
+		Search: conf.Search,
+		Ndots:  conf.Ndots,
+	}
+	for _, server := range conf.Servers {
+		cfg.Servers = append(cfg.Servers, net.JoinHostPort(server, conf.Port))
+	}
+	return cfg, nil
+}
@@ -0,0 +1,50 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseResolvConf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	conf := "nameserver 192.168.1.1\nnameserver 2001:db8::1\nsearch corp.example.com example.com\noptions ndots:2\n"
+	if err := os.WriteFile(path, []byte(conf), 0600); err != nil {
+		t.Fatalf("failed to write the resolv.conf file: %v", err)
+	}
+
+	cfg, err := parseResolvConf(path)
+	if err != nil {
+		t.Fatalf("failed to parse the resolv.conf file: %v", err)
+	}
+	if len(cfg.Servers) != 2 || cfg.Servers[0] != "192.168.1.1:53" || cfg.Servers[1] != "[2001:db8::1]:53" {
+		t.Errorf("the servers were not parsed correctly: %v", cfg.Servers)
+	}
+	if len(cfg.Search) != 2 || cfg.Search[0] != "corp.example.com" {
+		t.Errorf("the search domains were not parsed correctly: %v", cfg.Search)
+	}
+	if cfg.Ndots != 2 {
+		t.Errorf("the ndots value was parsed as %d instead of 2", cfg.Ndots)
+	}
+
+	if _, err := parseResolvConf(filepath.Join(t.TempDir(), "missing.conf")); err == nil {
+		t.Errorf("parsing a missing file did not return an error")
+	}
+}
+
+func TestAddSystemResolvers(t *testing.T) {
+	if _, err := SystemResolverConfig(); err != nil {
+		t.Skipf("the host system resolver configuration is not available: %v", err)
+	}
+
+	r := NewResolvers()
+	defer r.Stop()
+
+	if cfg, err := r.AddSystemResolvers(10); err == nil && r.Len() != len(cfg.Servers) {
+		t.Errorf("the pool has %d resolvers instead of the %d configured", r.Len(), len(cfg.Servers))
+	}
+}
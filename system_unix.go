@@ -0,0 +1,13 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package resolve
+
+const resolvConfPath = "/etc/resolv.conf"
+
+func systemConfig() (*SystemConfig, error) {
+	return parseResolvConf(resolvConfPath)
+}
@@ -0,0 +1,60 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+
+package resolve
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+func systemConfig() (*SystemConfig, error) {
+	size := uint32(15000)
+
+	var buf []byte
+	for {
+		buf = make([]byte, size)
+		err := windows.GetAdaptersAddresses(syscall.AF_UNSPEC, windows.GAA_FLAG_SKIP_ANYCAST|windows.GAA_FLAG_SKIP_MULTICAST,
+			0, (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])), &size)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, windows.ERROR_BUFFER_OVERFLOW) {
+			return nil, err
+		}
+	}
+
+	cfg := &SystemConfig{Ndots: 1}
+	servers := make(map[string]struct{})
+	for aa := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])); aa != nil; aa = aa.Next {
+		if aa.OperStatus != windows.IfOperStatusUp {
+			continue
+		}
+
+		for ds := aa.FirstDnsServerAddress; ds != nil; ds = ds.Next {
+			ip := ds.Address.IP()
+			// Skip the deprecated site-local addresses that Windows configures by default
+			if ip == nil || (ip.To4() == nil && ip[0] == 0xfe && ip[1]&0xc0 == 0xc0) {
+				continue
+			}
+
+			addr := net.JoinHostPort(ip.String(), "53")
+			if _, found := servers[addr]; !found {
+				servers[addr] = struct{}{}
+				cfg.Servers = append(cfg.Servers, addr)
+			}
+		}
+
+		if suffix := windows.UTF16PtrToString(aa.DnsSuffix); suffix != "" {
+			cfg.Search = append(cfg.Search, suffix)
+		}
+	}
+	return cfg, nil
+}
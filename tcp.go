@@ -0,0 +1,36 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import "errors"
+
+// SetTCPOnly causes the nameservers added to the pool after the call, including the stub zone,
+// routing and authoritative servers, to receive all queries over persistent TCP connections
+// instead of UDP. This helps with upstream resolvers that rate limit UDP aggressively.
+func (r *Resolvers) SetTCPOnly(enabled bool) {
+	r.tcpOnly.Store(enabled)
+}
+
+// AddTCPResolvers adds the resolvers to the pool, which receive all queries over persistent
+// TCP connections instead of UDP. The addresses default to port 53.
+func (r *Resolvers) AddTCPResolvers(qps int, addrs ...string) error {
+	if qps == 0 {
+		return errors.New("failed to provide a maximum number of queries per second greater than zero")
+	}
+	if r.cloned {
+		return errors.New("resolvers cannot be added to a pool created by Clone")
+	}
+
+	r.addResolvers(qps, func(res *resolver) {
+		res.useTCP()
+	}, r.expandHostnames(addrs)...)
+	return nil
+}
+
+// Sends the queries for the resolver over the persistent TCP connections of its pipeline.
+func (r *resolver) useTCP() {
+	r.proto = TransportTCP
+	r.stream = r.tcp
+}
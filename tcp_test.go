@@ -0,0 +1,75 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestAddTCPResolvers(t *testing.T) {
+	dns.HandleFunc("tcp.net.", typeAHandler)
+	defer dns.HandleRemove("tcp.net.")
+
+	s, addrstr, _, err := RunLocalTCPServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	defer r.Stop()
+
+	if err := r.AddTCPResolvers(0, addrstr); err == nil {
+		t.Errorf("a QPS of zero was accepted")
+	}
+	if err := r.AddTCPResolvers(10, addrstr); err != nil || r.Len() != 1 {
+		t.Fatalf("failed to add the TCP resolver: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		resp, err := r.QueryBlocking(context.Background(), QueryMsg("tcp.net", dns.TypeA))
+		if err != nil || len(IPs(resp)) != 1 {
+			t.Fatalf("the query was not answered over TCP: %v", err)
+		}
+		if src := SourceOf(resp); src == nil || src.Transport != TransportTCP {
+			t.Errorf("the response was not reported as received over TCP: %+v", src)
+		}
+	}
+}
+
+func TestSetTCPOnly(t *testing.T) {
+	dns.HandleFunc("tcp.net.", typeAHandler)
+	defer dns.HandleRemove("tcp.net.")
+
+	s, addrstr, _, err := RunLocalTCPServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	defer r.Stop()
+
+	r.SetTCPOnly(true)
+	_ = r.AddResolvers(10, addrstr)
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("tcp.net", dns.TypeA))
+	if err != nil || len(IPs(resp)) != 1 {
+		t.Fatalf("the query was not answered over TCP: %v", err)
+	}
+	if src := SourceOf(resp); src == nil || src.Transport != TransportTCP {
+		t.Errorf("the response was not reported as received over TCP: %+v", src)
+	}
+
+	r.SetTCPOnly(false)
+	if res := r.initializeResolver(10, "127.0.0.1:5399"); res == nil || res.stream != nil {
+		t.Errorf("the resolver was set up for TCP after the mode was disabled")
+	} else {
+		res.stop()
+	}
+}
@@ -5,6 +5,7 @@
 package resolve
 
 import (
+	"sort"
 	"sync"
 	"time"
 
@@ -13,6 +14,9 @@ import (
 
 const thresholdCheckInterval time.Duration = 3 * time.Second
 
+// The number of recent response sizes kept for each resolver to compute the percentiles.
+const sizeSamples int = 256
+
 type ThresholdOptions struct {
 	ThresholdValue         uint64
 	CumulativeAccumulation bool // instead of continuous
@@ -36,6 +40,81 @@ type stats struct {
 	NotImplemented      uint64
 	CountQueryRefusals  bool
 	QueryRefusals       uint64
+	Queries             uint64
+	Responses           uint64
+	Successes           uint64
+	TotalRTT            time.Duration
+	Truncated           uint64
+	TotalSize           uint64
+	Sizes               [sizeSamples]uint16
+	SizeIndex           int
+	Throttle            throttleCounts
+}
+
+// ResolverStats summarizes the queries sent to a resolver in the pool.
+type ResolverStats struct {
+	Address   string
+	Queries   uint64
+	Successes uint64
+	Timeouts  uint64
+	AvgRTT    time.Duration
+	Responses uint64
+	Truncated uint64
+	AvgSize   int
+	P50Size   int
+	P95Size   int
+	MaxSize   int
+}
+
+// SuccessRate returns the percentage of the queries that received a NOERROR or NXDOMAIN response.
+func (s *ResolverStats) SuccessRate() float64 {
+	if s.Queries == 0 {
+		return 0
+	}
+	return float64(s.Successes) / float64(s.Queries) * 100
+}
+
+// TruncationRate returns the percentage of the responses received with the TC bit set.
+func (s *ResolverStats) TruncationRate() float64 {
+	if s.Responses == 0 {
+		return 0
+	}
+	return float64(s.Truncated) / float64(s.Responses) * 100
+}
+
+// ResolverStats returns the statistics for each resolver in the pool, sorted by address.
+func (r *Resolvers) ResolverStats() []*ResolverStats {
+	var all []*ResolverStats
+
+	for _, res := range r.pool.AllResolvers() {
+		res.stats.Lock()
+		s := &ResolverStats{
+			Address:   res.address.String(),
+			Queries:   res.stats.Queries,
+			Successes: res.stats.Successes,
+			Timeouts:  res.stats.Timeouts,
+			Responses: res.stats.Responses,
+			Truncated: res.stats.Truncated,
+		}
+		if res.stats.Responses > 0 {
+			s.AvgRTT = res.stats.TotalRTT / time.Duration(res.stats.Responses)
+			s.AvgSize = int(res.stats.TotalSize / res.stats.Responses)
+		}
+		sizes := res.stats.recentSizes()
+		res.stats.Unlock()
+
+		if l := len(sizes); l > 0 {
+			sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+			s.P50Size = int(sizes[(l-1)*50/100])
+			s.P95Size = int(sizes[(l-1)*95/100])
+			s.MaxSize = int(sizes[l-1])
+		}
+
+		all = append(all, s)
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].Address < all[j].Address })
+	return all
 }
 
 // SetThresholdOptions updates the settings used for discontinuing use of a resolver due to poor performance.
@@ -72,6 +151,7 @@ func (r *Resolvers) thresholdChecks() {
 		case <-r.done:
 			return
 		case <-t.C:
+			r.throttleChecks()
 			r.shutdownIfThresholdViolated()
 		}
 	}
@@ -96,7 +176,7 @@ func (r *Resolvers) shutdownIfThresholdViolated() {
 			stop = true
 		}
 		if stop {
-			res.stop()
+			r.disqualify(res, RemovedThreshold)
 		}
 	}
 }
@@ -131,6 +211,46 @@ func (r *resolver) cumulativeThresholdReached(tv uint64) bool {
 	return total >= tv
 }
 
+func (r *resolver) collectQuery() {
+	r.stats.Lock()
+	defer r.stats.Unlock()
+
+	r.stats.Queries++
+}
+
+func (r *resolver) collectRTT(rtt time.Duration) {
+	r.stats.Lock()
+	defer r.stats.Unlock()
+
+	r.stats.Responses++
+	r.stats.TotalRTT += rtt
+}
+
+func (r *resolver) collectSize(size int, resp *dns.Msg) {
+	if size <= 0 {
+		size = resp.Len()
+	}
+
+	r.stats.Lock()
+	defer r.stats.Unlock()
+
+	if resp.Truncated {
+		r.stats.Truncated++
+	}
+	r.stats.TotalSize += uint64(size)
+	r.stats.Sizes[r.stats.SizeIndex%sizeSamples] = uint16(min(size, dns.MaxMsgSize))
+	r.stats.SizeIndex++
+}
+
+// Returns a copy of the response sizes currently kept in the samples.
+func (s *stats) recentSizes() []uint16 {
+	n := min(s.SizeIndex, sizeSamples)
+	sizes := make([]uint16, n)
+
+	copy(sizes, s.Sizes[:n])
+	return sizes
+}
+
 func (r *resolver) collectStats(resp *dns.Msg) {
 	if resp == nil {
 		return
@@ -139,6 +259,10 @@ func (r *resolver) collectStats(resp *dns.Msg) {
 	r.stats.Lock()
 	defer r.stats.Unlock()
 
+	if resp.Rcode == dns.RcodeSuccess || resp.Rcode == dns.RcodeNameError {
+		r.stats.Successes++
+	}
+
 	switch resp.Rcode {
 	case RcodeNoResponse:
 		r.stats.Timeouts++
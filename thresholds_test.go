@@ -35,6 +35,10 @@ func TestThresholdContinuousShutdown(t *testing.T) {
 	r := NewResolvers()
 	_ = r.AddResolvers(10, "8.8.8.8")
 	defer r.Stop()
+	// The threshold checks run once the pool has been started
+	if err := r.Start(); err != nil {
+		t.Fatalf("unable to start the resolver pool: %v", err)
+	}
 
 	res := r.pool.GetResolver()
 	time.Sleep(thresholdCheckInterval + time.Second)
@@ -72,6 +76,10 @@ func TestThresholdCumulativeShutdown(t *testing.T) {
 	r := NewResolvers()
 	_ = r.AddResolvers(10, "8.8.8.8")
 	defer r.Stop()
+	// The threshold checks run once the pool has been started
+	if err := r.Start(); err != nil {
+		t.Fatalf("unable to start the resolver pool: %v", err)
+	}
 
 	r.SetThresholdOptions(&ThresholdOptions{
 		ThresholdValue:         100,
@@ -171,6 +179,73 @@ func TestCollectStats(t *testing.T) {
 	res.stats.Unlock()
 }
 
+func TestResolverStats(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, statsHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	for _, name := range []string{"legit.caffix.net", "legit.caffix.net", "legit.caffix.net", "refused.caffix.net"} {
+		_, _ = r.QueryBlocking(context.Background(), QueryMsg(name, 1))
+	}
+
+	all := r.ResolverStats()
+	if len(all) != 1 {
+		t.Fatalf("expected statistics for 1 resolver, got %d", len(all))
+	}
+	if s := all[0]; s.Address != addrstr || s.Queries != 4 || s.Successes != 3 || s.AvgRTT <= 0 {
+		t.Errorf("the resolver statistics were not collected: %+v", s)
+	}
+	if rate := all[0].SuccessRate(); rate != 75 {
+		t.Errorf("Got: %.2f; Expected: %.2f", rate, 75.0)
+	}
+	if s := all[0]; s.Responses != 4 || s.AvgSize <= 0 || s.P95Size < s.P50Size || s.MaxSize < s.P95Size {
+		t.Errorf("the response size statistics were not collected: %+v", s)
+	}
+}
+
+func TestResponseSizeStats(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+	_ = r.AddResolvers(10, "192.168.1.1")
+
+	res := r.pool.AllResolvers()[0]
+	msg := QueryMsg("caffix.net", dns.TypeA)
+	for i := 1; i <= 100; i++ {
+		msg.Truncated = i%4 == 0
+		res.collectRTT(time.Millisecond)
+		res.collectSize(i*10, msg)
+	}
+
+	s := r.ResolverStats()[0]
+	if s.Responses != 100 || s.Truncated != 25 || s.TruncationRate() != 25 {
+		t.Errorf("the truncation statistics were not collected: %+v", s)
+	}
+	if s.AvgSize != 505 || s.P50Size != 500 || s.P95Size != 950 || s.MaxSize != 1000 {
+		t.Errorf("unexpected response size statistics: %+v", s)
+	}
+
+	// Only the most recent sizes are used for the percentiles
+	for i := 0; i < sizeSamples; i++ {
+		res.collectSize(100, msg)
+	}
+	if s := r.ResolverStats()[0]; s.P95Size != 100 || s.MaxSize != 100 {
+		t.Errorf("the older response sizes were used for the percentiles: %+v", s)
+	}
+	if s := (&ResolverStats{}); s.TruncationRate() != 0 {
+		t.Errorf("a truncation rate was reported without any responses")
+	}
+}
+
 func statsHandler(w dns.ResponseWriter, req *dns.Msg) {
 	m := new(dns.Msg)
 	m.SetReply(req)
@@ -0,0 +1,104 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import "time"
+
+// The reasons reported in a ThrottleEvent.
+const (
+	ThrottleTimeouts   string = "timeout burst"
+	ThrottleRefusals   string = "REFUSED spike"
+	ThrottleTruncation string = "truncation storm"
+)
+
+const (
+	// The minimum occurrences within a check interval before throttling is reported.
+	minThrottleOccurrences uint64 = 10
+	// The fraction of the queries or responses within a check interval indicating throttling.
+	throttleRatio float64 = 0.5
+)
+
+// ThrottleEvent describes a pattern indicating that a resolver is rate limiting the queries.
+// Count is the number of occurrences and Total the number of queries or responses observed
+// during the interval ending at the time provided.
+type ThrottleEvent struct {
+	Server string
+	Reason string
+	Count  uint64
+	Total  uint64
+	At     time.Time
+}
+
+// ThrottleHook is called for each ThrottleEvent detected on the resolvers in the pool.
+type ThrottleHook func(ev *ThrottleEvent)
+
+// throttleCounts is the snapshot of the statistics taken at the previous check interval.
+type throttleCounts struct {
+	Queries   uint64
+	Responses uint64
+	Timeouts  uint64
+	Refusals  uint64
+	Truncated uint64
+}
+
+// SetThrottleHook assigns the hook called when a resolver shows sudden bursts of timeouts,
+// spikes of REFUSED responses or storms of truncated responses, so the query rate can be
+// reduced. The resolvers are checked periodically and a nil hook disables the checks.
+func (r *Resolvers) SetThrottleHook(hook ThrottleHook) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.throttle = hook
+}
+
+func (r *Resolvers) throttleChecks() {
+	r.Lock()
+	hook := r.throttle
+	r.Unlock()
+
+	if hook == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, res := range r.pool.AllResolvers() {
+		for _, ev := range res.throttleEvents() {
+			ev.At = now
+			hook(ev)
+		}
+	}
+}
+
+// Returns the events detected since the previous check and takes a new snapshot of the statistics.
+func (r *resolver) throttleEvents() []*ThrottleEvent {
+	r.stats.Lock()
+	prev := r.stats.Throttle
+	cur := throttleCounts{
+		Queries:   r.stats.Queries,
+		Responses: r.stats.Responses,
+		Timeouts:  r.stats.Timeouts,
+		Refusals:  r.stats.QueryRefusals,
+		Truncated: r.stats.Truncated,
+	}
+	r.stats.Throttle = cur
+	r.stats.Unlock()
+
+	var events []*ThrottleEvent
+	check := func(reason string, count, total uint64) {
+		if count >= minThrottleOccurrences && float64(count) >= float64(total)*throttleRatio {
+			events = append(events, &ThrottleEvent{
+				Server: r.address.String(),
+				Reason: reason,
+				Count:  count,
+				Total:  total,
+			})
+		}
+	}
+
+	check(ThrottleTimeouts, cur.Timeouts-prev.Timeouts, cur.Queries-prev.Queries)
+	check(ThrottleRefusals, cur.Refusals-prev.Refusals, cur.Responses-prev.Responses)
+	check(ThrottleTruncation, cur.Truncated-prev.Truncated, cur.Responses-prev.Responses)
+	return events
+}
@@ -0,0 +1,80 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestThrottleEvents(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+	_ = r.AddResolvers(10, "192.168.1.1")
+	res := r.pool.AllResolvers()[0]
+
+	var events []*ThrottleEvent
+	r.SetThrottleHook(func(ev *ThrottleEvent) { events = append(events, ev) })
+
+	// A healthy interval does not produce events
+	msg := QueryMsg("caffix.net", dns.TypeA)
+	for i := 0; i < 100; i++ {
+		res.collectQuery()
+		res.collectRTT(0)
+		res.collectSize(100, msg)
+		res.collectStats(msg)
+	}
+	r.throttleChecks()
+	if len(events) != 0 {
+		t.Fatalf("events were produced for a healthy interval: %v", events)
+	}
+
+	// The resolver begins to time out and refuse the queries
+	refused := new(dns.Msg).SetRcode(msg, dns.RcodeRefused)
+	timeout := new(dns.Msg).SetRcode(msg, RcodeNoResponse)
+	for i := 0; i < 20; i++ {
+		res.collectQuery()
+		res.collectStats(timeout)
+		res.collectQuery()
+		res.collectRTT(0)
+		res.collectSize(100, refused)
+		res.collectStats(refused)
+	}
+	r.throttleChecks()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if ev := events[0]; ev.Reason != ThrottleTimeouts || ev.Count != 20 || ev.Total != 40 || ev.Server != "192.168.1.1:53" || ev.At.IsZero() {
+		t.Errorf("unexpected timeout event: %+v", ev)
+	}
+	if ev := events[1]; ev.Reason != ThrottleRefusals || ev.Count != 20 || ev.Total != 20 {
+		t.Errorf("unexpected refusal event: %+v", ev)
+	}
+
+	// Truncated responses are reported, and the previous interval is not reported again
+	events = nil
+	msg.Truncated = true
+	for i := 0; i < 10; i++ {
+		res.collectQuery()
+		res.collectRTT(0)
+		res.collectSize(100, msg)
+	}
+	r.throttleChecks()
+	if len(events) != 1 || events[0].Reason != ThrottleTruncation || events[0].Count != 10 {
+		t.Errorf("the truncation storm was not reported: %v", events)
+	}
+
+	r.SetThrottleHook(nil)
+	events = nil
+	for i := 0; i < 10; i++ {
+		res.collectQuery()
+		res.collectStats(timeout)
+	}
+	r.throttleChecks()
+	if len(events) != 0 {
+		t.Errorf("events were produced without a hook")
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !notls
+
+package resolve
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+)
+
+// AddTLSResolvers adds the DNS-over-TLS (RFC 7858) resolvers to the pool, which receive queries
+// on persistent connections instead of UDP. The addresses default to port 853, and hostnames are
+// used to verify the certificates when the config does not provide a ServerName. A nil config
+// verifies the certificates using the system roots.
+func (r *Resolvers) AddTLSResolvers(qps int, config *tls.Config, addrs ...string) error {
+	if qps == 0 {
+		return errors.New("failed to provide a maximum number of queries per second greater than zero")
+	}
+	if r.cloned {
+		return errors.New("resolvers cannot be added to a pool created by Clone")
+	}
+
+	for _, addr := range addrs {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			host, port = addr, "853"
+			addr = net.JoinHostPort(host, port)
+		}
+
+		cfg := config
+		if cfg == nil {
+			cfg = new(tls.Config)
+		}
+		if cfg.ServerName == "" && net.ParseIP(host) == nil {
+			cfg = cfg.Clone()
+			cfg.ServerName = host
+		}
+		r.addResolvers(qps, func(res *resolver) {
+			res.proto = TransportTLS
+			res.stream = newStreamPool("tcp-tls", addrKey(res.address), cfg)
+		}, r.expandHostnames([]string{addr})...)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !notls
+
+package resolve
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Returns a self-signed certificate for 127.0.0.1 and the pool trusting it.
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate the key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "resolve test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create the certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse the certificate: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, roots
+}
+
+func TestAddTLSResolvers(t *testing.T) {
+	dns.HandleFunc("tls.net.", typeAHandler)
+	defer dns.HandleRemove("tls.net.")
+
+	cert, roots := testCertificate(t)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("unable to listen for TLS: %v", err)
+	}
+
+	s, addrstr, _, err := RunLocalServer(nil, l)
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	defer r.Stop()
+
+	if err := r.AddTLSResolvers(10, &tls.Config{RootCAs: roots}, addrstr); err != nil {
+		t.Fatalf("failed to add the TLS resolver: %v", err)
+	}
+	if r.Len() != 1 {
+		t.Fatalf("expected one resolver in the pool, got %d", r.Len())
+	}
+
+	for i := 0; i < 5; i++ {
+		resp, err := r.QueryBlocking(context.Background(), QueryMsg("tls.net", dns.TypeA))
+		if err != nil || resp.Rcode != dns.RcodeSuccess {
+			t.Fatalf("the query over TLS failed: %v", err)
+		}
+		if ans := ExtractAnswers(resp); len(ans) != 1 || ans[0].Data != "192.168.1.1" {
+			t.Errorf("the response did not contain the expected answer")
+		}
+		if src := SourceOf(resp); src == nil || src.Transport != TransportTLS || src.Server != addrstr {
+			t.Errorf("the response was not reported as received over TLS: %+v", src)
+		}
+	}
+
+	// The connections are kept open between the queries
+	res := r.pool.LookupResolver(addrstr)
+	if res == nil || res.stream == nil {
+		t.Fatalf("the TLS resolver was not found")
+	}
+	sp := res.stream.(*streamPool)
+	sp.Lock()
+	idle := len(sp.idle)
+	sp.Unlock()
+	if idle != 1 {
+		t.Errorf("expected one idle connection, got %d", idle)
+	}
+}
+
+func TestAddTLSResolversUntrusted(t *testing.T) {
+	dns.HandleFunc("tls.net.", typeAHandler)
+	defer dns.HandleRemove("tls.net.")
+
+	cert, _ := testCertificate(t)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("unable to listen for TLS: %v", err)
+	}
+
+	s, addrstr, _, err := RunLocalServer(nil, l)
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	defer r.Stop()
+
+	_ = r.AddTLSResolvers(10, nil, addrstr)
+	r.SetRetries(0)
+	if resp, _ := r.QueryBlocking(context.Background(), QueryMsg("tls.net", dns.TypeA)); resp == nil || resp.Rcode != RcodeNoResponse {
+		t.Errorf("a response was accepted from a server with an untrusted certificate")
+	}
+}
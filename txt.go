@@ -0,0 +1,106 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// JoinTXT returns the content of the TXT record with the character strings concatenated without
+// a separator, reassembling the SPF, DKIM and DMARC records split across multiple strings.
+func JoinTXT(txt *dns.TXT) string {
+	return strings.Join(txt.Txt, "")
+}
+
+// TXTRecords returns the reassembled content of each TXT record found in the answer section of
+// the provided message.
+func TXTRecords(msg *dns.Msg) []string {
+	var records []string
+
+	for _, txt := range Answers[*dns.TXT](msg) {
+		records = append(records, JoinTXT(txt))
+	}
+	return records
+}
+
+// SPFTerm is a mechanism or modifier found in an SPF record (RFC 7208). The qualifier of a
+// mechanism defaults to "+", and the value holds the domain specification, the network or the
+// CIDR lengths following the name. Modifiers do not have a qualifier.
+type SPFTerm struct {
+	Qualifier string
+	Name      string
+	Value     string
+	Modifier  bool
+}
+
+// ParseSPF returns the terms of the SPF record in the provided text, or an error when the
+// text is not an SPF record. The names of the terms are converted to lowercase.
+func ParseSPF(txt string) ([]*SPFTerm, error) {
+	fields := strings.Fields(txt)
+	if len(fields) == 0 || !strings.EqualFold(fields[0], "v=spf1") {
+		return nil, errors.New("ParseSPF: the text is not an SPF record")
+	}
+
+	var terms []*SPFTerm
+	for _, field := range fields[1:] {
+		// Modifiers have a name followed by the equal sign
+		if i := strings.IndexAny(field, "=:/"); i > 0 && field[i] == '=' {
+			terms = append(terms, &SPFTerm{
+				Name:     strings.ToLower(field[:i]),
+				Value:    field[i+1:],
+				Modifier: true,
+			})
+			continue
+		}
+
+		term := &SPFTerm{Qualifier: "+"}
+		if strings.ContainsAny(field[:1], "+-~?") {
+			term.Qualifier = field[:1]
+			field = field[1:]
+		}
+
+		term.Name = field
+		if i := strings.IndexAny(field, ":/"); i >= 0 {
+			term.Name = field[:i]
+			term.Value = strings.TrimPrefix(field[i:], ":")
+		}
+		term.Name = strings.ToLower(term.Name)
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+// TagValues returns the tags and values of a tag-value list (RFC 6376, section 3.2), the format
+// used by DKIM key and DMARC records. The tag names are converted to lowercase and whitespace
+// is removed from the values.
+func TagValues(txt string) map[string]string {
+	tags := make(map[string]string)
+
+	for _, spec := range strings.Split(txt, ";") {
+		tag, value, found := strings.Cut(spec, "=")
+		if tag = strings.ToLower(strings.TrimSpace(tag)); !found || tag == "" {
+			continue
+		}
+		tags[tag] = strings.Join(strings.Fields(value), "")
+	}
+	return tags
+}
+
+// DecodeDKIMKey returns the public key data decoded from the base64 "p" tag of a DKIM key
+// record. An empty key indicates that the key has been revoked.
+func DecodeDKIMKey(txt string) ([]byte, error) {
+	p, found := TagValues(txt)["p"]
+	if !found {
+		return nil, errors.New("DecodeDKIMKey: the record does not have a public key tag")
+	}
+	if p == "" {
+		return nil, errors.New("DecodeDKIMKey: the public key has been revoked")
+	}
+	return base64.StdEncoding.DecodeString(p)
+}
@@ -0,0 +1,82 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestTXTRecords(t *testing.T) {
+	msg := QueryMsg("caffix.net", dns.TypeTXT)
+	for _, s := range []string{
+		`caffix.net. 300 IN TXT "v=spf1 include:_spf.caf" "fix.net -all"`,
+		`caffix.net. 300 IN TXT "google-site-verification=abcdef"`,
+	} {
+		if rr, err := dns.NewRR(s); err == nil {
+			msg.Answer = append(msg.Answer, rr)
+		}
+	}
+
+	expected := []string{"v=spf1 include:_spf.caffix.net -all", "google-site-verification=abcdef"}
+	if got := TXTRecords(msg); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Got: %v; Expected: %v", got, expected)
+	}
+	if got := TXTRecords(nil); len(got) != 0 {
+		t.Errorf("records were returned for a nil message")
+	}
+}
+
+func TestParseSPF(t *testing.T) {
+	terms, err := ParseSPF("v=spf1 A mx/24 -ip4:192.168.1.0/24 ~include:_spf.caffix.net a:web.caffix.net/24//64 Redirect=_spf.owasp.org ?all")
+	if err != nil {
+		t.Fatalf("failed to parse the SPF record: %v", err)
+	}
+
+	expected := []*SPFTerm{
+		{Qualifier: "+", Name: "a"},
+		{Qualifier: "+", Name: "mx", Value: "/24"},
+		{Qualifier: "-", Name: "ip4", Value: "192.168.1.0/24"},
+		{Qualifier: "~", Name: "include", Value: "_spf.caffix.net"},
+		{Qualifier: "+", Name: "a", Value: "web.caffix.net/24//64"},
+		{Name: "redirect", Value: "_spf.owasp.org", Modifier: true},
+		{Qualifier: "?", Name: "all"},
+	}
+	if !reflect.DeepEqual(terms, expected) {
+		for i, term := range terms {
+			t.Errorf("%d: %+v", i, term)
+		}
+	}
+
+	for _, txt := range []string{"", "v=spf10 -all", "include:caffix.net"} {
+		if _, err := ParseSPF(txt); err == nil {
+			t.Errorf("%q was parsed as an SPF record", txt)
+		}
+	}
+}
+
+func TestTagValues(t *testing.T) {
+	tags := TagValues("v=DMARC1; P=reject ; rua=mailto:dmarc@caffix.net;; invalid; =empty")
+
+	expected := map[string]string{"v": "DMARC1", "p": "reject", "rua": "mailto:dmarc@caffix.net"}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("Got: %v; Expected: %v", tags, expected)
+	}
+}
+
+func TestDecodeDKIMKey(t *testing.T) {
+	key, err := DecodeDKIMKey("v=DKIM1; k=rsa; p=aGVsbG8g d29ybGQ=")
+	if err != nil || string(key) != "hello world" {
+		t.Errorf("failed to decode the key: %q %v", key, err)
+	}
+
+	for _, txt := range []string{"v=DKIM1; k=rsa; p=", "v=DKIM1; k=rsa", "v=DKIM1; p=!invalid!"} {
+		if _, err := DecodeDKIMKey(txt); err == nil {
+			t.Errorf("a key was decoded from %q", txt)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+)
+
+// The context key marking the queries sent while verifying an NXDOMAIN response.
+type verifyingKey struct{}
+
+// nxVerifier tracks the NXDOMAIN responses checked against the authoritative nameservers.
+type nxVerifier struct {
+	enabled    atomic.Bool
+	overturned atomic.Uint64
+}
+
+// SetNXDomainVerification causes each NXDOMAIN response from the resolver pool to be checked
+// against the authoritative nameservers of the zone before being returned. When a nameserver
+// reports that the name exists, its response is returned instead, catching resolvers that
+// filter or block names.
+func (r *Resolvers) SetNXDomainVerification(enabled bool) {
+	r.verify.enabled.Store(enabled)
+}
+
+// OverturnedNXDomains returns the number of NXDOMAIN responses that were contradicted by the
+// authoritative nameservers of the zone.
+func (r *Resolvers) OverturnedNXDomains() uint64 {
+	return r.verify.overturned.Load()
+}
+
+// Returns true when NXDOMAIN responses for queries using the context must be verified. The
+// queries sent to find the authoritative nameservers are not verified, preventing recursion.
+func (r *Resolvers) verifyNXDomains(ctx context.Context) bool {
+	return r.verify.enabled.Load() && ctx.Value(verifyingKey{}) == nil
+}
+
+func (r *Resolvers) verifiedQuery(ctx context.Context, msg *dns.Msg, ch chan *dns.Msg) {
+	first := make(chan *dns.Msg, 1)
+	r.send(ctx, msg, first)
+
+	resp := <-first
+	if resp != nil && resp.Rcode == dns.RcodeNameError {
+		if auth := r.verifyNXDomain(ctx, resp); auth != nil {
+			resp = auth
+		}
+	}
+	ch <- resp
+}
+
+// Returns the response from an authoritative nameserver for the zone when it contradicts the
+// NXDOMAIN response, or nil when the name does not exist or the nameservers cannot be reached.
+func (r *Resolvers) verifyNXDomain(ctx context.Context, resp *dns.Msg) *dns.Msg {
+	q := resp.Question[0]
+	name := strings.ToLower(RemoveLastDot(q.Name))
+	ctx = context.WithValue(ctx, verifyingKey{}, true)
+
+	for _, res := range r.authoritativeResolvers(ctx, name) {
+		auth, err := r.authExchange(ctx, res, authMsg(name, q.Qtype))
+		if err != nil {
+			continue
+		}
+		if auth.Rcode != dns.RcodeSuccess {
+			return nil
+		}
+
+		r.verify.overturned.Add(1)
+		r.log.Printf("NXDOMAIN overturned by the authoritative nameserver %s: %s", res.address, name)
+		return auth
+	}
+	return nil
+}
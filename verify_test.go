@@ -0,0 +1,91 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestNXDomainVerification(t *testing.T) {
+	// The authoritative nameserver has a record for blocked.verify.net
+	authMux := dns.NewServeMux()
+	authMux.HandleFunc("verify.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Authoritative = true
+
+		if req.Question[0].Name == "blocked.verify.net." && req.Question[0].Qtype == dns.TypeA {
+			m.Answer = append(m.Answer, testARecord("blocked.verify.net.", "192.0.2.1"))
+		} else {
+			m.Rcode = dns.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	as, authaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = authMux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = as.Shutdown() }()
+	_, authport, _ := net.SplitHostPort(authaddr)
+
+	// The recursive resolver filters every name other than the nameserver
+	recMux := dns.NewServeMux()
+	recMux.HandleFunc("verify.net.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+
+		switch name := req.Question[0].Name; {
+		case name == "verify.net." && req.Question[0].Qtype == dns.TypeNS:
+			m.Answer = append(m.Answer, &dns.NS{
+				Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeNS, Class: dns.ClassINET},
+				Ns:  "ns1.verify.net.",
+			})
+		case name == "ns1.verify.net." && req.Question[0].Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, testARecord(name, "127.0.0.1"))
+		case name == "verify.net.":
+		default:
+			m.Rcode = dns.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	rs, recaddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = recMux })
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = rs.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, recaddr)
+	defer r.Stop()
+	r.auth.port = authport
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("blocked.verify.net", dns.TypeA))
+	if err != nil || resp.Rcode != dns.RcodeNameError {
+		t.Fatalf("the recursive resolver did not filter the name: %v", err)
+	}
+
+	r.SetNXDomainVerification(true)
+	resp, err = r.QueryBlocking(context.Background(), QueryMsg("blocked.verify.net", dns.TypeA))
+	if err != nil || resp.Rcode != dns.RcodeSuccess || len(IPs(resp)) != 1 {
+		t.Errorf("the NXDOMAIN response was not overturned: %v", resp)
+	}
+	if n := r.OverturnedNXDomains(); n != 1 {
+		t.Errorf("expected 1 overturned NXDOMAIN response, got %d", n)
+	}
+
+	resp, err = r.QueryBlocking(context.Background(), QueryMsg("missing.verify.net", dns.TypeA))
+	if err != nil || resp.Rcode != dns.RcodeNameError {
+		t.Errorf("the NXDOMAIN response confirmed by the nameserver was not returned: %v", resp)
+	}
+	if n := r.OverturnedNXDomains(); n != 1 {
+		t.Errorf("expected 1 overturned NXDOMAIN response, got %d", n)
+	}
+}
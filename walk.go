@@ -8,10 +8,28 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/miekg/dns"
+	"go.uber.org/ratelimit"
 )
 
+// DefaultWalkSegments is the number of segments walked in parallel by NsecTraversal.
+const DefaultWalkSegments int = 4
+
+// The characters used to select starting points across the NSEC chain.
+const walkSeedChars = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+type nsecWalk struct {
+	sync.Mutex
+	pool  *Resolvers
+	apex  string
+	rate  ratelimit.Limiter
+	out   chan *dns.NSEC
+	found map[string]struct{}
+}
+
 // NsecTraversal attempts to retrieve a DNS zone using NSEC-walking.
 func (r *Resolvers) NsecTraversal(ctx context.Context, domain string) ([]*dns.NSEC, error) {
 	select {
@@ -22,48 +40,117 @@ func (r *Resolvers) NsecTraversal(ctx context.Context, domain string) ([]*dns.NS
 	default:
 	}
 
-	found := true
-	var err error
-	domain = domain + "."
 	var results []*dns.NSEC
-	names := make(map[string]struct{})
-	for next := domain; found; {
-		found = false
-		var nsec *dns.NSEC
-
-		nsec, err = r.searchGap(ctx, next)
-		if err == nil {
-			if _, yes := names[nsec.NextDomain]; yes {
-				break
-			}
-			names[nsec.NextDomain] = struct{}{}
-
-			found = true
-			next = nsec.NextDomain
-			results = append(results, nsec)
+	for nsec := range r.NsecWalk(ctx, domain, DefaultWalkSegments, 0) {
+		results = append(results, nsec)
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("NsecTraversal: %s NSEC record not found", domain)
+	}
+	return results, nil
+}
+
+// NsecWalk performs NSEC-walking of the provided domain by starting the number of segments
+// specified at different points in the NSEC chain and walking them in parallel across the
+// resolver pool. Each NSEC record is sent on the returned channel as it is discovered, and
+// the channel is closed once the walk is complete. A qps greater than zero limits the rate
+// of queries sent by the walk, independent of the pool rate limits.
+func (r *Resolvers) NsecWalk(ctx context.Context, domain string, segments, qps int) <-chan *dns.NSEC {
+	if segments <= 0 {
+		segments = 1
+	}
+
+	w := &nsecWalk{
+		pool:  r,
+		apex:  strings.ToLower(dns.Fqdn(domain)),
+		out:   make(chan *dns.NSEC, segments),
+		found: make(map[string]struct{}),
+	}
+	if qps > 0 {
+		w.rate = ratelimit.New(qps)
+	}
+
+	var wg sync.WaitGroup
+	for _, seed := range walkSeeds(w.apex, segments) {
+		wg.Add(1)
+		go func(start string) {
+			defer wg.Done()
+			w.segment(ctx, start)
+		}(seed)
+	}
+
+	go func() {
+		wg.Wait()
+		close(w.out)
+	}()
+	return w.out
+}
+
+func walkSeeds(apex string, segments int) []string {
+	seeds := []string{apex}
+
+	step := len(walkSeedChars) / segments
+	for i := 1; i < segments && i*step < len(walkSeedChars); i++ {
+		seeds = append(seeds, string(walkSeedChars[i*step])+"."+apex)
+	}
+	return seeds
+}
+
+func (w *nsecWalk) segment(ctx context.Context, start string) {
+	for next := start; ; {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.pool.done:
+			return
+		default:
 		}
-		if next == domain {
-			break
+
+		if w.rate != nil {
+			_ = w.rate.Take()
 		}
+
+		nsec, err := w.pool.searchGap(ctx, next)
+		if err != nil {
+			return
+		}
+		// Stop once this segment reaches a portion of the chain already walked
+		if !w.discovered(nsec.Hdr.Name) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case w.out <- nsec:
+		}
+
+		if next = strings.ToLower(nsec.NextDomain); next == w.apex {
+			return
+		}
+	}
+}
+
+// Returns true when the owner name had not already been discovered by the walk.
+func (w *nsecWalk) discovered(owner string) bool {
+	w.Lock()
+	defer w.Unlock()
+
+	owner = strings.ToLower(owner)
+	if _, found := w.found[owner]; found {
+		return false
 	}
-	return results, err
+	w.found[owner] = struct{}{}
+	return true
 }
 
 func (r *Resolvers) searchGap(ctx context.Context, name string) (*dns.NSEC, error) {
-	for i := 0; i < maxQueryAttempts; i++ {
-		resp, err := r.QueryBlocking(ctx, WalkMsg(name, dns.TypeNSEC))
-		if err != nil || resp.Rcode == dns.RcodeNameError {
-			break
-		}
-		if resp.Rcode == dns.RcodeSuccess {
-			if len(resp.Answer) == 0 {
-				break
-			}
-			for _, rr := range append(resp.Answer, resp.Ns...) {
-				if nsec, ok := rr.(*dns.NSEC); ok {
-					return nsec, nil
-				}
-			}
+	resp, err := r.QueryWithRetries(ctx, WalkMsg(name, dns.TypeNSEC))
+	// Names that do not exist are covered by a NSEC record in the authority section
+	if err == nil && (resp.Rcode == dns.RcodeSuccess || resp.Rcode == dns.RcodeNameError) {
+		if nsecs := Records[*dns.NSEC](append(resp.Answer, resp.Ns...)); len(nsecs) > 0 {
+			return nsecs[0], nil
 		}
 	}
 	return nil, fmt.Errorf("NsecTraversal: %s NSEC record not found", name)
@@ -6,9 +6,9 @@ package resolve
 
 import (
 	"context"
+	"strings"
 	"testing"
 
-	"github.com/caffix/stringset"
 	"github.com/miekg/dns"
 )
 
@@ -124,19 +124,19 @@ func TestNsecTraversal(t *testing.T) {
 		t.Errorf("The NSEC traversal was not successful: %v", err)
 	}
 
-	set := stringset.New()
-	defer set.Close()
-
+	set := make(map[string]struct{})
 	for _, name := range names {
-		set.Insert(name.NextDomain)
+		set[name.NextDomain] = struct{}{}
 	}
 
-	nsecSet := stringset.New(nsecLinkedList...)
-	defer nsecSet.Close()
-
-	nsecSet.Subtract(set)
-	if nsecSet.Len() != 0 {
-		t.Errorf("The NSEC traversal found %d names and failed to discover the following names: %v", set.Len(), nsecSet.Slice())
+	var missing []string
+	for _, name := range nsecLinkedList {
+		if _, found := set[name]; !found {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) != 0 {
+		t.Errorf("The NSEC traversal found %d names and failed to discover the following names: %v", len(set), missing)
 	}
 
 	cancel()
@@ -215,3 +215,46 @@ func walkHandler(w dns.ResponseWriter, req *dns.Msg) {
 	}
 	_ = w.WriteMsg(m)
 }
+
+func TestNsecWalk(t *testing.T) {
+	name := "walk.com."
+	dns.HandleFunc(name, walkHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("Unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	defer r.Stop()
+	_ = r.AddResolvers(100, addrstr)
+
+	owners := make(map[string]struct{})
+	var count int
+	for nsec := range r.NsecWalk(context.Background(), "walk.com", 8, 500) {
+		count++
+		owners[nsec.Hdr.Name] = struct{}{}
+	}
+
+	if count != len(nsecLinkedList) {
+		t.Errorf("The NSEC walk streamed %d records and expected %d", count, len(nsecLinkedList))
+	}
+	if len(owners) != count {
+		t.Errorf("The NSEC walk streamed duplicate records")
+	}
+}
+
+func TestWalkSeeds(t *testing.T) {
+	seeds := walkSeeds("walk.com.", 4)
+
+	if len(seeds) != 4 || seeds[0] != "walk.com." {
+		t.Errorf("Unexpected seeds returned: %v", seeds)
+	}
+	for _, seed := range seeds[1:] {
+		if !strings.HasSuffix(seed, ".walk.com.") {
+			t.Errorf("The seed %s was not within the domain", seed)
+		}
+	}
+}
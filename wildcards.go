@@ -6,13 +6,13 @@ package resolve
 
 import (
 	"context"
-	"math/rand"
 	"net"
+	"runtime"
 	"strings"
 	"sync"
 
-	"github.com/caffix/stringset"
 	"github.com/miekg/dns"
+	"go.uber.org/ratelimit"
 )
 
 // Constants related to DNS labels.
@@ -35,10 +35,15 @@ var wildcardQueryTypes = []uint16{
 	dns.TypeAAAA,
 }
 
+// wildcard contains the results of the detection for a subdomain. The once ensures the
+// detection is performed a single time, and that concurrent callers wait for the results.
+// The results are incomplete when the context of the caller performing the detection
+// expired, and the entry is then removed so the next caller performs the detection again.
 type wildcard struct {
-	sync.Mutex
-	Detected bool
-	Answers  []*ExtractedAnswer
+	once       sync.Once
+	incomplete bool
+	Detected   bool
+	Answers    []*ExtractedAnswer
 }
 
 // UnlikelyName takes a subdomain name and returns an unlikely DNS name within that subdomain.
@@ -54,14 +59,14 @@ func UnlikelyName(sub string) string {
 		l = MinLabelLen
 	}
 	// Shuffle our LDH characters
-	rand.Shuffle(ldhLen, func(i, j int) {
+	prng.Shuffle(ldhLen, func(i, j int) {
 		ldh[i], ldh[j] = ldh[j], ldh[i]
 	})
 
 	var newlabel string
-	l = MinLabelLen + rand.Intn((l-MinLabelLen)+1)
+	l = MinLabelLen + prng.Intn((l-MinLabelLen)+1)
 	for i := 0; i < l; i++ {
-		sel := rand.Int() % (ldhLen - 1)
+		sel := prng.Int() % (ldhLen - 1)
 		newlabel = newlabel + string(ldh[sel])
 	}
 
@@ -73,8 +78,9 @@ func UnlikelyName(sub string) string {
 }
 
 // WildcardDetected returns true when the provided DNS response could be a wildcard match.
+// It is safe for concurrent use, and each subdomain is tested for a wildcard only once.
 func (r *Resolvers) WildcardDetected(ctx context.Context, resp *dns.Msg, domain string) bool {
-	if !r.goodDetector() {
+	if ValidateMsg(resp) != nil || !r.goodDetector() || r.routes.skipWildcards(resp.Question[0].Name) {
 		return false
 	}
 
@@ -93,40 +99,157 @@ func (r *Resolvers) WildcardDetected(ctx context.Context, resp *dns.Msg, domain
 		}
 		return false
 	})
+
+	updateSource(resp, func(s *ResponseSource) {
+		s.WildcardChecked = true
+		s.Wildcard = found
+	})
+	if found {
+		r.events.publish(&Event{
+			Type: EventWildcardFiltered,
+			Name: strings.ToLower(RemoveLastDot(resp.Question[0].Name)),
+			Msg:  resp,
+		})
+	}
 	return found
 }
 
+// WildcardLevels returns the results of the DNS wildcard detection performed for the domain and
+// its subdomains, keyed by the subdomain tested. Detection still in progress is waited for.
+func (r *Resolvers) WildcardLevels(ctx context.Context, domain string) map[string]bool {
+	domain = strings.ToLower(RemoveLastDot(domain))
+
+	var subs []string
+	r.Lock()
+	for sub := range r.wildcards {
+		if sub == domain || strings.HasSuffix(sub, "."+domain) {
+			subs = append(subs, sub)
+		}
+	}
+	r.Unlock()
+
+	levels := make(map[string]bool, len(subs))
+	for _, sub := range subs {
+		levels[sub] = r.getWildcard(ctx, sub).Detected
+	}
+	return levels
+}
+
 // SetDetectionResolver sets the provided DNS resolver as responsible for wildcard detection.
 func (r *Resolvers) SetDetectionResolver(qps int, addr string) {
+	r.SetDetectionResolvers(qps, 1, addr)
+}
+
+// SetDetectionResolvers sets the provided DNS resolvers as responsible for wildcard detection.
+// A DNS wildcard is only declared when the number of resolvers specified by quorum agree.
+// A quorum of zero requires agreement from the majority of the detection resolvers.
+func (r *Resolvers) SetDetectionResolvers(qps, quorum int, addrs ...string) {
 	r.Lock()
 	defer r.Unlock()
 
+	var detectors []*resolver
+	for _, addr := range addrs {
+		if res := r.detectionResolver(qps, addr); res != nil {
+			detectors = append(detectors, res)
+		}
+	}
+
+	if len(detectors) > 0 {
+		r.detectors = detectors
+		r.quorum = quorum
+	}
+}
+
+func (r *Resolvers) detectionResolver(qps int, addr string) *resolver {
 	if _, _, err := net.SplitHostPort(addr); err != nil {
 		// add the default port number to the IP address
 		addr = net.JoinHostPort(addr, "53")
 	}
 	// check that this address will not create a duplicate resolver
 	if uaddr, err := net.ResolveUDPAddr("udp", addr); err == nil {
-		if _, found := r.rmap[uaddr.IP.String()]; found {
-			r.detector = r.pool.LookupResolver(uaddr.IP.String())
-			return
+		if _, found := r.rmap[addrKey(uaddr)]; found {
+			return r.pool.LookupResolver(addrKey(uaddr))
 		}
 		if res := r.initializeResolver(qps, addr); res != nil {
-			r.rmap[res.address.IP.String()] = struct{}{}
+			r.rmap[addrKey(res.address)] = struct{}{}
 			r.pool.AddResolver(res)
-			r.detector = res
+			return res
 		}
 	}
+	return nil
+}
+
+// SetDetectionOptions provides the wildcard detection probes a rate limit independent of the
+// pool, and optionally dedicated sockets, so detection latency is not impacted by the load of
+// the main scan. A qps of zero removes the rate limit for the detection probes.
+func (r *Resolvers) SetDetectionOptions(qps int, dedicatedSockets bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.detRate = nil
+	if qps > 0 {
+		r.detRate = ratelimit.New(qps)
+	}
+
+	if dedicatedSockets && r.detConns == nil {
+		r.detConns = newConnections(runtime.NumCPU(), r.resps)
+	} else if !dedicatedSockets && r.detConns != nil {
+		r.detConns.Close()
+		r.detConns = nil
+	}
+}
+
+func (r *Resolvers) detectionRate() ratelimit.Limiter {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.detRate
+}
+
+func (r *Resolvers) detectionConns() *connections {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.detConns
 }
 
 func (r *Resolvers) getDetectionResolver() *resolver {
 	r.Lock()
 	defer r.Unlock()
 
-	return r.detector
+	if len(r.detectors) == 0 {
+		return nil
+	}
+	return r.detectors[0]
+}
+
+func (r *Resolvers) getDetectionResolvers() []*resolver {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.detectors
+}
+
+// Returns the number of detection resolvers that must agree for a DNS wildcard to be declared.
+func (r *Resolvers) detectionQuorum(n int) int {
+	r.Lock()
+	q := r.quorum
+	r.Unlock()
+
+	if q <= 0 {
+		q = (n / 2) + 1
+	}
+	if q > n {
+		q = n
+	}
+	return q
 }
 
 func (r *Resolvers) goodDetector() bool {
+	if r.authDetection() {
+		return true
+	}
+
 	success := true
 
 	if d := r.getDetectionResolver(); d == nil {
@@ -135,7 +258,7 @@ func (r *Resolvers) goodDetector() bool {
 		if d = r.pool.GetResolver(); d != nil {
 			r.SetDetectionResolver(d.qps, d.address.String())
 
-			if r.detector != nil {
+			if r.getDetectionResolver() != nil {
 				success = true
 			}
 		}
@@ -144,50 +267,112 @@ func (r *Resolvers) goodDetector() bool {
 }
 
 func (r *Resolvers) getWildcard(ctx context.Context, sub string) *wildcard {
-	r.Lock()
-	w, found := r.wildcards[sub]
-	if !found {
-		w = &wildcard{}
-		r.wildcards[sub] = w
-	}
-	r.Unlock()
-
-	if !found {
-		w.Lock()
-		w.Detected, w.Answers = r.wildcardTest(ctx, sub)
-		w.Unlock()
+	for {
+		r.Lock()
+		w, found := r.wildcards[sub]
+		if !found {
+			w = &wildcard{}
+			r.wildcards[sub] = w
+		}
+		r.Unlock()
+
+		w.once.Do(func() {
+			w.Detected, w.Answers = r.wildcardTest(ctx, sub)
+			if ctx.Err() != nil {
+				w.incomplete = true
+				r.Lock()
+				if r.wildcards[sub] == w {
+					delete(r.wildcards, sub)
+				}
+				r.Unlock()
+			}
+		})
+		// Callers waiting on detection that was cut short perform it again
+		if !w.incomplete || ctx.Err() != nil {
+			return w
+		}
 	}
-	return w
 }
 
 func (w *wildcard) respMatchesWildcard(resp *dns.Msg) bool {
-	w.Lock()
-	defer w.Unlock()
-
 	if w.Detected {
 		if len(w.Answers) == 0 || len(resp.Answer) == 0 {
 			return w.Detected
 		}
 
-		set := stringset.New()
-		defer set.Close()
-
+		set := make(recordSet)
 		insertRecordData(set, ExtractAnswers(resp))
 		intersectRecordData(set, w.Answers)
-		if set.Len() > 0 {
+		if len(set) > 0 {
 			return w.Detected
 		}
 	}
 	return false
 }
 
+// Returns the resolvers that should receive the wildcard probes for the provided subdomain.
+func (r *Resolvers) wildcardDetectors(ctx context.Context, sub string) []*resolver {
+	if r.authDetection() {
+		if servers := r.authoritativeResolvers(ctx, sub); len(servers) > 0 {
+			return []*resolver{servers[prng.Intn(len(servers))]}
+		}
+	}
+	return r.getDetectionResolvers()
+}
+
 // Determines if the provided subdomain has a DNS wildcard.
 func (r *Resolvers) wildcardTest(ctx context.Context, sub string) (bool, []*ExtractedAnswer) {
+	detectors := r.wildcardDetectors(ctx, sub)
+	if len(detectors) == 0 {
+		return false, nil
+	}
+
+	type vote struct {
+		detected bool
+		answers  []*ExtractedAnswer
+	}
+
+	votes := make([]vote, len(detectors))
+	var wg sync.WaitGroup
+	for i, detector := range detectors {
+		wg.Add(1)
+		go func(i int, detector *resolver) {
+			defer wg.Done()
+			votes[i].detected, votes[i].answers = r.detectorTest(ctx, detector, sub)
+		}(i, detector)
+	}
+	wg.Wait()
+
+	var count int
+	var answers []*ExtractedAnswer
+	for _, v := range votes {
+		if v.detected {
+			count++
+			answers = append(answers, v.answers...)
+		}
+	}
+
+	if count == 0 || count < r.detectionQuorum(len(detectors)) {
+		return false, nil
+	}
+
+	already := make(recordSet)
+	var final []*ExtractedAnswer
+	for _, a := range answers {
+		if !already.has(a.Data) {
+			final = append(final, a)
+			already[a.Data] = struct{}{}
+		}
+	}
+	return true, final
+}
+
+// Determines if the provided detector observes a DNS wildcard for the subdomain.
+func (r *Resolvers) detectorTest(ctx context.Context, detector *resolver, sub string) (bool, []*ExtractedAnswer) {
 	var detected bool
 	var answers []*ExtractedAnswer
 
-	set := stringset.New()
-	defer set.Close()
+	set := make(recordSet)
 	// Query multiple times with unlikely names against this subdomain
 	for i := 0; i < numOfWildcardTests; i++ {
 		var name string
@@ -200,7 +385,7 @@ func (r *Resolvers) wildcardTest(ctx context.Context, sub string) (bool, []*Extr
 
 		var ans []*ExtractedAnswer
 		for _, t := range wildcardQueryTypes {
-			if a := r.makeQueryAttempts(ctx, name, t); len(a) > 0 {
+			if a := r.makeQueryAttempts(ctx, detector, name, t); len(a) > 0 {
 				detected = true
 				ans = append(ans, a...)
 			}
@@ -214,72 +399,98 @@ func (r *Resolvers) wildcardTest(ctx context.Context, sub string) (bool, []*Extr
 		answers = append(answers, ans...)
 	}
 
-	already := stringset.New()
-	defer already.Close()
-
+	already := make(recordSet)
 	var final []*ExtractedAnswer
 	// Create the slice of answers common across all the responses from unlikely name queries
 	for _, a := range answers {
 		a.Data = strings.Trim(a.Data, ".")
 
-		if set.Has(a.Data) && !already.Has(a.Data) {
+		if set.has(a.Data) && !already.has(a.Data) {
 			final = append(final, a)
-			already.Insert(a.Data)
+			already[a.Data] = struct{}{}
 		}
 	}
 	if detected {
-		r.log.Printf("DNS wildcard detected: Resolver %s: %s", r.detector.address, "*."+sub)
+		r.log.Printf("DNS wildcard detected: Resolver %s: %s", detector.address, "*."+sub)
 	}
 	return detected, final
 }
 
-func (r *Resolvers) makeQueryAttempts(ctx context.Context, name string, qtype uint16) []*ExtractedAnswer {
+func (r *Resolvers) makeQueryAttempts(ctx context.Context, detector *resolver, name string, qtype uint16) []*ExtractedAnswer {
 	ch := make(chan *dns.Msg, 1)
-	detector := r.getDetectionResolver()
-loop:
-	for i := 0; i < maxQueryAttempts; i++ {
+	// Probes sent directly to authoritative nameservers do not request recursion
+	recursion := r.lookupAuthResolver(detector.address.String()) != detector
+	tried := map[*resolver]struct{}{detector: {}}
+
+	var answers []*ExtractedAnswer
+	retryAttempts(ctx, func() bool {
+		msg := QueryMsg(name, qtype)
+		msg.RecursionDesired = recursion
+
 		req := &request{
 			Res:    detector,
-			Msg:    QueryMsg(name, qtype),
+			Msg:    msg,
 			Result: ch,
+			ctx:    ctx,
 		}
 
-		detector.writeReq(req)
+		if rate := r.detectionRate(); rate != nil {
+			_ = rate.Take()
+		}
+		if conns := r.detectionConns(); conns != nil {
+			detector.writeReqWith(req, conns)
+		} else {
+			detector.writeReq(req)
+		}
 		select {
 		case <-ctx.Done():
-			break loop
+			return true
 		case resp := <-ch:
+			if r.nonAuthoritative(detector, resp) {
+				if detector = r.alternateAuthResolver(ctx, name, tried); detector == nil {
+					return true
+				}
+				tried[detector] = struct{}{}
+				return false
+			}
 			// Check if the response indicates that the name does not exist
 			if resp.Rcode == dns.RcodeNameError {
-				break loop
+				return true
 			}
 			if resp.Rcode == dns.RcodeSuccess {
-				if len(resp.Answer) == 0 {
-					break loop
-				}
-				return ExtractAnswers(resp)
+				answers = ExtractAnswers(resp)
+				return true
 			}
 		}
-	}
-	return nil
+		return false
+	})
+	return answers
 }
 
-func intersectRecordData(set *stringset.Set, ans []*ExtractedAnswer) {
-	records := stringset.New()
-	defer records.Close()
+// recordSet holds the record data compared during wildcard detection. A map is used instead
+// of a stringset, keeping the dependencies of the package small for the embedding applications.
+type recordSet map[string]struct{}
 
+func (s recordSet) has(data string) bool {
+	_, found := s[data]
+	return found
+}
+
+func intersectRecordData(set recordSet, ans []*ExtractedAnswer) {
+	records := make(recordSet)
 	for _, a := range ans {
-		records.Insert(strings.Trim(a.Data, "."))
+		records[strings.Trim(a.Data, ".")] = struct{}{}
 	}
-	set.Intersect(records)
-}
 
-func insertRecordData(set *stringset.Set, ans []*ExtractedAnswer) {
-	records := stringset.New()
-	defer records.Close()
+	for data := range set {
+		if !records.has(data) {
+			delete(set, data)
+		}
+	}
+}
 
+func insertRecordData(set recordSet, ans []*ExtractedAnswer) {
 	for _, a := range ans {
-		records.Insert(strings.Trim(a.Data, "."))
+		set[strings.Trim(a.Data, ".")] = struct{}{}
 	}
-	set.Union(records)
 }
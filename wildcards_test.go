@@ -8,6 +8,8 @@ import (
 	"context"
 	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/miekg/dns"
@@ -18,7 +20,7 @@ func TestSetDetectionResolver(t *testing.T) {
 	defer r.Stop()
 
 	r.SetDetectionResolver(10, "8.8.8.8")
-	if r.detector == nil {
+	if r.getDetectionResolver() == nil {
 		t.Errorf("failed to add the wildcard detector")
 	}
 }
@@ -72,6 +74,113 @@ func TestWildcardDetected(t *testing.T) {
 	}
 }
 
+func TestWildcardLevels(t *testing.T) {
+	name := "domain.com."
+	dns.HandleFunc(name, wildcardHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("Unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	if levels := r.WildcardLevels(context.Background(), "domain.com"); len(levels) != 0 {
+		t.Errorf("Expected no levels before detection, got %v", levels)
+	}
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("jeff_foley.wildcard.domain.com", 1))
+	if err != nil {
+		t.Fatalf("The query failed %v", err)
+	}
+	_ = r.WildcardDetected(context.Background(), resp, "domain.com")
+
+	levels := r.WildcardLevels(context.Background(), "domain.com")
+	if detected, found := levels["domain.com"]; !found || detected {
+		t.Errorf("Expected the registered domain to be tested without a wildcard, got %v", levels)
+	}
+	if detected, found := levels["wildcard.domain.com"]; !found || !detected {
+		t.Errorf("Expected a wildcard at wildcard.domain.com, got %v", levels)
+	}
+	if levels := r.WildcardLevels(context.Background(), "owasp.org"); len(levels) != 0 {
+		t.Errorf("Expected no levels for another domain, got %v", levels)
+	}
+}
+
+func TestConcurrentWildcardDetection(t *testing.T) {
+	name := "domain.com."
+	dns.HandleFunc(name, wildcardHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("Unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("jeff_foley.wildcard.domain.com", 1))
+	if err != nil {
+		t.Fatalf("The query failed %v", err)
+	}
+
+	num := 50
+	var wg sync.WaitGroup
+	var missed int32
+	for i := 0; i < num; i++ {
+		wg.Add(1)
+		go func(resp *dns.Msg) {
+			defer wg.Done()
+
+			if !r.WildcardDetected(context.Background(), resp, "domain.com") {
+				atomic.AddInt32(&missed, 1)
+			}
+		}(resp.Copy())
+	}
+	wg.Wait()
+
+	if missed > 0 {
+		t.Errorf("%d of the %d concurrent callers did not detect the wildcard", missed, num)
+	}
+}
+
+func TestWildcardDetectionCancelled(t *testing.T) {
+	name := "domain.com."
+	dns.HandleFunc(name, wildcardHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("Unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("jeff_foley.wildcard.domain.com", 1))
+	if err != nil {
+		t.Fatalf("The query failed %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r.WildcardDetected(ctx, resp, "domain.com") {
+		t.Errorf("The wildcard was detected with a cancelled context")
+	}
+	if !r.WildcardDetected(context.Background(), resp, "domain.com") {
+		t.Errorf("The results of the cancelled detection were cached")
+	}
+}
+
 func wildcardHandler(w dns.ResponseWriter, req *dns.Msg) {
 	m := new(dns.Msg)
 	m.SetReply(req)
@@ -103,3 +212,134 @@ func wildcardHandler(w dns.ResponseWriter, req *dns.Msg) {
 	}
 	_ = w.WriteMsg(m)
 }
+
+func TestSetDetectionResolvers(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	r.SetDetectionResolvers(10, 2, "8.8.8.8", "1.1.1.1", "300.300.300.300")
+	if l := len(r.getDetectionResolvers()); l != 2 {
+		t.Errorf("expected 2 wildcard detectors, got %d", l)
+	}
+
+	cases := []struct {
+		quorum int
+		n      int
+		want   int
+	}{
+		{quorum: 0, n: 1, want: 1},
+		{quorum: 0, n: 3, want: 2},
+		{quorum: 0, n: 4, want: 3},
+		{quorum: 2, n: 3, want: 2},
+		{quorum: 5, n: 3, want: 3},
+	}
+
+	for _, c := range cases {
+		r.SetDetectionResolvers(10, c.quorum, "8.8.8.8")
+		if got := r.detectionQuorum(c.n); got != c.want {
+			t.Errorf("quorum %d of %d detectors returned %d instead of the expected %d", c.quorum, c.n, got, c.want)
+		}
+	}
+}
+
+func TestDetectionQuorum(t *testing.T) {
+	// Only the first detection resolver observes the wildcard
+	var addrs []string
+	for i, handler := range []dns.HandlerFunc{wildcardHandler, func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetRcode(req, dns.RcodeNameError)
+		_ = w.WriteMsg(m)
+	}} {
+		mux := dns.NewServeMux()
+		mux.HandleFunc("domain.com.", handler)
+
+		s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) { s.Handler = mux })
+		if err != nil {
+			t.Fatalf("unable to run test server %d: %v", i, err)
+		}
+		defer func() { _ = s.Shutdown() }()
+		addrs = append(addrs, addrstr)
+	}
+
+	cases := []struct {
+		quorum int
+		want   bool
+	}{
+		{quorum: 1, want: true},
+		{quorum: 2, want: false},
+	}
+
+	for _, c := range cases {
+		r := NewResolvers()
+		_ = r.AddResolvers(100, addrs[0])
+		r.SetDetectionResolvers(100, c.quorum, addrs...)
+
+		resp, err := r.QueryBlocking(context.Background(), QueryMsg("jeff_foley.wildcard.domain.com", 1))
+		if err != nil {
+			t.Fatalf("The query failed %v", err)
+		}
+		if got := r.WildcardDetected(context.Background(), resp, "domain.com"); got != c.want {
+			t.Errorf("a quorum of %d returned %t instead of the expected %t", c.quorum, got, c.want)
+		}
+		r.Stop()
+	}
+}
+
+func TestSetDetectionOptions(t *testing.T) {
+	name := "domain.com."
+	dns.HandleFunc(name, wildcardHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("Unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	r.SetDetectionOptions(50, true)
+	if r.detectionRate() == nil {
+		t.Errorf("failed to create the wildcard detection rate limiter")
+	}
+	if r.detectionConns() == nil {
+		t.Errorf("failed to create the dedicated wildcard detection sockets")
+	}
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("jeff_foley.wildcard.domain.com", 1))
+	if err != nil {
+		t.Fatalf("The query failed %v", err)
+	}
+	if !r.WildcardDetected(context.Background(), resp, "domain.com") {
+		t.Errorf("failed to detect the wildcard using the dedicated sockets")
+	}
+
+	r.SetDetectionOptions(0, false)
+	if r.detectionRate() != nil || r.detectionConns() != nil {
+		t.Errorf("failed to remove the wildcard detection options")
+	}
+}
+
+func TestRecordData(t *testing.T) {
+	set := make(recordSet)
+
+	insertRecordData(set, []*ExtractedAnswer{
+		{Data: "192.168.1.1"},
+		{Data: "www.owasp.org."},
+		{Data: "192.168.1.2"},
+	})
+	if len(set) != 3 || !set.has("www.owasp.org") {
+		t.Errorf("the record data was not inserted: %v", set)
+	}
+
+	intersectRecordData(set, []*ExtractedAnswer{
+		{Data: "www.owasp.org"},
+		{Data: "192.168.1.2"},
+		{Data: "192.168.1.3"},
+	})
+	if len(set) != 2 || !set.has("www.owasp.org") || !set.has("192.168.1.2") {
+		t.Errorf("the record data was not intersected: %v", set)
+	}
+}
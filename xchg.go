@@ -5,6 +5,7 @@
 package resolve
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync"
@@ -30,6 +31,20 @@ type request struct {
 	Timestamp time.Time
 	Msg, Resp *dns.Msg
 	Result    chan *dns.Msg
+	trace     *requestTrace
+	probe     bool
+	badCookie bool
+	// slot is true while the request holds one of the slots limiting the requests in flight
+	slot bool
+	ctx  context.Context
+}
+
+// Returns the context of the query, or the background context when the request has none.
+func (r *request) context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
 }
 
 func (r *request) errNoResponse() {
@@ -45,16 +60,22 @@ func (r *request) release() {
 }
 
 // The xchgMgr handles DNS message IDs and identifying messages that have timed out.
+// The requests holding a slot are counted by inflight, which is limited by max when it is
+// greater than zero. The freed channel is closed when a slot may have become available.
 type xchgMgr struct {
 	sync.Mutex
-	timeout time.Duration
-	xchgs   map[string]*request
+	timeout  time.Duration
+	xchgs    map[string]*request
+	max      int
+	inflight int
+	freed    chan struct{}
 }
 
 func newXchgMgr(d time.Duration) *xchgMgr {
 	return &xchgMgr{
 		timeout: d,
 		xchgs:   make(map[string]*request),
+		freed:   make(chan struct{}),
 	}
 }
 
@@ -69,12 +90,82 @@ func (r *xchgMgr) setTimeout(d time.Duration) {
 	r.timeout = d
 }
 
+// Changes the maximum number of requests in flight. The requests already holding a slot keep
+// it, so a lower maximum is enforced as those requests complete.
+func (r *xchgMgr) setMaxInFlight(max int) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.max = max
+	r.wake()
+}
+
+// Blocks until the number of requests in flight is below the maximum, unless the request
+// already holds a slot. Returns false when the context expires or the done channel is closed
+// before a slot was acquired.
+func (r *xchgMgr) acquire(ctx context.Context, req *request, done chan struct{}) bool {
+	for {
+		r.Lock()
+		if req.slot || r.max <= 0 || r.inflight < r.max {
+			if !req.slot {
+				req.slot = true
+				r.inflight++
+			}
+			r.Unlock()
+			return true
+		}
+		freed := r.freed
+		r.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-done:
+			return false
+		case <-freed:
+		}
+	}
+}
+
+// Makes the slot held by a request no longer in flight available. Must be called holding the lock.
+func (r *xchgMgr) releaseSlot(req *request) {
+	if !req.slot {
+		return
+	}
+
+	req.slot = false
+	r.inflight--
+	r.wake()
+}
+
+// Signals the requests waiting in acquire to check for an available slot. Must be called holding the lock.
+func (r *xchgMgr) wake() {
+	close(r.freed)
+	r.freed = make(chan struct{})
+}
+
+// Makes the slot held by a request that will not be written available.
+func (r *xchgMgr) abandon(req *request) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.releaseSlot(req)
+}
+
+func (r *xchgMgr) len() int {
+	r.Lock()
+	defer r.Unlock()
+
+	return len(r.xchgs)
+}
+
 func (r *xchgMgr) add(req *request) error {
 	r.Lock()
 	defer r.Unlock()
 
 	key := xchgKey(req.Msg.Id, req.Msg.Question[0].Name)
 	if _, found := r.xchgs[key]; found {
+		r.releaseSlot(req)
 		return fmt.Errorf("key %s is already in use", key)
 	}
 	r.xchgs[key] = req
@@ -132,9 +223,11 @@ func (r *xchgMgr) delete(keys []string) []*request {
 	var removed []*request
 
 	for _, k := range keys {
-		removed = append(removed, r.xchgs[k])
+		req := r.xchgs[k]
+		removed = append(removed, req)
 		r.xchgs[k] = nil
 		delete(r.xchgs, k)
+		r.releaseSlot(req)
 	}
 	return removed
 }
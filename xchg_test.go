@@ -5,11 +5,11 @@
 package resolve
 
 import (
+	"context"
 	"strings"
 	"testing"
 	"time"
 
-	"github.com/caffix/stringset"
 	"github.com/miekg/dns"
 )
 
@@ -87,15 +87,17 @@ func TestXchgRemoveExpired(t *testing.T) {
 	}
 
 	time.Sleep(1500 * time.Millisecond)
-	set := stringset.New(names...)
-	defer set.Close()
+	set := make(map[string]struct{})
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
 
 	for _, req := range xchg.removeExpired() {
 		name := strings.ToLower(RemoveLastDot(req.Msg.Question[0].Name))
 
-		set.Remove(name)
+		delete(set, name)
 	}
-	if set.Len() > 0 {
+	if len(set) > 0 {
 		t.Errorf("Not all expected requests were returned by removeExpired")
 	}
 }
@@ -111,15 +113,103 @@ func TestXchgRemoveAll(t *testing.T) {
 		}
 	}
 
-	set := stringset.New(names...)
-	defer set.Close()
+	set := make(map[string]struct{})
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
 
 	for _, req := range xchg.removeAll() {
 		name := strings.ToLower(RemoveLastDot(req.Msg.Question[0].Name))
 
-		set.Remove(name)
+		delete(set, name)
 	}
-	if set.Len() > 0 {
+	if len(set) > 0 {
 		t.Errorf("Not all expected requests were returned by removeAll")
 	}
 }
+
+func TestXchgMaxInFlight(t *testing.T) {
+	mgr := newXchgMgr(DefaultTimeout)
+	mgr.setMaxInFlight(1)
+	done := make(chan struct{})
+
+	msg := QueryMsg("caffix.net", dns.TypeA)
+	req := &request{Msg: msg}
+	if !mgr.acquire(context.Background(), req, done) {
+		t.Fatalf("failed to acquire the available slot")
+	}
+	if err := mgr.add(req); err != nil {
+		t.Fatalf("failed to add the request: %v", err)
+	}
+	// The request holding a slot does not wait for another slot when it is sent again
+	if !mgr.acquire(context.Background(), req, done) {
+		t.Errorf("the request holding a slot failed to acquire a slot")
+	}
+
+	acquired := make(chan bool, 1)
+	go func() { acquired <- mgr.acquire(context.Background(), new(request), done) }()
+	select {
+	case <-acquired:
+		t.Fatalf("a slot was acquired beyond the maximum in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_ = mgr.remove(msg.Id, msg.Question[0].Name)
+	if !<-acquired {
+		t.Errorf("the slot was not made available after the request was removed")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if mgr.acquire(ctx, new(request), done) {
+		t.Errorf("a slot was acquired after the context expired")
+	}
+
+	close(done)
+	if mgr.acquire(context.Background(), new(request), done) {
+		t.Errorf("a slot was acquired after the done channel was closed")
+	}
+}
+
+func TestXchgResizeMaxInFlight(t *testing.T) {
+	mgr := newXchgMgr(DefaultTimeout)
+	mgr.setMaxInFlight(1)
+	done := make(chan struct{})
+	defer close(done)
+
+	first := &request{Msg: QueryMsg("caffix.net", dns.TypeA)}
+	if !mgr.acquire(context.Background(), first, done) {
+		t.Fatalf("failed to acquire the available slot")
+	}
+	_ = mgr.add(first)
+
+	// The request already in flight is still counted after the maximum is raised
+	mgr.setMaxInFlight(2)
+	second := &request{Msg: QueryMsg("owasp.org", dns.TypeA)}
+	if !mgr.acquire(context.Background(), second, done) {
+		t.Fatalf("failed to acquire the slot made available by the larger maximum")
+	}
+	_ = mgr.add(second)
+
+	acquired := make(chan bool, 1)
+	go func() { acquired <- mgr.acquire(context.Background(), new(request), done) }()
+	select {
+	case <-acquired:
+		t.Fatalf("a slot was acquired beyond the maximum in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Lowering the maximum keeps the waiting request blocked until the requests in flight drop below it
+	mgr.setMaxInFlight(1)
+	_ = mgr.remove(first.Msg.Id, first.Msg.Question[0].Name)
+	select {
+	case <-acquired:
+		t.Fatalf("a slot was acquired beyond the lowered maximum in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_ = mgr.remove(second.Msg.Id, second.Msg.Question[0].Name)
+	if !<-acquired {
+		t.Errorf("the slot was not made available after the requests were removed")
+	}
+}